@@ -1,5 +1,5 @@
 import http from './http'
-import type { User, LoginRequest, RegisterRequest, AuthResponse } from '@/types'
+import type { User, LoginRequest, RegisterRequest, AuthResponse, AuthTokens } from '@/types'
 
 export const authApi = {
     // 登录
@@ -22,8 +22,8 @@ export const authApi = {
         return http.post('/auth/logout')
     },
 
-    // 刷新token
-    refreshToken: (): Promise<{ token: string }> => {
-        return http.post('/auth/refresh')
+    // 刷新token：刷新令牌只能使用一次，需保存返回的新刷新令牌
+    refreshToken: (refreshToken: string): Promise<AuthTokens> => {
+        return http.post('/auth/refresh', { refresh_token: refreshToken })
     }
 }
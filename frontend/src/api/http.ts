@@ -1,6 +1,6 @@
 import axios, { type AxiosResponse, type InternalAxiosRequestConfig } from 'axios'
 import { ElMessage } from 'element-plus'
-import type { ApiResponse } from '@/types'
+import type { ApiResponse, AuthTokens } from '@/types'
 
 // 创建axios实例
 const http = axios.create({
@@ -11,6 +11,29 @@ const http = axios.create({
     }
 })
 
+// 进行中的刷新请求，多个请求同时过期时只刷新一次
+let refreshing: Promise<string> | null = null
+
+// 用刷新令牌换取新的访问令牌；直接使用 axios，避免经过下面的拦截器
+const refreshAccessToken = (): Promise<string> => {
+    if (!refreshing) {
+        const refreshToken = localStorage.getItem('refresh_token')
+        refreshing = (refreshToken
+            ? axios.post<ApiResponse<AuthTokens>>('/api/v1/auth/refresh', { refresh_token: refreshToken })
+                .then(({ data }) => {
+                    const tokens = data.data as AuthTokens
+                    localStorage.setItem('token', tokens.token)
+                    localStorage.setItem('refresh_token', tokens.refresh_token)
+                    return tokens.token
+                })
+            : Promise.reject(new Error('没有刷新令牌'))
+        ).finally(() => {
+            refreshing = null
+        })
+    }
+    return refreshing
+}
+
 // 请求拦截器
 http.interceptors.request.use(
     (config: InternalAxiosRequestConfig) => {
@@ -31,7 +54,22 @@ http.interceptors.response.use(
     (response: AxiosResponse<ApiResponse<any>>) => {
         return response.data.data
     },
-    (error) => {
+    async (error) => {
+        const config = error.config as (InternalAxiosRequestConfig & { _retried?: boolean }) | undefined
+
+        // 访问令牌过期：刷新后重试一次原请求，登录和刷新接口本身除外
+        if (error.response?.status === 401 && config && !config._retried &&
+            !config.url?.startsWith('/auth/login') && !config.url?.startsWith('/auth/refresh')) {
+            config._retried = true
+            try {
+                const token = await refreshAccessToken()
+                config.headers.Authorization = `Bearer ${token}`
+                return http(config)
+            } catch {
+                // 刷新失败，按未授权处理
+            }
+        }
+
         if (error.response) {
             const { status, data } = error.response
 
@@ -39,6 +77,7 @@ http.interceptors.response.use(
                 case 401:
                     // 未授权，清除token并跳转到登录页
                     localStorage.removeItem('token')
+                    localStorage.removeItem('refresh_token')
                     window.location.href = '/login'
                     ElMessage.error('登录已过期，请重新登录')
                     break
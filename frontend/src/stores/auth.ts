@@ -41,6 +41,7 @@ export const useAuthStore = defineStore('auth', () => {
             user.value = response.user
 
             localStorage.setItem('token', response.token)
+            localStorage.setItem('refresh_token', response.refresh_token)
             ElMessage.success('登录成功')
 
             return response
@@ -85,6 +86,7 @@ export const useAuthStore = defineStore('auth', () => {
         user.value = null
         token.value = null
         localStorage.removeItem('token')
+        localStorage.removeItem('refresh_token')
     }
 
     // 初始化
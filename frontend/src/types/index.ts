@@ -12,7 +12,7 @@ export type ItemStatus = 'pending' | 'in_progress' | 'completed' | 'cancelled'
 
 export type Priority = 'low' | 'normal' | 'medium' | 'high'
 
-export type RelationType = 'depends_on' | 'blocks' | 'related_to' | 'follows'
+export type RelationType = 'depends_on' | 'blocks' | 'related_to' | 'follows' | 'must_precede'
 
 // 计划相关类型
 export interface Plan {
@@ -153,13 +153,18 @@ export interface RegisterRequest {
     full_name?: string
 }
 
-export interface AuthResponse {
-    user: User
-    access_token: string
+// 登录和刷新返回的令牌，expires_in 为访问令牌剩余秒数
+export interface AuthTokens {
+    token: string
     refresh_token: string
+    token_type: string
     expires_in: number
 }
 
+export interface AuthResponse extends AuthTokens {
+    user: User
+}
+
 // API响应类型
 export interface ApiResponse<T = any> {
     success: boolean
@@ -13,6 +13,7 @@ require (
 	github.com/redis/go-redis/v9 v9.12.0
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.41.0
+	golang.org/x/image v0.30.0
 )
 
 require (
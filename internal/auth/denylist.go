@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"planner/internal/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Denylist 访问令牌黑名单，令牌过期后记录可以丢弃
+type Denylist interface {
+	// Add 将令牌ID加入黑名单直到 expiresAt
+	Add(ctx context.Context, jti string, expiresAt time.Time) error
+	// Contains 令牌ID是否在黑名单中
+	Contains(ctx context.Context, jti string) (bool, error)
+}
+
+var current Denylist
+
+// GetDenylist 获取当前黑名单：配置了Redis时使用Redis，否则使用数据库
+func GetDenylist() Denylist {
+	if current != nil {
+		return current
+	}
+	if client := database.GetRedis(); client != nil {
+		return &redisDenylist{client: client}
+	}
+	return &postgresDenylist{db: database.GetDB()}
+}
+
+// SetDenylist 替换当前黑名单（用于测试），传入 nil 恢复默认
+func SetDenylist(d Denylist) {
+	current = d
+}
+
+// RevokeAccessToken 使访问令牌立即失效
+func RevokeAccessToken(ctx context.Context, claims *AccessClaims) error {
+	expiresAt := time.Now().Add(AccessTokenTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return GetDenylist().Add(ctx, claims.ID, expiresAt)
+}
+
+const redisDenylistPrefix = "revoked_token:"
+
+type redisDenylist struct {
+	client *redis.Client
+}
+
+func (d *redisDenylist) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return d.client.Set(ctx, redisDenylistPrefix+jti, 1, ttl).Err()
+}
+
+func (d *redisDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	n, err := d.client.Exists(ctx, redisDenylistPrefix+jti).Result()
+	return n > 0, err
+}
+
+type postgresDenylist struct {
+	db *sql.DB
+}
+
+func (d *postgresDenylist) Add(ctx context.Context, jti string, expiresAt time.Time) error {
+	if _, err := d.db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt); err != nil {
+		return err
+	}
+	// 顺便清理已过期的记录
+	_, err := d.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at < NOW()")
+	return err
+}
+
+func (d *postgresDenylist) Contains(ctx context.Context, jti string) (bool, error) {
+	var exists bool
+	err := d.db.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1 AND expires_at > NOW())", jti).Scan(&exists)
+	return exists, err
+}
+
+// MemoryDenylist 进程内黑名单，用于测试
+type MemoryDenylist struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+}
+
+// NewMemoryDenylist 创建进程内黑名单
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: map[string]time.Time{}}
+}
+
+func (d *MemoryDenylist) Add(_ context.Context, jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[jti] = expiresAt
+	return nil
+}
+
+func (d *MemoryDenylist) Contains(_ context.Context, jti string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	expiresAt, ok := d.entries[jti]
+	return ok && time.Now().Before(expiresAt), nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"planner/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// ErrMissingClaims 令牌缺少用户、会话或令牌ID，通常是旧版本签发的令牌
+var ErrMissingClaims = errors.New("令牌缺少必要信息")
+
+// AccessClaims 访问令牌声明。Version 与 users.token_version 不一致、或 SessionID 对应的会话被撤销时令牌失效
+type AccessClaims struct {
+	UserID    string `json:"user_id"`
+	Username  string `json:"username"`
+	Email     string `json:"email"`
+	Version   int    `json:"ver"`
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// AccessTokenTTL 访问令牌有效期
+func AccessTokenTTL() time.Duration {
+	return time.Duration(config.Get().AccessTokenTTLMinutes) * time.Minute
+}
+
+// RefreshTokenTTL 刷新令牌有效期
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(config.Get().RefreshTokenTTLHours) * time.Hour
+}
+
+// IssueAccessToken 为登录会话签发短期访问令牌，每个令牌有唯一的 jti 以便加入黑名单
+func IssueAccessToken(userID, username, email string, version int, sessionID string) (string, *AccessClaims, error) {
+	now := time.Now()
+	claims := &AccessClaims{
+		UserID:    userID,
+		Username:  username,
+		Email:     email,
+		Version:   version,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL())),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Get().JWTSecret))
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ParseAccessToken 校验签名和有效期并返回声明
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		// 验证签名方法
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("意外的签名方法: %v", token.Header["alg"])
+		}
+		return []byte(config.Get().JWTSecret), nil
+	}, jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, err
+	}
+	if claims.UserID == "" || claims.SessionID == "" || claims.ID == "" {
+		return nil, ErrMissingClaims
+	}
+	return claims, nil
+}
+
+// NewOpaqueToken 生成不透明的随机令牌（刷新令牌、密码重置和邮箱验证令牌），返回明文（只发给客户端）和存储用的哈希
+func NewOpaqueToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken 不透明令牌的 SHA-256 哈希。令牌本身是高熵随机数，不需要加盐
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
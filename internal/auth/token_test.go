@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"planner/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueAndParseAccessToken(t *testing.T) {
+	config.Load()
+
+	token, issued, err := IssueAccessToken("user-1", "aming", "aming@example.com", 3, "session-1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, issued.ID)
+	assert.WithinDuration(t, time.Now().Add(AccessTokenTTL()), issued.ExpiresAt.Time, 2*time.Second)
+
+	claims, err := ParseAccessToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", claims.UserID)
+	assert.Equal(t, "aming", claims.Username)
+	assert.Equal(t, 3, claims.Version)
+	assert.Equal(t, "session-1", claims.SessionID)
+	assert.Equal(t, issued.ID, claims.ID)
+
+	// 篡改签名中间的一个字符（末尾字符只有部分比特有效，改了可能不影响解码结果）
+	i := len(token) - 10
+	tampered := byte('A')
+	if token[i] == 'A' {
+		tampered = 'B'
+	}
+	_, err = ParseAccessToken(token[:i] + string(tampered) + token[i+1:])
+	assert.Error(t, err)
+}
+
+func TestParseAccessTokenRejectsLegacyTokens(t *testing.T) {
+	config.Load()
+
+	// 旧版本签发的令牌没有 jti 或会话ID，无法撤销，必须重新登录
+	for _, claims := range []jwt.MapClaims{
+		{"user_id": "user-1", "sid": "session-1"},
+		{"user_id": "user-1", "jti": "abc"},
+	} {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+		legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(config.Get().JWTSecret))
+		require.NoError(t, err)
+		_, err = ParseAccessToken(legacy)
+		assert.ErrorIs(t, err, ErrMissingClaims)
+	}
+
+	noExpiry, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "user-1", "sid": "session-1", "jti": "abc",
+	}).SignedString([]byte(config.Get().JWTSecret))
+	require.NoError(t, err)
+	_, err = ParseAccessToken(noExpiry)
+	assert.Error(t, err)
+}
+
+func TestNewOpaqueToken(t *testing.T) {
+	first, hash, err := NewOpaqueToken()
+	require.NoError(t, err)
+	second, _, err := NewOpaqueToken()
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first, second)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashOpaqueToken(first))
+	assert.NotEqual(t, first, hash)
+}
+
+func TestMemoryDenylist(t *testing.T) {
+	d := NewMemoryDenylist()
+	ctx := context.Background()
+
+	require.NoError(t, d.Add(ctx, "live", time.Now().Add(time.Minute)))
+	require.NoError(t, d.Add(ctx, "expired", time.Now().Add(-time.Minute)))
+
+	revoked, _ := d.Contains(ctx, "live")
+	assert.True(t, revoked)
+	revoked, _ = d.Contains(ctx, "expired")
+	assert.False(t, revoked)
+	revoked, _ = d.Contains(ctx, "unknown")
+	assert.False(t, revoked)
+}
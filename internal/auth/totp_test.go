@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"planner/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfc6238Secret RFC 6238 附录B 的 SHA1 测试密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// 附录B 给出的是8位验证码，6位验证码为其后6位
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range vectors {
+		code, err := TOTPCode(rfc6238Secret, time.Unix(unix, 0))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := TOTPCode(rfc6238Secret, now)
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	require.True(t, ok)
+	assert.Equal(t, int64(1234567890/30), step)
+
+	// 允许前后一个时间步的时钟误差
+	_, ok = ValidateTOTP(rfc6238Secret, code, now.Add(30*time.Second), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTP(rfc6238Secret, code, now.Add(90*time.Second), 0)
+	assert.False(t, ok)
+
+	// 同一时间步的验证码不能重复使用
+	_, ok = ValidateTOTP(rfc6238Secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTP(rfc6238Secret, "12345", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	require.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	u, err := url.Parse(TOTPProvisioningURI("旅行计划", "aming", secret))
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/旅行计划:aming", u.Path)
+	assert.Equal(t, secret, u.Query().Get("secret"))
+	assert.Equal(t, "旅行计划", u.Query().Get("issuer"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	format := regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, format, code)
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+
+	assert.Equal(t, "abcde-fghij", NormalizeRecoveryCode("ABCDE FGHIJ"))
+	assert.Equal(t, "abcde-fghij", NormalizeRecoveryCode("abcdefghij"))
+}
+
+func TestSealSecret(t *testing.T) {
+	config.Load()
+
+	sealed, err := SealSecret(rfc6238Secret)
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, rfc6238Secret)
+
+	opened, err := OpenSecret(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, rfc6238Secret, opened)
+
+	_, err = OpenSecret(sealed[:len(sealed)-4] + "AAAA")
+	assert.Error(t, err)
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -20,8 +22,79 @@ type Config struct {
 	// JWT配置
 	JWTSecret string
 
+	// 访问令牌有效期（分钟）和刷新令牌有效期（小时）
+	AccessTokenTTLMinutes int64
+	RefreshTokenTTLHours  int64
+
 	// CORS配置
 	CORSOrigin string
+
+	// 汇率文件（可选，启动时导入）
+	ExchangeRatesFile string
+
+	// 文件上传配置
+	MaxFileSize   int64
+	UploadPath    string
+	StorageDriver string
+
+	// S3兼容存储配置（STORAGE_DRIVER=s3 时使用）
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3UsePathStyle bool
+	S3PublicURL    string
+
+	// 行程重新分配时每天默认的活动小时数
+	DailyActiveHours float64
+
+	// 计划未指定时区时使用的默认时区
+	DefaultTimeZone string
+
+	// 高海拔适应：超过该海拔后限制每晚睡眠海拔的上升高度，累计上升达到休整间隔时建议休整一天
+	AltitudeThresholdM int64
+	MaxNightlyAscentM  int64
+	RestDayAscentM     int64
+
+	// PDF行程手册中的紧急电话，格式为 名称:号码，逗号分隔
+	EmergencyContacts string
+
+	// 邮件配置：驱动为 smtp、file 或 log
+	MailDriver   string
+	MailFilePath string
+	SMTPHost     string
+	SMTPPort     int64
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// 邮件中链接指向的前端地址
+	AppBaseURL string
+
+	// 邮箱验证和密码重置链接有效期
+	EmailVerificationTTLHours int64
+	PasswordResetTTLMinutes   int64
+
+	// 未验证邮箱的用户不能创建计划
+	RequireEmailVerification bool
+
+	// 两步验证在验证器App中显示的发行方名称
+	TOTPIssuer string
+
+	// 单点登录身份提供方（OpenID Connect）
+	OIDCProviders []OIDCProvider
+}
+
+// OIDCProvider 一个 OpenID Connect 身份提供方的配置
+type OIDCProvider struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
 }
 
 var globalConfig *Config
@@ -40,7 +113,50 @@ func Load() *Config {
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
 		CORSOrigin:  getEnv("CORS_ORIGIN", "*"),
+
+		AccessTokenTTLMinutes: getEnvInt64("ACCESS_TOKEN_TTL_MINUTES", 15),
+		RefreshTokenTTLHours:  getEnvInt64("REFRESH_TOKEN_TTL_HOURS", 720),
+
+		ExchangeRatesFile: getEnv("EXCHANGE_RATES_FILE", ""),
+
+		MaxFileSize:   getEnvInt64("MAX_FILE_SIZE", 5*1024*1024),
+		UploadPath:    getEnv("UPLOAD_PATH", "./uploads"),
+		StorageDriver: getEnv("STORAGE_DRIVER", "local"),
+
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Region:       getEnv("S3_REGION", "us-east-1"),
+		S3Bucket:       getEnv("S3_BUCKET", ""),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+		S3UsePathStyle: getEnvBool("S3_USE_PATH_STYLE", true),
+		S3PublicURL:    getEnv("S3_PUBLIC_URL", ""),
+
+		DailyActiveHours: getEnvFloat("DAILY_ACTIVE_HOURS", 10),
+		DefaultTimeZone:  getEnv("DEFAULT_TIME_ZONE", "Asia/Shanghai"),
+
+		AltitudeThresholdM: getEnvInt64("ALTITUDE_THRESHOLD_M", 3000),
+		MaxNightlyAscentM:  getEnvInt64("MAX_NIGHTLY_ASCENT_M", 500),
+		RestDayAscentM:     getEnvInt64("REST_DAY_ASCENT_M", 1000),
+
+		EmergencyContacts: getEnv("EMERGENCY_CONTACTS", "报警:110,急救:120,火警:119,交通事故:122"),
+
+		MailDriver:   getEnv("MAIL_DRIVER", "log"),
+		MailFilePath: getEnv("MAIL_FILE_PATH", "./mails"),
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt64("SMTP_PORT", 587),
+		SMTPUser:     getEnv("SMTP_USER", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		AppBaseURL: getEnv("APP_BASE_URL", "http://localhost:3000"),
+
+		EmailVerificationTTLHours: getEnvInt64("EMAIL_VERIFICATION_TTL_HOURS", 48),
+		PasswordResetTTLMinutes:   getEnvInt64("PASSWORD_RESET_TTL_MINUTES", 60),
+		RequireEmailVerification:  getEnvBool("REQUIRE_EMAIL_VERIFICATION", false),
+
+		TOTPIssuer: getEnv("TOTP_ISSUER", "Planner"),
 	}
+	globalConfig.OIDCProviders = loadOIDCProviders(globalConfig.AppBaseURL)
 
 	return globalConfig
 }
@@ -53,6 +169,33 @@ func Get() *Config {
 	return globalConfig
 }
 
+// loadOIDCProviders 读取 OIDC_PROVIDERS 中列出的身份提供方，
+// 每个提供方的配置项以 OIDC_<名称大写>_ 为前缀，回调地址默认为 APP_BASE_URL 下的 API 地址
+func loadOIDCProviders(appBaseURL string) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		p := OIDCProvider{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL: getEnv(prefix+"REDIRECT_URL",
+				strings.TrimRight(appBaseURL, "/")+"/api/v1/auth/oidc/"+name+"/callback"),
+		}
+		if scopes := getEnv(prefix+"SCOPES", ""); scopes != "" {
+			p.Scopes = strings.Fields(strings.ReplaceAll(scopes, ",", " "))
+		}
+		providers = append(providers, p)
+	}
+	return providers
+}
+
 // getEnv 获取环境变量，带默认值
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -60,3 +203,36 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvInt64 获取整数环境变量，无法解析时使用默认值
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+		log.Printf("环境变量 %s 不是有效的整数，使用默认值", key)
+	}
+	return defaultValue
+}
+
+// getEnvFloat 获取浮点数环境变量，无法解析时使用默认值
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+		log.Printf("环境变量 %s 不是有效的数字，使用默认值", key)
+	}
+	return defaultValue
+}
+
+// getEnvBool 获取布尔环境变量，无法解析时使用默认值
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+		log.Printf("环境变量 %s 不是有效的布尔值，使用默认值", key)
+	}
+	return defaultValue
+}
@@ -87,6 +87,15 @@ func GetRedis() *redis.Client {
 	return redisClient
 }
 
+// GetSetting 读取系统设置，设置不存在时 ok 为 false
+func GetSetting(key string) (value string, ok bool, err error) {
+	err = db.QueryRow("SELECT value FROM system_settings WHERE key = $1", key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return value, err == nil, err
+}
+
 // Close 关闭数据库连接
 func Close() {
 	if db != nil {
@@ -311,6 +320,8 @@ func initSchema() error {
 			facilities JSONB,
 			accessibility_info TEXT
 		)`,
+		// 日出日落时间是否根据坐标和日期自动计算，自动计算的值在坐标或日期变化时更新
+		`ALTER TABLE attraction_details ADD COLUMN IF NOT EXISTS sun_times_auto BOOLEAN NOT NULL DEFAULT false`,
 
 		// 元素关联表
 		`CREATE TABLE IF NOT EXISTS item_relations (
@@ -340,6 +351,29 @@ func initSchema() error {
 			uploaded_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 
+		// 附件存储信息
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS storage_key TEXT`,
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS checksum VARCHAR(64)`,
+
+		// 图片附件处理结果：缩略图、EXIF拍摄时间和GPS坐标
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS thumbnails JSONB`,
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS processing_status VARCHAR(20)`,
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS taken_at TIMESTAMPTZ`,
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS gps_latitude DECIMAL(10,6)`,
+		`ALTER TABLE item_attachments ADD COLUMN IF NOT EXISTS gps_longitude DECIMAL(10,6)`,
+
+		// 元素状态变更历史
+		`CREATE TABLE IF NOT EXISTS item_status_history (
+			id VARCHAR(36) PRIMARY KEY,
+			item_id VARCHAR(36) NOT NULL REFERENCES travel_items(id) ON DELETE CASCADE,
+			field VARCHAR(20) NOT NULL,
+			from_status VARCHAR(20),
+			to_status VARCHAR(20) NOT NULL,
+			note TEXT,
+			changed_by VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+
 		// 标注表
 		`CREATE TABLE IF NOT EXISTS item_annotations (
 			id VARCHAR(36) PRIMARY KEY,
@@ -372,6 +406,156 @@ func initSchema() error {
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 
+		// 计划基准货币
+		`ALTER TABLE plans ADD COLUMN IF NOT EXISTS base_currency VARCHAR(10) NOT NULL DEFAULT 'CNY'`,
+
+		// 时区：计划默认时区，元素和交通两端可单独指定（IANA 名称，如 Asia/Kathmandu）
+		`ALTER TABLE plans ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64)`,
+		`ALTER TABLE travel_items ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64)`,
+		`ALTER TABLE transport_details ADD COLUMN IF NOT EXISTS departure_time_zone VARCHAR(64)`,
+		`ALTER TABLE transport_details ADD COLUMN IF NOT EXISTS arrival_time_zone VARCHAR(64)`,
+
+		// 汇率表：1 单位 from_currency = rate 单位 to_currency
+		`CREATE TABLE IF NOT EXISTS exchange_rates (
+			id VARCHAR(36) PRIMARY KEY,
+			from_currency VARCHAR(10) NOT NULL,
+			to_currency VARCHAR(10) NOT NULL,
+			rate DECIMAL(18,8) NOT NULL CHECK (rate > 0),
+			rate_date DATE NOT NULL,
+			source VARCHAR(100),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE(from_currency, to_currency, rate_date)
+		)`,
+
+		// 计划参与者表
+		`CREATE TABLE IF NOT EXISTS plan_participants (
+			id VARCHAR(36) PRIMARY KEY,
+			plan_id VARCHAR(36) NOT NULL REFERENCES plans(id) ON DELETE CASCADE,
+			name VARCHAR(100) NOT NULL,
+			user_id VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL,
+			email VARCHAR(100),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			UNIQUE(plan_id, name)
+		)`,
+
+		// 预算项目付款人和分摊规则
+		`ALTER TABLE budget_items ADD COLUMN IF NOT EXISTS paid_by VARCHAR(36) REFERENCES plan_participants(id) ON DELETE SET NULL`,
+		`ALTER TABLE budget_items ADD COLUMN IF NOT EXISTS split_type VARCHAR(20) NOT NULL DEFAULT 'equal'`,
+		`ALTER TABLE budget_items ADD COLUMN IF NOT EXISTS split_details JSONB`,
+
+		// 令牌版本：递增后该用户已签发的访问令牌全部失效
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS token_version INTEGER NOT NULL DEFAULT 0`,
+
+		// 刷新令牌表：只保存哈希，同一次登录轮换出的令牌属于同一个 family
+		`CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			family_id VARCHAR(36) NOT NULL,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			used_at TIMESTAMPTZ,
+			replaced_by VARCHAR(36),
+			revoked_at TIMESTAMPTZ
+		)`,
+
+		// 登录会话表：每次登录一条记录，刷新令牌的 family_id 即会话ID
+		`CREATE TABLE IF NOT EXISTS user_sessions (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			device_label VARCHAR(100) NOT NULL,
+			user_agent TEXT,
+			ip_address VARCHAR(45),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			revoked_at TIMESTAMPTZ
+		)`,
+
+		// 邮箱验证
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ`,
+
+		// 一次性邮件令牌（邮箱验证、密码重置），只保存哈希；email 为签发时的邮箱，邮箱变更后旧令牌失效
+		`CREATE TABLE IF NOT EXISTS email_tokens (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			purpose VARCHAR(20) NOT NULL CHECK (purpose IN ('verify_email', 'password_reset')),
+			email VARCHAR(100) NOT NULL,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			used_at TIMESTAMPTZ
+		)`,
+
+		// 两步验证：totp_secret 为加密后的密钥，totp_enabled_at 为空表示尚未启用；
+		// totp_last_step 记录最后一次使用的时间步，防止验证码重放
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMPTZ`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS mfa_verified BOOLEAN NOT NULL DEFAULT false`,
+
+		// 两步验证恢复码，只保存 bcrypt 哈希
+		`CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash VARCHAR(60) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			used_at TIMESTAMPTZ
+		)`,
+
+		// 两步登录的中间状态：密码验证通过后签发，输入验证码后换取访问令牌
+		`CREATE TABLE IF NOT EXISTS mfa_challenges (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			token_hash VARCHAR(64) UNIQUE NOT NULL,
+			device_label VARCHAR(100),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			used_at TIMESTAMPTZ
+		)`,
+
+		// 系统设置（键值对），由管理员修改
+		`CREATE TABLE IF NOT EXISTS system_settings (
+			key VARCHAR(50) PRIMARY KEY,
+			value TEXT NOT NULL,
+			updated_by VARCHAR(36) REFERENCES users(id) ON DELETE SET NULL,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+
+		// 外部身份（单点登录），同一提供方的同一 subject 只能关联一个用户
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			id VARCHAR(36) PRIMARY KEY,
+			user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			provider VARCHAR(50) NOT NULL,
+			subject VARCHAR(255) NOT NULL,
+			email VARCHAR(100),
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			last_login_at TIMESTAMPTZ,
+			UNIQUE (provider, subject)
+		)`,
+
+		// 单点登录过程状态：跳转前保存 state、nonce 和 PKCE 校验码，
+		// 回调成功后记录用户并签发一次性登录码，由前端换取访问令牌
+		`CREATE TABLE IF NOT EXISTS oidc_logins (
+			id VARCHAR(36) PRIMARY KEY,
+			provider VARCHAR(50) NOT NULL,
+			state_hash VARCHAR(64) UNIQUE NOT NULL,
+			nonce VARCHAR(64) NOT NULL,
+			code_verifier VARCHAR(128) NOT NULL,
+			redirect_path TEXT,
+			user_id VARCHAR(36) REFERENCES users(id) ON DELETE CASCADE,
+			login_code_hash VARCHAR(64) UNIQUE,
+			expires_at TIMESTAMPTZ NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			used_at TIMESTAMPTZ
+		)`,
+
+		// 访问令牌黑名单（未配置Redis时使用），过期后可清理
+		`CREATE TABLE IF NOT EXISTS revoked_tokens (
+			jti VARCHAR(36) PRIMARY KEY,
+			expires_at TIMESTAMPTZ NOT NULL
+		)`,
+
 		// 创建索引
 		`CREATE INDEX IF NOT EXISTS idx_plans_user_id ON plans(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_plans_status ON plans(status)`,
@@ -383,9 +567,18 @@ func initSchema() error {
 		`CREATE INDEX IF NOT EXISTS idx_item_relations_target ON item_relations(target_item_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_item_attachments_item ON item_attachments(item_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_item_annotations_item ON item_annotations(item_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_item_status_history_item ON item_status_history(item_id, changed_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_budget_items_plan ON budget_items(plan_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_plan_participants_plan ON plan_participants(plan_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_username ON users(username)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_email ON users(email)`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_revoked_tokens_expires ON revoked_tokens(expires_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_email_tokens_user ON email_tokens(user_id, purpose)`,
+		`CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id)`,
 
 		// 创建更新时间触发器
 		`CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// accommodationColumns 允许部分更新的住宿详情字段
+var accommodationColumns = map[string]bool{
+	"hotel_name":          true,
+	"room_type":           true,
+	"check_in_time":       true,
+	"check_out_time":      true,
+	"guests_count":        true,
+	"breakfast_included":  true,
+	"booking_platform":    true,
+	"booking_number":      true,
+	"booking_url":         true,
+	"phone":               true,
+	"email":               true,
+	"rating":              true,
+	"amenities":           true,
+	"price_per_night":     true,
+	"total_nights":        true,
+	"taxes_fees":          true,
+	"cancellation_policy": true,
+}
+
+const accommodationSelect = `
+	SELECT t.id, t.plan_id, t.item_type, t.name, t.description,
+		t.latitude, t.longitude, t.address,
+		t.start_datetime, t.end_datetime, t.time_zone, t.duration_hours,
+		t.cost, t.priority, t.status, t.booking_status,
+		t.properties, t.images, t.notes, t.tags,
+		t.order_index, t.group_id,
+		t.created_by, t.created_at, t.updated_at,
+		a.item_id, a.hotel_name, a.room_type, a.check_in_time::text, a.check_out_time::text,
+		a.guests_count, COALESCE(a.breakfast_included, false), a.booking_platform, a.booking_number,
+		a.booking_url, a.phone, a.email, a.rating, a.amenities,
+		a.price_per_night, a.total_nights, a.taxes_fees, a.cancellation_policy,
+		(SELECT p.time_zone FROM plans p WHERE p.id = t.plan_id)
+	FROM travel_items t
+	LEFT JOIN accommodation_details a ON a.item_id = t.id
+`
+
+// GetAccommodations 获取住宿列表
+func GetAccommodations(c *gin.Context) {
+	planID := c.Param("planId")
+	db := database.GetDB()
+
+	rows, err := db.Query(accommodationSelect+`
+		WHERE t.plan_id = $1 AND t.item_type = $2
+		ORDER BY COALESCE(t.start_datetime, '9999-12-31'::timestamp), t.order_index, t.created_at
+	`, planID, models.ItemTypeAccommodation)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	accommodations := []models.AccommodationItem{}
+	for rows.Next() {
+		item, err := scanAccommodation(rows)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		accommodations = append(accommodations, *item)
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      accommodations,
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateAccommodation 创建住宿
+func CreateAccommodation(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	// 验证计划所有权
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.CreateTravelItemRequest
+	req.ItemType = models.ItemTypeAccommodation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	req.ItemType = models.ItemTypeAccommodation
+
+	if req.AccommodationDetails == nil {
+		req.AccommodationDetails = &models.AccommodationDetails{}
+	}
+
+	var itemID string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var err error
+		itemID, err = insertTravelItem(tx, planID, userID, &req)
+		return err
+	})
+
+	if err != nil {
+		respondItemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success:   true,
+		Data:      map[string]string{"id": itemID},
+		Message:   "住宿创建成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetAccommodationDetails 获取住宿详情
+func GetAccommodationDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+	db := database.GetDB()
+
+	rows, err := db.Query(accommodationSelect+" WHERE t.id = $1 AND t.item_type = $2",
+		itemID, models.ItemTypeAccommodation)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusNotFound, models.ApiResponse{
+			Success:   false,
+			Message:   "住宿不存在",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	item, err := scanAccommodation(rows)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      item,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateAccommodationDetails 更新住宿详情
+func UpdateAccommodationDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	// 验证权限
+	planID, itemType, err := getItemPlan(itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "住宿不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if itemType != models.ItemTypeAccommodation {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "该元素不是住宿类型",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权修改此住宿",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	err = database.Transaction(func(tx *sql.Tx) error {
+		return updateItemDetails(tx, "accommodation_details", itemID, req, accommodationColumns)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "住宿更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// scanAccommodation 扫描住宿元素及详情，并计算晚数和总价
+func scanAccommodation(rows *sql.Rows) (*models.AccommodationItem, error) {
+	var item models.AccommodationItem
+	var detailsID sql.NullString
+	var details models.AccommodationDetails
+	var planZone *string
+
+	err := rows.Scan(
+		&item.ID, &item.PlanID, &item.ItemType, &item.Name, &item.Description,
+		&item.Latitude, &item.Longitude, &item.Address,
+		&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
+		&item.Cost, &item.Priority, &item.Status, &item.BookingStatus,
+		&item.Properties, pq.Array(&item.Images), &item.Notes, pq.Array(&item.Tags),
+		&item.OrderIndex, &item.GroupID,
+		&item.CreatedBy, &item.CreatedAt, &item.UpdatedAt,
+		&detailsID, &details.HotelName, &details.RoomType, &details.CheckInTime, &details.CheckOutTime,
+		&details.GuestsCount, &details.BreakfastIncluded, &details.BookingPlatform, &details.BookingNumber,
+		&details.BookingURL, &details.Phone, &details.Email, &details.Rating, &details.Amenities,
+		&details.PricePerNight, &details.TotalNights, &details.TaxesFees, &details.CancellationPolicy,
+		&planZone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	loc := resolveLocation(item.TimeZone, planZone)
+	if detailsID.Valid {
+		details.ItemID = detailsID.String
+		item.Details = &details
+		item.Nights = accommodationNights(&item.TravelItem, &details, loc)
+		item.TotalPrice = accommodationTotalPrice(&details, item.Nights)
+	} else {
+		item.Nights = accommodationNights(&item.TravelItem, nil, loc)
+	}
+
+	return &item, nil
+}
+
+// accommodationNights 住宿晚数：优先使用total_nights，否则按入住和退房在住宿所在时区的日期计算
+func accommodationNights(item *models.TravelItem, details *models.AccommodationDetails, loc *time.Location) int {
+	if details != nil && details.TotalNights != nil {
+		return *details.TotalNights
+	}
+	if item.StartDatetime == nil || item.EndDatetime == nil {
+		return 0
+	}
+
+	nights := calendarDaysBetween(item.StartDatetime.In(loc), item.EndDatetime.In(loc))
+	if nights < 0 {
+		return 0
+	}
+	return nights
+}
+
+// accommodationTotalPrice 住宿总价 = 每晚价格 × 晚数 + 税费
+func accommodationTotalPrice(details *models.AccommodationDetails, nights int) float64 {
+	total := 0.0
+	if details.PricePerNight != nil {
+		total += *details.PricePerNight * float64(nights)
+	}
+	if details.TaxesFees != nil {
+		total += *details.TaxesFees
+	}
+	return total
+}
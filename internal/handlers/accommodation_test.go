@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccommodationNights(t *testing.T) {
+	checkIn := time.Date(2025, 9, 10, 15, 0, 0, 0, time.UTC)
+	checkOut := time.Date(2025, 9, 12, 11, 0, 0, 0, time.UTC)
+	item := &models.TravelItem{StartDatetime: &checkIn, EndDatetime: &checkOut}
+
+	t.Run("按入住退房日期计算", func(t *testing.T) {
+		assert.Equal(t, 2, accommodationNights(item, nil, time.UTC))
+	})
+
+	t.Run("优先使用total_nights", func(t *testing.T) {
+		nights := 3
+		assert.Equal(t, 3, accommodationNights(item, &models.AccommodationDetails{TotalNights: &nights}, time.UTC))
+	})
+
+	t.Run("按住宿所在时区的日期计算", func(t *testing.T) {
+		lateCheckIn := time.Date(2025, 9, 10, 23, 0, 0, 0, time.UTC)
+		local := &models.TravelItem{StartDatetime: &lateCheckIn, EndDatetime: &checkOut}
+		shanghai, err := time.LoadLocation("Asia/Shanghai")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, accommodationNights(local, nil, time.UTC))
+		assert.Equal(t, 1, accommodationNights(local, nil, shanghai))
+	})
+
+	t.Run("缺少时间", func(t *testing.T) {
+		assert.Equal(t, 0, accommodationNights(&models.TravelItem{}, nil, time.UTC))
+	})
+}
+
+func TestAccommodationTotalPrice(t *testing.T) {
+	price := 380.0
+	fees := 45.5
+	details := &models.AccommodationDetails{PricePerNight: &price, TaxesFees: &fees}
+
+	assert.InDelta(t, 805.5, accommodationTotalPrice(details, 2), 0.001)
+	assert.InDelta(t, 45.5, accommodationTotalPrice(details, 0), 0.001)
+}
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"planner/internal/auth"
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/mail"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 一次性邮件令牌用途
+const (
+	emailTokenVerify = "verify_email"
+	emailTokenReset  = "password_reset"
+)
+
+// errInvalidEmailToken 邮件令牌不存在、已使用、已过期或邮箱已变更
+var errInvalidEmailToken = errors.New("链接无效或已过期")
+
+// SendVerificationEmail 重新发送邮箱验证邮件
+func SendVerificationEmail(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var username, email string
+	var verifiedAt sql.NullTime
+	err := database.GetDB().QueryRow("SELECT username, email, email_verified_at FROM users WHERE id = $1", userID).
+		Scan(&username, &email, &verifiedAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if verifiedAt.Valid {
+		c.JSON(http.StatusConflict, models.ApiResponse{
+			Success:   false,
+			Message:   "邮箱已验证",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if err := sendVerificationEmail(c, userID, username, email); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "验证邮件已发送",
+		Timestamp: time.Now(),
+	})
+}
+
+// VerifyEmail 使用邮件中的令牌完成邮箱验证
+func VerifyEmail(c *gin.Context) {
+	var req models.EmailTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		userID, err := consumeEmailToken(tx, req.Token, emailTokenVerify)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE users SET email_verified_at = NOW() WHERE id = $1 AND email_verified_at IS NULL", userID)
+		return err
+	})
+	if err != nil {
+		respondEmailTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "邮箱验证成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// ForgotPassword 发送重置密码邮件。无论邮箱是否存在都返回成功，避免泄露注册信息
+func ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var userID, username, email string
+	err := database.GetDB().QueryRow(`
+		SELECT id, username, email FROM users
+		WHERE LOWER(email) = LOWER($1) AND is_active = true
+	`, strings.TrimSpace(req.Email)).Scan(&userID, &username, &email)
+	if err != nil && err != sql.ErrNoRows {
+		c.Error(err)
+		return
+	}
+
+	if err == nil {
+		ttl := time.Duration(config.Get().PasswordResetTTLMinutes) * time.Minute
+		var token string
+		err := database.Transaction(func(tx *sql.Tx) error {
+			var err error
+			token, err = issueEmailToken(tx, userID, emailTokenReset, email, ttl)
+			return err
+		})
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		sendTemplateMail(mail.TemplatePasswordReset, mail.Language(c.GetHeader("Accept-Language")), email, mail.TemplateData{
+			Username: username,
+			Link:     emailLink("/reset-password", token),
+			ValidFor: ttl,
+		})
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "如果该邮箱已注册，重置密码邮件将很快送达",
+		Timestamp: time.Now(),
+	})
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码，成功后撤销该用户的全部会话
+func ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	err = database.Transaction(func(tx *sql.Tx) error {
+		userID, err := consumeEmailToken(tx, req.Token, emailTokenReset)
+		if err != nil {
+			return err
+		}
+
+		// 能收到重置邮件也说明邮箱属于该用户
+		_, err = tx.Exec(`
+			UPDATE users SET password_hash = $1, email_verified_at = COALESCE(email_verified_at, NOW()), updated_at = NOW()
+			WHERE id = $2
+		`, string(hashedPassword), userID)
+		if err != nil {
+			return err
+		}
+		// 其他未使用的重置链接一并作废
+		_, err = tx.Exec("UPDATE email_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+			userID, emailTokenReset)
+		if err != nil {
+			return err
+		}
+		return revokeUserTokens(tx, userID)
+	})
+	if err != nil {
+		respondEmailTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "密码已重置，请重新登录",
+		Timestamp: time.Now(),
+	})
+}
+
+// sendVerificationEmail 签发邮箱验证令牌并异步发送验证邮件
+func sendVerificationEmail(c *gin.Context, userID, username, email string) error {
+	ttl := time.Duration(config.Get().EmailVerificationTTLHours) * time.Hour
+	var token string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var err error
+		token, err = issueEmailToken(tx, userID, emailTokenVerify, email, ttl)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	sendTemplateMail(mail.TemplateVerifyEmail, mail.Language(c.GetHeader("Accept-Language")), email, mail.TemplateData{
+		Username: username,
+		Link:     emailLink("/verify-email", token),
+		ValidFor: ttl,
+	})
+	return nil
+}
+
+// issueEmailToken 签发一次性邮件令牌，同一用途之前未使用的令牌作废
+func issueEmailToken(tx *sql.Tx, userID, purpose, email string, ttl time.Duration) (string, error) {
+	_, err := tx.Exec("UPDATE email_tokens SET used_at = NOW() WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userID, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO email_tokens (id, user_id, purpose, email, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, uuid.New().String(), userID, purpose, email, hash, time.Now().Add(ttl), time.Now())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeEmailToken 校验并使用一次性邮件令牌，返回用户ID。
+// 令牌签发后用户修改了邮箱或账户被禁用时同样视为无效
+func consumeEmailToken(tx *sql.Tx, token, purpose string) (string, error) {
+	var id, userID string
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	var sameEmail, isActive bool
+	err := tx.QueryRow(`
+		SELECT t.id, t.user_id, t.expires_at, t.used_at, t.email = u.email, u.is_active
+		FROM email_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.purpose = $2
+		FOR UPDATE OF t
+	`, auth.HashOpaqueToken(token), purpose).Scan(&id, &userID, &expiresAt, &usedAt, &sameEmail, &isActive)
+	if err == sql.ErrNoRows {
+		return "", errInvalidEmailToken
+	}
+	if err != nil {
+		return "", err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) || !sameEmail || !isActive {
+		return "", errInvalidEmailToken
+	}
+
+	if _, err := tx.Exec("UPDATE email_tokens SET used_at = NOW() WHERE id = $1", id); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+func respondEmailTokenError(c *gin.Context, err error) {
+	if err == errInvalidEmailToken {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	c.Error(err)
+}
+
+// emailLink 生成邮件中指向前端页面的链接
+func emailLink(path, token string) string {
+	return strings.TrimRight(config.Get().AppBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendTemplateMail 渲染模板并在后台发送，发送失败只记录日志，不影响请求结果
+func sendTemplateMail(name, lang, to string, data mail.TemplateData) {
+	msg, err := mail.Render(name, lang, to, data)
+	if err != nil {
+		log.Printf("⚠️ 渲染邮件模板失败: %v", err)
+		return
+	}
+
+	mailer := mail.Get()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := mailer.Send(ctx, msg); err != nil {
+			log.Printf("⚠️ 发送邮件失败 (%s): %v", name, err)
+		}
+	}()
+}
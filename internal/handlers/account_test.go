@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"planner/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailLink(t *testing.T) {
+	config.Load()
+	config.Get().AppBaseURL = "https://planner.example.com/"
+
+	assert.Equal(t, "https://planner.example.com/reset-password?token=a%2Bb",
+		emailLink("/reset-password", "a+b"))
+}
+
+func TestPasswordResetValidation(t *testing.T) {
+	router := setupTestRouter()
+	router.POST("/api/v1/auth/password/forgot", ForgotPassword)
+	router.POST("/api/v1/auth/password/reset", ResetPassword)
+	router.POST("/api/v1/auth/verify-email", VerifyEmail)
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/api/v1/auth/password/forgot", `{"email":"not-an-email"}`},
+		{"/api/v1/auth/password/reset", `{"token":"abc","password":"123"}`},
+		{"/api/v1/auth/password/reset", `{"password":"12345678"}`},
+		{"/api/v1/auth/verify-email", `{}`},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest("POST", tc.path, bytes.NewBufferString(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.body)
+	}
+}
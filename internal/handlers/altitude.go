@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// altitudeItem 海拔分析所需的元素字段，时间为元素所在时区的本地时间
+type altitudeItem struct {
+	id       string
+	name     string
+	itemType models.ItemType
+	altitude *int
+	start    *time.Time
+	end      *time.Time
+}
+
+type altitudeOptions struct {
+	thresholdM     int
+	maxAscentM     int
+	restDayAscentM int
+}
+
+// GetAltitudeProfile 分析计划的睡眠海拔变化，检查上升速度并给出休整建议
+func GetAltitudeProfile(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	cfg := config.Get()
+	opts := altitudeOptions{
+		thresholdM:     int(cfg.AltitudeThresholdM),
+		maxAscentM:     int(cfg.MaxNightlyAscentM),
+		restDayAscentM: int(cfg.RestDayAscentM),
+	}
+	for _, param := range []struct {
+		key   string
+		value *int
+	}{
+		{"threshold_m", &opts.thresholdM},
+		{"max_ascent_m", &opts.maxAscentM},
+		{"rest_day_ascent_m", &opts.restDayAscentM},
+	} {
+		raw := c.Query(param.key)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   param.key + " 必须是正整数",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		*param.value = n
+	}
+
+	window, items, err := loadAltitudeItems(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := analyzeAltitude(opts, window, items)
+	report.PlanID = planID
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      report,
+		Timestamp: time.Now(),
+	})
+}
+
+// loadAltitudeItems 加载计划起止日期和未跳过、未取消的元素
+func loadAltitudeItems(planID string) (planWindow, []altitudeItem, error) {
+	db := database.GetDB()
+
+	var window planWindow
+	var planZone *string
+	if err := db.QueryRow("SELECT start_date, end_date, time_zone FROM plans WHERE id = $1", planID).
+		Scan(&window.startDate, &window.endDate, &planZone); err != nil {
+		return window, nil, err
+	}
+	zone := resolveTimeZoneName(planZone)
+
+	rows, err := db.Query(`
+		SELECT id, name, item_type, altitude, start_datetime, end_datetime, time_zone
+		FROM travel_items
+		WHERE plan_id = $1 AND COALESCE(status, '') NOT IN ($2, $3)
+		ORDER BY start_datetime NULLS LAST, order_index NULLS LAST, id
+	`, planID, models.ItemStatusSkipped, models.ItemStatusCancelled)
+	if err != nil {
+		return window, nil, err
+	}
+	defer rows.Close()
+
+	var items []altitudeItem
+	for rows.Next() {
+		var item altitudeItem
+		var itemZone *string
+		if err := rows.Scan(&item.id, &item.name, &item.itemType, &item.altitude,
+			&item.start, &item.end, &itemZone); err != nil {
+			return window, nil, err
+		}
+		loc := resolveLocation(itemZone, &zone)
+		item.start = localTime(item.start, loc)
+		item.end = localTime(item.end, loc)
+		items = append(items, item)
+	}
+	return window, items, rows.Err()
+}
+
+// analyzeAltitude 生成每晚的睡眠海拔和每天的最高海拔，并检查适应节奏。
+// 超过阈值后按睡眠海拔计算上升：上一晚低于阈值或未知时从阈值算起，
+// 平均每晚上升超过上限时建议在中间海拔增加过渡的夜晚；阈值以上累计上升达到休整间隔且
+// 下一晚继续上升时建议原地多住一晚。
+func analyzeAltitude(opts altitudeOptions, window planWindow, items []altitudeItem) models.AltitudeReport {
+	report := models.AltitudeReport{
+		ThresholdM:     opts.thresholdM,
+		MaxAscentM:     opts.maxAscentM,
+		RestDayAscentM: opts.restDayAscentM,
+		Nights:         []models.AltitudeNight{},
+		Days:           []models.AltitudeDay{},
+		Issues:         []models.ScheduleIssue{},
+		RestDays:       []models.RestDaySuggestion{},
+	}
+
+	first, last := altitudeRange(window, items)
+	if first == nil || last == nil {
+		return report
+	}
+
+	report.Nights = sleepingProfile(*first, *last, items)
+	nightByDate := make(map[string]*models.AltitudeNight, len(report.Nights))
+	for i := range report.Nights {
+		nightByDate[report.Nights[i].Date] = &report.Nights[i]
+	}
+
+	var prevAlt *int
+	prevIndex := -1
+	climbed := 0
+	for i := range report.Nights {
+		night := &report.Nights[i]
+		if night.Altitude == nil {
+			if night.ItemID != nil {
+				report.Issues = append(report.Issues, models.ScheduleIssue{
+					Code:     models.IssueUnknownSleepAltitude,
+					Severity: models.IssueSeverityWarning,
+					Message:  fmt.Sprintf("「%s」没有填写海拔，无法评估 %s 晚的适应情况", night.ItemName, night.Date),
+					ItemIDs:  []string{*night.ItemID},
+					Date:     night.Date,
+				})
+			}
+			continue
+		}
+
+		alt := *night.Altitude
+		base := opts.thresholdM
+		if prevAlt != nil {
+			ascent := alt - *prevAlt
+			night.Ascent = &ascent
+			if *prevAlt > base {
+				base = *prevAlt
+			}
+			// 原地或下降过夜即为休整，重新累计
+			if ascent <= 0 {
+				climbed = 0
+			}
+		}
+
+		nights := 1
+		if prevIndex >= 0 {
+			nights = i - prevIndex
+		}
+		if excess := alt - base; excess > 0 {
+			climbed += excess
+			if rate := excess / nights; rate > opts.maxAscentM {
+				report.Issues = append(report.Issues, excessiveAscentIssue(opts, night, prevAlt, excess, nights))
+				needed := int(math.Ceil(float64(excess)/float64(opts.maxAscentM))) - nights
+				report.RestDays = append(report.RestDays, models.RestDaySuggestion{
+					Date:     night.Date,
+					Altitude: base + opts.maxAscentM,
+					Nights:   needed,
+					Reason: fmt.Sprintf("到达 %d 米前在约 %d 米增加 %d 晚过渡，使每晚上升不超过 %d 米",
+						alt, base+opts.maxAscentM, needed, opts.maxAscentM),
+				})
+			}
+		}
+
+		if climbed >= opts.restDayAscentM {
+			if next := nextKnownNight(report.Nights, i); next != nil && *next.Altitude > alt {
+				report.Issues = append(report.Issues, models.ScheduleIssue{
+					Code:     models.IssueRestDayNeeded,
+					Severity: models.IssueSeverityWarning,
+					Message: fmt.Sprintf("%d 米以上已累计上升 %d 米，%s 晚后继续上升到 %d 米前没有休整",
+						opts.thresholdM, climbed, night.Date, *next.Altitude),
+					ItemIDs: nightItemIDs(night, next),
+					Date:    night.Date,
+				})
+				report.RestDays = append(report.RestDays, models.RestDaySuggestion{
+					Date:     night.Date,
+					Altitude: alt,
+					Nights:   1,
+					Reason:   fmt.Sprintf("累计上升 %d 米，建议在 %d 米原地多住一晚休整", climbed, alt),
+				})
+			}
+			climbed = 0
+		}
+
+		if report.HighestSleeping == nil || alt > *report.HighestSleeping {
+			report.HighestSleeping = night.Altitude
+		}
+		prevAlt = night.Altitude
+		prevIndex = i
+	}
+
+	report.Days = dailyMaxAltitude(*first, *last, items, nightByDate)
+	for _, day := range report.Days {
+		if day.MaxAltitude != nil && (report.HighestAltitude == nil || *day.MaxAltitude > *report.HighestAltitude) {
+			report.HighestAltitude = day.MaxAltitude
+		}
+	}
+	return report
+}
+
+func excessiveAscentIssue(opts altitudeOptions, night *models.AltitudeNight, prevAlt *int, excess, nights int) models.ScheduleIssue {
+	severity := models.IssueSeverityWarning
+	if excess/nights > 2*opts.maxAscentM {
+		severity = models.IssueSeverityError
+	}
+	from := "较低海拔"
+	if prevAlt != nil {
+		from = fmt.Sprintf("%d 米", *prevAlt)
+	}
+	issue := models.ScheduleIssue{
+		Code:     models.IssueExcessiveAscent,
+		Severity: severity,
+		Message: fmt.Sprintf("%s 晚睡眠海拔从%s上升到 %d 米，%d 米以上平均每晚上升 %d 米，超过 %d 米",
+			night.Date, from, *night.Altitude, opts.thresholdM, excess/nights, opts.maxAscentM),
+		Date: night.Date,
+	}
+	if night.ItemID != nil {
+		issue.ItemIDs = []string{*night.ItemID}
+	}
+	return issue
+}
+
+// altitudeRange 分析的日期范围，计划未设置起止日期时按元素的最早和最晚日期推算
+func altitudeRange(window planWindow, items []altitudeItem) (*time.Time, *time.Time) {
+	if window.startDate != nil && window.endDate != nil && !window.endDate.Before(*window.startDate) {
+		first, last := dateOnly(*window.startDate), dateOnly(*window.endDate)
+		return &first, &last
+	}
+
+	var first, last *time.Time
+	for _, item := range items {
+		for _, t := range []*time.Time{item.start, item.end} {
+			if t == nil {
+				continue
+			}
+			d := dateOnly(*t)
+			if first == nil || d.Before(*first) {
+				first = &d
+			}
+			if last == nil || d.After(*last) {
+				last = &d
+			}
+		}
+	}
+	return first, last
+}
+
+// sleepingProfile 按住宿生成从 first 到 last 前一天的每晚睡眠海拔，同一晚有多个住宿时以较晚入住的为准
+func sleepingProfile(first, last time.Time, items []altitudeItem) []models.AltitudeNight {
+	stays := make([]*altitudeItem, 0)
+	for i := range items {
+		if items[i].itemType == models.ItemTypeAccommodation && items[i].start != nil {
+			stays = append(stays, &items[i])
+		}
+	}
+	sort.SliceStable(stays, func(i, j int) bool { return stays[i].start.Before(*stays[j].start) })
+
+	byNight := make(map[string]*altitudeItem)
+	for _, stay := range stays {
+		checkIn := dateOnly(*stay.start)
+		checkOut := checkIn.AddDate(0, 0, 1)
+		if stay.end != nil && dateOnly(*stay.end).After(checkIn) {
+			checkOut = dateOnly(*stay.end)
+		}
+		for d := checkIn; d.Before(checkOut); d = d.AddDate(0, 0, 1) {
+			byNight[d.Format("2006-01-02")] = stay
+		}
+	}
+
+	nights := []models.AltitudeNight{}
+	for d := first; d.Before(last); d = d.AddDate(0, 0, 1) {
+		night := models.AltitudeNight{Date: d.Format("2006-01-02")}
+		if stay, ok := byNight[night.Date]; ok {
+			night.ItemID = &stay.id
+			night.ItemName = stay.name
+			night.Altitude = stay.altitude
+		}
+		nights = append(nights, night)
+	}
+	return nights
+}
+
+// dailyMaxAltitude 每天所有元素中的最高海拔，跨天的元素计入其经过的每一天
+func dailyMaxAltitude(first, last time.Time, items []altitudeItem, nightByDate map[string]*models.AltitudeNight) []models.AltitudeDay {
+	days := []models.AltitudeDay{}
+	index := make(map[string]int)
+	for d := first; !d.After(last); d = d.AddDate(0, 0, 1) {
+		day := models.AltitudeDay{Date: d.Format("2006-01-02")}
+		if night, ok := nightByDate[day.Date]; ok {
+			day.SleepingAltitude = night.Altitude
+		}
+		index[day.Date] = len(days)
+		days = append(days, day)
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.altitude == nil || item.start == nil {
+			continue
+		}
+		end := dateOnly(*item.start)
+		if item.end != nil && dateOnly(*item.end).After(end) {
+			end = dateOnly(*item.end)
+		}
+		for d := dateOnly(*item.start); !d.After(end); d = d.AddDate(0, 0, 1) {
+			j, ok := index[d.Format("2006-01-02")]
+			if !ok {
+				continue
+			}
+			if days[j].MaxAltitude == nil || *item.altitude > *days[j].MaxAltitude {
+				days[j].MaxAltitude = item.altitude
+				days[j].ItemID = &item.id
+				days[j].ItemName = item.name
+			}
+		}
+	}
+	return days
+}
+
+func nextKnownNight(nights []models.AltitudeNight, i int) *models.AltitudeNight {
+	for j := i + 1; j < len(nights); j++ {
+		if nights[j].Altitude != nil {
+			return &nights[j]
+		}
+	}
+	return nil
+}
+
+func nightItemIDs(nights ...*models.AltitudeNight) []string {
+	var ids []string
+	for _, n := range nights {
+		if n.ItemID != nil && indexOf(ids, *n.ItemID) < 0 {
+			ids = append(ids, *n.ItemID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func altitudeStay(id string, altitude, day, nights int) altitudeItem {
+	checkIn := time.Date(2025, 10, day, 15, 0, 0, 0, time.UTC)
+	checkOut := checkIn.AddDate(0, 0, nights).Add(-3 * time.Hour)
+	return altitudeItem{id: id, name: id, itemType: models.ItemTypeAccommodation,
+		altitude: &altitude, start: &checkIn, end: &checkOut}
+}
+
+func TestAnalyzeAltitudeFlagsFastAscent(t *testing.T) {
+	opts := altitudeOptions{thresholdM: 3000, maxAscentM: 500, restDayAscentM: 1000}
+	peak := 4700
+	summit := time.Date(2025, 10, 3, 9, 0, 0, 0, time.UTC)
+	items := []altitudeItem{
+		altitudeStay("chengdu", 500, 1, 1),
+		altitudeStay("daocheng", 3750, 2, 1),
+		altitudeStay("yading", 3900, 3, 2),
+		{id: "niunaihai", name: "牛奶海", itemType: models.ItemTypeAttraction, altitude: &peak, start: &summit},
+	}
+
+	report := analyzeAltitude(opts, planWindow{}, items)
+
+	require.Len(t, report.Nights, 4)
+	assert.Equal(t, "2025-10-02", report.Nights[1].Date)
+	assert.Equal(t, 3250, *report.Nights[1].Ascent)
+	assert.Nil(t, report.Nights[0].Ascent)
+
+	require.Len(t, report.Issues, 1)
+	assert.Equal(t, models.IssueExcessiveAscent, report.Issues[0].Code)
+	assert.Equal(t, models.IssueSeverityWarning, report.Issues[0].Severity)
+	assert.Equal(t, []string{"daocheng"}, report.Issues[0].ItemIDs)
+
+	require.Len(t, report.RestDays, 1)
+	assert.Equal(t, models.RestDaySuggestion{Date: "2025-10-02", Altitude: 3500, Nights: 1,
+		Reason: report.RestDays[0].Reason}, report.RestDays[0])
+
+	assert.Equal(t, 4700, *report.HighestAltitude)
+	assert.Equal(t, 3900, *report.HighestSleeping)
+	assert.Equal(t, "niunaihai", *report.Days[2].ItemID)
+	assert.Equal(t, 3900, *report.Days[2].SleepingAltitude)
+}
+
+func TestAnalyzeAltitudeSuggestsRestDay(t *testing.T) {
+	opts := altitudeOptions{thresholdM: 3000, maxAscentM: 500, restDayAscentM: 1000}
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC)
+	items := []altitudeItem{
+		altitudeStay("a", 3400, 1, 1),
+		altitudeStay("b", 3800, 2, 1),
+		altitudeStay("c", 4200, 3, 1),
+		altitudeStay("d", 4500, 4, 1),
+		{id: "e", name: "e", itemType: models.ItemTypeAccommodation, start: ptrTime(time.Date(2025, 10, 5, 15, 0, 0, 0, time.UTC))},
+	}
+
+	report := analyzeAltitude(opts, planWindow{startDate: &start, endDate: &end}, items)
+
+	require.Len(t, report.Nights, 5)
+	codes := make([]string, len(report.Issues))
+	for i, issue := range report.Issues {
+		codes[i] = issue.Code
+	}
+	assert.Equal(t, []string{models.IssueRestDayNeeded, models.IssueUnknownSleepAltitude}, codes)
+	assert.Equal(t, "2025-10-03", report.Issues[0].Date)
+	assert.Equal(t, []string{"c", "d"}, report.Issues[0].ItemIDs)
+
+	require.Len(t, report.RestDays, 1)
+	assert.Equal(t, 4200, report.RestDays[0].Altitude)
+	assert.Len(t, report.Days, 6)
+}
+
+func TestAnalyzeAltitudeRestResetsClimb(t *testing.T) {
+	opts := altitudeOptions{thresholdM: 3000, maxAscentM: 500, restDayAscentM: 1000}
+	items := []altitudeItem{
+		altitudeStay("a", 3400, 1, 1),
+		altitudeStay("b", 3800, 2, 2),
+		altitudeStay("c", 4200, 4, 1),
+		altitudeStay("d", 4500, 5, 1),
+	}
+
+	report := analyzeAltitude(opts, planWindow{}, items)
+	assert.Empty(t, report.Issues)
+	assert.Empty(t, report.RestDays)
+}
+
+func ptrTime(t time.Time) *time.Time { return &t }
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/imaging"
+	"planner/internal/models"
+	"planner/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// thumbnailSizes 缩略图规格（最长边像素）
+var thumbnailSizes = []struct {
+	name string
+	edge int
+}{
+	{"small", 240},
+	{"medium", 960},
+}
+
+const (
+	// 照片拍摄位置与元素位置相距超过该距离时建议添加标注
+	photoLocationThresholdKm = 0.5
+	// 解码前检查像素数，避免超大图片耗尽内存
+	maxImagePixels = 50_000_000
+)
+
+// ApplyPhotoLocation 使用照片的GPS坐标设置元素位置或添加地图标注
+func ApplyPhotoLocation(c *gin.Context) {
+	attachmentID := c.Param("attachmentId")
+	userID := c.GetString("user_id")
+
+	attachment, ok := loadOwnedAttachment(c, attachmentID, userID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Action  string `json:"action" binding:"required,oneof=set_item_location add_marker"`
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if attachment.GPSLatitude == nil || attachment.GPSLongitude == nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "该附件没有GPS位置信息",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+
+	if req.Action == models.PhotoLocationSetItem {
+		_, err := db.Exec("UPDATE travel_items SET latitude = $2, longitude = $3 WHERE id = $1",
+			attachment.ItemID, *attachment.GPSLatitude, *attachment.GPSLongitude)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+
+		c.JSON(http.StatusOK, models.ApiResponse{
+			Success: true,
+			Data: map[string]interface{}{
+				"item_id":   attachment.ItemID,
+				"latitude":  *attachment.GPSLatitude,
+				"longitude": *attachment.GPSLongitude,
+			},
+			Message:   "元素位置已更新",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	if content == "" {
+		content = "照片拍摄位置"
+		if attachment.Title != nil {
+			content += ": " + *attachment.Title
+		} else if attachment.FileName != nil {
+			content += ": " + *attachment.FileName
+		}
+	}
+
+	annotationType := "photo_location"
+	now := time.Now()
+	annotation := models.ItemAnnotation{
+		ID:             uuid.New().String(),
+		ItemID:         attachment.ItemID,
+		AnnotationType: &annotationType,
+		Content:        content,
+		MarkerLat:      attachment.GPSLatitude,
+		MarkerLng:      attachment.GPSLongitude,
+		CreatedBy:      &userID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO item_annotations (id, item_id, annotation_type, content, marker_lat, marker_lng, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, annotation.ID, annotation.ItemID, annotation.AnnotationType, annotation.Content,
+		annotation.MarkerLat, annotation.MarkerLng, annotation.CreatedBy, annotation.CreatedAt, annotation.UpdatedAt)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success:   true,
+		Data:      annotation,
+		Message:   "标注添加成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// processImageAttachment 后台生成缩略图并提取EXIF拍摄时间和GPS坐标
+func processImageAttachment(attachmentID, itemID, key string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ 处理图片附件 %s 时发生异常: %v", attachmentID, r)
+			markAttachmentProcessing(attachmentID, models.AttachmentProcessingFailed)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	result, err := buildImageDerivatives(ctx, storage.Get(), attachmentID, itemID, key)
+	if err != nil {
+		log.Printf("⚠️ 处理图片附件 %s 失败: %v", attachmentID, err)
+	}
+
+	status := models.AttachmentProcessingDone
+	if err != nil {
+		status = models.AttachmentProcessingFailed
+	}
+
+	var thumbnails models.JSONB
+	if len(result.thumbnails) > 0 {
+		thumbnails = models.JSONB{}
+		for name, url := range result.thumbnails {
+			thumbnails[name] = url
+		}
+	}
+
+	var takenAt *time.Time
+	var lat, lng *float64
+	if result.exif != nil {
+		takenAt, lat, lng = result.exif.TakenAt, result.exif.Latitude, result.exif.Longitude
+	}
+
+	res, dbErr := database.GetDB().Exec(`
+		UPDATE item_attachments
+		SET thumbnails = $2, taken_at = $3, gps_latitude = $4, gps_longitude = $5, processing_status = $6
+		WHERE id = $1
+	`, attachmentID, thumbnails, takenAt, lat, lng, status)
+	if dbErr != nil {
+		log.Printf("⚠️ 保存图片附件 %s 处理结果失败: %v", attachmentID, dbErr)
+		return
+	}
+
+	// 处理期间附件已被删除，清理刚生成的缩略图
+	if n, _ := res.RowsAffected(); n == 0 {
+		removeStoredFiles(result.keys)
+	}
+}
+
+type imageDerivatives struct {
+	exif       *imaging.Exif
+	thumbnails map[string]string
+	keys       []string
+}
+
+// buildImageDerivatives 读取原图，提取EXIF并写入各规格缩略图
+func buildImageDerivatives(ctx context.Context, store storage.Storage, attachmentID, itemID, key string) (imageDerivatives, error) {
+	var result imageDerivatives
+
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		return result, fmt.Errorf("读取原图失败: %v", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(r, config.Get().MaxFileSize+1))
+	r.Close()
+	if err != nil {
+		return result, fmt.Errorf("读取原图失败: %v", err)
+	}
+
+	orientation := 1
+	if exif, err := imaging.ReadExif(bytes.NewReader(data)); err == nil {
+		result.exif = exif
+		orientation = exif.Orientation
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return result, fmt.Errorf("无法识别图片格式: %v", err)
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return result, fmt.Errorf("图片尺寸过大: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return result, fmt.Errorf("解码图片失败: %v", err)
+	}
+
+	result.thumbnails = make(map[string]string, len(thumbnailSizes))
+	for _, size := range thumbnailSizes {
+		encoded, err := imaging.EncodeJPEG(imaging.Thumbnail(src, size.edge, orientation))
+		if err != nil {
+			return result, fmt.Errorf("生成缩略图失败: %v", err)
+		}
+
+		thumbKey := thumbnailKey(itemID, attachmentID, size.name)
+		if err := store.Put(ctx, thumbKey, bytes.NewReader(encoded), int64(len(encoded)), "image/jpeg"); err != nil {
+			return result, fmt.Errorf("保存缩略图失败: %v", err)
+		}
+		result.keys = append(result.keys, thumbKey)
+		result.thumbnails[size.name] = store.URL(thumbKey)
+	}
+
+	return result, nil
+}
+
+func markAttachmentProcessing(attachmentID, status string) {
+	if _, err := database.GetDB().Exec("UPDATE item_attachments SET processing_status = $2 WHERE id = $1", attachmentID, status); err != nil {
+		log.Printf("⚠️ 更新附件 %s 处理状态失败: %v", attachmentID, err)
+	}
+}
+
+// thumbnailKey 缩略图的存储路径
+func thumbnailKey(itemID, attachmentID, size string) string {
+	return fmt.Sprintf("thumbnails/%s/%s_%s.jpg", itemID, attachmentID, size)
+}
+
+// photoLocationSuggestion 根据照片GPS坐标和元素坐标给出建议：
+// 元素没有坐标时建议设置元素位置，相距较远时建议添加标注
+func photoLocationSuggestion(a *models.ItemAttachment, itemLat, itemLng *float64) string {
+	if a.GPSLatitude == nil || a.GPSLongitude == nil {
+		return ""
+	}
+	if itemLat == nil || itemLng == nil {
+		return models.PhotoLocationSetItem
+	}
+	if haversineKm(*itemLat, *itemLng, *a.GPSLatitude, *a.GPSLongitude) > photoLocationThresholdKm {
+		return models.PhotoLocationAddMarker
+	}
+	return ""
+}
+
+// haversineKm 计算两个经纬度坐标间的球面距离（公里）
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
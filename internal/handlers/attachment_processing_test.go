@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"testing"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhotoLocationSuggestion(t *testing.T) {
+	lat, lng := 28.44, 100.335
+	photo := &models.ItemAttachment{GPSLatitude: &lat, GPSLongitude: &lng}
+
+	assert.Equal(t, models.PhotoLocationSetItem, photoLocationSuggestion(photo, nil, nil))
+
+	nearLat, nearLng := 28.441, 100.336
+	assert.Equal(t, "", photoLocationSuggestion(photo, &nearLat, &nearLng))
+
+	farLat, farLng := 29.03, 100.30
+	assert.Equal(t, models.PhotoLocationAddMarker, photoLocationSuggestion(photo, &farLat, &farLng))
+
+	assert.Equal(t, "", photoLocationSuggestion(&models.ItemAttachment{}, &farLat, &farLng))
+}
+
+func TestHaversineKm(t *testing.T) {
+	// 成都 → 稻城 直线距离约 400 公里
+	assert.InDelta(t, 400, haversineKm(30.5728, 104.0668, 29.0379, 100.2966), 5)
+	assert.InDelta(t, 0, haversineKm(28.44, 100.335, 28.44, 100.335), 1e-9)
+}
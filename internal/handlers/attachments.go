@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/models"
+	"planner/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// allowedAttachmentTypes 允许上传的MIME类型（按文件内容识别）及其扩展名和附件类型
+var allowedAttachmentTypes = map[string]struct {
+	ext      string
+	fileType string
+}{
+	"image/jpeg":      {".jpg", "image"},
+	"image/png":       {".png", "image"},
+	"image/gif":       {".gif", "image"},
+	"image/webp":      {".webp", "image"},
+	"application/pdf": {".pdf", "document"},
+	"text/plain":      {".txt", "document"},
+}
+
+const attachmentSelect = `
+	SELECT id, item_id, file_type, file_url, file_name, file_size, mime_type, checksum, storage_key,
+		thumbnails, processing_status, taken_at, gps_latitude, gps_longitude,
+		title, description, is_primary, order_index, uploaded_by, uploaded_at
+	FROM item_attachments
+`
+
+// UploadAttachment 上传附件
+func UploadAttachment(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	if !checkItemAccess(c, itemID, userID, "无权为此元素上传附件") {
+		return
+	}
+
+	maxSize := config.Get().MaxFileSize
+	// 为表单字段预留空间，文件本身的大小在下面单独检查
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			respondFileTooLarge(c, maxSize)
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请选择要上传的文件",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		respondFileTooLarge(c, maxSize)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer file.Close()
+
+	// 按文件内容识别类型，不信任客户端提供的 Content-Type 和扩展名
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.Error(err)
+		return
+	}
+	head = head[:n]
+
+	mimeType := detectMimeType(head)
+	kind, ok := allowedAttachmentTypes[mimeType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, models.ApiResponse{
+			Success:   false,
+			Message:   "不支持的文件类型: " + mimeType,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	attachment := models.ItemAttachment{
+		ID:         uuid.New().String(),
+		ItemID:     itemID,
+		FileType:   kind.fileType,
+		MimeType:   &mimeType,
+		IsPrimary:  c.PostForm("is_primary") == "true",
+		UploadedBy: &userID,
+		UploadedAt: time.Now(),
+	}
+	if name := filepath.Base(fileHeader.Filename); name != "." && name != "/" {
+		attachment.FileName = &name
+	}
+	if title := strings.TrimSpace(c.PostForm("title")); title != "" {
+		attachment.Title = &title
+	}
+	if description := strings.TrimSpace(c.PostForm("description")); description != "" {
+		attachment.Description = &description
+	}
+
+	store := storage.Get()
+	key := fmt.Sprintf("attachments/%s/%s%s", itemID, attachment.ID, kind.ext)
+	attachment.StorageKey = &key
+	attachment.FileURL = store.URL(key)
+
+	// 写入存储的同时计算校验和
+	hasher := sha256.New()
+	content := io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hasher)
+	if err := store.Put(c.Request.Context(), key, content, fileHeader.Size, mimeType); err != nil {
+		c.Error(fmt.Errorf("保存文件失败: %v", err))
+		return
+	}
+
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+	size := int(fileHeader.Size)
+	attachment.Checksum = &checksum
+	attachment.FileSize = &size
+	if attachment.FileType == "image" {
+		status := models.AttachmentProcessingPending
+		attachment.ProcessingStatus = &status
+	}
+
+	err = database.Transaction(func(tx *sql.Tx) error {
+		var count, nextIndex int
+		if err := tx.QueryRow(`
+			SELECT COUNT(*), COALESCE(MAX(order_index) + 1, 0)
+			FROM item_attachments WHERE item_id = $1
+		`, itemID).Scan(&count, &nextIndex); err != nil {
+			return err
+		}
+
+		// 第一个附件自动成为主附件
+		if count == 0 {
+			attachment.IsPrimary = true
+		}
+		if attachment.IsPrimary {
+			if _, err := tx.Exec("UPDATE item_attachments SET is_primary = false WHERE item_id = $1", itemID); err != nil {
+				return err
+			}
+		}
+		attachment.OrderIndex = &nextIndex
+
+		_, err := tx.Exec(`
+			INSERT INTO item_attachments (
+				id, item_id, file_type, file_url, file_name, file_size, mime_type, checksum, storage_key,
+				processing_status, title, description, is_primary, order_index, uploaded_by, uploaded_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		`, attachment.ID, attachment.ItemID, attachment.FileType, attachment.FileURL, attachment.FileName,
+			attachment.FileSize, attachment.MimeType, attachment.Checksum, attachment.StorageKey,
+			attachment.ProcessingStatus, attachment.Title, attachment.Description, attachment.IsPrimary, attachment.OrderIndex,
+			attachment.UploadedBy, attachment.UploadedAt)
+		return err
+	})
+
+	if err != nil {
+		// 数据库写入失败时清理已保存的文件
+		removeStoredFiles([]string{key})
+		c.Error(err)
+		return
+	}
+
+	// 图片在后台生成缩略图并提取EXIF
+	if attachment.FileType == "image" {
+		go processImageAttachment(attachment.ID, attachment.ItemID, key)
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success:   true,
+		Data:      attachment,
+		Message:   "文件上传成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetAttachments 获取附件列表
+func GetAttachments(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	if !checkItemAccess(c, itemID, userID, "无权查看此元素的附件") {
+		return
+	}
+
+	attachments, err := loadAttachments(itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var itemLat, itemLng *float64
+	if err := database.GetDB().QueryRow("SELECT latitude, longitude FROM travel_items WHERE id = $1", itemID).
+		Scan(&itemLat, &itemLng); err != nil {
+		c.Error(err)
+		return
+	}
+	for i := range attachments {
+		attachments[i].LocationSuggestion = photoLocationSuggestion(&attachments[i], itemLat, itemLng)
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      attachments,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateAttachment 更新附件信息（标题、描述、主附件、排序）
+func UpdateAttachment(c *gin.Context) {
+	attachmentID := c.Param("attachmentId")
+	userID := c.GetString("user_id")
+
+	attachment, ok := loadOwnedAttachment(c, attachmentID, userID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		IsPrimary   *bool   `json:"is_primary"`
+		OrderIndex  *int    `json:"order_index" binding:"omitempty,min=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		if req.IsPrimary != nil && *req.IsPrimary {
+			if _, err := tx.Exec("UPDATE item_attachments SET is_primary = false WHERE item_id = $1", attachment.ItemID); err != nil {
+				return err
+			}
+		}
+		_, err := tx.Exec(`
+			UPDATE item_attachments SET
+				title = COALESCE($2, title),
+				description = COALESCE($3, description),
+				is_primary = COALESCE($4, is_primary),
+				order_index = COALESCE($5, order_index)
+			WHERE id = $1
+		`, attachmentID, req.Title, req.Description, req.IsPrimary, req.OrderIndex)
+		if err != nil {
+			return err
+		}
+		return ensurePrimaryAttachment(tx, attachment.ItemID)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	updated, err := loadAttachment(attachmentID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      updated,
+		Message:   "附件更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteAttachment 删除附件
+func DeleteAttachment(c *gin.Context) {
+	attachmentID := c.Param("attachmentId")
+	userID := c.GetString("user_id")
+
+	attachment, ok := loadOwnedAttachment(c, attachmentID, userID)
+	if !ok {
+		return
+	}
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec("DELETE FROM item_attachments WHERE id = $1", attachmentID); err != nil {
+			return err
+		}
+		return ensurePrimaryAttachment(tx, attachment.ItemID)
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	removeStoredFiles(attachmentStorageKeys(attachment))
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "附件删除成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// checkItemAccess 检查元素存在且属于当前用户的计划，失败时写入响应
+func checkItemAccess(c *gin.Context, itemID, userID, forbiddenMessage string) bool {
+	planID, _, err := getItemPlan(itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "元素不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return false
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   forbiddenMessage,
+			Timestamp: time.Now(),
+		})
+		return false
+	}
+	return true
+}
+
+// loadOwnedAttachment 加载附件并检查所有权，失败时写入响应
+func loadOwnedAttachment(c *gin.Context, attachmentID, userID string) (*models.ItemAttachment, bool) {
+	attachment, err := loadAttachment(attachmentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "附件不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return nil, false
+	}
+
+	if !checkItemAccess(c, attachment.ItemID, userID, "无权操作此附件") {
+		return nil, false
+	}
+	return attachment, true
+}
+
+// loadAttachment 加载单个附件
+func loadAttachment(attachmentID string) (*models.ItemAttachment, error) {
+	db := database.GetDB()
+	return scanAttachment(db.QueryRow(attachmentSelect+" WHERE id = $1", attachmentID))
+}
+
+// loadAttachments 加载元素的所有附件，按排序号排列
+func loadAttachments(itemID string) ([]models.ItemAttachment, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(attachmentSelect+" WHERE item_id = $1 ORDER BY order_index NULLS LAST, uploaded_at", itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []models.ItemAttachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *attachment)
+	}
+	return attachments, rows.Err()
+}
+
+func scanAttachment(row interface{ Scan(...interface{}) error }) (*models.ItemAttachment, error) {
+	var a models.ItemAttachment
+	err := row.Scan(&a.ID, &a.ItemID, &a.FileType, &a.FileURL, &a.FileName, &a.FileSize, &a.MimeType,
+		&a.Checksum, &a.StorageKey, &a.Thumbnails, &a.ProcessingStatus, &a.TakenAt, &a.GPSLatitude, &a.GPSLongitude,
+		&a.Title, &a.Description, &a.IsPrimary, &a.OrderIndex,
+		&a.UploadedBy, &a.UploadedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ensurePrimaryAttachment 元素有附件但没有主附件时，将排序最前的附件设为主附件
+func ensurePrimaryAttachment(tx *sql.Tx, itemID string) error {
+	_, err := tx.Exec(`
+		UPDATE item_attachments SET is_primary = true
+		WHERE id = (
+			SELECT id FROM item_attachments WHERE item_id = $1
+			ORDER BY order_index NULLS LAST, uploaded_at LIMIT 1
+		)
+		AND NOT EXISTS (SELECT 1 FROM item_attachments WHERE item_id = $1 AND is_primary)
+	`, itemID)
+	return err
+}
+
+// attachmentKeys 查询即将被级联删除的附件存储路径（含缩略图），condition 为 travel_items 上的过滤条件
+func attachmentKeys(condition string, args ...interface{}) ([]string, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(`
+		SELECT a.id, a.item_id, a.file_type, a.storage_key FROM item_attachments a
+		JOIN travel_items t ON t.id = a.item_id
+		WHERE a.storage_key IS NOT NULL AND `+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var a models.ItemAttachment
+		if err := rows.Scan(&a.ID, &a.ItemID, &a.FileType, &a.StorageKey); err != nil {
+			return nil, err
+		}
+		keys = append(keys, attachmentStorageKeys(&a)...)
+	}
+	return keys, rows.Err()
+}
+
+// attachmentStorageKeys 附件在存储中的所有文件：原文件和图片缩略图
+func attachmentStorageKeys(a *models.ItemAttachment) []string {
+	if a.StorageKey == nil {
+		return nil
+	}
+	keys := []string{*a.StorageKey}
+	if a.FileType == "image" {
+		for _, size := range thumbnailSizes {
+			keys = append(keys, thumbnailKey(a.ItemID, a.ID, size.name))
+		}
+	}
+	return keys
+}
+
+// removeStoredFiles 删除存储中的文件，失败只记录日志（数据库记录已删除）
+func removeStoredFiles(keys []string) {
+	store := storage.Get()
+	if store == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := store.Delete(context.Background(), key); err != nil {
+			log.Printf("⚠️ 删除附件文件失败 %s: %v", key, err)
+		}
+	}
+}
+
+// detectMimeType 根据文件头识别MIME类型，去掉参数部分
+func detectMimeType(head []byte) string {
+	mimeType := http.DetectContentType(head)
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+	return mimeType
+}
+
+func respondFileTooLarge(c *gin.Context, maxSize int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, models.ApiResponse{
+		Success:   false,
+		Message:   fmt.Sprintf("文件大小超过限制（最大 %d 字节）", maxSize),
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	assert.Equal(t, "image/png", detectMimeType(png))
+	assert.Equal(t, "image/jpeg", detectMimeType([]byte("\xff\xd8\xff\xe0\x00\x10JFIF")))
+	assert.Equal(t, "application/pdf", detectMimeType([]byte("%PDF-1.7\n")))
+	assert.Equal(t, "text/plain", detectMimeType([]byte("行程备注")))
+
+	_, allowed := allowedAttachmentTypes[detectMimeType([]byte("MZ\x90\x00"))]
+	assert.False(t, allowed)
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// attractionColumns 允许部分更新的景点详情字段
+var attractionColumns = map[string]bool{
+	"attraction_type":          true,
+	"opening_hours":            true,
+	"ticket_price":             true,
+	"ticket_type":              true,
+	"advance_booking_required": true,
+	"best_visit_time":          true,
+	"recommended_duration":     true,
+	"difficulty_level":         true,
+	"photography_tips":         true,
+	"best_photo_spots":         true,
+	"sunrise_time":             true,
+	"sunset_time":              true,
+	"facilities":               true,
+	"accessibility_info":       true,
+}
+
+const attractionSelect = `
+	SELECT t.id, t.plan_id, t.item_type, t.name, t.description,
+		t.latitude, t.longitude, t.address,
+		t.start_datetime, t.end_datetime, t.time_zone, t.duration_hours,
+		t.cost, t.priority, t.status, t.booking_status,
+		t.properties, t.images, t.notes, t.tags,
+		t.order_index, t.group_id,
+		t.created_by, t.created_at, t.updated_at,
+		d.item_id, d.attraction_type, d.opening_hours, d.ticket_price, d.ticket_type,
+		COALESCE(d.advance_booking_required, false), d.best_visit_time, d.recommended_duration,
+		d.difficulty_level, d.photography_tips, d.best_photo_spots,
+		d.sunrise_time::text, d.sunset_time::text, d.facilities, d.accessibility_info,
+		(SELECT p.time_zone FROM plans p WHERE p.id = t.plan_id)
+	FROM travel_items t
+	LEFT JOIN attraction_details d ON d.item_id = t.id
+`
+
+// GetAttractions 获取景点列表
+func GetAttractions(c *gin.Context) {
+	planID := c.Param("planId")
+	db := database.GetDB()
+
+	rows, err := db.Query(attractionSelect+`
+		WHERE t.plan_id = $1 AND t.item_type IN ($2, $3)
+		ORDER BY COALESCE(t.start_datetime, '9999-12-31'::timestamp), t.order_index, t.created_at
+	`, planID, models.ItemTypeAttraction, models.ItemTypePhotoSpot)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	attractions := []models.AttractionItem{}
+	for rows.Next() {
+		item, err := scanAttraction(rows)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		attractions = append(attractions, *item)
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      attractions,
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateAttraction 创建景点
+func CreateAttraction(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	// 验证计划所有权
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.CreateTravelItemRequest
+	req.ItemType = models.ItemTypeAttraction
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if req.ItemType != models.ItemTypePhotoSpot {
+		req.ItemType = models.ItemTypeAttraction
+	}
+
+	if req.AttractionDetails == nil {
+		req.AttractionDetails = &models.AttractionDetails{}
+	}
+
+	if err := validateOpeningHours(req.AttractionDetails.OpeningHours); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "开放时间无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var itemID string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var err error
+		itemID, err = insertTravelItem(tx, planID, userID, &req)
+		return err
+	})
+
+	if err != nil {
+		respondItemError(c, err)
+		return
+	}
+
+	planZone, err := planTimeZoneName(database.GetDB(), planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	loc := resolveLocation(req.TimeZone, &planZone)
+	start, end := localTime(req.StartDatetime, loc), localTime(req.EndDatetime, loc)
+	warnings := checkVisitHours(req.AttractionDetails.OpeningHours, start, end)
+	if req.ItemType == models.ItemTypePhotoSpot {
+		if warning := photoLightWarning(req.Latitude, req.Longitude, start, end); warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":       itemID,
+			"warnings": warnings,
+		},
+		Message:   "景点创建成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetAttractionDetails 获取景点详情
+func GetAttractionDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+
+	item, err := loadAttraction(itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "景点不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      item,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateAttractionDetails 更新景点详情
+func UpdateAttractionDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	// 验证权限
+	planID, itemType, err := getItemPlan(itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "景点不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if itemType != models.ItemTypeAttraction && itemType != models.ItemTypePhotoSpot {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "该元素不是景点类型",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权修改此景点",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// 开放时间需要按结构校验后再写入
+	if raw, ok := req["opening_hours"]; ok && raw != nil {
+		hours, err := decodeOpeningHours(raw)
+		if err == nil {
+			err = validateOpeningHours(hours)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "开放时间无效: " + err.Error(),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		req["opening_hours"] = hours
+	}
+
+	err = database.Transaction(func(tx *sql.Tx) error {
+		if err := updateItemDetails(tx, "attraction_details", itemID, req, attractionColumns); err != nil {
+			return err
+		}
+		// 手动填写的日出日落时间不再自动重新计算
+		_, sunrise := req["sunrise_time"]
+		_, sunset := req["sunset_time"]
+		if sunrise || sunset {
+			if _, err := tx.Exec("UPDATE attraction_details SET sun_times_auto = false WHERE item_id = $1", itemID); err != nil {
+				return err
+			}
+		}
+		return refreshSunTimes(tx, itemID)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	item, err := loadAttraction(itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":       itemID,
+			"warnings": item.Warnings,
+		},
+		Message:   "景点更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteAttraction 删除景点
+func DeleteAttraction(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	// 验证权限
+	planID, itemType, err := getItemPlan(itemID)
+	if err == nil && itemType != models.ItemTypeAttraction && itemType != models.ItemTypePhotoSpot {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "景点不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权删除此景点",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	// 详情随元素级联删除
+	db := database.GetDB()
+	if _, err := db.Exec("DELETE FROM travel_items WHERE id = $1", itemID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "景点删除成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// loadAttraction 加载单个景点及详情
+func loadAttraction(itemID string) (*models.AttractionItem, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(attractionSelect+" WHERE t.id = $1 AND t.item_type IN ($2, $3)",
+		itemID, models.ItemTypeAttraction, models.ItemTypePhotoSpot)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, sql.ErrNoRows
+	}
+	return scanAttraction(rows)
+}
+
+// scanAttraction 扫描景点元素及详情，并检查游览时间是否在开放时间内
+func scanAttraction(rows *sql.Rows) (*models.AttractionItem, error) {
+	var item models.AttractionItem
+	var detailsID sql.NullString
+	var details models.AttractionDetails
+	var planZone *string
+
+	err := rows.Scan(
+		&item.ID, &item.PlanID, &item.ItemType, &item.Name, &item.Description,
+		&item.Latitude, &item.Longitude, &item.Address,
+		&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
+		&item.Cost, &item.Priority, &item.Status, &item.BookingStatus,
+		&item.Properties, pq.Array(&item.Images), &item.Notes, pq.Array(&item.Tags),
+		&item.OrderIndex, &item.GroupID,
+		&item.CreatedBy, &item.CreatedAt, &item.UpdatedAt,
+		&detailsID, &details.AttractionType, &details.OpeningHours, &details.TicketPrice, &details.TicketType,
+		&details.AdvanceBookingRequired, &details.BestVisitTime, &details.RecommendedDuration,
+		&details.DifficultyLevel, &details.PhotographyTips, &details.BestPhotoSpots,
+		&details.SunriseTime, &details.SunsetTime, &details.Facilities, &details.AccessibilityInfo,
+		&planZone,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if detailsID.Valid {
+		details.ItemID = detailsID.String
+		item.Details = &details
+		// 开放时间是景点当地时间，比较前先转换到景点所在时区
+		loc := resolveLocation(item.TimeZone, planZone)
+		start, end := localTime(item.StartDatetime, loc), localTime(item.EndDatetime, loc)
+		item.Warnings = checkVisitHours(details.OpeningHours, start, end)
+		if item.ItemType == models.ItemTypePhotoSpot {
+			if warning := photoLightWarning(item.Latitude, item.Longitude, start, end); warning != "" {
+				item.Warnings = append(item.Warnings, warning)
+			}
+		}
+	}
+
+	return &item, nil
+}
+
+// decodeOpeningHours 将请求中的任意JSON转换为开放时间结构
+func decodeOpeningHours(raw interface{}) (*models.OpeningHours, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var hours models.OpeningHours
+	if err := json.Unmarshal(data, &hours); err != nil {
+		return nil, err
+	}
+	return &hours, nil
+}
+
+// validateOpeningHours 校验开放时间的格式
+func validateOpeningHours(hours *models.OpeningHours) error {
+	if hours == nil {
+		return nil
+	}
+
+	validateWeekly := func(weekly []models.DailyHours) error {
+		for _, day := range weekly {
+			if day.Weekday < 0 || day.Weekday > 6 {
+				return fmt.Errorf("weekday 必须在 0-6 之间: %d", day.Weekday)
+			}
+			for _, r := range day.Ranges {
+				open, err := parseClock(r.Open)
+				if err != nil {
+					return err
+				}
+				closeAt, err := parseClock(r.Close)
+				if err != nil {
+					return err
+				}
+				if closeAt <= open {
+					return fmt.Errorf("关闭时间 %s 必须晚于开放时间 %s", r.Close, r.Open)
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := validateWeekly(hours.Weekly); err != nil {
+		return err
+	}
+	for _, season := range hours.Seasonal {
+		for _, md := range []string{season.StartDate, season.EndDate} {
+			if _, err := time.Parse("01-02", md); err != nil {
+				return fmt.Errorf("季节日期格式应为 MM-DD: %s", md)
+			}
+		}
+		if err := validateWeekly(season.Weekly); err != nil {
+			return err
+		}
+	}
+	for _, date := range hours.ClosedDates {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("闭园日期格式应为 YYYY-MM-DD: %s", date)
+		}
+	}
+	return nil
+}
+
+// parseClock 解析 HH:MM 为当天的分钟数，允许 24:00
+func parseClock(s string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("时间格式应为 HH:MM: %s", s)
+	}
+	return h*60 + m, nil
+}
+
+// openingRangesOn 返回某天的开放时段；known 为 false 表示没有可用的时间表
+func openingRangesOn(hours *models.OpeningHours, date time.Time) (ranges []models.TimeRange, known bool) {
+	if hours == nil {
+		return nil, false
+	}
+
+	day := date.Format("2006-01-02")
+	for _, closed := range hours.ClosedDates {
+		if closed == day {
+			return nil, true
+		}
+	}
+
+	weekly := hours.Weekly
+	md := date.Format("01-02")
+	for _, season := range hours.Seasonal {
+		inSeason := season.StartDate <= md && md <= season.EndDate
+		if season.StartDate > season.EndDate {
+			// 跨年季节，如 11-01 至 03-31
+			inSeason = md >= season.StartDate || md <= season.EndDate
+		}
+		if inSeason {
+			weekly = season.Weekly
+			break
+		}
+	}
+
+	if len(weekly) == 0 {
+		return nil, false
+	}
+	for _, d := range weekly {
+		if d.Weekday == int(date.Weekday()) {
+			ranges = append(ranges, d.Ranges...)
+		}
+	}
+	return ranges, true
+}
+
+// checkVisitHours 检查游览时间是否落在开放时段内，返回警告信息
+func checkVisitHours(hours *models.OpeningHours, start, end *time.Time) []string {
+	if start == nil {
+		return nil
+	}
+
+	ranges, known := openingRangesOn(hours, *start)
+	if !known {
+		return nil
+	}
+	if len(ranges) == 0 {
+		return []string{fmt.Sprintf("%s 当天不开放", start.Format("2006-01-02"))}
+	}
+
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := startMin
+	if end != nil {
+		endMin = startMin + int(end.Sub(*start).Minutes())
+	}
+
+	for _, r := range ranges {
+		open, err := parseClock(r.Open)
+		if err != nil {
+			continue
+		}
+		closeAt, err := parseClock(r.Close)
+		if err != nil {
+			continue
+		}
+		if startMin >= open && endMin <= closeAt {
+			return nil
+		}
+	}
+
+	return []string{fmt.Sprintf("游览时间 %s 不在开放时间内", start.Format("2006-01-02 15:04"))}
+}
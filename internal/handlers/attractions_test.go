@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVisitHours(t *testing.T) {
+	daily := []models.TimeRange{{Open: "08:00", Close: "17:30"}}
+	hours := &models.OpeningHours{
+		Weekly: []models.DailyHours{
+			{Weekday: 1, Ranges: daily}, {Weekday: 2, Ranges: daily}, {Weekday: 3, Ranges: daily},
+			{Weekday: 4, Ranges: daily}, {Weekday: 5, Ranges: daily}, {Weekday: 6, Ranges: daily},
+		},
+		Seasonal: []models.SeasonalHours{{
+			Name:      "冬季",
+			StartDate: "11-01",
+			EndDate:   "03-31",
+			Weekly: []models.DailyHours{
+				{Weekday: 6, Ranges: []models.TimeRange{{Open: "09:00", Close: "16:00"}}},
+			},
+		}},
+		ClosedDates: []string{"2025-10-01"},
+	}
+
+	at := func(s string) *time.Time {
+		v, _ := time.Parse("2006-01-02 15:04", s)
+		return &v
+	}
+
+	t.Run("开放时间内", func(t *testing.T) {
+		assert.Empty(t, checkVisitHours(hours, at("2025-09-10 09:00"), at("2025-09-10 12:00")))
+	})
+
+	t.Run("结束时间超出关闭时间", func(t *testing.T) {
+		assert.Len(t, checkVisitHours(hours, at("2025-09-10 16:00"), at("2025-09-10 18:00")), 1)
+	})
+
+	t.Run("星期日不开放", func(t *testing.T) {
+		assert.Len(t, checkVisitHours(hours, at("2025-09-14 10:00"), nil), 1)
+	})
+
+	t.Run("闭园日期", func(t *testing.T) {
+		assert.Len(t, checkVisitHours(hours, at("2025-10-01 10:00"), nil), 1)
+	})
+
+	t.Run("跨年季节时间表", func(t *testing.T) {
+		assert.Len(t, checkVisitHours(hours, at("2026-01-03 08:30"), nil), 1)
+		assert.Empty(t, checkVisitHours(hours, at("2026-01-03 09:30"), at("2026-01-03 15:00")))
+		assert.Len(t, checkVisitHours(hours, at("2026-01-05 10:00"), nil), 1)
+	})
+
+	t.Run("没有时间表", func(t *testing.T) {
+		assert.Empty(t, checkVisitHours(nil, at("2025-09-10 03:00"), nil))
+	})
+}
+
+func TestValidateOpeningHours(t *testing.T) {
+	assert.NoError(t, validateOpeningHours(nil))
+	assert.NoError(t, validateOpeningHours(&models.OpeningHours{
+		Weekly: []models.DailyHours{{Weekday: 0, Ranges: []models.TimeRange{{Open: "00:00", Close: "24:00"}}}},
+	}))
+	assert.Error(t, validateOpeningHours(&models.OpeningHours{
+		Weekly: []models.DailyHours{{Weekday: 7}},
+	}))
+	assert.Error(t, validateOpeningHours(&models.OpeningHours{
+		Weekly: []models.DailyHours{{Weekday: 1, Ranges: []models.TimeRange{{Open: "18:00", Close: "08:00"}}}},
+	}))
+	assert.Error(t, validateOpeningHours(&models.OpeningHours{ClosedDates: []string{"10/01"}}))
+}
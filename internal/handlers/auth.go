@@ -2,15 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
-	"planner/internal/config"
+	"planner/internal/auth"
 	"planner/internal/database"
 	"planner/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -79,12 +80,18 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// 验证邮件发送失败不影响注册，用户可以稍后重新发送
+	if err := sendVerificationEmail(c, userID, req.Username, req.Email); err != nil {
+		log.Printf("⚠️ 签发邮箱验证令牌失败: %v", err)
+	}
+
 	c.JSON(http.StatusCreated, models.ApiResponse{
 		Success: true,
 		Data: map[string]interface{}{
-			"id":       userID,
-			"username": req.Username,
-			"email":    req.Email,
+			"id":             userID,
+			"username":       req.Username,
+			"email":          req.Email,
+			"email_verified": false,
 		},
 		Message:   "注册成功",
 		Timestamp: time.Now(),
@@ -108,9 +115,10 @@ func Login(c *gin.Context) {
 	// 查询用户
 	var user models.User
 	err := db.QueryRow(`
-		SELECT id, username, email, password_hash, is_active 
+		SELECT id, username, email, password_hash, is_active, token_version, totp_enabled_at
 		FROM users WHERE username = $1
-	`, req.Username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive)
+	`, req.Username).Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.IsActive, &user.TokenVersion,
+		&user.TOTPEnabledAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -145,19 +153,32 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 启用了两步验证时只返回挑战令牌，验证码通过后才签发访问令牌
+	if user.TOTPEnabledAt != nil {
+		respondMFAChallenge(c, user.ID, req.DeviceLabel)
+		return
+	}
+
+	completeLogin(c, &user, req.DeviceLabel, false)
+}
+
+// completeLogin 身份验证通过后创建会话并签发令牌。
+// 每次登录创建一个会话，会话内轮换的刷新令牌属于同一个 family
+func completeLogin(c *gin.Context, user *models.User, deviceLabel string, mfaVerified bool) {
 	// 更新最后登录时间
-	_, _ = db.Exec("UPDATE users SET last_login_at = $1 WHERE id = $2", time.Now(), user.ID)
-
-	// 生成JWT
-	cfg := config.Get()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  user.ID,
-		"username": user.Username,
-		"email":    user.Email,
-		"exp":      time.Now().Add(7 * 24 * time.Hour).Unix(),
-	})
+	_, _ = database.GetDB().Exec("UPDATE users SET last_login_at = $1 WHERE id = $2", time.Now(), user.ID)
 
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	var tokens *models.AuthTokens
+	var sessionID string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var err error
+		sessionID, err = createSession(tx, user.ID, deviceLabel, c.Request.UserAgent(), c.ClientIP(), mfaVerified)
+		if err != nil {
+			return err
+		}
+		tokens, _, err = issueTokens(tx, user, sessionID)
+		return err
+	})
 	if err != nil {
 		c.Error(err)
 		return
@@ -166,7 +187,11 @@ func Login(c *gin.Context) {
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success: true,
 		Data: map[string]interface{}{
-			"token": tokenString,
+			"token":         tokens.Token,
+			"refresh_token": tokens.RefreshToken,
+			"token_type":    tokens.TokenType,
+			"expires_in":    tokens.ExpiresIn,
+			"session_id":    sessionID,
 			"user": map[string]interface{}{
 				"id":       user.ID,
 				"username": user.Username,
@@ -178,9 +203,28 @@ func Login(c *gin.Context) {
 	})
 }
 
-// Logout 用户登出
+// Logout 用户登出：当前访问令牌立即失效，并撤销当前会话及其全部刷新令牌
 func Logout(c *gin.Context) {
-	// 在实际应用中，这里可以将token加入黑名单
+	userID := c.GetString("user_id")
+	claims := c.MustGet("token_claims").(*auth.AccessClaims)
+
+	if err := auth.RevokeAccessToken(c.Request.Context(), claims); err != nil {
+		c.Error(err)
+		return
+	}
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		err := revokeSession(tx, userID, claims.SessionID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success:   true,
 		Message:   "登出成功",
@@ -188,36 +232,187 @@ func Logout(c *gin.Context) {
 	})
 }
 
-// RefreshToken 刷新令牌
+// errInvalidRefreshToken 刷新令牌不存在、已撤销或已过期
+var errInvalidRefreshToken = errors.New("刷新令牌无效或已过期")
+
+// RefreshToken 用刷新令牌换取新的访问令牌和刷新令牌。
+// 刷新令牌只能使用一次，已使用过的令牌再次出现视为泄露，撤销其所属会话
 func RefreshToken(c *gin.Context) {
-	// 获取当前用户信息
-	userID := c.GetString("user_id")
-	username := c.GetString("username")
-
-	// 生成新的JWT
-	cfg := config.Get()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":  userID,
-		"username": username,
-		"exp":      time.Now().Add(7 * 24 * time.Hour).Unix(),
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var tokens *models.AuthTokens
+	reused := false
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var id, userID, familyID string
+		var expiresAt time.Time
+		var usedAt, revokedAt sql.NullTime
+		err := tx.QueryRow(`
+			SELECT id, user_id, family_id, expires_at, used_at, revoked_at
+			FROM refresh_tokens WHERE token_hash = $1
+			FOR UPDATE
+		`, auth.HashOpaqueToken(req.RefreshToken)).Scan(&id, &userID, &familyID, &expiresAt, &usedAt, &revokedAt)
+		if err == sql.ErrNoRows {
+			return errInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if usedAt.Valid {
+			reused = true
+			if err := revokeSession(tx, userID, familyID); err != sql.ErrNoRows {
+				return err
+			}
+			return nil
+		}
+		if revokedAt.Valid || time.Now().After(expiresAt) {
+			return errInvalidRefreshToken
+		}
+
+		// 会话已撤销（或是会话功能上线前签发的令牌）时需要重新登录
+		var sessionActive bool
+		err = tx.QueryRow("SELECT revoked_at IS NULL FROM user_sessions WHERE id = $1", familyID).Scan(&sessionActive)
+		if err == sql.ErrNoRows || (err == nil && !sessionActive) {
+			return errInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		err = tx.QueryRow("SELECT id, username, email, is_active, token_version FROM users WHERE id = $1", userID).
+			Scan(&user.ID, &user.Username, &user.Email, &user.IsActive, &user.TokenVersion)
+		if err == sql.ErrNoRows || (err == nil && !user.IsActive) {
+			return errInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		var nextID string
+		tokens, nextID, err = issueTokens(tx, &user, familyID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE refresh_tokens SET used_at = NOW(), replaced_by = $1 WHERE id = $2", nextID, id)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE user_sessions SET last_seen_at = NOW(), ip_address = $1 WHERE id = $2",
+			c.ClientIP(), familyID)
+		return err
 	})
 
-	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
+	if reused {
+		log.Printf("⚠️ 检测到刷新令牌重复使用，已撤销该登录的全部令牌")
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Success:   false,
+			Message:   "刷新令牌已被使用，请重新登录",
+			Timestamp: time.Now(),
+		})
+		return
+	}
 	if err != nil {
-		c.Error(err)
+		if err == errInvalidRefreshToken {
+			c.JSON(http.StatusUnauthorized, models.ApiResponse{
+				Success:   false,
+				Message:   err.Error(),
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
 		return
 	}
 
 	c.JSON(http.StatusOK, models.ApiResponse{
-		Success: true,
-		Data: map[string]interface{}{
-			"token": tokenString,
-		},
+		Success:   true,
+		Data:      tokens,
 		Message:   "令牌刷新成功",
 		Timestamp: time.Now(),
 	})
 }
 
+// RevokeUserSessions 管理员撤销用户的全部会话：已签发的访问令牌和刷新令牌立即失效
+func RevokeUserSessions(c *gin.Context) {
+	userID := c.Param("userId")
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		return revokeUserTokens(tx, userID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "用户不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "已撤销该用户的全部会话",
+		Timestamp: time.Now(),
+	})
+}
+
+// issueTokens 为会话签发访问令牌并保存新的刷新令牌哈希，返回令牌和刷新令牌记录ID
+func issueTokens(tx *sql.Tx, user *models.User, sessionID string) (*models.AuthTokens, string, error) {
+	accessToken, _, err := auth.IssueAccessToken(user.ID, user.Username, user.Email, user.TokenVersion, sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshID := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, refreshID, user.ID, sessionID, hash, time.Now().Add(auth.RefreshTokenTTL()), time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &models.AuthTokens{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(auth.AccessTokenTTL().Seconds()),
+	}, refreshID, nil
+}
+
+// revokeUserTokens 递增令牌版本使访问令牌失效，并撤销用户的全部会话和刷新令牌；用户不存在时返回 sql.ErrNoRows
+func revokeUserTokens(tx *sql.Tx, userID string) error {
+	result, err := tx.Exec("UPDATE users SET token_version = token_version + 1 WHERE id = $1", userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = tx.Exec("UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", userID)
+	return err
+}
+
 // GetProfile 获取用户资料
 func GetProfile(c *gin.Context) {
 	userID := c.GetString("user_id")
@@ -225,10 +420,11 @@ func GetProfile(c *gin.Context) {
 
 	var user models.User
 	err := db.QueryRow(`
-		SELECT id, username, email, avatar_url, bio, is_active, created_at, updated_at, last_login_at
+		SELECT id, username, email, avatar_url, bio, is_active, created_at, updated_at, last_login_at,
+			email_verified_at, totp_enabled_at
 		FROM users WHERE id = $1
 	`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.AvatarURL, &user.Bio,
-		&user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt)
+		&user.IsActive, &user.CreatedAt, &user.UpdatedAt, &user.LastLoginAt, &user.EmailVerifiedAt, &user.TOTPEnabledAt)
 
 	if err != nil {
 		c.Error(err)
@@ -273,14 +469,26 @@ func UpdateProfile(c *gin.Context) {
 		delete(req, "password")
 	}
 
-	// 更新其他字段
+	// 更新其他字段，邮箱变更后需要重新验证
 	if email, ok := req["email"].(string); ok {
-		_, err := db.Exec("UPDATE users SET email = $1, updated_at = $2 WHERE id = $3",
-			email, time.Now(), userID)
+		var username, oldEmail string
+		err := db.QueryRow("SELECT username, email FROM users WHERE id = $1", userID).Scan(&username, &oldEmail)
 		if err != nil {
 			c.Error(err)
 			return
 		}
+		if email != oldEmail {
+			_, err := db.Exec("UPDATE users SET email = $1, email_verified_at = NULL, updated_at = $2 WHERE id = $3",
+				email, time.Now(), userID)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			if err := sendVerificationEmail(c, userID, username, email); err != nil {
+				c.Error(err)
+				return
+			}
+		}
 	}
 
 	if avatarURL, ok := req["avatar_url"].(string); ok {
@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// 单次批量操作的最大项目数
+const maxBatchItems = 500
+
+// errBatchAborted 全部成功模式下某一项失败，用于回滚事务
+var errBatchAborted = errors.New("批量操作已中止")
+
+// batchOp 批量操作中的一项：err 为校验阶段发现的错误，apply 在事务内执行并返回元素ID
+type batchOp struct {
+	id    string
+	err   string
+	apply func(tx *sql.Tx) (string, error)
+}
+
+// BatchCreateItems 批量创建元素
+func BatchCreateItems(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.BatchCreateItemsRequest
+	if !bindBatchRequest(c, &req, &req.Mode, func() int { return len(req.Items) }) {
+		return
+	}
+
+	canAccess := planAccessChecker(userID)
+	ops := make([]batchOp, len(req.Items))
+	for i := range req.Items {
+		item := &req.Items[i]
+		planID := item.PlanID
+		if planID == "" {
+			planID = req.PlanID
+		}
+
+		switch {
+		case planID == "":
+			ops[i].err = "缺少 plan_id"
+		case !canAccess(planID):
+			ops[i].err = "无权操作此计划"
+		default:
+			if err := binding.Validator.ValidateStruct(&item.CreateTravelItemRequest); err != nil {
+				ops[i].err = "请求参数无效: " + err.Error()
+				continue
+			}
+			ops[i].apply = func(tx *sql.Tx) (string, error) {
+				return insertTravelItem(tx, planID, userID, &item.CreateTravelItemRequest)
+			}
+		}
+	}
+
+	respondBatch(c, req.Mode, ops, nil)
+}
+
+// BatchUpdateItems 批量更新元素
+func BatchUpdateItems(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.BatchUpdateItemsRequest
+	if !bindBatchRequest(c, &req, &req.Mode, func() int { return len(req.Items) }) {
+		return
+	}
+
+	canAccess := planAccessChecker(userID)
+	ops := make([]batchOp, len(req.Items))
+	for i := range req.Items {
+		item := &req.Items[i]
+		ops[i].id = item.ID
+		if ops[i].err = checkBatchItem(item.ID, canAccess); ops[i].err != "" {
+			continue
+		}
+		ops[i].apply = func(tx *sql.Tx) (string, error) {
+			return item.ID, updateTravelItem(tx, item.ID, userID, &item.UpdateTravelItemRequest)
+		}
+	}
+
+	respondBatch(c, req.Mode, ops, nil)
+}
+
+// BatchDeleteItems 批量删除元素
+func BatchDeleteItems(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.BatchDeleteItemsRequest
+	if !bindBatchRequest(c, &req, &req.Mode, func() int { return len(req.IDs) }) {
+		return
+	}
+
+	canAccess := planAccessChecker(userID)
+	ops := make([]batchOp, len(req.IDs))
+	keys := make([][]string, len(req.IDs))
+	for i, id := range req.IDs {
+		ops[i].id = id
+		if ops[i].err = checkBatchItem(id, canAccess); ops[i].err != "" {
+			continue
+		}
+
+		var err error
+		if keys[i], err = attachmentKeys("t.id = $1", id); err != nil {
+			c.Error(err)
+			return
+		}
+		ops[i].apply = func(tx *sql.Tx) (string, error) {
+			result, err := tx.Exec("DELETE FROM travel_items WHERE id = $1", id)
+			if err != nil {
+				return id, err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return id, &invalidInputError{msg: "元素不存在"}
+			}
+			return id, nil
+		}
+	}
+
+	respondBatch(c, req.Mode, ops, func(result models.BatchResult) {
+		for _, r := range result.Results {
+			if r.Success {
+				removeStoredFiles(keys[r.Index])
+			}
+		}
+	})
+}
+
+// bindBatchRequest 绑定批量请求并校验模式和数量，失败时写入响应
+func bindBatchRequest(c *gin.Context, req interface{}, mode *string, count func() int) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return false
+	}
+
+	if *mode == "" {
+		*mode = models.BatchModeAtomic
+	}
+	if *mode != models.BatchModeAtomic && *mode != models.BatchModePartial {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "无效的批量模式: " + *mode,
+			Timestamp: time.Now(),
+		})
+		return false
+	}
+
+	if count() > maxBatchItems {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   fmt.Sprintf("单次最多处理 %d 个元素", maxBatchItems),
+			Timestamp: time.Now(),
+		})
+		return false
+	}
+	return true
+}
+
+// planAccessChecker 返回带缓存的计划所有权检查函数
+func planAccessChecker(userID string) func(planID string) bool {
+	cache := make(map[string]bool)
+	return func(planID string) bool {
+		allowed, ok := cache[planID]
+		if !ok {
+			allowed = verifyPlanOwnership(planID, userID)
+			cache[planID] = allowed
+		}
+		return allowed
+	}
+}
+
+// checkBatchItem 检查元素存在且属于当前用户的计划，返回错误信息
+func checkBatchItem(itemID string, canAccess func(string) bool) string {
+	if itemID == "" {
+		return "缺少元素ID"
+	}
+	planID, _, err := getItemPlan(itemID)
+	if err != nil {
+		return "元素不存在"
+	}
+	if !canAccess(planID) {
+		return "无权操作此元素"
+	}
+	return ""
+}
+
+// respondBatch 执行批量操作并写入响应，onCommit 在事务提交后调用
+func respondBatch(c *gin.Context, mode string, ops []batchOp, onCommit func(models.BatchResult)) {
+	result, err := runBatch(mode, ops)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !result.Committed {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Data:      result,
+			Message:   "批量操作失败，所有更改均未生效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if onCommit != nil {
+		onCommit(result)
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      result,
+		Message:   fmt.Sprintf("批量操作完成：成功 %d 项，失败 %d 项", result.Succeeded, result.Failed),
+		Timestamp: time.Now(),
+	})
+}
+
+// runBatch 在一个事务中执行批量操作。
+// atomic 模式下任一项校验或执行失败都会回滚全部；partial 模式下每项使用保存点，失败只回滚该项。
+func runBatch(mode string, ops []batchOp) (models.BatchResult, error) {
+	result := models.BatchResult{
+		Mode:    mode,
+		Total:   len(ops),
+		Results: make([]models.BatchItemResult, len(ops)),
+	}
+
+	invalid := 0
+	for i, op := range ops {
+		result.Results[i] = models.BatchItemResult{Index: i, ID: op.id, Error: op.err}
+		if op.err != "" {
+			invalid++
+		}
+	}
+
+	if mode == models.BatchModeAtomic && invalid > 0 {
+		for i := range result.Results {
+			if result.Results[i].Error == "" {
+				result.Results[i].Error = "其他项目校验失败，未执行"
+			}
+		}
+		return finishBatch(result), nil
+	}
+
+	failedIndex := -1
+	var failedErr error
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		for i, op := range ops {
+			if op.err != "" {
+				continue
+			}
+
+			if mode == models.BatchModePartial {
+				if _, err := tx.Exec("SAVEPOINT batch_item"); err != nil {
+					return err
+				}
+			}
+
+			id, err := op.apply(tx)
+			if err != nil {
+				if mode == models.BatchModeAtomic {
+					failedIndex, failedErr = i, err
+					return errBatchAborted
+				}
+				if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT batch_item"); rbErr != nil {
+					return rbErr
+				}
+				result.Results[i].Error = batchErrorMessage(i, err)
+				continue
+			}
+
+			if mode == models.BatchModePartial {
+				if _, err := tx.Exec("RELEASE SAVEPOINT batch_item"); err != nil {
+					return err
+				}
+			}
+			result.Results[i].ID = id
+			result.Results[i].Success = true
+		}
+		return nil
+	})
+
+	if errors.Is(err, errBatchAborted) {
+		for i := range result.Results {
+			result.Results[i].ID = ops[i].id
+			result.Results[i].Success = false
+			if i == failedIndex {
+				result.Results[i].Error = batchErrorMessage(i, failedErr)
+			} else {
+				result.Results[i].Error = "事务已回滚"
+			}
+		}
+		return finishBatch(result), nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	result.Committed = true
+	return finishBatch(result), nil
+}
+
+// batchErrorMessage 返回单项失败时给用户看的信息：校验错误原样返回，
+// 其他错误（如数据库错误）只记录日志，避免泄露表结构
+func batchErrorMessage(index int, err error) string {
+	var statusErr *itemStatusError
+	var inputErr *invalidInputError
+	if errors.As(err, &statusErr) || errors.As(err, &inputErr) {
+		return err.Error()
+	}
+	log.Printf("⚠️ 批量操作第 %d 项失败: %v", index, err)
+	return "操作失败"
+}
+
+// finishBatch 统计成功和失败数量
+func finishBatch(result models.BatchResult) models.BatchResult {
+	result.Succeeded, result.Failed = 0, 0
+	for _, r := range result.Results {
+		if r.Success {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunBatchAtomicValidationFailure(t *testing.T) {
+	applied := false
+	ops := []batchOp{
+		{id: "a", apply: func(tx *sql.Tx) (string, error) { applied = true; return "a", nil }},
+		{id: "b", err: "无权操作此元素"},
+	}
+
+	result, err := runBatch(models.BatchModeAtomic, ops)
+
+	assert.NoError(t, err)
+	assert.False(t, applied)
+	assert.False(t, result.Committed)
+	assert.Equal(t, 2, result.Total)
+	assert.Equal(t, 0, result.Succeeded)
+	assert.Equal(t, 2, result.Failed)
+	assert.Equal(t, []models.BatchItemResult{
+		{Index: 0, ID: "a", Error: "其他项目校验失败，未执行"},
+		{Index: 1, ID: "b", Error: "无权操作此元素"},
+	}, result.Results)
+}
+
+func TestFinishBatch(t *testing.T) {
+	result := finishBatch(models.BatchResult{Results: []models.BatchItemResult{
+		{Index: 0, Success: true},
+		{Index: 1, Error: "元素不存在"},
+		{Index: 2, Success: true},
+	}})
+	assert.Equal(t, 2, result.Succeeded)
+	assert.Equal(t, 1, result.Failed)
+}
+
+func TestBatchErrorMessage(t *testing.T) {
+	assert.Equal(t, "元素不存在", batchErrorMessage(0, &invalidInputError{msg: "元素不存在"}))
+	assert.Equal(t, "无效的状态: x", batchErrorMessage(1, &itemStatusError{msg: "无效的状态: x"}))
+	assert.Equal(t, "操作失败", batchErrorMessage(2, errors.New(`pq: null value in column "title" violates not-null constraint`)))
+}
@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// budgetColumns 允许部分更新的预算字段
+var budgetColumns = map[string]bool{
+	"item_id":          true,
+	"category":         true,
+	"description":      true,
+	"estimated_amount": true,
+	"actual_amount":    true,
+	"currency":         true,
+	"payment_method":   true,
+	"payment_status":   true,
+	"payment_date":     true,
+	"notes":            true,
+	"receipt_url":      true,
+	"paid_by":          true,
+	"split_type":       true,
+	"split_details":    true,
+}
+
+var paymentStatuses = map[string]bool{
+	models.PaymentStatusPending:  true,
+	models.PaymentStatusPartial:  true,
+	models.PaymentStatusPaid:     true,
+	models.PaymentStatusRefunded: true,
+}
+
+const budgetItemSelect = `
+	SELECT id, plan_id, item_id, category, description,
+		estimated_amount, actual_amount, COALESCE(currency, 'CNY'),
+		payment_method, COALESCE(payment_status, 'pending'), payment_date,
+		notes, receipt_url, paid_by, split_type, split_details, created_at
+	FROM budget_items
+`
+
+// GetBudgetSummary 获取预算摘要
+func GetBudgetSummary(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划的预算",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+
+	var budget sql.NullFloat64
+	var baseCurrency string
+	err := db.QueryRow("SELECT budget, base_currency FROM plans WHERE id = $1", planID).Scan(&budget, &baseCurrency)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	items, err := loadBudgetItems(planID, "", "")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	unbudgeted, err := loadUnbudgetedItems(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	book, err := loadRateBook()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// 计划预算以基准货币计，所有预算项目先换算为基准货币
+	converted, applied, missing := convertBudgetItems(items, baseCurrency, book)
+
+	summary := summarizeBudget(budget.Float64, converted, unbudgeted)
+	summary.PlanID = planID
+	summary.BaseCurrency = baseCurrency
+	summary.AppliedRates = applied
+	summary.MissingRates = missing
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      summary,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddBudgetItem 添加预算项目
+func AddBudgetItem(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.CreateBudgetItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	item := models.BudgetItem{
+		ID:              uuid.New().String(),
+		PlanID:          planID,
+		ItemID:          req.ItemID,
+		Category:        req.Category,
+		Description:     req.Description,
+		EstimatedAmount: req.EstimatedAmount,
+		ActualAmount:    req.ActualAmount,
+		Currency:        "CNY",
+		PaymentMethod:   req.PaymentMethod,
+		PaymentStatus:   models.PaymentStatusPending,
+		PaymentDate:     req.PaymentDate,
+		Notes:           req.Notes,
+		ReceiptURL:      req.ReceiptURL,
+		PaidBy:          req.PaidBy,
+		SplitType:       models.SplitTypeEqual,
+		SplitDetails:    req.SplitDetails,
+		CreatedAt:       time.Now(),
+	}
+	if item.PaidBy != nil && *item.PaidBy == "" {
+		// 空字符串表示未指定付款人
+		item.PaidBy = nil
+	}
+	if req.SplitType != nil {
+		item.SplitType = *req.SplitType
+	}
+	if req.Currency != nil && *req.Currency != "" {
+		item.Currency = strings.ToUpper(*req.Currency)
+	}
+	if req.PaymentStatus != nil {
+		item.PaymentStatus = *req.PaymentStatus
+	}
+
+	if msg := validateBudgetItem(planID, &item); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   msg,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+	_, err := db.Exec(`
+		INSERT INTO budget_items (
+			id, plan_id, item_id, category, description,
+			estimated_amount, actual_amount, currency,
+			payment_method, payment_status, payment_date,
+			notes, receipt_url, paid_by, split_type, split_details, created_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+	`, item.ID, item.PlanID, item.ItemID, item.Category, item.Description,
+		item.EstimatedAmount, item.ActualAmount, item.Currency,
+		item.PaymentMethod, item.PaymentStatus, item.PaymentDate,
+		item.Notes, item.ReceiptURL, item.PaidBy, item.SplitType, item.SplitDetails, item.CreatedAt)
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success:   true,
+		Data:      item,
+		Message:   "预算项目添加成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetBudgetItems 获取预算项目列表
+func GetBudgetItems(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划的预算",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	items, err := loadBudgetItems(planID, c.Query("category"), c.Query("payment_status"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      items,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateBudgetItem 更新预算项目
+func UpdateBudgetItem(c *gin.Context) {
+	budgetItemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	db := database.GetDB()
+
+	// 验证权限
+	var planID string
+	err := db.QueryRow("SELECT plan_id FROM budget_items WHERE id = $1", budgetItemID).Scan(&planID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "预算项目不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权修改此预算项目",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	existing, err := loadBudgetItem(budgetItemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if v, ok := req["currency"].(string); ok {
+		req["currency"] = strings.ToUpper(v)
+	}
+	// 空字符串表示清除付款人
+	if v, ok := req["paid_by"].(string); ok && v == "" {
+		req["paid_by"] = nil
+	}
+
+	// 将更新合并到现有记录上，按完整记录校验
+	merged, err := mergeBudgetItem(existing, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if msg := validateBudgetItem(planID, merged); msg != "" {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   msg,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if _, ok := req["split_details"]; ok {
+		req["split_details"] = merged.SplitDetails
+	}
+
+	// 构建更新语句
+	query := "UPDATE budget_items SET id = id"
+	args := []interface{}{}
+	argIndex := 1
+
+	for key, value := range req {
+		if budgetColumns[key] {
+			query += fmt.Sprintf(", %s = $%d", key, argIndex)
+			args = append(args, value)
+			argIndex++
+		}
+	}
+
+	if len(args) > 0 {
+		query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+		args = append(args, budgetItemID)
+
+		if _, err := db.Exec(query, args...); err != nil {
+			c.Error(err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "预算项目更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// DeleteBudgetItem 删除预算项目
+func DeleteBudgetItem(c *gin.Context) {
+	budgetItemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	db := database.GetDB()
+
+	// 验证权限
+	var planID string
+	err := db.QueryRow("SELECT plan_id FROM budget_items WHERE id = $1", budgetItemID).Scan(&planID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "预算项目不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权删除此预算项目",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if _, err := db.Exec("DELETE FROM budget_items WHERE id = $1", budgetItemID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "预算项目删除成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// validateBudgetItem 校验货币、支付状态、关联元素、付款人和分摊规则，返回错误信息
+func validateBudgetItem(planID string, item *models.BudgetItem) string {
+	if !isCurrencyCode(item.Currency) {
+		return "无效的货币代码: " + item.Currency
+	}
+	if !paymentStatuses[item.PaymentStatus] {
+		return "无效的支付状态: " + item.PaymentStatus
+	}
+
+	if item.ItemID != nil && *item.ItemID != "" {
+		itemPlanID, _, err := getItemPlan(*item.ItemID)
+		if err != nil || itemPlanID != planID {
+			return "关联的元素不属于此计划"
+		}
+	}
+
+	if item.PaidBy == nil && len(item.SplitDetails) == 0 && item.SplitType == models.SplitTypeEqual {
+		return ""
+	}
+
+	participants, err := loadParticipants(planID)
+	if err != nil {
+		return "加载参与者失败"
+	}
+	if err := validateSplit(item, participants); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// loadBudgetItem 加载单个预算项目
+func loadBudgetItem(budgetItemID string) (*models.BudgetItem, error) {
+	db := database.GetDB()
+
+	var item models.BudgetItem
+	err := db.QueryRow(budgetItemSelect+" WHERE id = $1", budgetItemID).Scan(
+		&item.ID, &item.PlanID, &item.ItemID, &item.Category, &item.Description,
+		&item.EstimatedAmount, &item.ActualAmount, &item.Currency,
+		&item.PaymentMethod, &item.PaymentStatus, &item.PaymentDate,
+		&item.Notes, &item.ReceiptURL, &item.PaidBy, &item.SplitType, &item.SplitDetails,
+		&item.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// mergeBudgetItem 将部分更新合并到预算项目副本上
+func mergeBudgetItem(existing *models.BudgetItem, updates map[string]interface{}) (*models.BudgetItem, error) {
+	data, err := json.Marshal(existing)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range updates {
+		if budgetColumns[key] {
+			fields[key] = value
+		}
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var merged models.BudgetItem
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	return &merged, nil
+}
+
+// loadBudgetItems 加载计划的预算项目，可按分类和支付状态过滤
+func loadBudgetItems(planID, category, paymentStatus string) ([]models.BudgetItem, error) {
+	db := database.GetDB()
+
+	query := budgetItemSelect + " WHERE plan_id = $1"
+	args := []interface{}{planID}
+	argIndex := 2
+
+	if category != "" {
+		query += fmt.Sprintf(" AND category = $%d", argIndex)
+		args = append(args, category)
+		argIndex++
+	}
+	if paymentStatus != "" {
+		query += fmt.Sprintf(" AND payment_status = $%d", argIndex)
+		args = append(args, paymentStatus)
+		argIndex++
+	}
+	query += " ORDER BY created_at"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.BudgetItem{}
+	for rows.Next() {
+		var item models.BudgetItem
+		err := rows.Scan(
+			&item.ID, &item.PlanID, &item.ItemID, &item.Category, &item.Description,
+			&item.EstimatedAmount, &item.ActualAmount, &item.Currency,
+			&item.PaymentMethod, &item.PaymentStatus, &item.PaymentDate,
+			&item.Notes, &item.ReceiptURL, &item.PaidBy, &item.SplitType, &item.SplitDetails,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// loadUnbudgetedItems 加载有费用但没有关联预算项目的元素
+func loadUnbudgetedItems(planID string) ([]models.UnbudgetedItem, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(`
+		SELECT t.id, t.name, t.item_type, t.cost
+		FROM travel_items t
+		WHERE t.plan_id = $1 AND t.cost IS NOT NULL AND t.cost > 0
+			AND NOT EXISTS (SELECT 1 FROM budget_items b WHERE b.item_id = t.id)
+		ORDER BY COALESCE(t.start_datetime, '9999-12-31'::timestamp), t.created_at
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.UnbudgetedItem{}
+	for rows.Next() {
+		var item models.UnbudgetedItem
+		if err := rows.Scan(&item.ItemID, &item.Name, &item.ItemType, &item.Cost); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// summarizeBudget 汇总预算：总额对比、按分类和支付状态分组
+func summarizeBudget(budget float64, items []models.BudgetItem, unbudgeted []models.UnbudgetedItem) models.BudgetSummary {
+	summary := models.BudgetSummary{
+		TotalBudget:     budget,
+		UnbudgetedItems: unbudgeted,
+	}
+
+	byCategory := make(map[string]*models.BudgetBreakdown)
+	byStatus := make(map[string]*models.BudgetBreakdown)
+	add := func(groups map[string]*models.BudgetBreakdown, key string, estimated, actual float64) {
+		group, ok := groups[key]
+		if !ok {
+			group = &models.BudgetBreakdown{Key: key}
+			groups[key] = group
+		}
+		group.Count++
+		group.Estimated += estimated
+		group.Actual += actual
+	}
+
+	for _, item := range items {
+		var estimated, actual float64
+		if item.EstimatedAmount != nil {
+			estimated = *item.EstimatedAmount
+		}
+		if item.ActualAmount != nil {
+			actual = *item.ActualAmount
+		}
+
+		summary.TotalEstimated += estimated
+		summary.TotalActual += actual
+		add(byCategory, item.Category, estimated, actual)
+		add(byStatus, item.PaymentStatus, estimated, actual)
+	}
+
+	for _, item := range unbudgeted {
+		summary.UnbudgetedTotal += item.Cost
+	}
+
+	summary.Remaining = budget - summary.TotalActual
+	summary.EstimatedRemaining = budget - summary.TotalEstimated
+	summary.OverBudget = budget > 0 && (summary.TotalActual > budget || summary.TotalEstimated > budget)
+	summary.ByCategory = sortedBreakdown(byCategory)
+	summary.ByPaymentStatus = sortedBreakdown(byStatus)
+
+	return summary
+}
+
+// sortedBreakdown 按键排序输出分组结果
+func sortedBreakdown(groups map[string]*models.BudgetBreakdown) []models.BudgetBreakdown {
+	result := make([]models.BudgetBreakdown, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, *group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"testing"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarizeBudget(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+
+	items := []models.BudgetItem{
+		{Category: "住宿", PaymentStatus: models.PaymentStatusPaid, EstimatedAmount: amount(800), ActualAmount: amount(760)},
+		{Category: "交通", PaymentStatus: models.PaymentStatusPending, EstimatedAmount: amount(1200)},
+		{Category: "住宿", PaymentStatus: models.PaymentStatusPending, EstimatedAmount: amount(400)},
+	}
+	unbudgeted := []models.UnbudgetedItem{{ItemID: "x", Name: "亚丁景区门票", Cost: 146}}
+
+	summary := summarizeBudget(2000, items, unbudgeted)
+
+	assert.InDelta(t, 2400, summary.TotalEstimated, 0.001)
+	assert.InDelta(t, 760, summary.TotalActual, 0.001)
+	assert.InDelta(t, 1240, summary.Remaining, 0.001)
+	assert.InDelta(t, -400, summary.EstimatedRemaining, 0.001)
+	assert.True(t, summary.OverBudget)
+	assert.InDelta(t, 146, summary.UnbudgetedTotal, 0.001)
+
+	assert.Equal(t, []models.BudgetBreakdown{
+		{Key: "交通", Count: 1, Estimated: 1200},
+		{Key: "住宿", Count: 2, Estimated: 1200, Actual: 760},
+	}, summary.ByCategory)
+	assert.Equal(t, []models.BudgetBreakdown{
+		{Key: models.PaymentStatusPaid, Count: 1, Estimated: 800, Actual: 760},
+		{Key: models.PaymentStatusPending, Count: 2, Estimated: 1600},
+	}, summary.ByPaymentStatus)
+}
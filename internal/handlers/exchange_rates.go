@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// rateBook 按货币对索引的汇率，每个货币对按日期升序排列
+type rateBook map[[2]string][]models.ExchangeRate
+
+// GetExchangeRates 获取汇率列表
+func GetExchangeRates(c *gin.Context) {
+	from := strings.ToUpper(c.Query("from"))
+	to := strings.ToUpper(c.Query("to"))
+
+	db := database.GetDB()
+	query := `
+		SELECT id, from_currency, to_currency, rate, to_char(rate_date, 'YYYY-MM-DD'), source, created_at
+		FROM exchange_rates WHERE 1 = 1
+	`
+	args := []interface{}{}
+	argIndex := 1
+
+	if from != "" {
+		query += fmt.Sprintf(" AND from_currency = $%d", argIndex)
+		args = append(args, from)
+		argIndex++
+	}
+	if to != "" {
+		query += fmt.Sprintf(" AND to_currency = $%d", argIndex)
+		args = append(args, to)
+		argIndex++
+	}
+	query += " ORDER BY rate_date DESC, from_currency, to_currency"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.ID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate,
+			&rate.RateDate, &rate.Source, &rate.CreatedAt); err != nil {
+			c.Error(err)
+			return
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      rates,
+		Timestamp: time.Now(),
+	})
+}
+
+// SetExchangeRates 管理员设置汇率（同一货币对同一天的汇率会被覆盖）
+func SetExchangeRates(c *gin.Context) {
+	var req struct {
+		Rates []models.ExchangeRate `json:"rates" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	for i := range req.Rates {
+		if err := normalizeExchangeRate(&req.Rates[i]); err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   fmt.Sprintf("第 %d 条汇率无效: %v", i+1, err),
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	if err := database.Transaction(func(tx *sql.Tx) error {
+		return upsertExchangeRates(tx, req.Rates)
+	}); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      map[string]int{"count": len(req.Rates)},
+		Message:   "汇率设置成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// LoadExchangeRatesFile 从CSV文件导入汇率，每行格式为 rate_date,from_currency,to_currency,rate[,source]
+func LoadExchangeRatesFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开汇率文件失败: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var rates []models.ExchangeRate
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取汇率文件失败: %v", err)
+		}
+
+		// 跳过表头
+		if line == 1 && strings.EqualFold(record[0], "rate_date") {
+			continue
+		}
+		if len(record) < 4 {
+			return fmt.Errorf("汇率文件第 %d 行字段不足", line)
+		}
+
+		value, err := strconv.ParseFloat(record[3], 64)
+		if err != nil {
+			return fmt.Errorf("汇率文件第 %d 行汇率无效: %v", line, err)
+		}
+
+		rate := models.ExchangeRate{
+			RateDate:     record[0],
+			FromCurrency: record[1],
+			ToCurrency:   record[2],
+			Rate:         value,
+		}
+		source := path
+		if len(record) > 4 && record[4] != "" {
+			source = record[4]
+		}
+		rate.Source = &source
+
+		if err := normalizeExchangeRate(&rate); err != nil {
+			return fmt.Errorf("汇率文件第 %d 行无效: %v", line, err)
+		}
+		rates = append(rates, rate)
+	}
+
+	if err := database.Transaction(func(tx *sql.Tx) error {
+		return upsertExchangeRates(tx, rates)
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("✅ 已导入 %d 条汇率", len(rates))
+	return nil
+}
+
+// normalizeExchangeRate 规范化货币代码并校验汇率
+func normalizeExchangeRate(rate *models.ExchangeRate) error {
+	rate.FromCurrency = strings.ToUpper(strings.TrimSpace(rate.FromCurrency))
+	rate.ToCurrency = strings.ToUpper(strings.TrimSpace(rate.ToCurrency))
+
+	if !isCurrencyCode(rate.FromCurrency) || !isCurrencyCode(rate.ToCurrency) {
+		return fmt.Errorf("货币代码应为3位字母: %s/%s", rate.FromCurrency, rate.ToCurrency)
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return fmt.Errorf("源货币和目标货币不能相同: %s", rate.FromCurrency)
+	}
+	if rate.Rate <= 0 {
+		return fmt.Errorf("汇率必须大于0")
+	}
+	if _, err := time.Parse("2006-01-02", rate.RateDate); err != nil {
+		return fmt.Errorf("日期格式应为 YYYY-MM-DD: %s", rate.RateDate)
+	}
+	return nil
+}
+
+// upsertExchangeRates 写入汇率，同一货币对同一天覆盖旧值
+func upsertExchangeRates(tx *sql.Tx, rates []models.ExchangeRate) error {
+	for _, rate := range rates {
+		_, err := tx.Exec(`
+			INSERT INTO exchange_rates (id, from_currency, to_currency, rate, rate_date, source, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (from_currency, to_currency, rate_date) DO UPDATE
+			SET rate = EXCLUDED.rate, source = EXCLUDED.source
+		`, uuid.New().String(), rate.FromCurrency, rate.ToCurrency, rate.Rate,
+			rate.RateDate, rate.Source, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isCurrencyCode 检查是否为ISO 4217格式的货币代码
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// loadRateBook 加载全部汇率
+func loadRateBook() (rateBook, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(`
+		SELECT id, from_currency, to_currency, rate, to_char(rate_date, 'YYYY-MM-DD'), source, created_at
+		FROM exchange_rates
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.ID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate,
+			&rate.RateDate, &rate.Source, &rate.CreatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return newRateBook(rates), nil
+}
+
+// newRateBook 构建汇率簿
+func newRateBook(rates []models.ExchangeRate) rateBook {
+	book := make(rateBook)
+	for _, rate := range rates {
+		key := [2]string{rate.FromCurrency, rate.ToCurrency}
+		book[key] = append(book[key], rate)
+	}
+	for key := range book {
+		sort.Slice(book[key], func(i, j int) bool {
+			return book[key][i].RateDate < book[key][j].RateDate
+		})
+	}
+	return book
+}
+
+// lookup 查找某日（含）之前最近的汇率
+func (b rateBook) lookup(from, to string, on time.Time) (models.ExchangeRate, bool) {
+	day := on.Format("2006-01-02")
+	rates := b[[2]string{from, to}]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].RateDate > day })
+	if i == 0 {
+		return models.ExchangeRate{}, false
+	}
+	return rates[i-1], true
+}
+
+// convert 按某日汇率换算金额，支持反向汇率
+func (b rateBook) convert(amount float64, from, to string, on time.Time) (float64, *models.AppliedRate, bool) {
+	if from == to {
+		return amount, nil, true
+	}
+
+	if rate, ok := b.lookup(from, to, on); ok {
+		return amount * rate.Rate, &models.AppliedRate{
+			FromCurrency: from,
+			ToCurrency:   to,
+			Rate:         rate.Rate,
+			RateDate:     rate.RateDate,
+		}, true
+	}
+
+	if rate, ok := b.lookup(to, from, on); ok {
+		return amount / rate.Rate, &models.AppliedRate{
+			FromCurrency: from,
+			ToCurrency:   to,
+			Rate:         1 / rate.Rate,
+			RateDate:     rate.RateDate,
+			Inverse:      true,
+		}, true
+	}
+
+	return 0, nil, false
+}
+
+// convertBudgetItems 将预算项目金额换算为基准货币，按支付日期（缺省为创建时间）取汇率。
+// 缺少汇率的项目不计入结果，并在 missing 中列出。
+func convertBudgetItems(items []models.BudgetItem, base string, book rateBook) (converted []models.BudgetItem, applied []models.AppliedRate, missing []string) {
+	converted = make([]models.BudgetItem, 0, len(items))
+	applied = []models.AppliedRate{}
+
+	for _, item := range items {
+		on := item.CreatedAt
+		if item.PaymentDate != nil {
+			on = *item.PaymentDate
+		}
+
+		original, hasAmount := settlementAmount(&item)
+
+		var rate *models.AppliedRate
+		ok := true
+		convertAmount := func(amount *float64) *float64 {
+			if amount == nil || !ok {
+				return nil
+			}
+			value, r, found := book.convert(*amount, item.Currency, base, on)
+			if !found {
+				ok = false
+				return nil
+			}
+			rate = r
+			return &value
+		}
+
+		item.EstimatedAmount = convertAmount(item.EstimatedAmount)
+		item.ActualAmount = convertAmount(item.ActualAmount)
+		if !ok {
+			missing = append(missing, fmt.Sprintf("%s: 缺少 %s→%s 在 %s 的汇率",
+				item.Description, item.Currency, base, on.Format("2006-01-02")))
+			continue
+		}
+
+		if rate != nil {
+			rate.BudgetItemID = item.ID
+			applied = append(applied, *rate)
+
+			// 按金额分摊的明细是原币种金额，需与项目金额按同一汇率换算
+			if item.SplitType == models.SplitTypeExact && hasAmount {
+				convertedAmount, _ := settlementAmount(&item)
+				item.SplitDetails = scaleExactSplit(item.SplitDetails, original, convertedAmount)
+			}
+		}
+		item.Currency = base
+		converted = append(converted, item)
+	}
+
+	return converted, applied, missing
+}
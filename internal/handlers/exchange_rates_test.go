@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateBookConvert(t *testing.T) {
+	book := newRateBook([]models.ExchangeRate{
+		{FromCurrency: "USD", ToCurrency: "CNY", Rate: 7.10, RateDate: "2025-09-01"},
+		{FromCurrency: "USD", ToCurrency: "CNY", Rate: 7.20, RateDate: "2025-09-15"},
+		{FromCurrency: "CNY", ToCurrency: "NPR", Rate: 19.0, RateDate: "2025-09-01"},
+	})
+	day := func(s string) time.Time {
+		v, _ := time.Parse("2006-01-02", s)
+		return v
+	}
+
+	t.Run("使用支付日期之前最近的汇率", func(t *testing.T) {
+		value, rate, ok := book.convert(100, "USD", "CNY", day("2025-09-14"))
+		assert.True(t, ok)
+		assert.InDelta(t, 710, value, 0.001)
+		assert.Equal(t, "2025-09-01", rate.RateDate)
+
+		value, rate, ok = book.convert(100, "USD", "CNY", day("2025-09-15"))
+		assert.True(t, ok)
+		assert.InDelta(t, 720, value, 0.001)
+		assert.Equal(t, "2025-09-15", rate.RateDate)
+	})
+
+	t.Run("反向汇率", func(t *testing.T) {
+		value, rate, ok := book.convert(1900, "NPR", "CNY", day("2025-09-20"))
+		assert.True(t, ok)
+		assert.InDelta(t, 100, value, 0.001)
+		assert.True(t, rate.Inverse)
+	})
+
+	t.Run("缺少汇率", func(t *testing.T) {
+		_, _, ok := book.convert(100, "USD", "CNY", day("2025-08-31"))
+		assert.False(t, ok)
+		_, _, ok = book.convert(100, "EUR", "CNY", day("2025-09-20"))
+		assert.False(t, ok)
+	})
+
+	t.Run("相同货币", func(t *testing.T) {
+		value, rate, ok := book.convert(100, "CNY", "CNY", day("2025-09-20"))
+		assert.True(t, ok)
+		assert.Nil(t, rate)
+		assert.InDelta(t, 100, value, 0.001)
+	})
+}
+
+func TestConvertBudgetItems(t *testing.T) {
+	book := newRateBook([]models.ExchangeRate{
+		{FromCurrency: "USD", ToCurrency: "CNY", Rate: 7.0, RateDate: "2025-09-01"},
+	})
+	amount := func(v float64) *float64 { return &v }
+	paid := time.Date(2025, 9, 10, 12, 0, 0, 0, time.UTC)
+
+	items := []models.BudgetItem{
+		{ID: "a", Description: "机票", Currency: "USD", EstimatedAmount: amount(100), ActualAmount: amount(90), PaymentDate: &paid},
+		{ID: "b", Description: "酒店", Currency: "CNY", EstimatedAmount: amount(500)},
+		{ID: "c", Description: "向导", Currency: "NPR", EstimatedAmount: amount(5000), CreatedAt: paid},
+	}
+
+	converted, applied, missing := convertBudgetItems(items, "CNY", book)
+
+	assert.Len(t, converted, 2)
+	assert.InDelta(t, 700, *converted[0].EstimatedAmount, 0.001)
+	assert.InDelta(t, 630, *converted[0].ActualAmount, 0.001)
+	assert.Equal(t, "CNY", converted[0].Currency)
+	assert.InDelta(t, 500, *converted[1].EstimatedAmount, 0.001)
+
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "a", applied[0].BudgetItemID)
+	assert.Len(t, missing, 1)
+}
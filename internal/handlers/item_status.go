@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// itemStatusTransitions 元素状态允许的转换
+var itemStatusTransitions = map[string][]string{
+	models.ItemStatusPlanned:    {models.ItemStatusBooked, models.ItemStatusInProgress, models.ItemStatusSkipped, models.ItemStatusCancelled},
+	models.ItemStatusBooked:     {models.ItemStatusPlanned, models.ItemStatusInProgress, models.ItemStatusSkipped, models.ItemStatusCancelled},
+	models.ItemStatusInProgress: {models.ItemStatusDone, models.ItemStatusSkipped, models.ItemStatusCancelled},
+	models.ItemStatusDone:       {},
+	models.ItemStatusSkipped:    {models.ItemStatusPlanned},
+	models.ItemStatusCancelled:  {models.ItemStatusPlanned},
+}
+
+// bookingStatusTransitions 预订状态允许的转换，空字符串表示尚未设置
+var bookingStatusTransitions = map[string][]string{
+	"":                              {models.BookingStatusNotRequired, models.BookingStatusPending, models.BookingStatusConfirmed},
+	models.BookingStatusNotRequired: {models.BookingStatusPending},
+	models.BookingStatusPending:     {models.BookingStatusConfirmed, models.BookingStatusFailed, models.BookingStatusCancelled},
+	models.BookingStatusConfirmed:   {models.BookingStatusCancelled},
+	models.BookingStatusFailed:      {models.BookingStatusPending, models.BookingStatusCancelled},
+	models.BookingStatusCancelled:   {models.BookingStatusPending},
+}
+
+// legacyItemStatuses 旧数据中的状态值
+var legacyItemStatuses = map[string]string{
+	"":          models.ItemStatusPlanned,
+	"pending":   models.ItemStatusPlanned,
+	"completed": models.ItemStatusDone,
+}
+
+// itemStatusError 状态值或状态转换无效
+type itemStatusError struct {
+	msg string
+}
+
+func (e *itemStatusError) Error() string {
+	return e.msg
+}
+
+// UpdateItemStatus 更新元素状态和预订状态
+func UpdateItemStatus(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	if !checkItemAccess(c, itemID, userID, "无权修改此元素") {
+		return
+	}
+
+	var req models.UpdateItemStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if req.Status == nil && req.BookingStatus == nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请提供 status 或 booking_status",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var state *models.ItemStatusState
+	err := database.Transaction(func(tx *sql.Tx) error {
+		if req.Status != nil {
+			if err := changeItemStatus(tx, itemID, userID, "status", *req.Status, req.Note); err != nil {
+				return err
+			}
+		}
+		if req.BookingStatus != nil {
+			if err := changeItemStatus(tx, itemID, userID, "booking_status", *req.BookingStatus, req.Note); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		state, err = loadItemStatusState(tx, itemID)
+		return err
+	})
+
+	if err != nil {
+		respondItemError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      state,
+		Message:   "状态更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetItemStatusHistory 获取元素状态变更历史
+func GetItemStatusHistory(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	if !checkItemAccess(c, itemID, userID, "无权查看此元素") {
+		return
+	}
+
+	db := database.GetDB()
+	rows, err := db.Query(`
+		SELECT id, item_id, field, from_status, to_status, note, changed_by, changed_at
+		FROM item_status_history
+		WHERE item_id = $1
+		ORDER BY changed_at, id
+	`, itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	history := []models.ItemStatusHistory{}
+	for rows.Next() {
+		var h models.ItemStatusHistory
+		if err := rows.Scan(&h.ID, &h.ItemID, &h.Field, &h.From, &h.To, &h.Note, &h.ChangedBy, &h.ChangedAt); err != nil {
+			c.Error(err)
+			return
+		}
+		history = append(history, h)
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      history,
+		Timestamp: time.Now(),
+	})
+}
+
+// changeItemStatus 校验并执行状态转换，同时写入历史记录。field 为 status 或 booking_status
+func changeItemStatus(tx *sql.Tx, itemID, userID, field, to string, note *string) error {
+	var status string
+	var bookingStatus sql.NullString
+	err := tx.QueryRow("SELECT COALESCE(status, ''), booking_status FROM travel_items WHERE id = $1 FOR UPDATE", itemID).
+		Scan(&status, &bookingStatus)
+	if err != nil {
+		return err
+	}
+
+	var from string
+	var transitions map[string][]string
+	if field == "status" {
+		from = normalizeItemStatus(status)
+		to = canonicalItemStatus(to)
+		transitions = itemStatusTransitions
+	} else {
+		from = bookingStatus.String
+		transitions = bookingStatusTransitions
+	}
+
+	if from == to {
+		return nil
+	}
+	if err := checkTransition(transitions, field, from, to); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf("UPDATE travel_items SET %s = $2, updated_at = $3 WHERE id = $1", field),
+		itemID, to, time.Now()); err != nil {
+		return err
+	}
+
+	var fromValue *string
+	if field == "status" || bookingStatus.Valid {
+		fromValue = &from
+	}
+	var changedBy *string
+	if userID != "" {
+		changedBy = &userID
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO item_status_history (id, item_id, field, from_status, to_status, note, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New().String(), itemID, field, fromValue, to, note, changedBy, time.Now())
+	return err
+}
+
+// checkTransition 检查状态转换是否允许
+func checkTransition(transitions map[string][]string, field, from, to string) error {
+	if _, known := transitions[to]; !known || to == "" {
+		return &itemStatusError{msg: fmt.Sprintf("无效的%s: %s", statusFieldName(field), to)}
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	if from == "" {
+		from = "未设置"
+	}
+	return &itemStatusError{msg: fmt.Sprintf("%s不能从 %s 变更为 %s", statusFieldName(field), from, to)}
+}
+
+// validateInitialStatus 校验新建元素的初始状态，返回映射旧值后的状态
+func validateInitialStatus(status string) (string, error) {
+	status = canonicalItemStatus(status)
+	if _, ok := itemStatusTransitions[status]; !ok {
+		return "", &itemStatusError{msg: "无效的状态: " + status}
+	}
+	return status, nil
+}
+
+// canonicalItemStatus 将客户端提交的旧状态值（pending、completed）映射为新值，空值仍视为无效
+func canonicalItemStatus(status string) string {
+	if mapped, ok := legacyItemStatuses[status]; ok && status != "" {
+		return mapped
+	}
+	return status
+}
+
+// loadItemStatusState 加载元素当前状态和可用的转换
+func loadItemStatusState(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, itemID string) (*models.ItemStatusState, error) {
+	state := models.ItemStatusState{ItemID: itemID}
+	var status string
+	if err := q.QueryRow("SELECT COALESCE(status, ''), booking_status FROM travel_items WHERE id = $1", itemID).
+		Scan(&status, &state.BookingStatus); err != nil {
+		return nil, err
+	}
+
+	state.Status = normalizeItemStatus(status)
+	state.AllowedStatuses = append([]string{}, itemStatusTransitions[state.Status]...)
+	booking := ""
+	if state.BookingStatus != nil {
+		booking = *state.BookingStatus
+	}
+	state.AllowedBookingStatuses = append([]string{}, bookingStatusTransitions[booking]...)
+	return &state, nil
+}
+
+// normalizeItemStatus 将旧数据中的状态映射到当前状态机
+func normalizeItemStatus(status string) string {
+	if mapped, ok := legacyItemStatuses[status]; ok {
+		return mapped
+	}
+	if _, ok := itemStatusTransitions[status]; ok {
+		return status
+	}
+	return models.ItemStatusPlanned
+}
+
+func statusFieldName(field string) string {
+	if field == "booking_status" {
+		return "预订状态"
+	}
+	return "状态"
+}
+
+// respondItemError 状态错误和输入错误返回400，其他错误交给错误处理中间件
+func respondItemError(c *gin.Context, err error) {
+	var statusErr *itemStatusError
+	var inputErr *invalidInputError
+	if errors.As(err, &statusErr) || errors.As(err, &inputErr) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	c.Error(err)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"testing"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckTransition(t *testing.T) {
+	assert.NoError(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusPlanned, models.ItemStatusBooked))
+	assert.NoError(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusBooked, models.ItemStatusInProgress))
+	assert.NoError(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusInProgress, models.ItemStatusDone))
+	assert.NoError(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusCancelled, models.ItemStatusPlanned))
+
+	assert.Error(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusPlanned, models.ItemStatusDone))
+	assert.Error(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusDone, models.ItemStatusPlanned))
+	assert.Error(t, checkTransition(itemStatusTransitions, "status", models.ItemStatusPlanned, "whatever"))
+
+	assert.NoError(t, checkTransition(bookingStatusTransitions, "booking_status", "", models.BookingStatusPending))
+	assert.NoError(t, checkTransition(bookingStatusTransitions, "booking_status", models.BookingStatusPending, models.BookingStatusConfirmed))
+	assert.Error(t, checkTransition(bookingStatusTransitions, "booking_status", models.BookingStatusConfirmed, models.BookingStatusFailed))
+	assert.Error(t, checkTransition(bookingStatusTransitions, "booking_status", models.BookingStatusPending, ""))
+}
+
+func TestNormalizeItemStatus(t *testing.T) {
+	assert.Equal(t, models.ItemStatusDone, normalizeItemStatus("completed"))
+	assert.Equal(t, models.ItemStatusPlanned, normalizeItemStatus(""))
+	assert.Equal(t, models.ItemStatusBooked, normalizeItemStatus(models.ItemStatusBooked))
+	assert.Equal(t, models.ItemStatusPlanned, normalizeItemStatus("unknown"))
+}
+
+func TestLegacyStatusInput(t *testing.T) {
+	// 前端仍提交 pending / completed
+	status, err := validateInitialStatus("pending")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ItemStatusPlanned, status)
+	status, err = validateInitialStatus("completed")
+	assert.NoError(t, err)
+	assert.Equal(t, models.ItemStatusDone, status)
+	status, err = validateInitialStatus(models.ItemStatusBooked)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ItemStatusBooked, status)
+
+	_, err = validateInitialStatus("")
+	assert.Error(t, err)
+	_, err = validateInitialStatus("whatever")
+	assert.Error(t, err)
+
+	assert.Equal(t, models.ItemStatusDone, canonicalItemStatus("completed"))
+	assert.Equal(t, "", canonicalItemStatus(""))
+	assert.NoError(t, checkTransition(itemStatusTransitions, "status",
+		models.ItemStatusInProgress, canonicalItemStatus("completed")))
+}
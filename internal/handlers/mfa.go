@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"planner/internal/auth"
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// mfaChallengeTTL 两步登录挑战令牌有效期
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeAttempts 每个挑战令牌允许输错验证码的次数
+	mfaChallengeAttempts = 5
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+)
+
+var (
+	errMFAAlreadyEnabled   = errors.New("两步验证已启用")
+	errMFANotEnabled       = errors.New("两步验证未启用")
+	errMFASetupRequired    = errors.New("请先获取两步验证密钥")
+	errInvalidMFACode      = errors.New("验证码错误")
+	errInvalidPassword     = errors.New("密码错误")
+	errInvalidMFAChallenge = errors.New("登录验证已过期，请重新登录")
+)
+
+// SetupTOTP 生成新的 TOTP 密钥，输入一次验证码确认后才真正启用
+func SetupTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	sealed, err := auth.SealSecret(secret)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var username string
+	err = database.GetDB().QueryRow(`
+		UPDATE users SET totp_secret = $1, totp_last_step = 0
+		WHERE id = $2 AND totp_enabled_at IS NULL
+		RETURNING username
+	`, sealed, userID).Scan(&username)
+	if err == sql.ErrNoRows {
+		respondMFAError(c, errMFAAlreadyEnabled)
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data: models.TOTPSetup{
+			Secret:          secret,
+			ProvisioningURI: auth.TOTPProvisioningURI(config.Get().TOTPIssuer, username, secret),
+		},
+		Message:   "请用验证器App扫描二维码，并输入验证码完成启用",
+		Timestamp: time.Now(),
+	})
+}
+
+// EnableTOTP 校验验证码后启用两步验证，返回只显示一次的恢复码
+func EnableTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var codes []string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var sealed sql.NullString
+		var enabledAt sql.NullTime
+		err := tx.QueryRow("SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1 FOR UPDATE", userID).
+			Scan(&sealed, &enabledAt)
+		if err != nil {
+			return err
+		}
+		if enabledAt.Valid {
+			return errMFAAlreadyEnabled
+		}
+		if !sealed.Valid {
+			return errMFASetupRequired
+		}
+
+		secret, err := auth.OpenSecret(sealed.String)
+		if err != nil {
+			return err
+		}
+		step, ok := auth.ValidateTOTP(secret, req.Code, time.Now(), 0)
+		if !ok {
+			return errInvalidMFACode
+		}
+
+		if _, err := tx.Exec("UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $1 WHERE id = $2",
+			step, userID); err != nil {
+			return err
+		}
+		// 当前会话已经证明持有验证器，视为通过两步验证
+		if _, err := tx.Exec("UPDATE user_sessions SET mfa_verified = true WHERE id = $1",
+			c.GetString("session_id")); err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      map[string]interface{}{"recovery_codes": codes},
+		Message:   "两步验证已启用，请妥善保存恢复码",
+		Timestamp: time.Now(),
+	})
+}
+
+// DisableTOTP 关闭两步验证，需要密码和验证码（或恢复码）
+func DisableTOTP(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var passwordHash string
+		if err := tx.QueryRow("SELECT password_hash FROM users WHERE id = $1", userID).Scan(&passwordHash); err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil {
+			return errInvalidPassword
+		}
+		if err := verifySecondFactor(tx, userID, req.Code); err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+			WHERE id = $1
+		`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+		return err
+	})
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "两步验证已关闭",
+		Timestamp: time.Now(),
+	})
+}
+
+// RegenerateRecoveryCodes 生成新的恢复码，旧恢复码全部作废
+func RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var codes []string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		if err := verifySecondFactor(tx, userID, req.Code); err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      map[string]interface{}{"recovery_codes": codes},
+		Message:   "恢复码已重新生成，旧恢复码已失效",
+		Timestamp: time.Now(),
+	})
+}
+
+// VerifyMFALogin 两步登录第二步：校验挑战令牌和验证码后签发访问令牌
+func VerifyMFALogin(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var user models.User
+	var deviceLabel sql.NullString
+	codeRejected := false
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var challengeID string
+		var attempts int
+		var expiresAt time.Time
+		var usedAt sql.NullTime
+		err := tx.QueryRow(`
+			SELECT id, user_id, device_label, attempts, expires_at, used_at
+			FROM mfa_challenges WHERE token_hash = $1
+			FOR UPDATE
+		`, auth.HashOpaqueToken(req.ChallengeToken)).Scan(&challengeID, &user.ID, &deviceLabel, &attempts, &expiresAt, &usedAt)
+		if err == sql.ErrNoRows {
+			return errInvalidMFAChallenge
+		}
+		if err != nil {
+			return err
+		}
+		if usedAt.Valid || attempts >= mfaChallengeAttempts || time.Now().After(expiresAt) {
+			return errInvalidMFAChallenge
+		}
+
+		err = verifySecondFactor(tx, user.ID, req.Code)
+		if err == errInvalidMFACode {
+			// 记录失败次数并提交，超过次数后挑战令牌作废
+			codeRejected = true
+			_, err = tx.Exec("UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1", challengeID)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec("UPDATE mfa_challenges SET used_at = NOW() WHERE id = $1", challengeID); err != nil {
+			return err
+		}
+		err = tx.QueryRow("SELECT username, email, is_active, token_version FROM users WHERE id = $1", user.ID).
+			Scan(&user.Username, &user.Email, &user.IsActive, &user.TokenVersion)
+		if err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return errInvalidMFAChallenge
+		}
+		return nil
+	})
+	if err == nil && codeRejected {
+		err = errInvalidMFACode
+	}
+	if err != nil {
+		respondMFAError(c, err)
+		return
+	}
+
+	completeLogin(c, &user, deviceLabel.String, true)
+}
+
+// GetSecuritySettings 获取安全设置
+func GetSecuritySettings(c *gin.Context) {
+	settings, err := loadSecuritySettings()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      settings,
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateSecuritySettings 修改安全设置。开启“管理员必须启用两步验证”前，
+// 当前管理员自己必须已通过两步验证登录，避免把自己锁在外面
+func UpdateSecuritySettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req models.SecuritySettings
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if req.RequireAdminMFA {
+		var mfaVerified bool
+		err := database.GetDB().QueryRow("SELECT mfa_verified FROM user_sessions WHERE id = $1",
+			c.GetString("session_id")).Scan(&mfaVerified)
+		if err != nil && err != sql.ErrNoRows {
+			c.Error(err)
+			return
+		}
+		if !mfaVerified {
+			c.JSON(http.StatusConflict, models.ApiResponse{
+				Success:   false,
+				Message:   "请先启用两步验证并使用两步验证登录",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	_, err := database.GetDB().Exec(`
+		INSERT INTO system_settings (key, value, updated_by, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+	`, models.SettingRequireAdminMFA, strconv.FormatBool(req.RequireAdminMFA), userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      req,
+		Message:   "安全设置已更新",
+		Timestamp: time.Now(),
+	})
+}
+
+// loadSecuritySettings 读取安全设置，未设置的项使用默认值
+func loadSecuritySettings() (models.SecuritySettings, error) {
+	var settings models.SecuritySettings
+	value, ok, err := database.GetSetting(models.SettingRequireAdminMFA)
+	if err != nil {
+		return settings, err
+	}
+	if ok {
+		settings.RequireAdminMFA, _ = strconv.ParseBool(value)
+	}
+	return settings, nil
+}
+
+// respondMFAChallenge 第一步验证通过后返回两步登录挑战令牌
+func respondMFAChallenge(c *gin.Context, userID, deviceLabel string) {
+	challenge, err := createMFAChallenge(userID, deviceLabel)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"mfa_required":    true,
+			"challenge_token": challenge,
+			"expires_in":      int64(mfaChallengeTTL.Seconds()),
+		},
+		Message:   "请输入两步验证码",
+		Timestamp: time.Now(),
+	})
+}
+
+// createMFAChallenge 第一步验证通过后签发两步登录挑战令牌
+func createMFAChallenge(userID, deviceLabel string) (string, error) {
+	token, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = database.GetDB().Exec(`
+		INSERT INTO mfa_challenges (id, user_id, token_hash, device_label, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, uuid.New().String(), userID, hash, nullIfEmpty(deviceLabel), time.Now().Add(mfaChallengeTTL), time.Now())
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码，成功后记录时间步或标记恢复码已使用。
+// 验证码错误时返回 errInvalidMFACode
+func verifySecondFactor(tx *sql.Tx, userID, code string) error {
+	var sealed sql.NullString
+	var enabledAt sql.NullTime
+	var lastStep int64
+	err := tx.QueryRow("SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1 FOR UPDATE", userID).
+		Scan(&sealed, &enabledAt, &lastStep)
+	if err != nil {
+		return err
+	}
+	if !enabledAt.Valid || !sealed.Valid {
+		return errMFANotEnabled
+	}
+
+	secret, err := auth.OpenSecret(sealed.String)
+	if err != nil {
+		return err
+	}
+	if step, ok := auth.ValidateTOTP(secret, code, time.Now(), lastStep); ok {
+		_, err := tx.Exec("UPDATE users SET totp_last_step = $1 WHERE id = $2", step, userID)
+		return err
+	}
+
+	// 不是有效的 TOTP 验证码时再尝试恢复码（bcrypt 比较较慢，只对恢复码格式的输入执行）
+	recovery := auth.NormalizeRecoveryCode(code)
+	if len(recovery) != 11 {
+		return errInvalidMFACode
+	}
+	rows, err := tx.Query("SELECT id, code_hash FROM mfa_recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	matched := ""
+	for rows.Next() {
+		var id, hash string
+		if err := rows.Scan(&id, &hash); err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(recovery)) == nil {
+			matched = id
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+	if matched == "" {
+		return errInvalidMFACode
+	}
+
+	_, err = tx.Exec("UPDATE mfa_recovery_codes SET used_at = NOW() WHERE id = $1", matched)
+	return err
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的，返回明文（只在本次响应中出现）
+func replaceRecoveryCodes(tx *sql.Tx, userID string) ([]string, error) {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, err
+	}
+
+	codes, err := auth.NewRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		hash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+			VALUES ($1, $2, $3, $4)
+		`, uuid.New().String(), userID, string(hash), time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+func respondMFAError(c *gin.Context, err error) {
+	status := 0
+	switch err {
+	case errMFAAlreadyEnabled:
+		status = http.StatusConflict
+	case errMFANotEnabled, errMFASetupRequired:
+		status = http.StatusBadRequest
+	case errInvalidMFACode, errInvalidPassword, errInvalidMFAChallenge:
+		status = http.StatusUnauthorized
+	}
+	if status == 0 {
+		c.Error(err)
+		return
+	}
+	c.JSON(status, models.ApiResponse{
+		Success:   false,
+		Message:   err.Error(),
+		Timestamp: time.Now(),
+	})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespondMFAError(t *testing.T) {
+	cases := map[error]int{
+		errMFAAlreadyEnabled:   http.StatusConflict,
+		errMFASetupRequired:    http.StatusBadRequest,
+		errInvalidMFACode:      http.StatusUnauthorized,
+		errInvalidPassword:     http.StatusUnauthorized,
+		errInvalidMFAChallenge: http.StatusUnauthorized,
+	}
+	for err, status := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondMFAError(c, err)
+
+		assert.Equal(t, status, w.Code, err.Error())
+		var response models.ApiResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, err.Error(), response.Message)
+	}
+}
+
+func TestVerifyMFALoginValidation(t *testing.T) {
+	router := setupTestRouter()
+	router.POST("/api/v1/auth/login/2fa", VerifyMFALogin)
+
+	for _, body := range []string{`{}`, `{"challenge_token":"abc"}`, `{"code":"123456"}`} {
+		req, _ := http.NewRequest("POST", "/api/v1/auth/login/2fa", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
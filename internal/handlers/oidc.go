@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"planner/internal/auth"
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/models"
+	"planner/internal/oidc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// oidcLoginTTL 跳转到身份提供方后完成登录的时限
+	oidcLoginTTL = 10 * time.Minute
+	// oidcLoginCodeTTL 回调后前端换取访问令牌的时限
+	oidcLoginCodeTTL = 2 * time.Minute
+	// oidcCallbackPath 登录结果跳转到的前端页面
+	oidcCallbackPath = "/oauth/callback"
+	// oidcStateCookie 保存发起登录的浏览器的 state，回调时必须与参数一致，防止登录CSRF
+	oidcStateCookie = "oidc_state"
+	oidcCookiePath  = "/api/v1/auth/oidc"
+)
+
+var (
+	errOIDCLoginExpired    = errors.New("登录已过期，请重新登录")
+	errOIDCEmailRequired   = errors.New("身份提供方没有提供邮箱，无法登录")
+	errOIDCEmailNotLinked  = errors.New("该邮箱已注册但尚未验证，请先用密码登录并验证邮箱后再使用单点登录")
+	errOIDCEmailUnverified = errors.New("身份提供方未验证该邮箱，无法登录")
+	errOIDCStateMismatch   = errors.New("登录请求不是从此浏览器发起的，请重新登录")
+)
+
+// usernameInvalidChars 自动生成用户名时去掉的字符
+var usernameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ListOIDCProviders 列出可用的单点登录身份提供方
+func ListOIDCProviders(c *gin.Context) {
+	providers := []models.OIDCProvider{}
+	for _, p := range oidc.List() {
+		providers = append(providers, models.OIDCProvider{
+			Name:        p.Name(),
+			DisplayName: p.DisplayName(),
+			LoginURL:    "/api/v1/auth/oidc/" + url.PathEscape(p.Name()) + "/login",
+		})
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      providers,
+		Timestamp: time.Now(),
+	})
+}
+
+// StartOIDCLogin 保存 state、nonce 和 PKCE 校验码后跳转到身份提供方。
+// redirect 参数为登录后返回的前端路径，只接受站内相对路径
+func StartOIDCLogin(c *gin.Context) {
+	provider, ok := oidc.Get(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ApiResponse{
+			Success:   false,
+			Message:   "未知的身份提供方",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		log.Printf("⚠️ 单点登录 %s 不可用: %v", provider.Name(), err)
+		c.JSON(http.StatusBadGateway, models.ApiResponse{
+			Success:   false,
+			Message:   "暂时无法连接身份提供方",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	_, err = database.GetDB().Exec(`
+		INSERT INTO oidc_logins (id, provider, state_hash, nonce, code_verifier, redirect_path, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New().String(), provider.Name(), auth.HashOpaqueToken(state), nonce, verifier,
+		nullIfEmpty(safeRedirectPath(c.Query("redirect"))), time.Now().Add(oidcLoginTTL), time.Now())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	setOIDCStateCookie(c, state, int(oidcLoginTTL.Seconds()))
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 身份提供方回调：校验 state，用授权码换取并校验ID令牌，找到或创建用户，
+// 然后带着一次性登录码跳转回前端。出错时跳转回前端并带上 error 参数
+func OIDCCallback(c *gin.Context) {
+	provider, ok := oidc.Get(c.Param("provider"))
+	if !ok {
+		redirectOIDCResult(c, url.Values{"error": {"未知的身份提供方"}})
+		return
+	}
+
+	// 回调必须回到发起登录的浏览器，否则攻击者可以让受害者完成攻击者的登录
+	state := c.Query("state")
+	cookie, _ := c.Cookie(oidcStateCookie)
+	setOIDCStateCookie(c, "", -1)
+	if e := c.Query("error"); e != "" {
+		redirectOIDCResult(c, url.Values{"error": {"身份提供方拒绝了登录: " + e}})
+		return
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+		redirectOIDCError(c, errOIDCStateMismatch)
+		return
+	}
+
+	// state 只能使用一次
+	var loginID, nonce, verifier string
+	var redirectPath sql.NullString
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var expiresAt time.Time
+		var usedAt sql.NullTime
+		err := tx.QueryRow(`
+			SELECT id, nonce, code_verifier, redirect_path, expires_at, used_at
+			FROM oidc_logins WHERE state_hash = $1 AND provider = $2
+			FOR UPDATE
+		`, auth.HashOpaqueToken(state), provider.Name()).
+			Scan(&loginID, &nonce, &verifier, &redirectPath, &expiresAt, &usedAt)
+		if err == sql.ErrNoRows || (err == nil && (usedAt.Valid || time.Now().After(expiresAt))) {
+			return errOIDCLoginExpired
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE oidc_logins SET used_at = NOW() WHERE id = $1", loginID)
+		return err
+	})
+	if err != nil {
+		redirectOIDCError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+	token, err := provider.Exchange(ctx, c.Query("code"), verifier)
+	if err != nil {
+		redirectOIDCError(c, fmt.Errorf("单点登录 %s 换取令牌失败: %w", provider.Name(), err))
+		return
+	}
+	claims, err := provider.VerifyIDToken(ctx, token.IDToken, nonce)
+	if err != nil {
+		redirectOIDCError(c, fmt.Errorf("单点登录 %s: %w", provider.Name(), err))
+		return
+	}
+
+	loginCode, hash, err := auth.NewOpaqueToken()
+	if err != nil {
+		redirectOIDCError(c, err)
+		return
+	}
+	err = database.Transaction(func(tx *sql.Tx) error {
+		userID, err := resolveOIDCUser(tx, provider.Name(), claims)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE oidc_logins SET user_id = $1, login_code_hash = $2, expires_at = $3 WHERE id = $4",
+			userID, hash, time.Now().Add(oidcLoginCodeTTL), loginID)
+		return err
+	})
+	if err != nil {
+		redirectOIDCError(c, err)
+		return
+	}
+
+	result := url.Values{"code": {loginCode}}
+	if redirectPath.Valid {
+		result.Set("redirect", redirectPath.String)
+	}
+	redirectOIDCResult(c, result)
+}
+
+// CompleteOIDCLogin 前端用回调得到的一次性登录码换取访问令牌，启用了两步验证时返回挑战令牌
+func CompleteOIDCLogin(c *gin.Context) {
+	var req models.OIDCCompleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var user models.User
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var loginID string
+		var expiresAt time.Time
+		err := tx.QueryRow(`
+			SELECT id, user_id, expires_at FROM oidc_logins
+			WHERE login_code_hash = $1
+			FOR UPDATE
+		`, auth.HashOpaqueToken(req.Code)).Scan(&loginID, &user.ID, &expiresAt)
+		if err == sql.ErrNoRows || (err == nil && time.Now().After(expiresAt)) {
+			return errOIDCLoginExpired
+		}
+		if err != nil {
+			return err
+		}
+		// 登录码只能使用一次
+		if _, err := tx.Exec("UPDATE oidc_logins SET login_code_hash = NULL WHERE id = $1", loginID); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(`
+			SELECT username, email, is_active, token_version, totp_enabled_at
+			FROM users WHERE id = $1
+		`, user.ID).Scan(&user.Username, &user.Email, &user.IsActive, &user.TokenVersion, &user.TOTPEnabledAt)
+	})
+	if err == errOIDCLoginExpired {
+		c.JSON(http.StatusUnauthorized, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if !user.IsActive {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "账户已被禁用",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if user.TOTPEnabledAt != nil {
+		respondMFAChallenge(c, user.ID, req.DeviceLabel)
+		return
+	}
+
+	completeLogin(c, &user, req.DeviceLabel, false)
+}
+
+// resolveOIDCUser 找到外部身份对应的用户：已关联的直接使用；
+// 邮箱与现有账户相同且双方都已验证时自动关联；否则创建新用户
+func resolveOIDCUser(tx *sql.Tx, provider string, claims *oidc.Claims) (string, error) {
+	email := strings.TrimSpace(claims.Email)
+
+	var userID string
+	err := tx.QueryRow("SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, claims.Subject).Scan(&userID)
+	if err == nil {
+		_, err = tx.Exec(`
+			UPDATE user_identities SET last_login_at = NOW(), email = COALESCE($1, email)
+			WHERE provider = $2 AND subject = $3
+		`, nullIfEmpty(email), provider, claims.Subject)
+		return userID, err
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if email == "" {
+		return "", errOIDCEmailRequired
+	}
+	if !claims.EmailVerified {
+		return "", errOIDCEmailUnverified
+	}
+
+	// 只关联已验证邮箱的账户，避免他人抢先用该邮箱注册后接管单点登录用户
+	var localVerified bool
+	err = tx.QueryRow(`
+		SELECT id, email_verified_at IS NOT NULL FROM users
+		WHERE LOWER(email) = LOWER($1)
+		FOR UPDATE
+	`, email).Scan(&userID, &localVerified)
+	switch {
+	case err == nil && !localVerified:
+		return "", errOIDCEmailNotLinked
+	case err == sql.ErrNoRows:
+		userID, err = createOIDCUser(tx, claims, email)
+		if err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_identities (id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+	`, uuid.New().String(), userID, provider, claims.Subject, email, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// createOIDCUser 为首次单点登录的用户创建账户，密码为随机值（可通过找回密码设置）
+func createOIDCUser(tx *sql.Tx, claims *oidc.Claims, email string) (string, error) {
+	username, err := uniqueUsername(tx, oidcUsernameBase(claims, email))
+	if err != nil {
+		return "", err
+	}
+
+	randomPassword, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	userID := uuid.New().String()
+	_, err = tx.Exec(`
+		INSERT INTO users (id, username, email, password_hash, is_active, email_verified_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, true, $5, $5, $5)
+	`, userID, username, email, string(hashedPassword), time.Now())
+	if err != nil {
+		return "", err
+	}
+	log.Printf("✅ 单点登录创建用户 %s", username)
+	return userID, nil
+}
+
+// oidcUsernameBase 从 preferred_username、邮箱前缀或姓名生成用户名候选
+func oidcUsernameBase(claims *oidc.Claims, email string) string {
+	local, _, _ := strings.Cut(email, "@")
+	for _, candidate := range []string{claims.PreferredUsername, local, claims.Name} {
+		name := strings.Trim(usernameInvalidChars.ReplaceAllString(candidate, ""), ".-_")
+		if len(name) > 40 {
+			name = name[:40]
+		}
+		if len(name) >= 3 {
+			return name
+		}
+	}
+	return "user"
+}
+
+// uniqueUsername 用户名已存在时依次追加数字后缀
+func uniqueUsername(tx *sql.Tx, base string) (string, error) {
+	for i := 1; i <= 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+		var exists bool
+		if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", candidate).Scan(&exists); err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return base + "-" + uuid.New().String()[:8], nil
+}
+
+// safeRedirectPath 只接受站内相对路径，防止跳转到外部网站
+func safeRedirectPath(p string) string {
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.HasPrefix(p, "/\\") {
+		return ""
+	}
+	return p
+}
+
+// setOIDCStateCookie 写入（maxAge 为负时删除）state cookie。
+// SameSite=Lax 允许身份提供方跳转回来的顶级 GET 请求携带该 cookie
+func setOIDCStateCookie(c *gin.Context, state string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   maxAge,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// redirectOIDCError 跳转回前端并显示错误，内部错误只记录日志
+func redirectOIDCError(c *gin.Context, err error) {
+	switch err {
+	case errOIDCLoginExpired, errOIDCEmailRequired, errOIDCEmailNotLinked, errOIDCEmailUnverified, errOIDCStateMismatch:
+		redirectOIDCResult(c, url.Values{"error": {err.Error()}})
+		return
+	}
+	log.Printf("⚠️ 单点登录失败: %v", err)
+	redirectOIDCResult(c, url.Values{"error": {"单点登录失败，请稍后重试"}})
+}
+
+func redirectOIDCResult(c *gin.Context, params url.Values) {
+	c.Redirect(http.StatusFound, strings.TrimRight(config.Get().AppBaseURL, "/")+oidcCallbackPath+"?"+params.Encode())
+}
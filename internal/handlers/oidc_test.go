@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"planner/internal/config"
+	"planner/internal/models"
+	"planner/internal/oidc"
+	"planner/internal/oidc/oidctest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListOIDCProviders(t *testing.T) {
+	mock := oidctest.NewProvider("planner", "secret")
+	defer mock.Close()
+	oidc.Set(oidc.NewProvider(oidc.Config{Name: "mock", DisplayName: "Mock IdP", Issuer: mock.Issuer(), ClientID: "planner"}))
+	defer oidc.Set()
+
+	router := setupTestRouter()
+	router.GET("/api/v1/auth/oidc/providers", ListOIDCProviders)
+
+	req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/providers", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Data []models.OIDCProvider `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []models.OIDCProvider{{
+		Name:        "mock",
+		DisplayName: "Mock IdP",
+		LoginURL:    "/api/v1/auth/oidc/mock/login",
+	}}, response.Data)
+}
+
+func TestStartOIDCLoginErrors(t *testing.T) {
+	// 提供方不可达时不应跳转
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	oidc.Set(oidc.NewProvider(oidc.Config{Name: "down", Issuer: unreachable.URL, ClientID: "planner"}))
+	defer oidc.Set()
+
+	router := setupTestRouter()
+	router.GET("/api/v1/auth/oidc/:provider/login", StartOIDCLogin)
+
+	cases := map[string]int{
+		"/api/v1/auth/oidc/unknown/login": http.StatusNotFound,
+		"/api/v1/auth/oidc/down/login":    http.StatusBadGateway,
+	}
+	for path, status := range cases {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, status, w.Code, path)
+	}
+}
+
+func TestOIDCCallbackRedirectsErrors(t *testing.T) {
+	config.Load()
+	mock := oidctest.NewProvider("planner", "")
+	defer mock.Close()
+	oidc.Set(oidc.NewProvider(oidc.Config{Name: "mock", Issuer: mock.Issuer(), ClientID: "planner"}))
+	defer oidc.Set()
+
+	router := setupTestRouter()
+	router.GET("/api/v1/auth/oidc/:provider/callback", OIDCCallback)
+
+	for _, path := range []string{
+		"/api/v1/auth/oidc/unknown/callback?code=x&state=y",
+		"/api/v1/auth/oidc/mock/callback?error=access_denied&state=y",
+	} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code, path)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(location.Path, oidcCallbackPath), location.String())
+		assert.NotEmpty(t, location.Query().Get("error"), path)
+		assert.Empty(t, location.Query().Get("code"), path)
+	}
+}
+
+func TestCompleteOIDCLoginValidation(t *testing.T) {
+	router := setupTestRouter()
+	router.POST("/api/v1/auth/oidc/complete", CompleteOIDCLogin)
+
+	for _, body := range []string{``, `{}`, `{"code":""}`} {
+		req, _ := http.NewRequest("POST", "/api/v1/auth/oidc/complete", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestSafeRedirectPath(t *testing.T) {
+	cases := map[string]string{
+		"":                     "",
+		"/plans/123":           "/plans/123",
+		"/plans?tab=budget":    "/plans?tab=budget",
+		"//evil.example.com":   "",
+		"/\\evil.example.com":  "",
+		"https://evil.example": "",
+		"javascript:alert(1)":  "",
+		"plans":                "",
+	}
+	for input, expected := range cases {
+		assert.Equal(t, expected, safeRedirectPath(input), input)
+	}
+}
+
+func TestOIDCUsernameBase(t *testing.T) {
+	cases := []struct {
+		claims   oidc.Claims
+		email    string
+		expected string
+	}{
+		{oidc.Claims{PreferredUsername: "alice"}, "alice@example.com", "alice"},
+		{oidc.Claims{PreferredUsername: "张三"}, "zhang.san@example.com", "zhang.san"},
+		{oidc.Claims{}, "a+b@example.com", "user"},
+		{oidc.Claims{Name: "Bob Smith"}, "b@example.com", "BobSmith"},
+		{oidc.Claims{PreferredUsername: "..jo__"}, "jo@example.com", "user"},
+		{oidc.Claims{PreferredUsername: strings.Repeat("x", 60)}, "x@example.com", strings.Repeat("x", 40)},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, oidcUsernameBase(&tc.claims, tc.email), tc.email)
+	}
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	config.Load()
+	mock := oidctest.NewProvider("planner", "")
+	defer mock.Close()
+	oidc.Set(oidc.NewProvider(oidc.Config{Name: "mock", Issuer: mock.Issuer(), ClientID: "planner"}))
+	defer oidc.Set()
+
+	router := setupTestRouter()
+	router.GET("/api/v1/auth/oidc/:provider/callback", OIDCCallback)
+
+	// 没有 cookie（回调链接被发给了别的浏览器）或 cookie 与 state 不一致时都拒绝
+	for name, cookie := range map[string]*http.Cookie{
+		"missing":  nil,
+		"mismatch": {Name: oidcStateCookie, Value: "attacker-state"},
+	} {
+		req, _ := http.NewRequest("GET", "/api/v1/auth/oidc/mock/callback?code=x&state=victim-state", nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusFound, w.Code, name)
+		location, err := url.Parse(w.Header().Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, errOIDCStateMismatch.Error(), location.Query().Get("error"), name)
+		assert.Empty(t, location.Query().Get("code"), name)
+	}
+}
+
+func TestSetOIDCStateCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	setOIDCStateCookie(c, "state-value", 600)
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1)
+	cookie := cookies[0]
+	assert.Equal(t, oidcStateCookie, cookie.Name)
+	assert.Equal(t, "state-value", cookie.Value)
+	assert.Equal(t, "/api/v1/auth/oidc", cookie.Path)
+	assert.Equal(t, 600, cookie.MaxAge)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+}
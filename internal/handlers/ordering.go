@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 相邻元素 order_index 的初始间隔，移动时取两者中点，间隔耗尽时重新压缩
+const orderGap = 1024
+
+// orderedItem 计划内元素的当前排序
+type orderedItem struct {
+	id         string
+	orderIndex *int
+	start      *time.Time
+}
+
+// ReorderItems 重新排序元素：提交完整的ID顺序，或将一个元素移动到另一个元素之前/之后
+func ReorderItems(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.ReorderItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	hasList := len(req.ItemIDs) > 0
+	moving := req.ItemID != "" && (req.BeforeID != "") != (req.AfterID != "")
+	if hasList == moving {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请提供 item_ids，或 item_id 加 before_id/after_id 其中之一",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var result models.ReorderResult
+	var badRequest string
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		items, err := lockPlanOrder(tx, planID)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, len(items))
+		for i, item := range items {
+			ids[i] = item.id
+		}
+
+		if hasList {
+			ordered, err := applyOrderedList(ids, req.ItemIDs)
+			if err != nil {
+				badRequest = err.Error()
+				return err
+			}
+			result.Compacted = true
+			return writeCompactOrder(tx, ordered)
+		}
+
+		target, after := req.BeforeID, false
+		if req.AfterID != "" {
+			target, after = req.AfterID, true
+		}
+		ordered, err := moveInOrder(ids, req.ItemID, target, after)
+		if err != nil {
+			badRequest = err.Error()
+			return err
+		}
+
+		// 存在未设置索引的旧数据时整体压缩一次，之后的移动只需更新被移动的元素
+		if hasMissingIndex(items) {
+			result.Compacted = true
+			return writeCompactOrder(tx, ordered)
+		}
+
+		index := make(map[string]*int, len(items))
+		for _, item := range items {
+			index[item.id] = item.orderIndex
+		}
+		pos := indexOf(ordered, req.ItemID)
+		var prev, next *int
+		if pos > 0 {
+			prev = index[ordered[pos-1]]
+		}
+		if pos < len(ordered)-1 {
+			next = index[ordered[pos+1]]
+		}
+
+		slot, ok := orderSlot(prev, next)
+		if !ok {
+			result.Compacted = true
+			return writeCompactOrder(tx, ordered)
+		}
+		_, err = tx.Exec("UPDATE travel_items SET order_index = $2 WHERE id = $1", req.ItemID, slot)
+		return err
+	})
+
+	if badRequest != "" {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   badRequest,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	items, err := loadPlanOrder(database.GetDB(), planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	result.Items = make([]models.ItemOrder, len(items))
+	for i, item := range items {
+		result.Items[i] = models.ItemOrder{ID: item.id, OrderIndex: item.orderIndex}
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      result,
+		Message:   "排序已更新",
+		Timestamp: time.Now(),
+	})
+}
+
+// nextOrderIndex 为新元素计算 order_index：有开始时间时插在按顺序最后一个不晚于它的元素之后，否则追加到末尾
+func nextOrderIndex(tx *sql.Tx, planID string, start *time.Time) (int, error) {
+	return slotOrderIndex(tx, planID, "", start)
+}
+
+// reslotItem 元素开始时间变化后，按新的开始时间把它移到相应位置
+func reslotItem(tx *sql.Tx, planID, itemID string, start *time.Time) error {
+	orderIndex, err := slotOrderIndex(tx, planID, itemID, start)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("UPDATE travel_items SET order_index = $2 WHERE id = $1", itemID, orderIndex)
+	return err
+}
+
+// slotOrderIndex 按开始时间在其余元素之间计算 order_index，itemID 非空时不考虑该元素自身
+func slotOrderIndex(tx *sql.Tx, planID, itemID string, start *time.Time) (int, error) {
+	items, err := lockPlanOrder(tx, planID)
+	if err != nil {
+		return 0, err
+	}
+	items = withoutItem(items, itemID)
+
+	if hasMissingIndex(items) {
+		ids := make([]string, len(items))
+		for i, item := range items {
+			ids[i] = item.id
+		}
+		if err := writeCompactOrder(tx, ids); err != nil {
+			return 0, err
+		}
+		for i := range items {
+			value := (i + 1) * orderGap
+			items[i].orderIndex = &value
+		}
+	}
+
+	pos := insertPosition(items, start)
+	var prev, next *int
+	if pos > 0 {
+		prev = items[pos-1].orderIndex
+	}
+	if pos < len(items) {
+		next = items[pos].orderIndex
+	}
+
+	if slot, ok := orderSlot(prev, next); ok {
+		return slot, nil
+	}
+
+	// 间隔耗尽：压缩后在新位置留出空位
+	ids := make([]string, 0, len(items)+1)
+	for i, item := range items {
+		if i == pos {
+			ids = append(ids, "")
+		}
+		ids = append(ids, item.id)
+	}
+	if pos == len(items) {
+		ids = append(ids, "")
+	}
+	if err := writeCompactOrder(tx, ids); err != nil {
+		return 0, err
+	}
+	return (pos + 1) * orderGap, nil
+}
+
+// lockPlanOrder 锁定计划并按当前顺序加载其元素，避免并发排序互相覆盖
+func lockPlanOrder(tx *sql.Tx, planID string) ([]orderedItem, error) {
+	if _, err := tx.Exec("SELECT id FROM plans WHERE id = $1 FOR UPDATE", planID); err != nil {
+		return nil, err
+	}
+	return loadPlanOrder(tx, planID)
+}
+
+// loadPlanOrder 按 order_index 加载计划元素，未设置索引的旧数据按开始时间排在后面
+func loadPlanOrder(q interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}, planID string) ([]orderedItem, error) {
+	rows, err := q.Query(`
+		SELECT id, order_index, start_datetime FROM travel_items
+		WHERE plan_id = $1
+		ORDER BY order_index NULLS LAST, start_datetime NULLS LAST, created_at, id
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []orderedItem
+	for rows.Next() {
+		var item orderedItem
+		if err := rows.Scan(&item.id, &item.orderIndex, &item.start); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// writeCompactOrder 按给定顺序以固定间隔重写 order_index，空ID表示预留的位置
+func writeCompactOrder(tx *sql.Tx, ids []string) error {
+	for i, id := range ids {
+		if id == "" {
+			continue
+		}
+		if _, err := tx.Exec("UPDATE travel_items SET order_index = $2 WHERE id = $1", id, (i+1)*orderGap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutItem 去掉指定元素，保持其余元素的顺序
+func withoutItem(items []orderedItem, itemID string) []orderedItem {
+	rest := make([]orderedItem, 0, len(items))
+	for _, item := range items {
+		if item.id != itemID {
+			rest = append(rest, item)
+		}
+	}
+	return rest
+}
+
+func hasMissingIndex(items []orderedItem) bool {
+	for _, item := range items {
+		if item.orderIndex == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// orderSlot 返回位于 prev 和 next 之间的索引；两者相邻没有空位时返回 false
+func orderSlot(prev, next *int) (int, bool) {
+	switch {
+	case prev == nil && next == nil:
+		return orderGap, true
+	case prev == nil:
+		return *next - orderGap, true
+	case next == nil:
+		return *prev + orderGap, true
+	case *next-*prev < 2:
+		return 0, false
+	default:
+		return *prev + (*next-*prev)/2, true
+	}
+}
+
+// insertPosition 新元素在当前顺序中的插入位置
+func insertPosition(items []orderedItem, start *time.Time) int {
+	if start == nil {
+		return len(items)
+	}
+	for i := len(items) - 1; i >= 0; i-- {
+		if items[i].start != nil && !items[i].start.After(*start) {
+			return i + 1
+		}
+	}
+	// 比所有已安排时间的元素都早：放在第一个有时间的元素之前
+	for i, item := range items {
+		if item.start != nil {
+			return i
+		}
+	}
+	return len(items)
+}
+
+// applyOrderedList 将 listed 中的元素按给定顺序放回它们原来占据的位置，其余元素位置不变
+func applyOrderedList(current, listed []string) ([]string, error) {
+	positions := make(map[string]int, len(current))
+	for i, id := range current {
+		positions[id] = i
+	}
+
+	seen := make(map[string]bool, len(listed))
+	slots := make([]int, 0, len(listed))
+	for _, id := range listed {
+		pos, ok := positions[id]
+		if !ok {
+			return nil, fmt.Errorf("元素不属于此计划: %s", id)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("元素重复: %s", id)
+		}
+		seen[id] = true
+		slots = append(slots, pos)
+	}
+
+	// 位置按升序依次分配给 listed 中的元素
+	sort.Ints(slots)
+	ordered := append([]string(nil), current...)
+	for i, id := range listed {
+		ordered[slots[i]] = id
+	}
+	return ordered, nil
+}
+
+// moveInOrder 将 item 移动到 target 之前（after 为 true 时为之后）
+func moveInOrder(current []string, item, target string, after bool) ([]string, error) {
+	if item == target {
+		return nil, fmt.Errorf("不能相对自身移动")
+	}
+	if indexOf(current, item) < 0 {
+		return nil, fmt.Errorf("元素不属于此计划: %s", item)
+	}
+	if indexOf(current, target) < 0 {
+		return nil, fmt.Errorf("元素不属于此计划: %s", target)
+	}
+
+	ordered := make([]string, 0, len(current))
+	for _, id := range current {
+		if id == item {
+			continue
+		}
+		if id == target && !after {
+			ordered = append(ordered, item)
+		}
+		ordered = append(ordered, id)
+		if id == target && after {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered, nil
+}
+
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
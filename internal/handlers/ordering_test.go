@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderSlot(t *testing.T) {
+	i := func(v int) *int { return &v }
+
+	slot, ok := orderSlot(nil, nil)
+	assert.True(t, ok)
+	assert.Equal(t, orderGap, slot)
+
+	slot, _ = orderSlot(i(1024), i(2048))
+	assert.Equal(t, 1536, slot)
+	slot, _ = orderSlot(nil, i(1024))
+	assert.Equal(t, 0, slot)
+	slot, _ = orderSlot(i(2048), nil)
+	assert.Equal(t, 3072, slot)
+
+	_, ok = orderSlot(i(5), i(6))
+	assert.False(t, ok)
+}
+
+func TestInsertPosition(t *testing.T) {
+	at := func(hour int) *time.Time {
+		v := time.Date(2025, 10, 1, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+	items := []orderedItem{
+		{id: "a", start: at(8)},
+		{id: "b", start: at(10)},
+		{id: "c", start: at(10)},
+		{id: "d"},
+	}
+
+	assert.Equal(t, 0, insertPosition(items, at(7)))
+	assert.Equal(t, 3, insertPosition(items, at(10)))
+	assert.Equal(t, 1, insertPosition(items, at(9)))
+	assert.Equal(t, 4, insertPosition(items, nil))
+
+	// 改期的元素不参与自身的定位
+	rest := withoutItem(items, "a")
+	assert.Equal(t, []string{"b", "c", "d"}, []string{rest[0].id, rest[1].id, rest[2].id})
+	assert.Equal(t, 2, insertPosition(rest, at(11)))
+	assert.Len(t, withoutItem(items, ""), 4)
+}
+
+func TestApplyOrderedList(t *testing.T) {
+	current := []string{"a", "b", "c", "d"}
+
+	ordered, err := applyOrderedList(current, []string{"d", "c", "b", "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"d", "c", "b", "a"}, ordered)
+
+	// 只重排部分元素时，未列出的元素保持原位置
+	ordered, err = applyOrderedList(current, []string{"d", "b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "d", "c", "b"}, ordered)
+
+	_, err = applyOrderedList(current, []string{"a", "x"})
+	assert.Error(t, err)
+	_, err = applyOrderedList(current, []string{"a", "a"})
+	assert.Error(t, err)
+}
+
+func TestMoveInOrder(t *testing.T) {
+	current := []string{"a", "b", "c", "d"}
+
+	ordered, err := moveInOrder(current, "d", "b", false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "d", "b", "c"}, ordered)
+
+	ordered, err = moveInOrder(current, "a", "c", true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c", "a", "d"}, ordered)
+
+	_, err = moveInOrder(current, "a", "a", true)
+	assert.Error(t, err)
+	_, err = moveInOrder(current, "a", "x", true)
+	assert.Error(t, err)
+}
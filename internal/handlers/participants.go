@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// GetParticipants 获取计划参与者列表
+func GetParticipants(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划的参与者",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	participants, err := loadParticipants(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      participants,
+		Timestamp: time.Now(),
+	})
+}
+
+// AddParticipant 添加计划参与者
+func AddParticipant(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var participant models.PlanParticipant
+	if err := c.ShouldBindJSON(&participant); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	participant.ID = uuid.New().String()
+	participant.PlanID = planID
+	participant.Name = strings.TrimSpace(participant.Name)
+	participant.CreatedAt = time.Now()
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`
+			INSERT INTO plan_participants (id, plan_id, name, user_id, email, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, participant.ID, participant.PlanID, participant.Name,
+			participant.UserID, participant.Email, participant.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return syncParticipantCount(tx, planID)
+	})
+
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, models.ApiResponse{
+				Success:   false,
+				Message:   "参与者名称已存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success:   true,
+		Data:      participant,
+		Message:   "参与者添加成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// RemoveParticipant 移除计划参与者
+func RemoveParticipant(c *gin.Context) {
+	planID := c.Param("planId")
+	participantID := c.Param("participantId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var removed int64
+	err := database.Transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM plan_participants WHERE id = $1 AND plan_id = $2", participantID, planID)
+		if err != nil {
+			return err
+		}
+		removed, _ = result.RowsAffected()
+		return syncParticipantCount(tx, planID)
+	})
+
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	if removed == 0 {
+		c.JSON(http.StatusNotFound, models.ApiResponse{
+			Success:   false,
+			Message:   "参与者不存在",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "参与者移除成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// loadParticipants 加载计划的所有参与者
+func loadParticipants(planID string) ([]models.PlanParticipant, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(`
+		SELECT id, plan_id, name, user_id, email, created_at
+		FROM plan_participants
+		WHERE plan_id = $1
+		ORDER BY created_at, name
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []models.PlanParticipant{}
+	for rows.Next() {
+		var p models.PlanParticipant
+		if err := rows.Scan(&p.ID, &p.PlanID, &p.Name, &p.UserID, &p.Email, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// syncParticipantCount 具名参与者多于计划人数时把人数调大。
+// plans.participants 是出行人数，可以多于具名参与者（如4人出行只登记了付款人），因此不会调小
+func syncParticipantCount(tx *sql.Tx, planID string) error {
+	_, err := tx.Exec(`
+		UPDATE plans SET participants = GREATEST(participants, (SELECT COUNT(*) FROM plan_participants WHERE plan_id = $1))
+		WHERE id = $1
+	`, planID)
+	return err
+}
@@ -0,0 +1,845 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+	"planner/internal/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// documentItemTypes 导入文档允许的元素类型
+var documentItemTypes = map[models.ItemType]bool{
+	models.ItemTypeAccommodation: true,
+	models.ItemTypeTransport:     true,
+	models.ItemTypeAttraction:    true,
+	models.ItemTypePhotoSpot:     true,
+	models.ItemTypeRestArea:      true,
+	models.ItemTypeCheckpoint:    true,
+	models.ItemTypeOther:         true,
+}
+
+// ExportPlanJSON 导出计划为JSON文档，包含元素详情、关联、标注、参与者、预算和附件元数据
+func ExportPlanJSON(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权导出此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	doc, err := loadPlanDocument(planID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "计划不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	// 设置响应头
+	c.Header("Content-Type", "application/json")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"plan-%s.json\"", planID))
+
+	c.JSON(http.StatusOK, doc)
+}
+
+// ImportPlanJSON 从导出的JSON文档创建新计划，所有ID重新生成，任一部分失败则整体回滚
+func ImportPlanJSON(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var doc models.PlanDocument
+	if err := c.ShouldBindJSON(&doc); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if errs := validatePlanDocument(&doc); len(errs) > 0 {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Data:      errs,
+			Message:   "导入文档校验失败: " + errs[0],
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	remapPlanDocument(&doc, func() string { return uuid.New().String() })
+
+	copied, err := copyDocumentAttachments(c.Request.Context(), &doc, userID)
+	if err != nil {
+		removeStoredFiles(copied)
+		c.Error(err)
+		return
+	}
+
+	if err := database.Transaction(func(tx *sql.Tx) error {
+		return insertPlanDocument(tx, &doc, userID)
+	}); err != nil {
+		removeStoredFiles(copied)
+		respondItemError(c, err)
+		return
+	}
+
+	// 复制的图片重新生成缩略图并提取EXIF
+	for _, a := range doc.Attachments {
+		if a.StorageKey != nil && a.FileType == "image" {
+			go processImageAttachment(a.ID, a.ItemID, *a.StorageKey)
+		}
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":    doc.Plan.ID,
+			"items": len(doc.Items),
+		},
+		Message:   "计划导入成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// loadPlanDocument 加载计划的完整导出文档，时间按元素所在时区输出
+func loadPlanDocument(planID string) (*models.PlanDocument, error) {
+	db := database.GetDB()
+
+	doc := &models.PlanDocument{
+		Version:     models.PlanDocumentVersion,
+		ExportedAt:  time.Now(),
+		Relations:   []models.ItemRelation{},
+		Annotations: []models.ItemAnnotation{},
+		Attachments: []models.ItemAttachment{},
+	}
+
+	plan := &doc.Plan
+	err := db.QueryRow(`
+		SELECT id, user_id, name, description, destination, start_date, end_date,
+			budget, base_currency, time_zone, participants, status, visibility, tags, created_at, updated_at
+		FROM plans WHERE id = $1
+	`, planID).Scan(&plan.ID, &plan.UserID, &plan.Name, &plan.Description,
+		&plan.Destination, &plan.StartDate, &plan.EndDate, &plan.Budget,
+		&plan.BaseCurrency, &plan.TimeZone, &plan.Participants, &plan.Status, &plan.Visibility,
+		pq.Array(&plan.Tags), &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if doc.Participants, err = loadParticipants(planID); err != nil {
+		return nil, err
+	}
+	if doc.Items, err = loadDocumentItems(planID, plan.TimeZone); err != nil {
+		return nil, err
+	}
+	if doc.BudgetItems, err = loadBudgetItems(planID, "", ""); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT r.id, r.source_item_id, r.target_item_id, r.relation_type, r.relation_properties, r.created_at
+		FROM item_relations r
+		JOIN travel_items t ON t.id = r.source_item_id
+		WHERE t.plan_id = $1
+		ORDER BY r.created_at
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.ItemRelation
+		if err := rows.Scan(&r.ID, &r.SourceItemID, &r.TargetItemID,
+			&r.RelationType, &r.RelationProperties, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		doc.Relations = append(doc.Relations, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	annotationRows, err := db.Query(`
+		SELECT a.id, a.item_id, a.annotation_type, a.content,
+			a.marker_lat, a.marker_lng, a.rating,
+			a.created_by, a.created_at, a.updated_at
+		FROM item_annotations a
+		JOIN travel_items t ON t.id = a.item_id
+		WHERE t.plan_id = $1
+		ORDER BY a.created_at
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer annotationRows.Close()
+	for annotationRows.Next() {
+		var a models.ItemAnnotation
+		if err := annotationRows.Scan(&a.ID, &a.ItemID, &a.AnnotationType, &a.Content,
+			&a.MarkerLat, &a.MarkerLng, &a.Rating,
+			&a.CreatedBy, &a.CreatedAt, &a.UpdatedAt); err != nil {
+			return nil, err
+		}
+		doc.Annotations = append(doc.Annotations, a)
+	}
+	if err := annotationRows.Err(); err != nil {
+		return nil, err
+	}
+
+	attachmentRows, err := db.Query(attachmentSelect+`
+		WHERE item_id IN (SELECT id FROM travel_items WHERE plan_id = $1)
+		ORDER BY item_id, order_index NULLS LAST, uploaded_at
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer attachmentRows.Close()
+	for attachmentRows.Next() {
+		attachment, err := scanAttachment(attachmentRows)
+		if err != nil {
+			return nil, err
+		}
+		doc.Attachments = append(doc.Attachments, *attachment)
+	}
+	return doc, attachmentRows.Err()
+}
+
+// loadDocumentItems 加载计划的所有元素及其类型详情
+func loadDocumentItems(planID string, planZone *string) ([]models.PlanDocumentItem, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(`
+		SELECT id, plan_id, item_type, name, description,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, priority, status, booking_status,
+			properties, images, notes, tags,
+			order_index, group_id,
+			created_by, created_at, updated_at
+		FROM travel_items WHERE plan_id = $1
+		ORDER BY order_index NULLS LAST, start_datetime, created_at
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PlanDocumentItem{}
+	index := map[string]int{}
+	for rows.Next() {
+		var item models.PlanDocumentItem
+		err := rows.Scan(
+			&item.ID, &item.PlanID, &item.ItemType, &item.Name, &item.Description,
+			&item.Latitude, &item.Longitude, &item.Altitude, &item.Address,
+			&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
+			&item.Cost, &item.Priority, &item.Status, &item.BookingStatus,
+			&item.Properties, pq.Array(&item.Images), &item.Notes, pq.Array(&item.Tags),
+			&item.OrderIndex, &item.GroupID,
+			&item.CreatedBy, &item.CreatedAt, &item.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		// 旧数据的状态值按新状态导出，保证导出文档能再次导入
+		item.Status = normalizeItemStatus(item.Status)
+		// 导出时间使用元素所在时区的本地时间，时刻不变
+		loc := resolveLocation(item.TimeZone, planZone)
+		item.StartDatetime = localTime(item.StartDatetime, loc)
+		item.EndDatetime = localTime(item.EndDatetime, loc)
+		index[item.ID] = len(items)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	accommodationRows, err := db.Query(`
+		SELECT a.item_id, a.hotel_name, a.room_type, a.check_in_time::text, a.check_out_time::text,
+			a.guests_count, COALESCE(a.breakfast_included, false), a.booking_platform, a.booking_number,
+			a.booking_url, a.phone, a.email, a.rating, a.amenities,
+			a.price_per_night, a.total_nights, a.taxes_fees, a.cancellation_policy
+		FROM accommodation_details a
+		JOIN travel_items t ON t.id = a.item_id
+		WHERE t.plan_id = $1
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer accommodationRows.Close()
+	for accommodationRows.Next() {
+		var d models.AccommodationDetails
+		if err := accommodationRows.Scan(&d.ItemID, &d.HotelName, &d.RoomType, &d.CheckInTime, &d.CheckOutTime,
+			&d.GuestsCount, &d.BreakfastIncluded, &d.BookingPlatform, &d.BookingNumber,
+			&d.BookingURL, &d.Phone, &d.Email, &d.Rating, &d.Amenities,
+			&d.PricePerNight, &d.TotalNights, &d.TaxesFees, &d.CancellationPolicy); err != nil {
+			return nil, err
+		}
+		if i, ok := index[d.ItemID]; ok {
+			items[i].AccommodationDetails = &d
+		}
+	}
+	if err := accommodationRows.Err(); err != nil {
+		return nil, err
+	}
+
+	transportRows, err := db.Query(`
+		SELECT d.item_id, d.transport_type, d.departure_location, d.arrival_location,
+			d.departure_time, d.arrival_time, d.departure_time_zone, d.arrival_time_zone, d.distance_km,
+			d.booking_reference, d.carrier_name, d.vehicle_number, d.seat_number,
+			d.route_polyline, d.elevation_profile, d.road_conditions, d.fuel_stations, d.rest_stops,
+			d.estimated_fuel_cost, d.toll_cost, d.departure_terminal, d.arrival_terminal, d.transfer_info
+		FROM transport_details d
+		JOIN travel_items t ON t.id = d.item_id
+		WHERE t.plan_id = $1
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer transportRows.Close()
+	for transportRows.Next() {
+		var d models.TransportDetails
+		if err := transportRows.Scan(&d.ItemID, &d.TransportType, &d.DepartureLocation, &d.ArrivalLocation,
+			&d.DepartureTime, &d.ArrivalTime, &d.DepartureTimeZone, &d.ArrivalTimeZone, &d.DistanceKm,
+			&d.BookingReference, &d.CarrierName, &d.VehicleNumber, &d.SeatNumber,
+			&d.RoutePolyline, &d.ElevationProfile, &d.RoadConditions, &d.FuelStations, &d.RestStops,
+			&d.EstimatedFuelCost, &d.TollCost, &d.DepartureTerminal, &d.ArrivalTerminal, &d.TransferInfo); err != nil {
+			return nil, err
+		}
+		if i, ok := index[d.ItemID]; ok {
+			itemZone := items[i].TimeZone
+			d.DepartureTime = localTime(d.DepartureTime, resolveLocation(d.DepartureTimeZone, itemZone, planZone))
+			d.ArrivalTime = localTime(d.ArrivalTime, resolveLocation(d.ArrivalTimeZone, itemZone, planZone))
+			items[i].TransportDetails = &d
+		}
+	}
+	if err := transportRows.Err(); err != nil {
+		return nil, err
+	}
+
+	attractionRows, err := db.Query(`
+		SELECT d.item_id, d.attraction_type, d.opening_hours, d.ticket_price, d.ticket_type,
+			COALESCE(d.advance_booking_required, false), d.best_visit_time, d.recommended_duration,
+			d.difficulty_level, d.photography_tips, d.best_photo_spots,
+			d.sunrise_time::text, d.sunset_time::text, d.facilities, d.accessibility_info
+		FROM attraction_details d
+		JOIN travel_items t ON t.id = d.item_id
+		WHERE t.plan_id = $1
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer attractionRows.Close()
+	for attractionRows.Next() {
+		var d models.AttractionDetails
+		if err := attractionRows.Scan(&d.ItemID, &d.AttractionType, &d.OpeningHours, &d.TicketPrice, &d.TicketType,
+			&d.AdvanceBookingRequired, &d.BestVisitTime, &d.RecommendedDuration,
+			&d.DifficultyLevel, &d.PhotographyTips, &d.BestPhotoSpots,
+			&d.SunriseTime, &d.SunsetTime, &d.Facilities, &d.AccessibilityInfo); err != nil {
+			return nil, err
+		}
+		if i, ok := index[d.ItemID]; ok {
+			items[i].AttractionDetails = &d
+		}
+	}
+	return items, attractionRows.Err()
+}
+
+// validatePlanDocument 校验导入文档的版本、字段取值和文档内的引用关系，返回全部错误
+func validatePlanDocument(doc *models.PlanDocument) []string {
+	errs := []string{}
+	addf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if doc.Version < 1 || doc.Version > models.PlanDocumentVersion {
+		addf("不支持的文档版本: %d", doc.Version)
+		return errs
+	}
+
+	plan := &doc.Plan
+	if strings.TrimSpace(plan.Name) == "" {
+		addf("plan: 计划名称不能为空")
+	}
+	if plan.BaseCurrency != "" && !isCurrencyCode(strings.ToUpper(plan.BaseCurrency)) {
+		addf("plan: 无效的基准货币: %s", plan.BaseCurrency)
+	}
+	if err := validateTimeZone(plan.TimeZone); err != nil {
+		addf("plan: %v", err)
+	}
+
+	participants := map[string]bool{}
+	names := map[string]bool{}
+	for i, p := range doc.Participants {
+		switch {
+		case p.ID == "":
+			addf("participants[%d]: 缺少ID", i)
+		case participants[p.ID]:
+			addf("participants[%d]: 重复的ID %s", i, p.ID)
+		}
+		participants[p.ID] = true
+		if strings.TrimSpace(p.Name) == "" {
+			addf("participants[%d]: 名称不能为空", i)
+		} else if names[p.Name] {
+			addf("participants[%d]: 重复的参与者名称 %s", i, p.Name)
+		}
+		names[p.Name] = true
+	}
+
+	items := map[string]bool{}
+	for i, item := range doc.Items {
+		at := fmt.Sprintf("items[%d]", i)
+		switch {
+		case item.ID == "":
+			addf("%s: 缺少ID", at)
+		case items[item.ID]:
+			addf("%s: 重复的ID %s", at, item.ID)
+		}
+		items[item.ID] = true
+
+		if strings.TrimSpace(item.Name) == "" {
+			addf("%s: 名称不能为空", at)
+		}
+		if !documentItemTypes[item.ItemType] {
+			addf("%s: 无效的元素类型: %s", at, item.ItemType)
+		}
+		if item.Priority != 0 && (item.Priority < 1 || item.Priority > 5) {
+			addf("%s: 优先级必须在 1-5 之间", at)
+		}
+		if item.Status != "" {
+			if _, ok := itemStatusTransitions[canonicalItemStatus(item.Status)]; !ok {
+				addf("%s: 无效的状态: %s", at, item.Status)
+			}
+		}
+		if item.BookingStatus != nil && *item.BookingStatus != "" {
+			if _, ok := bookingStatusTransitions[*item.BookingStatus]; !ok {
+				addf("%s: 无效的预订状态: %s", at, *item.BookingStatus)
+			}
+		}
+		if err := validateTimeZone(item.TimeZone); err != nil {
+			addf("%s: %v", at, err)
+		}
+		if item.StartDatetime != nil && item.EndDatetime != nil && item.EndDatetime.Before(*item.StartDatetime) {
+			addf("%s: 结束时间早于开始时间", at)
+		}
+
+		if item.AccommodationDetails != nil && item.ItemType != models.ItemTypeAccommodation {
+			addf("%s: %s 类型的元素不能包含住宿详情", at, item.ItemType)
+		}
+		if d := item.TransportDetails; d != nil {
+			if item.ItemType != models.ItemTypeTransport {
+				addf("%s: %s 类型的元素不能包含交通详情", at, item.ItemType)
+			}
+			if err := validateTimeZone(d.DepartureTimeZone); err != nil {
+				addf("%s.transport_details: %v", at, err)
+			}
+			if err := validateTimeZone(d.ArrivalTimeZone); err != nil {
+				addf("%s.transport_details: %v", at, err)
+			}
+		}
+		if d := item.AttractionDetails; d != nil {
+			if item.ItemType != models.ItemTypeAttraction && item.ItemType != models.ItemTypePhotoSpot {
+				addf("%s: %s 类型的元素不能包含景点详情", at, item.ItemType)
+			}
+			if err := validateOpeningHours(d.OpeningHours); err != nil {
+				addf("%s.attraction_details: 开放时间无效: %v", at, err)
+			}
+		}
+	}
+
+	relations := map[string]bool{}
+	for i, r := range doc.Relations {
+		at := fmt.Sprintf("relations[%d]", i)
+		if !items[r.SourceItemID] {
+			addf("%s: 源元素不存在: %s", at, r.SourceItemID)
+		}
+		if !items[r.TargetItemID] {
+			addf("%s: 目标元素不存在: %s", at, r.TargetItemID)
+		}
+		if r.RelationType == "" {
+			addf("%s: 缺少关联类型", at)
+		}
+		key := r.SourceItemID + "|" + r.TargetItemID + "|" + r.RelationType
+		if relations[key] {
+			addf("%s: 重复的关联", at)
+		}
+		relations[key] = true
+	}
+
+	for i, a := range doc.Annotations {
+		if !items[a.ItemID] {
+			addf("annotations[%d]: 元素不存在: %s", i, a.ItemID)
+		}
+		if a.Content == "" {
+			addf("annotations[%d]: 内容不能为空", i)
+		}
+	}
+
+	for i, a := range doc.Attachments {
+		if !items[a.ItemID] {
+			addf("attachments[%d]: 元素不存在: %s", i, a.ItemID)
+		}
+		// 文件地址可以为空：导入时未能复制文件的附件只保留描述信息
+		if a.FileType == "" {
+			addf("attachments[%d]: 缺少文件类型", i)
+		}
+	}
+
+	for i := range doc.BudgetItems {
+		b := doc.BudgetItems[i]
+		at := fmt.Sprintf("budget_items[%d]", i)
+		if b.ItemID != nil && *b.ItemID != "" && !items[*b.ItemID] {
+			addf("%s: 关联的元素不存在: %s", at, *b.ItemID)
+		}
+		if b.Category == "" {
+			addf("%s: 缺少分类", at)
+		}
+		if b.Currency == "" {
+			b.Currency = "CNY"
+		}
+		if !isCurrencyCode(strings.ToUpper(b.Currency)) {
+			addf("%s: 无效的货币代码: %s", at, b.Currency)
+		}
+		if b.PaymentStatus != "" && !paymentStatuses[b.PaymentStatus] {
+			addf("%s: 无效的支付状态: %s", at, b.PaymentStatus)
+		}
+		if b.SplitType == "" {
+			b.SplitType = models.SplitTypeEqual
+		}
+		if err := validateSplit(&b, doc.Participants); err != nil {
+			addf("%s: %v", at, err)
+		}
+	}
+
+	return errs
+}
+
+// remapPlanDocument 为文档中的计划和所有记录生成新ID，并同步改写文档内的引用。
+// 调用前文档须已通过 validatePlanDocument 校验
+func remapPlanDocument(doc *models.PlanDocument, newID func() string) {
+	doc.Plan.ID = newID()
+
+	participants := make(map[string]string, len(doc.Participants))
+	for i := range doc.Participants {
+		p := &doc.Participants[i]
+		participants[p.ID] = newID()
+		p.ID = participants[p.ID]
+		p.PlanID = doc.Plan.ID
+	}
+
+	items := make(map[string]string, len(doc.Items))
+	for i := range doc.Items {
+		item := &doc.Items[i]
+		items[item.ID] = newID()
+		item.ID = items[item.ID]
+		item.PlanID = doc.Plan.ID
+		if item.AccommodationDetails != nil {
+			item.AccommodationDetails.ItemID = item.ID
+		}
+		if item.TransportDetails != nil {
+			item.TransportDetails.ItemID = item.ID
+		}
+		if item.AttractionDetails != nil {
+			item.AttractionDetails.ItemID = item.ID
+		}
+	}
+
+	for i := range doc.Relations {
+		r := &doc.Relations[i]
+		r.ID = newID()
+		r.SourceItemID = items[r.SourceItemID]
+		r.TargetItemID = items[r.TargetItemID]
+	}
+	for i := range doc.Annotations {
+		a := &doc.Annotations[i]
+		a.ID = newID()
+		a.ItemID = items[a.ItemID]
+	}
+	for i := range doc.Attachments {
+		a := &doc.Attachments[i]
+		a.ID = newID()
+		a.ItemID = items[a.ItemID]
+		// 存储中的文件由 copyDocumentAttachments 复制到新路径，这里不沿用原路径
+		a.StorageKey = nil
+	}
+
+	for i := range doc.BudgetItems {
+		b := &doc.BudgetItems[i]
+		b.ID = newID()
+		b.PlanID = doc.Plan.ID
+		if b.ItemID != nil && *b.ItemID != "" {
+			id := items[*b.ItemID]
+			b.ItemID = &id
+		}
+		if b.PaidBy != nil && *b.PaidBy != "" {
+			id := participants[*b.PaidBy]
+			b.PaidBy = &id
+		} else {
+			b.PaidBy = nil
+		}
+		if len(b.SplitDetails) > 0 {
+			split := make(models.SplitDetails, len(b.SplitDetails))
+			for id, value := range b.SplitDetails {
+				split[participants[id]] = value
+			}
+			b.SplitDetails = split
+		}
+	}
+}
+
+// copyDocumentAttachments 将导入文档中的附件文件复制到新的存储路径，返回已复制的路径（失败时用于清理）。
+// 只复制导入用户自己计划中的已存储文件；其他地址（他人的文件、外部链接、原文件已删除）
+// 只导入附件信息，不保留文件地址，避免通过导入引用他人的文件。调用前文档须已重新映射ID
+func copyDocumentAttachments(ctx context.Context, doc *models.PlanDocument, userID string) ([]string, error) {
+	db := database.GetDB()
+	store := storage.Get()
+
+	var copied []string
+	for i := range doc.Attachments {
+		a := &doc.Attachments[i]
+		if a.FileURL == "" {
+			detachAttachmentFile(a)
+			continue
+		}
+
+		var sourceKey string
+		var size sql.NullInt64
+		var mimeType sql.NullString
+		err := db.QueryRow(`
+			SELECT a.storage_key, a.file_size, a.mime_type
+			FROM item_attachments a
+			JOIN travel_items t ON t.id = a.item_id
+			JOIN plans p ON p.id = t.plan_id
+			WHERE a.file_url = $1 AND a.storage_key IS NOT NULL AND p.user_id = $2
+			LIMIT 1
+		`, a.FileURL, userID).Scan(&sourceKey, &size, &mimeType)
+		if err == sql.ErrNoRows {
+			detachAttachmentFile(a)
+			continue
+		}
+		if err != nil {
+			return copied, err
+		}
+
+		key := fmt.Sprintf("attachments/%s/%s%s", a.ItemID, a.ID, filepath.Ext(sourceKey))
+		if err := copyStoredFile(ctx, store, sourceKey, key, size.Int64, mimeType.String); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				detachAttachmentFile(a)
+				continue
+			}
+			return copied, fmt.Errorf("复制附件文件失败: %v", err)
+		}
+		copied = append(copied, key)
+
+		a.StorageKey = &key
+		a.FileURL = store.URL(key)
+		a.Thumbnails = nil
+		a.ProcessingStatus = nil
+		if a.FileType == "image" {
+			status := models.AttachmentProcessingPending
+			a.ProcessingStatus = &status
+		}
+	}
+	return copied, nil
+}
+
+// detachAttachmentFile 附件只保留描述信息，去掉文件地址和缩略图
+func detachAttachmentFile(a *models.ItemAttachment) {
+	a.FileURL = ""
+	a.StorageKey = nil
+	a.Thumbnails = nil
+	a.ProcessingStatus = nil
+}
+
+func copyStoredFile(ctx context.Context, store storage.Storage, from, to string, size int64, contentType string) error {
+	r, err := store.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return store.Put(ctx, to, r, size, contentType)
+}
+
+// insertPlanDocument 在事务中写入已重新映射ID的文档，计划归属于导入用户
+func insertPlanDocument(tx *sql.Tx, doc *models.PlanDocument, userID string) error {
+	now := time.Now()
+	plan := &doc.Plan
+
+	if plan.BaseCurrency == "" {
+		plan.BaseCurrency = "CNY"
+	}
+	plan.BaseCurrency = strings.ToUpper(plan.BaseCurrency)
+	if plan.TimeZone == nil || *plan.TimeZone == "" {
+		zone := defaultTimeZoneName()
+		plan.TimeZone = &zone
+	}
+	if plan.Status == "" {
+		plan.Status = "draft"
+	}
+	if plan.Participants < 1 {
+		plan.Participants = 1
+	}
+
+	// 导入的计划默认私有，和复制计划一致
+	_, err := tx.Exec(`
+		INSERT INTO plans (id, user_id, name, description, destination, start_date, end_date,
+			budget, base_currency, time_zone, participants, status, visibility, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, plan.ID, userID, plan.Name, plan.Description, plan.Destination, plan.StartDate, plan.EndDate,
+		plan.Budget, plan.BaseCurrency, plan.TimeZone, plan.Participants, plan.Status, "private",
+		pq.Array(plan.Tags), now, now)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range doc.Participants {
+		_, err := tx.Exec(`
+			INSERT INTO plan_participants (id, plan_id, name, user_id, email, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, p.ID, plan.ID, p.Name, p.UserID, p.Email, now)
+		if err != nil {
+			return err
+		}
+	}
+	if len(doc.Participants) > 0 {
+		if err := syncParticipantCount(tx, plan.ID); err != nil {
+			return err
+		}
+	}
+
+	for i := range doc.Items {
+		if err := insertDocumentItem(tx, &doc.Items[i], userID, now); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range doc.Relations {
+		_, err := tx.Exec(`
+			INSERT INTO item_relations (id, source_item_id, target_item_id, relation_type, relation_properties, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, r.ID, r.SourceItemID, r.TargetItemID, r.RelationType, r.RelationProperties, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, a := range doc.Annotations {
+		_, err := tx.Exec(`
+			INSERT INTO item_annotations (
+				id, item_id, annotation_type, content,
+				marker_lat, marker_lng, rating,
+				created_by, created_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, a.ID, a.ItemID, a.AnnotationType, a.Content,
+			a.MarkerLat, a.MarkerLng, a.Rating,
+			userID, now, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, a := range doc.Attachments {
+		_, err := tx.Exec(`
+			INSERT INTO item_attachments (
+				id, item_id, file_type, file_url, file_name, file_size, mime_type, checksum, storage_key,
+				thumbnails, processing_status, taken_at, gps_latitude, gps_longitude,
+				title, description, is_primary, order_index, uploaded_by, uploaded_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		`, a.ID, a.ItemID, a.FileType, a.FileURL, a.FileName, a.FileSize, a.MimeType, a.Checksum, a.StorageKey,
+			a.Thumbnails, a.ProcessingStatus, a.TakenAt, a.GPSLatitude, a.GPSLongitude,
+			a.Title, a.Description, a.IsPrimary, a.OrderIndex, userID, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, b := range doc.BudgetItems {
+		if b.Currency == "" {
+			b.Currency = "CNY"
+		}
+		if b.PaymentStatus == "" {
+			b.PaymentStatus = models.PaymentStatusPending
+		}
+		if b.SplitType == "" {
+			b.SplitType = models.SplitTypeEqual
+		}
+		_, err := tx.Exec(`
+			INSERT INTO budget_items (
+				id, plan_id, item_id, category, description,
+				estimated_amount, actual_amount, currency,
+				payment_method, payment_status, payment_date,
+				notes, receipt_url, paid_by, split_type, split_details, created_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+		`, b.ID, plan.ID, b.ItemID, b.Category, b.Description,
+			b.EstimatedAmount, b.ActualAmount, strings.ToUpper(b.Currency),
+			b.PaymentMethod, b.PaymentStatus, b.PaymentDate,
+			b.Notes, b.ReceiptURL, b.PaidBy, b.SplitType, b.SplitDetails, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertDocumentItem 写入文档中的元素及其类型详情，保留原有的状态、排序和分组
+func insertDocumentItem(tx *sql.Tx, item *models.PlanDocumentItem, userID string, now time.Time) error {
+	priority := item.Priority
+	if priority == 0 {
+		priority = 3
+	}
+	status := normalizeItemStatus(item.Status)
+
+	_, err := tx.Exec(`
+		INSERT INTO travel_items (
+			id, plan_id, item_type, name, description,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, priority, status, booking_status,
+			properties, images, notes, tags,
+			order_index, group_id,
+			created_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13,
+			$14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26)
+	`, item.ID, item.PlanID, item.ItemType, item.Name, item.Description,
+		item.Latitude, item.Longitude, item.Altitude, item.Address,
+		item.StartDatetime, item.EndDatetime, nullableTimeZone(item.TimeZone), item.DurationHours,
+		item.Cost, priority, status, item.BookingStatus,
+		item.Properties, pq.Array(item.Images), item.Notes, pq.Array(item.Tags),
+		item.OrderIndex, item.GroupID,
+		userID, now, now)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case item.AccommodationDetails != nil:
+		return insertAccommodationDetails(tx, item.AccommodationDetails)
+	case item.TransportDetails != nil:
+		return insertTransportDetails(tx, item.TransportDetails)
+	case item.AttractionDetails != nil:
+		return insertAttractionDetails(tx, item.AttractionDetails, false)
+	}
+	return nil
+}
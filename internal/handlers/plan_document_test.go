@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"planner/internal/models"
+	"planner/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func samplePlanDocument() models.PlanDocument {
+	start := time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+	amount := 300.0
+	return models.PlanDocument{
+		Version: models.PlanDocumentVersion,
+		Plan:    models.Plan{ID: "plan-1", Name: "川西环线", BaseCurrency: "CNY", TimeZone: strPtr("Asia/Shanghai")},
+		Participants: []models.PlanParticipant{
+			{ID: "p-a", Name: "阿明"},
+			{ID: "p-b", Name: "小林"},
+		},
+		Items: []models.PlanDocumentItem{
+			{
+				TravelItem:           models.TravelItem{ID: "hotel", ItemType: models.ItemTypeAccommodation, Name: "康定酒店"},
+				AccommodationDetails: &models.AccommodationDetails{ItemID: "hotel", HotelName: strPtr("康定酒店")},
+			},
+			{
+				TravelItem:        models.TravelItem{ID: "lake", ItemType: models.ItemTypeAttraction, Name: "木格措", StartDatetime: &start, EndDatetime: &end},
+				AttractionDetails: &models.AttractionDetails{ItemID: "lake", TicketPrice: &amount},
+			},
+		},
+		Relations:   []models.ItemRelation{{ID: "r1", SourceItemID: "hotel", TargetItemID: "lake", RelationType: models.RelationMustPrecede}},
+		Annotations: []models.ItemAnnotation{{ID: "n1", ItemID: "lake", Content: "早去人少"}},
+		BudgetItems: []models.BudgetItem{{
+			ID: "b1", ItemID: strPtr("lake"), Category: "门票", Currency: "CNY", PaymentStatus: models.PaymentStatusPaid,
+			ActualAmount: &amount, PaidBy: strPtr("p-a"), SplitType: models.SplitTypeExact,
+			SplitDetails: models.SplitDetails{"p-a": 100, "p-b": 200},
+		}},
+		Attachments: []models.ItemAttachment{{ID: "f1", ItemID: "lake", FileType: "image", FileURL: "/files/lake.jpg", StorageKey: strPtr("items/lake/f1.jpg")}},
+	}
+}
+
+func TestValidatePlanDocumentAcceptsExport(t *testing.T) {
+	doc := samplePlanDocument()
+
+	// 导出文档经过JSON序列化后应能原样通过校验
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var decoded models.PlanDocument
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Empty(t, validatePlanDocument(&decoded))
+	require.NotNil(t, decoded.Items[0].AccommodationDetails)
+	assert.Equal(t, "康定酒店", *decoded.Items[0].AccommodationDetails.HotelName)
+	assert.Nil(t, decoded.Attachments[0].StorageKey)
+}
+
+func TestValidatePlanDocumentReportsAllErrors(t *testing.T) {
+	doc := samplePlanDocument()
+	doc.Items[1].ItemType = "spaceship"
+	doc.Items[0].TimeZone = strPtr("Mars/Olympus")
+	doc.Relations[0].TargetItemID = "missing"
+	doc.BudgetItems[0].PaidBy = strPtr("p-x")
+
+	errs := validatePlanDocument(&doc)
+	assert.Len(t, errs, 5)
+	assert.Contains(t, errs, "items[1]: 无效的元素类型: spaceship")
+	assert.Contains(t, errs, "items[1]: spaceship 类型的元素不能包含景点详情")
+	assert.Contains(t, errs, "relations[0]: 目标元素不存在: missing")
+	assert.Contains(t, errs, "budget_items[0]: 付款人不是此计划的参与者")
+}
+
+func TestValidatePlanDocumentVersion(t *testing.T) {
+	doc := samplePlanDocument()
+	doc.Version = models.PlanDocumentVersion + 1
+	assert.Equal(t, []string{fmt.Sprintf("不支持的文档版本: %d", doc.Version)}, validatePlanDocument(&doc))
+}
+
+func TestRemapPlanDocument(t *testing.T) {
+	doc := samplePlanDocument()
+	n := 0
+	remapPlanDocument(&doc, func() string {
+		n++
+		return fmt.Sprintf("new-%d", n)
+	})
+
+	assert.Equal(t, "new-1", doc.Plan.ID)
+	a, b := doc.Participants[0].ID, doc.Participants[1].ID
+	hotel, lake := doc.Items[0].ID, doc.Items[1].ID
+	assert.Equal(t, []string{"new-2", "new-3", "new-4", "new-5"}, []string{a, b, hotel, lake})
+
+	assert.Equal(t, doc.Plan.ID, doc.Items[1].PlanID)
+	assert.Equal(t, hotel, doc.Items[0].AccommodationDetails.ItemID)
+	assert.Equal(t, lake, doc.Items[1].AttractionDetails.ItemID)
+	assert.Equal(t, hotel, doc.Relations[0].SourceItemID)
+	assert.Equal(t, lake, doc.Relations[0].TargetItemID)
+	assert.Equal(t, lake, doc.Annotations[0].ItemID)
+	assert.Equal(t, lake, doc.Attachments[0].ItemID)
+	assert.Nil(t, doc.Attachments[0].StorageKey)
+
+	budget := doc.BudgetItems[0]
+	assert.Equal(t, doc.Plan.ID, budget.PlanID)
+	assert.Equal(t, lake, *budget.ItemID)
+	assert.Equal(t, a, *budget.PaidBy)
+	assert.Equal(t, models.SplitDetails{a: 100, b: 200}, budget.SplitDetails)
+
+	// 重新映射后文档内的引用仍然完整
+	assert.Empty(t, validatePlanDocument(&doc))
+}
+
+func TestCopyStoredFile(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir(), "/uploads")
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, store.Put(ctx, "attachments/old/a.jpg", strings.NewReader("photo"), 5, "image/jpeg"))
+
+	require.NoError(t, copyStoredFile(ctx, store, "attachments/old/a.jpg", "attachments/new/b.jpg", 5, "image/jpeg"))
+	r, err := store.Get(ctx, "attachments/new/b.jpg")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "photo", string(data))
+
+	err = copyStoredFile(ctx, store, "attachments/old/missing.jpg", "attachments/new/c.jpg", 0, "")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestDetachAttachmentFile(t *testing.T) {
+	status := models.AttachmentProcessingDone
+	a := models.ItemAttachment{
+		FileType: "image", FileURL: "/uploads/attachments/other/x.jpg", StorageKey: strPtr("attachments/other/x.jpg"),
+		Thumbnails: models.JSONB{"small": "/uploads/thumbnails/other/x_small.jpg"}, ProcessingStatus: &status,
+		Title: strPtr("牛奶海"),
+	}
+	detachAttachmentFile(&a)
+
+	assert.Empty(t, a.FileURL)
+	assert.Nil(t, a.StorageKey)
+	assert.Nil(t, a.Thumbnails)
+	assert.Nil(t, a.ProcessingStatus)
+	assert.Equal(t, "牛奶海", *a.Title)
+}
+
+func TestRemapPlanDocumentClearsEmptyPayer(t *testing.T) {
+	doc := samplePlanDocument()
+	doc.BudgetItems[0].PaidBy = strPtr("")
+	doc.BudgetItems[0].SplitType = models.SplitTypeEqual
+	doc.BudgetItems[0].SplitDetails = nil
+	assert.Empty(t, validatePlanDocument(&doc))
+
+	remapPlanDocument(&doc, func() string { return uuid.New().String() })
+	assert.Nil(t, doc.BudgetItems[0].PaidBy)
+}
+
+func TestPlanDocumentRoundTripLegacyStatusAndDetachedFile(t *testing.T) {
+	doc := samplePlanDocument()
+	// 旧服务器导出的状态值，以及导入时未能复制文件、只保留描述信息的附件
+	doc.Items[0].Status = "pending"
+	doc.Items[1].Status = "completed"
+	detached := models.ItemAttachment{ID: "f2", ItemID: "hotel", FileType: "document", FileURL: "/files/other.pdf", Title: strPtr("订单")}
+	detachAttachmentFile(&detached)
+	doc.Attachments = append(doc.Attachments, detached)
+
+	data, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var decoded models.PlanDocument
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Empty(t, validatePlanDocument(&decoded))
+
+	// 导出和写入时都使用规范后的状态
+	assert.Equal(t, models.ItemStatusPlanned, normalizeItemStatus(decoded.Items[0].Status))
+	assert.Equal(t, models.ItemStatusDone, normalizeItemStatus(decoded.Items[1].Status))
+
+	// 再次导出的文档仍能导入
+	decoded.Items[0].Status = normalizeItemStatus(decoded.Items[0].Status)
+	decoded.Items[1].Status = normalizeItemStatus(decoded.Items[1].Status)
+	remapPlanDocument(&decoded, func() string { return uuid.New().String() })
+	assert.Empty(t, validatePlanDocument(&decoded))
+	assert.Empty(t, decoded.Attachments[1].FileURL)
+
+	decoded.Attachments[1].FileType = ""
+	assert.Equal(t, []string{"attachments[1]: 缺少文件类型"}, validatePlanDocument(&decoded))
+}
@@ -0,0 +1,632 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"planner/internal/config"
+	"planner/internal/models"
+	"planner/internal/pdf"
+
+	"github.com/gin-gonic/gin"
+)
+
+var itemTypeLabels = map[models.ItemType]string{
+	models.ItemTypeAccommodation: "住宿",
+	models.ItemTypeTransport:     "交通",
+	models.ItemTypeAttraction:    "景点",
+	models.ItemTypePhotoSpot:     "拍摄点",
+	models.ItemTypeRestArea:      "休息区",
+	models.ItemTypeCheckpoint:    "检查站",
+	models.ItemTypeOther:         "其他",
+}
+
+var paymentStatusLabels = map[string]string{
+	models.PaymentStatusPending:  "待支付",
+	models.PaymentStatusPartial:  "部分支付",
+	models.PaymentStatusPaid:     "已支付",
+	models.PaymentStatusRefunded: "已退款",
+}
+
+var bookingStatusLabels = map[string]string{
+	models.BookingStatusNotRequired: "无需预订",
+	models.BookingStatusPending:     "待确认",
+	models.BookingStatusConfirmed:   "已确认",
+	models.BookingStatusFailed:      "预订失败",
+	models.BookingStatusCancelled:   "已取消",
+}
+
+var weekdayLabels = [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// itineraryBooklet 行程手册使用的数据，每日行程和摘要与对应接口的数据一致
+type itineraryBooklet struct {
+	plan         models.Plan
+	summary      *models.PlanSummary
+	days         []models.DailyItinerary
+	items        []models.PlanDocumentItem
+	participants []models.PlanParticipant
+	budgetItems  []models.BudgetItem
+	emergency    [][2]string
+	exportedAt   time.Time
+}
+
+// ExportPlanPDF 导出计划为PDF行程手册：封面、每日行程、预订信息、预算和紧急信息
+func ExportPlanPDF(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权导出此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	booklet, err := loadItineraryBooklet(planID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "计划不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	data, err := renderItineraryBooklet(booklet).Bytes()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"plan-%s.pdf\"", planID))
+	c.Data(http.StatusOK, "application/pdf", data)
+}
+
+// loadItineraryBooklet 加载行程手册需要的全部数据
+func loadItineraryBooklet(planID string) (*itineraryBooklet, error) {
+	doc, err := loadPlanDocument(planID)
+	if err != nil {
+		return nil, err
+	}
+	days, err := loadDailyItinerary(planID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := loadPlanSummary(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &itineraryBooklet{
+		plan:         doc.Plan,
+		summary:      summary,
+		days:         days,
+		items:        doc.Items,
+		participants: doc.Participants,
+		budgetItems:  doc.BudgetItems,
+		emergency:    parseEmergencyContacts(config.Get().EmergencyContacts),
+		exportedAt:   time.Now(),
+	}, nil
+}
+
+// parseEmergencyContacts 解析 名称:号码 格式的紧急电话列表，忽略格式错误的条目
+func parseEmergencyContacts(raw string) [][2]string {
+	var contacts [][2]string
+	for _, entry := range strings.Split(raw, ",") {
+		name, number, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(number) == "" {
+			continue
+		}
+		contacts = append(contacts, [2]string{strings.TrimSpace(name), strings.TrimSpace(number)})
+	}
+	return contacts
+}
+
+// renderItineraryBooklet 排版行程手册
+func renderItineraryBooklet(b *itineraryBooklet) *pdf.Document {
+	doc := pdf.New()
+	doc.SetTitle(b.plan.Name)
+	w := &bookletWriter{doc: doc, footer: b.plan.Name}
+
+	w.cover(b)
+
+	w.newPage()
+	w.heading("每日行程")
+	details := make(map[string]*models.PlanDocumentItem, len(b.items))
+	for i := range b.items {
+		details[b.items[i].ID] = &b.items[i]
+	}
+	if len(b.days) == 0 {
+		w.paragraph("暂无已安排时间的行程", 10, 0.4, 0)
+	}
+	for i, day := range b.days {
+		w.dayHeader(i+1, day, b.plan.TimeZone)
+		for _, item := range day.Items {
+			w.itineraryItem(item, details[item.ID])
+		}
+		w.y += 8
+	}
+
+	var unscheduled []string
+	for _, item := range b.items {
+		if item.StartDatetime == nil {
+			unscheduled = append(unscheduled, fmt.Sprintf("%s（%s）", item.Name, itemTypeLabel(item.ItemType)))
+		}
+	}
+	if len(unscheduled) > 0 {
+		w.subheading("未安排时间")
+		for _, line := range unscheduled {
+			w.paragraph("· "+line, 10, 0, 8)
+		}
+	}
+
+	w.newPage()
+	w.bookings(b.items)
+	w.budget(b)
+	w.emergencyInfo(b)
+
+	return doc
+}
+
+// bookletWriter 按从上到下的顺序排版，空间不足时自动换页
+type bookletWriter struct {
+	doc    *pdf.Document
+	y      float64
+	footer string
+}
+
+const (
+	bookletMargin = 50.0
+	bookletWidth  = pdf.PageWidth - 2*bookletMargin
+)
+
+func (w *bookletWriter) newPage() {
+	w.doc.AddPage()
+	w.y = bookletMargin
+
+	size := w.doc.FontSize()
+	w.doc.SetFont(8, false)
+	footer := fmt.Sprintf("%s · 第 %d 页", w.footer, w.doc.PageCount())
+	w.doc.Text((pdf.PageWidth-w.doc.Width(footer))/2, pdf.PageHeight-bookletMargin/2, footer, 0.5)
+	w.doc.SetFont(size, false)
+}
+
+// ensure 当前页剩余高度不足 h 时换页，返回是否换页
+func (w *bookletWriter) ensure(h float64) bool {
+	if w.y+h <= pdf.PageHeight-bookletMargin {
+		return false
+	}
+	w.newPage()
+	return true
+}
+
+func (w *bookletWriter) heading(text string) {
+	w.ensure(40)
+	w.doc.SetFont(18, true)
+	w.y += 18
+	w.doc.Text(bookletMargin, w.y, text, 0)
+	w.y += 8
+	w.doc.Line(bookletMargin, w.y, bookletMargin+bookletWidth, w.y, 1, 0)
+	w.y += 16
+}
+
+func (w *bookletWriter) subheading(text string) {
+	w.ensure(36)
+	w.doc.FillRect(bookletMargin, w.y, bookletWidth, 20, 0.92)
+	w.doc.SetFont(12, true)
+	w.doc.Text(bookletMargin+6, w.y+14, text, 0)
+	w.y += 28
+}
+
+// paragraph 写一段自动折行的文字
+func (w *bookletWriter) paragraph(text string, size, gray, indent float64) {
+	w.doc.SetFont(size, false)
+	for _, line := range w.doc.WrapText(text, bookletWidth-indent) {
+		w.ensure(size * 1.5)
+		w.y += size * 1.2
+		w.doc.Text(bookletMargin+indent, w.y, line, gray)
+		w.y += size * 0.3
+	}
+}
+
+func (w *bookletWriter) cover(b *itineraryBooklet) {
+	w.doc.AddPage()
+	w.y = 220
+
+	w.doc.SetFont(26, true)
+	for _, line := range w.doc.WrapText(b.plan.Name, bookletWidth) {
+		w.doc.Text((pdf.PageWidth-w.doc.Width(line))/2, w.y, line, 0)
+		w.y += 36
+	}
+	if b.plan.Destination != "" {
+		w.doc.SetFont(14, false)
+		w.doc.Text((pdf.PageWidth-w.doc.Width(b.plan.Destination))/2, w.y, b.plan.Destination, 0.3)
+		w.y += 24
+	}
+	w.doc.Line(bookletMargin+100, w.y, pdf.PageWidth-bookletMargin-100, w.y, 0.8, 0.6)
+	w.y += 30
+
+	var facts [][2]string
+	if dates := bookletDateRange(b); dates != "" {
+		facts = append(facts, [2]string{"日期", dates})
+	}
+	if b.plan.TimeZone != nil && *b.plan.TimeZone != "" {
+		facts = append(facts, [2]string{"时区", *b.plan.TimeZone})
+	}
+	if len(b.participants) > 0 {
+		names := make([]string, len(b.participants))
+		for i, p := range b.participants {
+			names[i] = p.Name
+		}
+		facts = append(facts, [2]string{"参与者", strings.Join(names, "、")})
+	} else if b.plan.Participants > 0 {
+		facts = append(facts, [2]string{"人数", fmt.Sprintf("%d 人", b.plan.Participants)})
+	}
+	if s := b.summary; s != nil {
+		facts = append(facts, [2]string{"行程", fmt.Sprintf("共 %d 项：住宿 %d、交通 %d、景点 %d",
+			s.TotalItems, s.AccommodationDays, s.TransportCount, s.AttractionCount)})
+		budget := fmt.Sprintf("预计 %s，实际 %s", formatMoney(s.EstimatedCost, s.BaseCurrency), formatMoney(s.ActualCost, s.BaseCurrency))
+		if b.plan.Budget > 0 {
+			budget += fmt.Sprintf("（预算 %s）", formatMoney(b.plan.Budget, s.BaseCurrency))
+		}
+		facts = append(facts, [2]string{"费用", budget})
+	}
+
+	for _, fact := range facts {
+		w.doc.SetFont(11, true)
+		w.doc.Text(bookletMargin+60, w.y, fact[0], 0.3)
+		w.doc.SetFont(11, false)
+		for _, line := range w.doc.WrapText(fact[1], bookletWidth-180) {
+			w.doc.Text(bookletMargin+120, w.y, line, 0)
+			w.y += 16
+		}
+		w.y += 6
+	}
+
+	if b.plan.Description != "" {
+		w.y += 14
+		w.doc.SetFont(10, false)
+		for _, line := range w.doc.WrapText(b.plan.Description, bookletWidth-120) {
+			w.doc.Text(bookletMargin+60, w.y, line, 0.4)
+			w.y += 14
+		}
+	}
+
+	w.doc.SetFont(9, false)
+	exported := "导出时间 " + b.exportedAt.In(resolveLocation(b.plan.TimeZone)).Format("2006-01-02 15:04")
+	w.doc.Text((pdf.PageWidth-w.doc.Width(exported))/2, pdf.PageHeight-bookletMargin, exported, 0.5)
+}
+
+func (w *bookletWriter) dayHeader(n int, day models.DailyItinerary, planZone *string) {
+	title := fmt.Sprintf("第 %d 天 · %s", n, day.Date)
+	if date, err := time.Parse("2006-01-02", day.Date); err == nil {
+		title += " " + weekdayLabels[date.Weekday()]
+	}
+	if planZone == nil || day.TimeZone != *planZone {
+		title += " · " + day.TimeZone
+	}
+	w.subheading(title)
+}
+
+// itineraryItem 写一个行程元素：时间、名称以及预订号、电话等关键信息
+func (w *bookletWriter) itineraryItem(item models.TravelItem, details *models.PlanDocumentItem) {
+	lines := itineraryItemLines(item, details)
+	w.ensure(18 + float64(len(lines))*14)
+
+	clock := ""
+	if item.StartDatetime != nil {
+		clock = item.StartDatetime.Format("15:04")
+		if item.EndDatetime != nil {
+			clock += "-" + item.EndDatetime.Format("15:04")
+		}
+	}
+	w.doc.SetFont(10, false)
+	w.doc.Text(bookletMargin+6, w.y+12, clock, 0.3)
+	w.doc.SetFont(11, true)
+	w.doc.Text(bookletMargin+80, w.y+12, fmt.Sprintf("[%s] %s", itemTypeLabel(item.ItemType), item.Name), 0)
+	w.y += 16
+
+	w.doc.SetFont(9, false)
+	for _, line := range lines {
+		for _, wrapped := range w.doc.WrapText(line, bookletWidth-80) {
+			w.ensure(13)
+			w.y += 11
+			w.doc.Text(bookletMargin+80, w.y, wrapped, 0.3)
+			w.y += 2
+		}
+	}
+	w.y += 6
+}
+
+// itineraryItemLines 元素的补充信息，每项一行
+func itineraryItemLines(item models.TravelItem, details *models.PlanDocumentItem) []string {
+	var lines []string
+	add := func(label string, value *string) {
+		if value != nil && strings.TrimSpace(*value) != "" {
+			lines = append(lines, label+"："+*value)
+		}
+	}
+
+	add("地址", item.Address)
+	if details != nil {
+		if d := details.AccommodationDetails; d != nil {
+			add("酒店", d.HotelName)
+			add("房型", d.RoomType)
+			if d.CheckInTime != nil || d.CheckOutTime != nil {
+				lines = append(lines, fmt.Sprintf("入住 %s / 退房 %s", clockText(d.CheckInTime), clockText(d.CheckOutTime)))
+			}
+			add("预订号", d.BookingNumber)
+			add("电话", d.Phone)
+		}
+		if d := details.TransportDetails; d != nil {
+			if d.DepartureLocation != nil || d.ArrivalLocation != nil {
+				lines = append(lines, fmt.Sprintf("%s → %s", textOr(d.DepartureLocation, "?"), textOr(d.ArrivalLocation, "?")))
+			}
+			if d.DepartureTime != nil && d.ArrivalTime != nil {
+				lines = append(lines, fmt.Sprintf("出发 %s，到达 %s",
+					d.DepartureTime.Format("01-02 15:04"), d.ArrivalTime.Format("01-02 15:04")))
+			}
+			var vehicle []string
+			for _, v := range []*string{d.TransportType, d.CarrierName, d.VehicleNumber} {
+				if v != nil && *v != "" {
+					vehicle = append(vehicle, *v)
+				}
+			}
+			if d.SeatNumber != nil && *d.SeatNumber != "" {
+				vehicle = append(vehicle, "座位 "+*d.SeatNumber)
+			}
+			if len(vehicle) > 0 {
+				lines = append(lines, strings.Join(vehicle, " · "))
+			}
+			add("出发站", d.DepartureTerminal)
+			add("到达站", d.ArrivalTerminal)
+			add("预订号", d.BookingReference)
+		}
+		if d := details.AttractionDetails; d != nil {
+			if d.TicketPrice != nil {
+				lines = append(lines, "门票："+formatMoney(*d.TicketPrice, ""))
+			}
+			if d.AdvanceBookingRequired {
+				lines = append(lines, "需提前预约")
+			}
+			add("最佳游览时间", d.BestVisitTime)
+		}
+		add("备注", details.Notes)
+	}
+	if item.Cost != nil && *item.Cost > 0 {
+		lines = append(lines, "费用："+formatMoney(*item.Cost, ""))
+	}
+	return lines
+}
+
+// bookings 预订信息表：所有带预订号、联系电话或预订状态的元素
+func (w *bookletWriter) bookings(items []models.PlanDocumentItem) {
+	var rows [][]string
+	for _, item := range items {
+		var number, phone *string
+		if d := item.AccommodationDetails; d != nil {
+			number, phone = d.BookingNumber, d.Phone
+		}
+		if d := item.TransportDetails; d != nil {
+			number = d.BookingReference
+		}
+		status := ""
+		if item.BookingStatus != nil {
+			status = bookingStatusLabels[*item.BookingStatus]
+		}
+		if textOr(number, "") == "" && textOr(phone, "") == "" && status == "" {
+			continue
+		}
+		rows = append(rows, []string{item.Name, itemTypeLabel(item.ItemType), textOr(number, "-"), textOr(phone, "-"), textOr(&status, "-")})
+	}
+
+	w.heading("预订信息")
+	if len(rows) == 0 {
+		w.paragraph("暂无预订信息", 10, 0.4, 0)
+		w.y += 10
+		return
+	}
+	w.table([]bookletColumn{
+		{title: "元素", width: 0.32}, {title: "类型", width: 0.1}, {title: "预订号", width: 0.22},
+		{title: "电话", width: 0.22}, {title: "状态", width: 0.14},
+	}, rows)
+	w.y += 16
+}
+
+func (w *bookletWriter) budget(b *itineraryBooklet) {
+	w.heading("预算")
+	if len(b.budgetItems) == 0 {
+		w.paragraph("暂无预算项目", 10, 0.4, 0)
+	} else {
+		rows := make([][]string, len(b.budgetItems))
+		for i, item := range b.budgetItems {
+			rows[i] = []string{
+				item.Category, item.Description,
+				amountText(item.EstimatedAmount), amountText(item.ActualAmount),
+				item.Currency, textOr(ptrString(paymentStatusLabels[item.PaymentStatus]), item.PaymentStatus),
+			}
+		}
+		w.table([]bookletColumn{
+			{title: "分类", width: 0.14}, {title: "说明", width: 0.34}, {title: "预计", width: 0.14, right: true},
+			{title: "实际", width: 0.14, right: true}, {title: "币种", width: 0.1}, {title: "状态", width: 0.14},
+		}, rows)
+	}
+
+	if s := b.summary; s != nil {
+		w.y += 6
+		w.paragraph(fmt.Sprintf("合计（按 %s 换算）：预计 %s，实际 %s", s.BaseCurrency,
+			formatMoney(s.EstimatedCost, s.BaseCurrency), formatMoney(s.ActualCost, s.BaseCurrency)), 10, 0, 0)
+		if b.plan.Budget > 0 {
+			w.paragraph(fmt.Sprintf("计划预算：%s，剩余 %s", formatMoney(b.plan.Budget, s.BaseCurrency),
+				formatMoney(b.plan.Budget-s.EstimatedCost, s.BaseCurrency)), 10, 0, 0)
+		}
+		if len(s.MissingRates) > 0 {
+			w.paragraph("缺少汇率，以下币种未计入合计："+strings.Join(s.MissingRates, "、"), 9, 0.4, 0)
+		}
+	}
+	w.y += 16
+}
+
+func (w *bookletWriter) emergencyInfo(b *itineraryBooklet) {
+	w.heading("紧急信息")
+
+	if len(b.emergency) > 0 {
+		rows := make([][]string, len(b.emergency))
+		for i, contact := range b.emergency {
+			rows[i] = []string{contact[0], contact[1]}
+		}
+		w.table([]bookletColumn{{title: "紧急电话", width: 0.5}, {title: "号码", width: 0.5}}, rows)
+		w.y += 12
+	}
+
+	var stays [][]string
+	for _, item := range b.items {
+		d := item.AccommodationDetails
+		if d == nil {
+			continue
+		}
+		name := textOr(d.HotelName, item.Name)
+		stays = append(stays, []string{name, textOr(item.Address, "-"), textOr(d.Phone, "-")})
+	}
+	if len(stays) > 0 {
+		w.subheading("住宿联系方式")
+		w.table([]bookletColumn{{title: "住宿", width: 0.3}, {title: "地址", width: 0.45}, {title: "电话", width: 0.25}}, stays)
+		w.y += 12
+	}
+
+	if len(b.participants) > 0 {
+		rows := make([][]string, len(b.participants))
+		for i, p := range b.participants {
+			rows[i] = []string{p.Name, textOr(p.Email, "-")}
+		}
+		w.subheading("参与者")
+		w.table([]bookletColumn{{title: "姓名", width: 0.4}, {title: "邮箱", width: 0.6}}, rows)
+	}
+}
+
+// bookletColumn 表格列，width 为占表格宽度的比例
+type bookletColumn struct {
+	title string
+	width float64
+	right bool
+}
+
+// table 画表格，单元格自动折行，换页时重复表头
+func (w *bookletWriter) table(columns []bookletColumn, rows [][]string) {
+	const size, lineHeight, padding = 9.0, 12.0, 4.0
+
+	header := func() {
+		w.doc.FillRect(bookletMargin, w.y, bookletWidth, lineHeight+2*padding, 0.85)
+		w.doc.SetFont(size, true)
+		x := bookletMargin
+		for _, col := range columns {
+			w.doc.Text(x+padding, w.y+padding+size, col.title, 0)
+			x += col.width * bookletWidth
+		}
+		w.y += lineHeight + 2*padding
+	}
+
+	w.ensure(2 * (lineHeight + 2*padding))
+	header()
+
+	w.doc.SetFont(size, false)
+	for _, row := range rows {
+		cells := make([][]string, len(columns))
+		height := 1
+		for i, col := range columns {
+			if i < len(row) {
+				cells[i] = w.doc.WrapText(row[i], col.width*bookletWidth-2*padding)
+			}
+			if len(cells[i]) > height {
+				height = len(cells[i])
+			}
+		}
+		rowHeight := float64(height)*lineHeight + 2*padding
+		if w.ensure(rowHeight) {
+			header()
+		}
+
+		w.doc.SetFont(size, false)
+		x := bookletMargin
+		for i, col := range columns {
+			colWidth := col.width * bookletWidth
+			for j, line := range cells[i] {
+				lx := x + padding
+				if col.right {
+					lx = x + colWidth - padding - w.doc.Width(line)
+				}
+				w.doc.Text(lx, w.y+padding+size+float64(j)*lineHeight, line, 0)
+			}
+			x += colWidth
+		}
+		w.y += rowHeight
+		w.doc.Line(bookletMargin, w.y, bookletMargin+bookletWidth, w.y, 0.3, 0.75)
+	}
+}
+
+func bookletDateRange(b *itineraryBooklet) string {
+	if s := b.summary; s != nil && s.Duration > 0 {
+		return fmt.Sprintf("%s 至 %s（共 %d 天）", s.StartDate.Format("2006-01-02"), s.EndDate.Format("2006-01-02"), s.Duration)
+	}
+	if b.plan.StartDate != nil && b.plan.EndDate != nil {
+		return fmt.Sprintf("%s 至 %s", dateText(*b.plan.StartDate), dateText(*b.plan.EndDate))
+	}
+	return ""
+}
+
+func itemTypeLabel(t models.ItemType) string {
+	if label, ok := itemTypeLabels[t]; ok {
+		return label
+	}
+	return string(t)
+}
+
+func formatMoney(amount float64, currency string) string {
+	if currency == "" {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func amountText(amount *float64) string {
+	if amount == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *amount)
+}
+
+// clockText 数据库 TIME 字段输出为 HH:MM
+func clockText(v *string) string {
+	if v == nil || len(*v) < 5 {
+		return "-"
+	}
+	return (*v)[:5]
+}
+
+// dateText 计划日期可能带有时间部分，只保留日期
+func dateText(v string) string {
+	if len(v) > 10 {
+		return v[:10]
+	}
+	return v
+}
+
+func textOr(v *string, fallback string) string {
+	if v == nil || strings.TrimSpace(*v) == "" {
+		return fallback
+	}
+	return *v
+}
+
+func ptrString(v string) *string {
+	return &v
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pdfContent 解压PDF所有内容流并拼接
+func pdfContent(t *testing.T, data []byte) string {
+	t.Helper()
+	var b strings.Builder
+	for _, m := range regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindAllSubmatch(data, -1) {
+		zr, err := zlib.NewReader(bytes.NewReader(m[1]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		b.Write(content)
+	}
+	return b.String()
+}
+
+// pdfHex 与PDF内容流中的文字编码一致
+func pdfHex(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return "<" + b.String() + ">"
+}
+
+func TestParseEmergencyContacts(t *testing.T) {
+	assert.Equal(t, [][2]string{{"报警", "110"}, {"急救", "120"}},
+		parseEmergencyContacts("报警:110, 急救 : 120 ,无效,:119"))
+	assert.Empty(t, parseEmergencyContacts(""))
+}
+
+func TestRenderItineraryBooklet(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	checkIn := time.Date(2025, 10, 1, 15, 0, 0, 0, shanghai)
+	departure := time.Date(2025, 10, 1, 9, 0, 0, 0, shanghai)
+	arrival := departure.Add(90 * time.Minute)
+	estimated := 680.0
+
+	hotel := models.TravelItem{ID: "hotel", ItemType: models.ItemTypeAccommodation, Name: "稻城酒店",
+		StartDatetime: &checkIn, Address: strPtr("稻城县香格里拉镇"), BookingStatus: strPtr(models.BookingStatusConfirmed)}
+	flight := models.TravelItem{ID: "flight", ItemType: models.ItemTypeTransport, Name: "成都-稻城",
+		StartDatetime: &departure, EndDatetime: &arrival}
+
+	b := &itineraryBooklet{
+		plan: models.Plan{Name: "稻城亚丁", Destination: "四川甘孜", TimeZone: strPtr("Asia/Shanghai"), Budget: 5000},
+		summary: &models.PlanSummary{BaseCurrency: "CNY", TotalItems: 3, EstimatedCost: 680, Duration: 1,
+			StartDate: departure, EndDate: checkIn},
+		days: []models.DailyItinerary{{Date: "2025-10-01", TimeZone: "Asia/Shanghai", Items: []models.TravelItem{flight, hotel}}},
+		items: []models.PlanDocumentItem{
+			{TravelItem: hotel, AccommodationDetails: &models.AccommodationDetails{
+				HotelName: strPtr("稻城酒店"), BookingNumber: strPtr("HT20251001"), Phone: strPtr("0836-5728888"),
+				CheckInTime: strPtr("14:00:00")}},
+			{TravelItem: flight, TransportDetails: &models.TransportDetails{
+				CarrierName: strPtr("川航"), VehicleNumber: strPtr("3U8625"), BookingReference: strPtr("PNR7QX"),
+				DepartureTime: &departure, ArrivalTime: &arrival}},
+			{TravelItem: models.TravelItem{ID: "lake", ItemType: models.ItemTypeAttraction, Name: "牛奶海"}},
+		},
+		participants: []models.PlanParticipant{{ID: "p1", Name: "阿明", Email: strPtr("aming@example.com")}},
+		budgetItems: []models.BudgetItem{{Category: "住宿", Description: "两晚", EstimatedAmount: &estimated,
+			Currency: "CNY", PaymentStatus: models.PaymentStatusPaid}},
+		emergency:  [][2]string{{"急救", "120"}},
+		exportedAt: departure,
+	}
+
+	doc := renderItineraryBooklet(b)
+	assert.Equal(t, 3, doc.PageCount())
+
+	data, err := doc.Bytes()
+	require.NoError(t, err)
+	content := pdfContent(t, data)
+
+	for _, text := range []string{
+		"稻城亚丁", "第 1 天 · 2025-10-01 星期三", "09:00-10:30", "[交通] 成都-稻城", "川航 · 3U8625",
+		"预订号：HT20251001", "电话：0836-5728888", "入住 14:00 / 退房 -", "PNR7QX",
+		"未安排时间", "· 牛奶海（景点）", "已确认", "680.00", "已支付", "120", "aming@example.com",
+		"稻城亚丁 · 第 2 页",
+	} {
+		assert.Contains(t, content, pdfHex(text), text)
+	}
+}
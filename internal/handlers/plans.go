@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"planner/internal/database"
@@ -45,14 +46,34 @@ func CreatePlan(c *gin.Context) {
 	if plan.Participants == 0 {
 		plan.Participants = 1
 	}
+	if plan.BaseCurrency == "" {
+		plan.BaseCurrency = "CNY"
+	}
+	plan.BaseCurrency = strings.ToUpper(plan.BaseCurrency)
+	if !isCurrencyCode(plan.BaseCurrency) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "无效的基准货币: " + plan.BaseCurrency,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err := validateTimeZone(plan.TimeZone); err != nil {
+		respondItemError(c, err)
+		return
+	}
+	if plan.TimeZone == nil || *plan.TimeZone == "" {
+		zone := defaultTimeZoneName()
+		plan.TimeZone = &zone
+	}
 
 	db := database.GetDB()
 	_, err := db.Exec(`
 		INSERT INTO plans (id, user_id, name, description, destination, start_date, end_date, 
-			budget, participants, status, visibility, tags, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			budget, base_currency, time_zone, participants, status, visibility, tags, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 	`, plan.ID, plan.UserID, plan.Name, plan.Description, plan.Destination,
-		plan.StartDate, plan.EndDate, plan.Budget, plan.Participants,
+		plan.StartDate, plan.EndDate, plan.Budget, plan.BaseCurrency, plan.TimeZone, plan.Participants,
 		plan.Status, plan.Visibility, pq.Array(plan.Tags), plan.CreatedAt, plan.UpdatedAt)
 
 	if err != nil {
@@ -75,7 +96,7 @@ func GetMyPlans(c *gin.Context) {
 
 	rows, err := db.Query(`
 		SELECT id, user_id, name, description, destination, start_date, end_date,
-			budget, participants, status, visibility, created_at, updated_at
+			budget, base_currency, time_zone, participants, status, visibility, created_at, updated_at
 		FROM plans
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -92,7 +113,7 @@ func GetMyPlans(c *gin.Context) {
 		var plan models.Plan
 		err := rows.Scan(&plan.ID, &plan.UserID, &plan.Name, &plan.Description,
 			&plan.Destination, &plan.StartDate, &plan.EndDate, &plan.Budget,
-			&plan.Participants, &plan.Status, &plan.Visibility,
+			&plan.BaseCurrency, &plan.TimeZone, &plan.Participants, &plan.Status, &plan.Visibility,
 			&plan.CreatedAt, &plan.UpdatedAt)
 
 		if err != nil {
@@ -116,7 +137,7 @@ func GetUserPlans(c *gin.Context) {
 
 	rows, err := db.Query(`
 		SELECT id, user_id, name, description, destination, start_date, end_date,
-			budget, participants, status, visibility, created_at, updated_at
+			budget, base_currency, time_zone, participants, status, visibility, created_at, updated_at
 		FROM plans
 		WHERE user_id = $1 AND visibility = 'public'
 		ORDER BY created_at DESC
@@ -133,7 +154,7 @@ func GetUserPlans(c *gin.Context) {
 		var plan models.Plan
 		err := rows.Scan(&plan.ID, &plan.UserID, &plan.Name, &plan.Description,
 			&plan.Destination, &plan.StartDate, &plan.EndDate, &plan.Budget,
-			&plan.Participants, &plan.Status, &plan.Visibility,
+			&plan.BaseCurrency, &plan.TimeZone, &plan.Participants, &plan.Status, &plan.Visibility,
 			&plan.CreatedAt, &plan.UpdatedAt)
 
 		if err != nil {
@@ -158,11 +179,11 @@ func GetPlan(c *gin.Context) {
 	var plan models.Plan
 	err := db.QueryRow(`
 		SELECT id, user_id, name, description, destination, start_date, end_date,
-			budget, participants, status, visibility, created_at, updated_at
+			budget, base_currency, time_zone, participants, status, visibility, created_at, updated_at
 		FROM plans WHERE id = $1
 	`, planID).Scan(&plan.ID, &plan.UserID, &plan.Name, &plan.Description,
 		&plan.Destination, &plan.StartDate, &plan.EndDate, &plan.Budget,
-		&plan.Participants, &plan.Status, &plan.Visibility,
+		&plan.BaseCurrency, &plan.TimeZone, &plan.Participants, &plan.Status, &plan.Visibility,
 		&plan.CreatedAt, &plan.UpdatedAt)
 
 	if err != nil {
@@ -227,6 +248,35 @@ func UpdatePlan(c *gin.Context) {
 		return
 	}
 
+	if zone, ok := updates["time_zone"]; ok {
+		name, isString := zone.(string)
+		if zone != nil && (!isString || validateTimeZone(&name) != nil) {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "无效的时区",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		if zone == nil || name == "" {
+			updates["time_zone"] = defaultTimeZoneName()
+		}
+	}
+
+	if currency, ok := updates["base_currency"]; ok {
+		code, _ := currency.(string)
+		code = strings.ToUpper(code)
+		if !isCurrencyCode(code) {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "无效的基准货币",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		updates["base_currency"] = code
+	}
+
 	// 构建动态更新语句
 	query := "UPDATE plans SET updated_at = $1"
 	args := []interface{}{time.Now()}
@@ -288,12 +338,19 @@ func DeletePlan(c *gin.Context) {
 		return
 	}
 
+	keys, err := attachmentKeys("t.plan_id = $1", planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	// 删除计划（级联删除相关数据）
 	_, err = db.Exec("DELETE FROM plans WHERE id = $1", planID)
 	if err != nil {
 		c.Error(err)
 		return
 	}
+	removeStoredFiles(keys)
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success:   true,
@@ -313,11 +370,11 @@ func DuplicatePlan(c *gin.Context) {
 	var originalPlan models.Plan
 	err := db.QueryRow(`
 		SELECT name, description, destination, start_date, end_date,
-			budget, participants, status, visibility
+			budget, base_currency, time_zone, participants, status, visibility
 		FROM plans WHERE id = $1
 	`, planID).Scan(&originalPlan.Name, &originalPlan.Description,
 		&originalPlan.Destination, &originalPlan.StartDate, &originalPlan.EndDate,
-		&originalPlan.Budget, &originalPlan.Participants,
+		&originalPlan.Budget, &originalPlan.BaseCurrency, &originalPlan.TimeZone, &originalPlan.Participants,
 		&originalPlan.Status, &originalPlan.Visibility)
 
 	if err != nil {
@@ -339,11 +396,11 @@ func DuplicatePlan(c *gin.Context) {
 
 	_, err = db.Exec(`
 		INSERT INTO plans (id, user_id, name, description, destination, start_date, end_date,
-			budget, participants, status, visibility, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+			budget, base_currency, time_zone, participants, status, visibility, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 	`, newPlanID, userID, originalPlan.Name, originalPlan.Description,
 		originalPlan.Destination, originalPlan.StartDate, originalPlan.EndDate,
-		originalPlan.Budget, originalPlan.Participants,
+		originalPlan.Budget, originalPlan.BaseCurrency, originalPlan.TimeZone, originalPlan.Participants,
 		"draft", "private", time.Now(), time.Now())
 
 	if err != nil {
@@ -355,16 +412,16 @@ func DuplicatePlan(c *gin.Context) {
 	_, err = db.Exec(`
 		INSERT INTO travel_items (
 			id, plan_id, item_type, name, description,
-			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
-			cost, priority, status, properties,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, priority, status, properties, order_index,
 			created_by, created_at, updated_at
 		)
 		SELECT 
 			gen_random_uuid(), $1, item_type, name, description,
-			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
-			cost, priority, 'planned', properties,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, priority, 'planned', properties, order_index,
 			$2, NOW(), NOW()
 		FROM travel_items
 		WHERE plan_id = $3
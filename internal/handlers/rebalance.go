@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"planner/internal/config"
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 没有时长信息的元素按1小时计算
+	defaultItemDuration = time.Hour
+	// 寻找空闲时段时的步长
+	rebalanceStep = 15 * time.Minute
+)
+
+// errPlanDatesMissing 计划未设置起止日期
+var errPlanDatesMissing = errors.New("计划未设置起止日期，无法重新分配")
+
+// rebalanceItem 参与重新分配的元素
+type rebalanceItem struct {
+	id            string
+	name          string
+	itemType      models.ItemType
+	start         *time.Time
+	end           *time.Time
+	durationHours *float64
+	status        string
+	bookingStatus *string
+	groupID       *string
+	timeZone      *string
+	openingHours  *models.OpeningHours
+}
+
+// rebalanceUnit 必须安排在同一天的一组元素（相同 group_id），day 为当前所在日期的下标，-1 表示未安排或分散在多天
+type rebalanceUnit struct {
+	items    []*rebalanceItem
+	duration time.Duration
+	day      int
+}
+
+type rebalanceOptions struct {
+	days     []time.Time // 计划时区中每天的零点
+	budget   time.Duration
+	dayStart time.Duration
+	dayEnd   time.Duration
+}
+
+type busyInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+type rebalanceDay struct {
+	date   time.Time
+	before time.Duration
+	load   time.Duration
+	count  int
+	busy   []busyInterval
+}
+
+// RebalanceItinerary 将未固定的元素重新分配到计划的各天，使每天的活动时间不超过上限
+func RebalanceItinerary(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.RebalanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	opts, dailyHours, msg := rebalanceRequestOptions(&req)
+	if msg != "" {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   msg,
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	result := &models.RebalanceResult{PlanID: planID, DryRun: req.DryRun, DailyHours: dailyHours}
+	run := func(q routeQuerier, rowQ interface {
+		QueryRow(string, ...interface{}) *sql.Row
+	}) error {
+		items, err := loadRebalanceItems(q, planID)
+		if err != nil {
+			return err
+		}
+		if err := planDays(rowQ, planID, &opts); err != nil {
+			return err
+		}
+		result.Days, result.Moves, result.Unplaced = rebalanceItems(opts, items)
+		return nil
+	}
+
+	var err error
+	if req.DryRun {
+		db := database.GetDB()
+		err = run(db, db)
+	} else {
+		err = database.Transaction(func(tx *sql.Tx) error {
+			if _, err := lockPlanOrder(tx, planID); err != nil {
+				return err
+			}
+			if err := run(tx, tx); err != nil {
+				return err
+			}
+			return applyRebalance(tx, planID, result.Moves)
+		})
+	}
+
+	if errors.Is(err, errPlanDatesMissing) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	message := "重新分配方案已生成"
+	if !req.DryRun {
+		message = "行程已重新分配"
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      result,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
+
+// rebalanceRequestOptions 校验请求并填充默认值，返回错误信息
+func rebalanceRequestOptions(req *models.RebalanceRequest) (rebalanceOptions, float64, string) {
+	var opts rebalanceOptions
+
+	dailyHours := config.Get().DailyActiveHours
+	if req.DailyHours != nil {
+		dailyHours = *req.DailyHours
+	}
+	if dailyHours <= 0 || dailyHours > 24 {
+		return opts, 0, "daily_hours 必须在 0 到 24 之间"
+	}
+
+	if req.DayStart == "" {
+		req.DayStart = "08:00"
+	}
+	if req.DayEnd == "" {
+		req.DayEnd = "22:00"
+	}
+	start, err := parseClock(req.DayStart)
+	if err != nil {
+		return opts, 0, err.Error()
+	}
+	end, err := parseClock(req.DayEnd)
+	if err != nil {
+		return opts, 0, err.Error()
+	}
+	if end <= start {
+		return opts, 0, "day_end 必须晚于 day_start"
+	}
+
+	opts.budget = time.Duration(dailyHours * float64(time.Hour))
+	opts.dayStart = time.Duration(start) * time.Minute
+	opts.dayEnd = time.Duration(end) * time.Minute
+	return opts, dailyHours, ""
+}
+
+// loadRebalanceItems 加载计划中未跳过、未取消的元素
+func loadRebalanceItems(q routeQuerier, planID string) ([]*rebalanceItem, error) {
+	rows, err := q.Query(`
+		SELECT t.id, t.name, t.item_type, t.start_datetime, t.end_datetime, t.duration_hours,
+			COALESCE(t.status, ''), t.booking_status, t.group_id, t.time_zone, ad.opening_hours
+		FROM travel_items t
+		LEFT JOIN attraction_details ad ON ad.item_id = t.id
+		WHERE t.plan_id = $1 AND COALESCE(t.status, '') NOT IN ($2, $3)
+		ORDER BY t.start_datetime NULLS LAST, t.order_index NULLS LAST, t.created_at, t.id
+	`, planID, models.ItemStatusSkipped, models.ItemStatusCancelled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*rebalanceItem
+	for rows.Next() {
+		item := &rebalanceItem{}
+		if err := rows.Scan(&item.id, &item.name, &item.itemType, &item.start, &item.end, &item.durationHours,
+			&item.status, &item.bookingStatus, &item.groupID, &item.timeZone, &item.openingHours); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// planDays 按计划起止日期生成计划时区中每天的零点
+func planDays(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, planID string, opts *rebalanceOptions) error {
+	var startDate, endDate *time.Time
+	var zone *string
+	if err := q.QueryRow("SELECT start_date, end_date, time_zone FROM plans WHERE id = $1", planID).
+		Scan(&startDate, &endDate, &zone); err != nil {
+		return err
+	}
+	if startDate == nil || endDate == nil || endDate.Before(*startDate) {
+		return errPlanDatesMissing
+	}
+
+	loc := resolveLocation(zone)
+	opts.days = nil
+	for d := *startDate; !d.After(*endDate); d = d.AddDate(0, 0, 1) {
+		opts.days = append(opts.days, time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, loc))
+	}
+	return nil
+}
+
+// location 元素所在时区，未设置时使用计划时区，开放时间按此时区比较
+func (item *rebalanceItem) location(planLoc *time.Location) *time.Location {
+	if item.timeZone != nil && *item.timeZone != "" {
+		if loc, err := loadTimeZone(*item.timeZone); err == nil {
+			return loc
+		}
+	}
+	return planLoc
+}
+
+// duration 元素占用的活动时间
+func (item *rebalanceItem) duration() time.Duration {
+	if item.start != nil {
+		if end := itemEnd(item.start, item.end, item.durationHours); end != nil && end.After(*item.start) {
+			return end.Sub(*item.start)
+		}
+	}
+	if item.durationHours != nil && *item.durationHours > 0 {
+		return time.Duration(*item.durationHours * float64(time.Hour))
+	}
+	return defaultItemDuration
+}
+
+// rebalanceItems 计算重新分配方案。
+// 固定元素（交通、住宿、已预订或已开始）保持不变并占用当天的时间；其余元素按 group_id 组成单元，
+// 先尽量留在原来的日期，超出当天上限或未安排日期的单元按时长从长到短放入负载最低、
+// 有足够剩余时间且开放时间允许的日期，并在当天活动时段内寻找最早的空闲时间。
+func rebalanceItems(opts rebalanceOptions, items []*rebalanceItem) ([]models.RebalanceDay, []models.ItemMove, []models.UnplacedItem) {
+	days := make([]*rebalanceDay, len(opts.days))
+	dayIndex := make(map[string]int, len(opts.days))
+	for i, d := range opts.days {
+		days[i] = &rebalanceDay{date: d}
+		dayIndex[d.Format("2006-01-02")] = i
+	}
+	loc := time.UTC
+	if len(opts.days) > 0 {
+		loc = opts.days[0].Location()
+	}
+	dayOf := func(t *time.Time) int {
+		if t == nil {
+			return -1
+		}
+		if i, ok := dayIndex[t.In(loc).Format("2006-01-02")]; ok {
+			return i
+		}
+		return -1
+	}
+
+	var units []*rebalanceUnit
+	groups := make(map[string]*rebalanceUnit)
+	for _, item := range items {
+		// 住宿跨越整晚，不计入白天的活动时间
+		if item.itemType == models.ItemTypeAccommodation {
+			continue
+		}
+
+		day := dayOf(item.start)
+		if day >= 0 {
+			days[day].before += item.duration()
+		}
+
+		if itemPinReason(item.itemType, item.status, item.bookingStatus) != "" {
+			if day >= 0 {
+				days[day].load += item.duration()
+				days[day].count++
+				days[day].busy = append(days[day].busy, busyInterval{*item.start, item.start.Add(item.duration())})
+			}
+			continue
+		}
+
+		if item.groupID != nil {
+			if unit, ok := groups[*item.groupID]; ok {
+				unit.items = append(unit.items, item)
+				unit.duration += item.duration()
+				if unit.day != day {
+					unit.day = -1
+				}
+				continue
+			}
+		}
+		unit := &rebalanceUnit{items: []*rebalanceItem{item}, duration: item.duration(), day: day}
+		units = append(units, unit)
+		if item.groupID != nil {
+			groups[*item.groupID] = unit
+		}
+	}
+
+	// 第一轮：能留在原日期的单元不动
+	var pending []*rebalanceUnit
+	for _, unit := range units {
+		if unit.day < 0 || days[unit.day].load+unit.duration > opts.budget {
+			pending = append(pending, unit)
+			continue
+		}
+		day := days[unit.day]
+		day.load += unit.duration
+		day.count += len(unit.items)
+		for _, item := range unit.items {
+			day.busy = append(day.busy, busyInterval{*item.start, item.start.Add(item.duration())})
+		}
+	}
+
+	// 第二轮：其余单元按时长从长到短放入负载最低的可行日期
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].duration > pending[j].duration })
+
+	moves := []models.ItemMove{}
+	unplaced := []models.UnplacedItem{}
+	for _, unit := range pending {
+		candidates := make([]int, len(days))
+		for i := range candidates {
+			candidates[i] = i
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			da, db := days[candidates[a]], days[candidates[b]]
+			if da.load != db.load {
+				return da.load < db.load
+			}
+			if unit.day >= 0 {
+				return absInt(candidates[a]-unit.day) < absInt(candidates[b]-unit.day)
+			}
+			return false
+		})
+
+		placed := false
+		for _, i := range candidates {
+			day := days[i]
+			if day.load+unit.duration > opts.budget {
+				continue
+			}
+			starts, ok := scheduleUnit(day, unit, opts)
+			if !ok {
+				continue
+			}
+
+			day.load += unit.duration
+			day.count += len(unit.items)
+			for k, item := range unit.items {
+				start := starts[k]
+				end := start.Add(item.duration())
+				day.busy = append(day.busy, busyInterval{start, end})
+				if item.start != nil && item.start.Equal(start) {
+					continue
+				}
+				move := models.ItemMove{
+					ItemID:    item.id,
+					Name:      item.name,
+					GroupID:   item.groupID,
+					FromStart: item.start,
+					ToDate:    day.date.Format("2006-01-02"),
+					ToStart:   start,
+					ToEnd:     end,
+				}
+				if item.start != nil {
+					from := item.start.In(loc).Format("2006-01-02")
+					move.FromDate = &from
+				}
+				moves = append(moves, move)
+			}
+			placed = true
+			break
+		}
+
+		if !placed {
+			ids := make([]string, len(unit.items))
+			for k, item := range unit.items {
+				ids[k] = item.id
+			}
+			unplaced = append(unplaced, models.UnplacedItem{
+				ItemIDs: ids,
+				Reason:  "没有剩余活动时间足够且开放时间合适的日期",
+			})
+		}
+	}
+
+	summary := make([]models.RebalanceDay, len(days))
+	for i, day := range days {
+		summary[i] = models.RebalanceDay{
+			Date:        day.date.Format("2006-01-02"),
+			BeforeHours: roundHours(day.before),
+			AfterHours:  roundHours(day.load),
+			ItemCount:   day.count,
+		}
+	}
+	return summary, moves, unplaced
+}
+
+// scheduleUnit 在当天活动时段内为单元中的元素依次寻找最早的空闲时间，元素之间保持原来的先后顺序
+func scheduleUnit(day *rebalanceDay, unit *rebalanceUnit, opts rebalanceOptions) ([]time.Time, bool) {
+	busy := append([]busyInterval(nil), day.busy...)
+	cursor := wallClock(day.date, opts.dayStart)
+	windowEnd := wallClock(day.date, opts.dayEnd)
+
+	starts := make([]time.Time, 0, len(unit.items))
+	for _, item := range unit.items {
+		d := item.duration()
+		loc := item.location(day.date.Location())
+		t := cursor
+		for {
+			end := t.Add(d)
+			if end.After(windowEnd) {
+				return nil, false
+			}
+			if b, ok := overlapping(busy, t, end); ok {
+				t = b.end
+				continue
+			}
+			if len(checkVisitHours(item.openingHours, localTime(&t, loc), localTime(&end, loc))) > 0 {
+				t = t.Add(rebalanceStep)
+				continue
+			}
+			starts = append(starts, t)
+			busy = append(busy, busyInterval{t, end})
+			cursor = end
+			break
+		}
+	}
+	return starts, true
+}
+
+// wallClock 当天零点之后 offset 对应的当地时刻，夏令时切换当天按钟面时间而不是经过的时长计算
+func wallClock(day time.Time, offset time.Duration) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, int(offset/time.Minute), 0, 0, day.Location())
+}
+
+func overlapping(busy []busyInterval, start, end time.Time) (busyInterval, bool) {
+	for _, b := range busy {
+		if start.Before(b.end) && b.start.Before(end) {
+			return b, true
+		}
+	}
+	return busyInterval{}, false
+}
+
+// applyRebalance 写入新的开始和结束时间，并把移动的元素按新时间插入排序
+func applyRebalance(tx *sql.Tx, planID string, moves []models.ItemMove) error {
+	if len(moves) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	moved := make(map[string]time.Time, len(moves))
+	for _, m := range moves {
+		if _, err := tx.Exec("UPDATE travel_items SET start_datetime = $2, end_datetime = $3, updated_at = $4 WHERE id = $1",
+			m.ItemID, m.ToStart, m.ToEnd, now); err != nil {
+			return err
+		}
+		if err := refreshSunTimes(tx, m.ItemID); err != nil {
+			return err
+		}
+		moved[m.ItemID] = m.ToStart
+	}
+
+	items, err := loadPlanOrder(tx, planID)
+	if err != nil {
+		return err
+	}
+	var kept []orderedItem
+	for _, item := range items {
+		if _, ok := moved[item.id]; !ok {
+			kept = append(kept, item)
+		}
+	}
+	for _, m := range moves {
+		start := moved[m.ItemID]
+		pos := insertPosition(kept, &start)
+		kept = append(kept[:pos], append([]orderedItem{{id: m.ItemID, start: &start}}, kept[pos:]...)...)
+	}
+
+	ids := make([]string, len(kept))
+	for i, item := range kept {
+		ids[i] = item.id
+	}
+	return writeCompactOrder(tx, ids)
+}
+
+func roundHours(d time.Duration) float64 {
+	return math.Round(d.Hours()*100) / 100
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
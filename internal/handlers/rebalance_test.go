@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rebalanceTestOptions(days int) rebalanceOptions {
+	opts := rebalanceOptions{budget: 10 * time.Hour, dayStart: 8 * time.Hour, dayEnd: 22 * time.Hour}
+	for i := 0; i < days; i++ {
+		opts.days = append(opts.days, time.Date(2025, 10, 1+i, 0, 0, 0, 0, time.UTC))
+	}
+	return opts
+}
+
+func rebalanceAt(day, hour int) *time.Time {
+	v := time.Date(2025, 10, day, hour, 0, 0, 0, time.UTC)
+	return &v
+}
+
+func rebalanceHours(v float64) *float64 { return &v }
+
+func TestRebalanceMovesOverflowToEmptyDay(t *testing.T) {
+	items := []*rebalanceItem{
+		{id: "a", name: "a", itemType: models.ItemTypeAttraction, start: rebalanceAt(1, 8), durationHours: rebalanceHours(5)},
+		{id: "b", name: "b", itemType: models.ItemTypeAttraction, start: rebalanceAt(1, 13), durationHours: rebalanceHours(5)},
+		{id: "c", name: "c", itemType: models.ItemTypeAttraction, start: rebalanceAt(1, 18), end: rebalanceAt(1, 22)},
+	}
+
+	days, moves, unplaced := rebalanceItems(rebalanceTestOptions(2), items)
+	assert.Empty(t, unplaced)
+	assert.Len(t, moves, 1)
+	assert.Equal(t, "c", moves[0].ItemID)
+	assert.Equal(t, "2025-10-01", *moves[0].FromDate)
+	assert.Equal(t, "2025-10-02", moves[0].ToDate)
+	assert.Equal(t, *rebalanceAt(2, 8), moves[0].ToStart)
+	assert.Equal(t, *rebalanceAt(2, 12), moves[0].ToEnd)
+
+	assert.Equal(t, 14.0, days[0].BeforeHours)
+	assert.Equal(t, 10.0, days[0].AfterHours)
+	assert.Equal(t, 4.0, days[1].AfterHours)
+}
+
+func TestRebalanceKeepsPinnedItemsAndGroups(t *testing.T) {
+	group := "g"
+	items := []*rebalanceItem{
+		{id: "flight", name: "flight", itemType: models.ItemTypeTransport, start: rebalanceAt(2, 8), end: rebalanceAt(2, 12)},
+		{id: "hotel", name: "hotel", itemType: models.ItemTypeAccommodation, start: rebalanceAt(1, 0), end: rebalanceAt(3, 0)},
+		{id: "busy", name: "busy", itemType: models.ItemTypeOther, start: rebalanceAt(1, 8), durationHours: rebalanceHours(8)},
+		{id: "g1", name: "g1", itemType: models.ItemTypeOther, groupID: &group, durationHours: rebalanceHours(2)},
+		{id: "g2", name: "g2", itemType: models.ItemTypeOther, groupID: &group, durationHours: rebalanceHours(3)},
+	}
+
+	days, moves, unplaced := rebalanceItems(rebalanceTestOptions(2), items)
+	assert.Empty(t, unplaced)
+	assert.Len(t, moves, 2)
+	assert.Nil(t, moves[0].FromDate)
+	assert.Equal(t, "2025-10-02", moves[0].ToDate)
+	assert.Equal(t, *rebalanceAt(2, 12), moves[0].ToStart)
+	assert.Equal(t, *rebalanceAt(2, 14), moves[1].ToStart)
+	assert.Equal(t, 9.0, days[1].AfterHours)
+}
+
+func TestRebalanceRespectsOpeningHours(t *testing.T) {
+	// 仅周一开放（2025-10-06）
+	hours := &models.OpeningHours{Weekly: []models.DailyHours{
+		{Weekday: int(time.Monday), Ranges: []models.TimeRange{{Open: "10:00", Close: "16:00"}}},
+	}}
+	items := []*rebalanceItem{
+		{id: "temple", name: "temple", itemType: models.ItemTypeAttraction, durationHours: rebalanceHours(2), openingHours: hours},
+	}
+
+	_, moves, unplaced := rebalanceItems(rebalanceTestOptions(7), items)
+	assert.Empty(t, unplaced)
+	assert.Len(t, moves, 1)
+	assert.Equal(t, "2025-10-06", moves[0].ToDate)
+	assert.Equal(t, *rebalanceAt(6, 10), moves[0].ToStart)
+}
+
+func TestRebalanceReportsUnplaced(t *testing.T) {
+	items := []*rebalanceItem{
+		{id: "a", name: "a", itemType: models.ItemTypeOther, start: rebalanceAt(1, 8), durationHours: rebalanceHours(9)},
+		{id: "b", name: "b", itemType: models.ItemTypeOther, start: rebalanceAt(1, 17), durationHours: rebalanceHours(4)},
+	}
+
+	days, moves, unplaced := rebalanceItems(rebalanceTestOptions(1), items)
+	assert.Empty(t, moves)
+	assert.Equal(t, []models.UnplacedItem{{ItemIDs: []string{"b"}, Reason: "没有剩余活动时间足够且开放时间合适的日期"}}, unplaced)
+	assert.Equal(t, 9.0, days[0].AfterHours)
+}
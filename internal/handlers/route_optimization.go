@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 局部优化的最大轮数
+const maxRouteImprovePasses = 50
+
+var (
+	// errRouteConstraints 先后约束存在环或无法同时满足
+	errRouteConstraints = errors.New("元素的先后约束存在循环或无法同时满足")
+	// errRouteConflicts 调整后的时间与其他元素重叠
+	errRouteConflicts = errors.New("优化后的时间安排与其他元素重叠，未写入")
+)
+
+// routeItem 参与路线优化的元素
+type routeItem struct {
+	id            string
+	name          string
+	itemType      models.ItemType
+	latitude      *float64
+	longitude     *float64
+	priority      int
+	status        string
+	bookingStatus *string
+	start         *time.Time
+	end           *time.Time
+	durationHours *float64
+
+	fixedReason string
+}
+
+// routePlan 一次优化的输入：按当前顺序排列的元素和元素间的先后约束
+type routePlan struct {
+	items []*routeItem
+	// before[a][b] 表示 a 必须排在 b 之前
+	before map[string]map[string]bool
+}
+
+// OptimizeItinerary 按最短行程距离重新排列某一天（或整个计划）中可调整的元素。
+// 固定元素保持原位置，仅在 apply 为 true 时写入数据库。
+func OptimizeItinerary(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.OptimizeItineraryRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if req.Date != "" {
+		if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "date 格式应为 YYYY-MM-DD",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	var result *models.OptimizeResult
+	optimize := func(q routeQuerier) error {
+		plan, err := loadRoutePlan(q, planID, req.Date)
+		if err != nil {
+			return err
+		}
+		order, err := optimizeRoute(plan)
+		if err != nil {
+			return err
+		}
+		result = buildOptimizeResult(planID, req.Date, plan, order)
+		result.Conflicts = routeScheduleConflicts(plan, result)
+		return nil
+	}
+
+	var err error
+	if req.Apply {
+		err = database.Transaction(func(tx *sql.Tx) error {
+			if _, err := lockPlanOrder(tx, planID); err != nil {
+				return err
+			}
+			if err := optimize(tx); err != nil {
+				return err
+			}
+			return applyOptimizedRoute(tx, planID, result)
+		})
+	} else {
+		err = optimize(database.GetDB())
+	}
+
+	if errors.Is(err, errRouteConflicts) {
+		c.JSON(http.StatusConflict, models.ApiResponse{
+			Success:   false,
+			Data:      result,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if errors.Is(err, errRouteConstraints) {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	message := "优化方案已生成"
+	if result.Applied {
+		message = "行程优化完成"
+	}
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      result,
+		Message:   message,
+		Timestamp: time.Now(),
+	})
+}
+
+type routeQuerier interface {
+	Query(string, ...interface{}) (*sql.Rows, error)
+}
+
+// loadRoutePlan 按当前顺序加载元素及其之间的先后约束，date 非空时只加载当天开始的元素
+func loadRoutePlan(q routeQuerier, planID, date string) (*routePlan, error) {
+	query := `
+		SELECT id, name, item_type, latitude, longitude, COALESCE(priority, 3),
+			COALESCE(status, ''), booking_status, start_datetime, end_datetime, duration_hours
+		FROM travel_items
+		WHERE plan_id = $1`
+	args := []interface{}{planID}
+	if date != "" {
+		// 按元素所在时区（未设置时为计划时区）的当地日期筛选
+		planZone := "(SELECT COALESCE(p.time_zone, $3) FROM plans p WHERE p.id = $1)"
+		query += " AND " + localDateSQL("start_datetime", "time_zone", planZone) + " = $2"
+		args = append(args, date, defaultTimeZoneName())
+	}
+	query += " ORDER BY order_index NULLS LAST, start_datetime NULLS LAST, created_at, id"
+
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plan := &routePlan{before: make(map[string]map[string]bool)}
+	inScope := make(map[string]bool)
+	for rows.Next() {
+		item := &routeItem{}
+		if err := rows.Scan(&item.id, &item.name, &item.itemType, &item.latitude, &item.longitude, &item.priority,
+			&item.status, &item.bookingStatus, &item.start, &item.end, &item.durationHours); err != nil {
+			return nil, err
+		}
+		item.fixedReason = routeFixedReason(item)
+		plan.items = append(plan.items, item)
+		inScope[item.id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	relRows, err := q.Query(`
+		SELECT r.source_item_id, r.target_item_id, r.relation_type
+		FROM item_relations r
+		JOIN travel_items t ON t.id = r.source_item_id
+		WHERE t.plan_id = $1 AND r.relation_type IN ($2, $3, $4, $5)
+	`, planID, models.RelationMustPrecede, models.RelationBlocks, models.RelationDependsOn, models.RelationFollows)
+	if err != nil {
+		return nil, err
+	}
+	defer relRows.Close()
+
+	for relRows.Next() {
+		var source, target, relationType string
+		if err := relRows.Scan(&source, &target, &relationType); err != nil {
+			return nil, err
+		}
+		if !inScope[source] || !inScope[target] {
+			continue
+		}
+		if relationType == models.RelationDependsOn || relationType == models.RelationFollows {
+			source, target = target, source
+		}
+		plan.addBefore(source, target)
+	}
+	return plan, relRows.Err()
+}
+
+func (p *routePlan) addBefore(a, b string) {
+	if p.before[a] == nil {
+		p.before[a] = make(map[string]bool)
+	}
+	p.before[a][b] = true
+}
+
+// routeFixedReason 返回元素在路线优化中不能移动的原因，没有坐标的元素无法计算距离
+func routeFixedReason(item *routeItem) string {
+	if reason := itemPinReason(item.itemType, item.status, item.bookingStatus); reason != "" {
+		return reason
+	}
+	if item.latitude == nil || item.longitude == nil {
+		return "no_location"
+	}
+	return ""
+}
+
+// itemPinReason 返回元素时间固定、不应被自动调整的原因：交通和住宿有固定时间，已预订或已开始的元素不再调整
+func itemPinReason(itemType models.ItemType, status string, bookingStatus *string) string {
+	switch {
+	case itemType == models.ItemTypeTransport || itemType == models.ItemTypeAccommodation:
+		return "fixed_time"
+	case bookingStatus != nil && *bookingStatus == models.BookingStatusConfirmed:
+		return "booked"
+	}
+	switch normalizeItemStatus(status) {
+	case models.ItemStatusBooked:
+		return "booked"
+	case models.ItemStatusInProgress, models.ItemStatusDone:
+		return "started"
+	}
+	return ""
+}
+
+// optimizeRoute 返回优化后的顺序（元素在 plan.items 中的下标）。
+// 固定元素保持原位置；可调整元素先按最近邻贪心填入剩余位置，再用 2-opt 反转局部改进。
+// 先后约束总是满足；没有约束时，优先级高的元素排在优先级低的元素之前。
+func optimizeRoute(plan *routePlan) ([]int, error) {
+	n := len(plan.items)
+	order := make([]int, n)
+	placed := make(map[string]int, n) // 元素ID -> 所在位置
+	var flexSlots, remaining []int
+
+	for i, item := range plan.items {
+		if item.fixedReason != "" {
+			order[i] = i
+			placed[item.id] = i
+		} else {
+			order[i] = -1
+			flexSlots = append(flexSlots, i)
+			remaining = append(remaining, i)
+		}
+	}
+
+	// deadline 可调整元素必须排在其前面的固定元素中最早的位置
+	deadline := make(map[int]int)
+	for _, idx := range remaining {
+		deadline[idx] = n
+		for after := range plan.before[plan.items[idx].id] {
+			if pos, ok := placed[after]; ok && pos < deadline[idx] {
+				deadline[idx] = pos
+			}
+		}
+	}
+
+	for k, slot := range flexSlots {
+		nextFlex := n
+		if k+1 < len(flexSlots) {
+			nextFlex = flexSlots[k+1]
+		}
+
+		var available, forced []int
+		for _, idx := range remaining {
+			if !plan.ready(plan.items[idx].id, placed, slot) {
+				continue
+			}
+			available = append(available, idx)
+			if deadline[idx] <= nextFlex {
+				forced = append(forced, idx)
+			}
+		}
+		if len(available) == 0 {
+			return nil, errRouteConstraints
+		}
+
+		candidates := forced
+		if len(candidates) == 0 {
+			top := 0
+			for _, idx := range available {
+				if plan.items[idx].priority > top {
+					top = plan.items[idx].priority
+				}
+			}
+			for _, idx := range available {
+				if plan.items[idx].priority == top {
+					candidates = append(candidates, idx)
+				}
+			}
+		}
+
+		chosen := candidates[0]
+		if prev := lastLocated(plan, order, slot); prev != nil {
+			best := math.Inf(1)
+			for _, idx := range candidates {
+				if d := routeLeg(prev, plan.items[idx]); d < best {
+					best, chosen = d, idx
+				}
+			}
+		}
+
+		order[slot] = chosen
+		placed[plan.items[chosen].id] = slot
+		for i, idx := range remaining {
+			if idx == chosen {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if !plan.satisfied(order) {
+		return nil, errRouteConstraints
+	}
+
+	improveRoute(plan, order, flexSlots)
+	return order, nil
+}
+
+// ready 检查元素的所有前置元素是否已排在 slot 之前
+func (p *routePlan) ready(id string, placed map[string]int, slot int) bool {
+	for a, successors := range p.before {
+		if !successors[id] {
+			continue
+		}
+		if pos, ok := placed[a]; !ok || pos >= slot {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfied 检查顺序是否满足全部先后约束
+func (p *routePlan) satisfied(order []int) bool {
+	pos := make(map[string]int, len(order))
+	for i, idx := range order {
+		pos[p.items[idx].id] = i
+	}
+	for a, successors := range p.before {
+		for b := range successors {
+			if pos[a] >= pos[b] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// improveRoute 对可调整位置上的元素做 2-opt 反转，只反转优先级相同的连续片段，接受能缩短距离且满足约束的改动
+func improveRoute(plan *routePlan, order []int, flexSlots []int) {
+	best := routeDistance(plan, order)
+	for pass := 0; pass < maxRouteImprovePasses; pass++ {
+		improved := false
+		for i := 0; i < len(flexSlots)-1; i++ {
+			for j := i + 1; j < len(flexSlots); j++ {
+				if plan.items[order[flexSlots[j]]].priority != plan.items[order[flexSlots[i]]].priority {
+					break
+				}
+				reverseSlots(order, flexSlots[i:j+1])
+				if d := routeDistance(plan, order); d < best-1e-9 && plan.satisfied(order) {
+					best, improved = d, true
+					continue
+				}
+				reverseSlots(order, flexSlots[i:j+1])
+			}
+		}
+		if !improved {
+			return
+		}
+	}
+}
+
+func reverseSlots(order []int, slots []int) {
+	for i, j := 0, len(slots)-1; i < j; i, j = i+1, j-1 {
+		order[slots[i]], order[slots[j]] = order[slots[j]], order[slots[i]]
+	}
+}
+
+// lastLocated 返回 slot 之前最后一个有坐标的元素
+func lastLocated(plan *routePlan, order []int, slot int) *routeItem {
+	for i := slot - 1; i >= 0; i-- {
+		if order[i] < 0 {
+			continue
+		}
+		if item := plan.items[order[i]]; item.latitude != nil && item.longitude != nil {
+			return item
+		}
+	}
+	return nil
+}
+
+func routeLeg(a, b *routeItem) float64 {
+	return haversineKm(*a.latitude, *a.longitude, *b.latitude, *b.longitude)
+}
+
+// routeDistance 依次经过所有有坐标元素的总距离
+func routeDistance(plan *routePlan, order []int) float64 {
+	total := 0.0
+	var prev *routeItem
+	for _, idx := range order {
+		item := plan.items[idx]
+		if item.latitude == nil || item.longitude == nil {
+			continue
+		}
+		if prev != nil {
+			total += routeLeg(prev, item)
+		}
+		prev = item
+	}
+	return total
+}
+
+func routeMetrics(distance float64) models.RouteMetrics {
+	return models.RouteMetrics{
+		DistanceKm:    math.Round(distance*100) / 100,
+		TravelMinutes: int(math.Round(distance / groundSpeedKmh * 60)),
+	}
+}
+
+// buildOptimizeResult 生成优化结果。所有可调整元素都有开始时间时，它们按新顺序依次使用原来各位置的开始时间并保持各自的时长。
+func buildOptimizeResult(planID, date string, plan *routePlan, order []int) *models.OptimizeResult {
+	identity := make([]int, len(plan.items))
+	for i := range identity {
+		identity[i] = i
+	}
+	before, after := routeDistance(plan, identity), routeDistance(plan, order)
+
+	result := &models.OptimizeResult{
+		PlanID:  planID,
+		Date:    date,
+		Before:  routeMetrics(before),
+		After:   routeMetrics(after),
+		SavedKm: math.Round((before-after)*100) / 100,
+		Retimed: true,
+		Items:   make([]models.OptimizedItem, len(order)),
+	}
+
+	for _, item := range plan.items {
+		if item.fixedReason == "" && item.start == nil {
+			result.Retimed = false
+		}
+	}
+
+	var prev *routeItem
+	for pos, idx := range order {
+		item := plan.items[idx]
+		out := models.OptimizedItem{
+			ID:               item.id,
+			Name:             item.name,
+			ItemType:         item.itemType,
+			Priority:         item.priority,
+			Fixed:            item.fixedReason != "",
+			FixedReason:      item.fixedReason,
+			PreviousPosition: idx,
+			Position:         pos,
+			StartDatetime:    item.start,
+			EndDatetime:      item.end,
+		}
+		if idx != pos {
+			result.Changed = true
+			if result.Retimed {
+				slotStart := plan.items[pos].start
+				out.StartDatetime = slotStart
+				if item.end != nil {
+					end := slotStart.Add(item.end.Sub(*item.start))
+					out.EndDatetime = &end
+				}
+			}
+		}
+		if item.latitude != nil && item.longitude != nil {
+			if prev != nil {
+				out.LegDistanceKm = math.Round(routeLeg(prev, item)*100) / 100
+			}
+			prev = item
+		}
+		result.Items[pos] = out
+	}
+
+	if !result.Changed {
+		result.Retimed = false
+	}
+	return result
+}
+
+// routeScheduleConflicts 用行程校验的重叠规则检查调整时间后的安排，只报告涉及被移动元素的重叠。
+// 被移动的元素使用新位置的开始时间和自己的时长，可能与后面的元素或固定元素重叠
+func routeScheduleConflicts(plan *routePlan, result *models.OptimizeResult) []models.ScheduleIssue {
+	conflicts := []models.ScheduleIssue{}
+	if !result.Retimed {
+		return conflicts
+	}
+
+	byID := make(map[string]*routeItem, len(plan.items))
+	for _, item := range plan.items {
+		byID[item.id] = item
+	}
+
+	moved := make(map[string]bool)
+	var spans []timelineSpan
+	for _, out := range result.Items {
+		item := byID[out.ID]
+		// 住宿跨越整晚，与其他元素重叠是正常的
+		if item.itemType == models.ItemTypeAccommodation {
+			continue
+		}
+		start, end := out.StartDatetime, itemEnd(out.StartDatetime, out.EndDatetime, item.durationHours)
+		if start == nil || end == nil || !end.After(*start) {
+			continue
+		}
+		if !out.Fixed && out.Position != out.PreviousPosition {
+			moved[out.ID] = true
+		}
+		spans = append(spans, timelineSpan{id: out.ID, name: out.Name, start: *start, end: *end})
+	}
+
+	walkSpans(spans, func(cur, next timelineSpan) {
+		if next.start.Before(cur.end) && (moved[cur.id] || moved[next.id]) {
+			conflicts = append(conflicts, models.ScheduleIssue{
+				Code:     models.IssueTimeOverlap,
+				Severity: models.IssueSeverityError,
+				Message:  fmt.Sprintf("「%s」与「%s」时间重叠", cur.name, next.name),
+				ItemIDs:  []string{cur.id, next.id},
+				Date:     next.start.Format("2006-01-02"),
+			})
+		}
+	})
+	return conflicts
+}
+
+// applyOptimizedRoute 将优化后的顺序写回：元素按新顺序占据原来的排序位置，需要时更新开始和结束时间。
+// 调整后的时间存在重叠时不写入
+func applyOptimizedRoute(tx *sql.Tx, planID string, result *models.OptimizeResult) error {
+	if len(result.Conflicts) > 0 {
+		return errRouteConflicts
+	}
+	if !result.Changed {
+		result.Applied = true
+		return nil
+	}
+
+	items, err := loadPlanOrder(tx, planID)
+	if err != nil {
+		return err
+	}
+	current := make([]string, len(items))
+	for i, item := range items {
+		current[i] = item.id
+	}
+
+	listed := make([]string, len(result.Items))
+	for i, item := range result.Items {
+		listed[i] = item.ID
+	}
+	ordered, err := applyOrderedList(current, listed)
+	if err != nil {
+		return err
+	}
+	if err := writeCompactOrder(tx, ordered); err != nil {
+		return err
+	}
+
+	if result.Retimed {
+		now := time.Now()
+		for _, item := range result.Items {
+			if item.Fixed || item.Position == item.PreviousPosition {
+				continue
+			}
+			if _, err := tx.Exec("UPDATE travel_items SET start_datetime = $2, end_datetime = $3, updated_at = $4 WHERE id = $1",
+				item.ID, item.StartDatetime, item.EndDatetime, now); err != nil {
+				return err
+			}
+			if err := refreshSunTimes(tx, item.ID); err != nil {
+				return err
+			}
+		}
+	}
+
+	result.Applied = true
+	return nil
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// routeStop 构造位于 (0, lng) 的元素，经度每度约 111 公里
+func routeStop(id string, lng float64) *routeItem {
+	lat := 0.0
+	return &routeItem{id: id, name: id, itemType: models.ItemTypeAttraction, latitude: &lat, longitude: &lng, priority: 3}
+}
+
+func routeIDs(plan *routePlan, order []int) []string {
+	ids := make([]string, len(order))
+	for i, idx := range order {
+		ids[i] = plan.items[idx].id
+	}
+	return ids
+}
+
+func newRoutePlan(items ...*routeItem) *routePlan {
+	for _, item := range items {
+		item.fixedReason = routeFixedReason(item)
+	}
+	return &routePlan{items: items, before: make(map[string]map[string]bool)}
+}
+
+func TestOptimizeRouteMinimisesDistance(t *testing.T) {
+	plan := newRoutePlan(routeStop("a", 0), routeStop("c", 2), routeStop("b", 1), routeStop("d", 3))
+
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, routeIDs(plan, order))
+
+	result := buildOptimizeResult("p", "", plan, order)
+	assert.True(t, result.Changed)
+	assert.InDelta(t, 555.9, result.Before.DistanceKm, 0.5)
+	assert.InDelta(t, 333.6, result.After.DistanceKm, 0.5)
+	assert.Equal(t, 334, result.After.TravelMinutes)
+	assert.Equal(t, 2, result.Items[1].PreviousPosition)
+}
+
+func TestOptimizeRouteKeepsFixedItems(t *testing.T) {
+	flight := routeStop("flight", 10)
+	flight.itemType = models.ItemTypeTransport
+	booked := routeStop("booked", 0)
+	confirmed := models.BookingStatusConfirmed
+	booked.bookingStatus = &confirmed
+
+	plan := newRoutePlan(routeStop("far", 9), booked, routeStop("near", 0.5), flight)
+
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	ids := routeIDs(plan, order)
+	assert.Equal(t, "booked", ids[1])
+	assert.Equal(t, "flight", ids[3])
+	assert.Equal(t, []string{"near", "booked", "far", "flight"}, ids)
+}
+
+func TestOptimizeRouteRespectsRelationsAndPriority(t *testing.T) {
+	plan := newRoutePlan(routeStop("a", 0), routeStop("b", 1), routeStop("c", 2))
+	plan.addBefore("c", "b")
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "c", "b"}, routeIDs(plan, order))
+
+	important := routeStop("important", 5)
+	important.priority = 5
+	plan = newRoutePlan(routeStop("a", 0), routeStop("b", 1), important)
+	order, err = optimizeRoute(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"important", "b", "a"}, routeIDs(plan, order))
+}
+
+func TestOptimizeRouteMustPrecedeFixedItem(t *testing.T) {
+	train := routeStop("train", 0)
+	train.itemType = models.ItemTypeTransport
+	plan := newRoutePlan(routeStop("a", 0.1), train, routeStop("b", 5), routeStop("c", 0.2))
+	plan.addBefore("c", "train")
+
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c", "train", "a", "b"}, routeIDs(plan, order))
+}
+
+func TestOptimizeRouteCycle(t *testing.T) {
+	plan := newRoutePlan(routeStop("a", 0), routeStop("b", 1))
+	plan.addBefore("a", "b")
+	plan.addBefore("b", "a")
+
+	_, err := optimizeRoute(plan)
+	assert.ErrorIs(t, err, errRouteConstraints)
+}
+
+func TestBuildOptimizeResultRetimes(t *testing.T) {
+	at := func(hour int) *time.Time {
+		v := time.Date(2025, 10, 1, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+	a, b, c := routeStop("a", 0), routeStop("c", 2), routeStop("b", 1)
+	a.start, a.end = at(9), at(10)
+	b.start, b.end = at(11), at(14)
+	c.start, c.end = at(15), at(16)
+	plan := newRoutePlan(a, b, c)
+
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	result := buildOptimizeResult("p", "2025-10-01", plan, order)
+	assert.True(t, result.Retimed)
+	assert.Equal(t, "b", result.Items[1].ID)
+	assert.Equal(t, *at(11), *result.Items[1].StartDatetime)
+	assert.Equal(t, *at(12), *result.Items[1].EndDatetime)
+	assert.Equal(t, *at(15), *result.Items[2].StartDatetime)
+	assert.Equal(t, *at(18), *result.Items[2].EndDatetime)
+}
+
+func TestRouteScheduleConflicts(t *testing.T) {
+	at := func(hour int) *time.Time {
+		v := time.Date(2025, 10, 1, hour, 0, 0, 0, time.UTC)
+		return &v
+	}
+	a, b, c := routeStop("a", 0), routeStop("c", 2), routeStop("b", 1)
+	a.start, a.end = at(9), at(10)
+	b.start, b.end = at(11), at(14)
+	c.start, c.end = at(15), at(16)
+	bus := routeStop("bus", 3)
+	bus.itemType = models.ItemTypeTransport
+	bus.start, bus.end = at(17), at(19)
+
+	plan := newRoutePlan(a, b, c)
+	order, err := optimizeRoute(plan)
+	assert.NoError(t, err)
+	assert.Empty(t, routeScheduleConflicts(plan, buildOptimizeResult("p", "", plan, order)))
+
+	// 三小时的「c」移到 15:00 后与 17:00 的固定交通重叠
+	plan = newRoutePlan(a, b, c, bus)
+	order, err = optimizeRoute(plan)
+	assert.NoError(t, err)
+	result := buildOptimizeResult("p", "", plan, order)
+	result.Conflicts = routeScheduleConflicts(plan, result)
+	assert.Len(t, result.Conflicts, 1)
+	assert.Equal(t, models.IssueTimeOverlap, result.Conflicts[0].Code)
+	assert.Equal(t, []string{"c", "bus"}, result.Conflicts[0].ItemIDs)
+
+	assert.ErrorIs(t, applyOptimizedRoute(nil, "p", result), errRouteConflicts)
+	assert.False(t, result.Applied)
+}
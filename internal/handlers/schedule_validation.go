@@ -0,0 +1,441 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// 相距不超过该距离的元素之间不要求预留交通时间
+	nearbyDistanceKm = 1.0
+	// 元素之间没有安排交通时，按地面交通的平均速度估算所需时间
+	groundSpeedKmh = 60.0
+)
+
+// transportMaxSpeedKmh 各交通方式的合理最高平均速度，超过说明时间安排不可行
+var transportMaxSpeedKmh = map[string]float64{
+	"flight":  950,
+	"train":   350,
+	"bus":     100,
+	"taxi":    120,
+	"car":     120,
+	"charter": 120,
+	"walk":    7,
+}
+
+// scheduleItem 行程校验所需的元素字段
+type scheduleItem struct {
+	id            string
+	itemType      models.ItemType
+	name          string
+	latitude      *float64
+	longitude     *float64
+	start         *time.Time
+	end           *time.Time
+	durationHours *float64
+	timeZone      *string
+
+	transportType     *string
+	distanceKm        *float64
+	departureTime     *time.Time
+	arrivalTime       *time.Time
+	departureTimeZone *string
+	arrivalTimeZone   *string
+
+	checkInTime  *string
+	checkOutTime *string
+
+	openingHours *models.OpeningHours
+}
+
+// planWindow 计划的起止日期，未设置时为 nil
+type planWindow struct {
+	startDate *time.Time
+	endDate   *time.Time
+}
+
+// ValidateItinerary 检查计划中的时间冲突和可行性问题
+func ValidateItinerary(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	window, items, err := loadScheduleItems(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	report := models.ScheduleReport{
+		PlanID: planID,
+		Issues: validateSchedule(window, items),
+	}
+	for _, issue := range report.Issues {
+		if issue.Severity == models.IssueSeverityError {
+			report.ErrorCount++
+		} else {
+			report.WarningCount++
+		}
+	}
+	report.Valid = report.ErrorCount == 0
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      report,
+		Timestamp: time.Now(),
+	})
+}
+
+// itemScheduleIssues 校验整个计划并返回涉及指定元素的问题
+func itemScheduleIssues(planID, itemID string) ([]models.ScheduleIssue, error) {
+	window, items, err := loadScheduleItems(planID)
+	if err != nil {
+		return nil, err
+	}
+
+	issues := []models.ScheduleIssue{}
+	for _, issue := range validateSchedule(window, items) {
+		if indexOf(issue.ItemIDs, itemID) >= 0 {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// loadScheduleItems 加载计划起止日期和元素
+func loadScheduleItems(planID string) (planWindow, []scheduleItem, error) {
+	db := database.GetDB()
+
+	var window planWindow
+	var planZone *string
+	if err := db.QueryRow("SELECT start_date, end_date, time_zone FROM plans WHERE id = $1", planID).
+		Scan(&window.startDate, &window.endDate, &planZone); err != nil {
+		return window, nil, err
+	}
+
+	rows, err := db.Query(`
+		SELECT t.id, t.item_type, t.name, t.latitude, t.longitude,
+			t.start_datetime, t.end_datetime, t.duration_hours, t.time_zone,
+			td.transport_type, td.distance_km, td.departure_time, td.arrival_time,
+			td.departure_time_zone, td.arrival_time_zone,
+			a.check_in_time::text, a.check_out_time::text,
+			ad.opening_hours
+		FROM travel_items t
+		LEFT JOIN transport_details td ON td.item_id = t.id
+		LEFT JOIN accommodation_details a ON a.item_id = t.id
+		LEFT JOIN attraction_details ad ON ad.item_id = t.id
+		WHERE t.plan_id = $1
+		ORDER BY t.start_datetime NULLS LAST, t.order_index NULLS LAST, t.id
+	`, planID)
+	if err != nil {
+		return window, nil, err
+	}
+	defer rows.Close()
+
+	var items []scheduleItem
+	for rows.Next() {
+		var item scheduleItem
+		if err := rows.Scan(
+			&item.id, &item.itemType, &item.name, &item.latitude, &item.longitude,
+			&item.start, &item.end, &item.durationHours, &item.timeZone,
+			&item.transportType, &item.distanceKm, &item.departureTime, &item.arrivalTime,
+			&item.departureTimeZone, &item.arrivalTimeZone,
+			&item.checkInTime, &item.checkOutTime,
+			&item.openingHours,
+		); err != nil {
+			return window, nil, err
+		}
+		item.localize(resolveTimeZoneName(planZone))
+		items = append(items, item)
+	}
+	return window, items, rows.Err()
+}
+
+// localize 将元素时间转换为当地时间，使开放时间、入住退房时刻和按天检查都按当地日历进行。
+// 交通的出发和到达时间分别转换到两端的时区
+func (item *scheduleItem) localize(planZone string) {
+	zone := resolveTimeZoneName(item.timeZone, &planZone)
+	loc := resolveLocation(&zone)
+	item.start = localTime(item.start, loc)
+	item.end = localTime(item.end, loc)
+	item.departureTime = localTime(item.departureTime, resolveLocation(item.departureTimeZone, &zone))
+	item.arrivalTime = localTime(item.arrivalTime, resolveLocation(item.arrivalTimeZone, &zone))
+}
+
+// validateSchedule 对计划元素执行全部校验规则
+func validateSchedule(window planWindow, items []scheduleItem) []models.ScheduleIssue {
+	issues := []models.ScheduleIssue{}
+	byID := make(map[string]*scheduleItem, len(items))
+	var spans []timelineSpan
+
+	for i := range items {
+		item := &items[i]
+		byID[item.id] = item
+
+		start, end := item.interval()
+		if start == nil || end == nil {
+			continue
+		}
+		if !end.After(*start) {
+			// 住宿的时间范围只表示入住日期，同一天入住退房不算错误
+			if item.itemType != models.ItemTypeAccommodation || end.Before(*start) {
+				issues = append(issues, models.ScheduleIssue{
+					Code:     models.IssueInvalidTimeRange,
+					Severity: models.IssueSeverityError,
+					Message:  fmt.Sprintf("「%s」的结束时间 %s 不晚于开始时间 %s", item.name, end.Format("2006-01-02 15:04"), start.Format("2006-01-02 15:04")),
+					ItemIDs:  []string{item.id},
+					Date:     start.Format("2006-01-02"),
+				})
+			}
+			continue
+		}
+
+		if item.itemType == models.ItemTypeTransport {
+			if issue := checkTransportSpeed(item, end.Sub(*start)); issue != nil {
+				issues = append(issues, *issue)
+			}
+		}
+
+		// 住宿跨越整晚，与其他元素重叠是正常的
+		if item.itemType != models.ItemTypeAccommodation {
+			spans = append(spans, timelineSpan{id: item.id, name: item.name, start: *start, end: *end})
+		}
+	}
+
+	walkSpans(spans, func(cur, next timelineSpan) {
+		if next.start.Before(cur.end) {
+			issues = append(issues, models.ScheduleIssue{
+				Code:     models.IssueTimeOverlap,
+				Severity: models.IssueSeverityError,
+				Message:  fmt.Sprintf("「%s」与「%s」时间重叠", cur.name, next.name),
+				ItemIDs:  []string{cur.id, next.id},
+				Date:     next.start.Format("2006-01-02"),
+			})
+			return
+		}
+		if issue := checkTravelTime(byID[cur.id], byID[next.id], cur.end, next.start); issue != nil {
+			issues = append(issues, *issue)
+		}
+	})
+
+	issues = append(issues, checkAccommodationSequence(items)...)
+
+	for i := range items {
+		item := &items[i]
+		start, end := item.interval()
+		switch item.itemType {
+		case models.ItemTypeAttraction:
+			for _, warning := range checkVisitHours(item.openingHours, start, end) {
+				issues = append(issues, models.ScheduleIssue{
+					Code:     models.IssueOutsideOpeningHours,
+					Severity: models.IssueSeverityWarning,
+					Message:  fmt.Sprintf("「%s」%s", item.name, warning),
+					ItemIDs:  []string{item.id},
+					Date:     start.Format("2006-01-02"),
+				})
+			}
+		case models.ItemTypePhotoSpot:
+			if warning := photoLightWarning(item.latitude, item.longitude, start, end); warning != "" {
+				issues = append(issues, models.ScheduleIssue{
+					Code:     models.IssueOutsideGoodLight,
+					Severity: models.IssueSeverityWarning,
+					Message:  fmt.Sprintf("「%s」%s", item.name, warning),
+					ItemIDs:  []string{item.id},
+					Date:     start.Format("2006-01-02"),
+				})
+			}
+		}
+	}
+
+	issues = append(issues, checkOvernightStays(window, items)...)
+	return issues
+}
+
+// interval 元素占用的时间段。交通优先使用详情中的出发和到达时间，住宿使用入住和退房时刻
+func (item *scheduleItem) interval() (*time.Time, *time.Time) {
+	switch item.itemType {
+	case models.ItemTypeTransport:
+		return firstTime(item.departureTime, item.start), firstTime(item.arrivalTime, item.end)
+	case models.ItemTypeAccommodation:
+		return atClock(item.start, item.checkInTime), atClock(item.end, item.checkOutTime)
+	default:
+		return item.start, itemEnd(item.start, item.end, item.durationHours)
+	}
+}
+
+// checkTransportSpeed 检查交通段的平均速度是否超出该交通方式的合理范围
+func checkTransportSpeed(item *scheduleItem, duration time.Duration) *models.ScheduleIssue {
+	if item.distanceKm == nil || *item.distanceKm <= 0 || item.transportType == nil {
+		return nil
+	}
+	maxSpeed, ok := transportMaxSpeedKmh[*item.transportType]
+	if !ok {
+		return nil
+	}
+	speed := *item.distanceKm / duration.Hours()
+	if speed <= maxSpeed {
+		return nil
+	}
+	required := time.Duration(*item.distanceKm / maxSpeed * float64(time.Hour))
+	return &models.ScheduleIssue{
+		Code:     models.IssueInsufficientTravel,
+		Severity: models.IssueSeverityError,
+		Message: fmt.Sprintf("「%s」%.0f 公里只安排了 %d 分钟，平均 %.0f 公里/小时，至少需要约 %d 分钟",
+			item.name, *item.distanceKm, int(duration.Minutes()), speed, int(math.Ceil(required.Minutes()))),
+		ItemIDs: []string{item.id},
+		Date:    firstTime(item.departureTime, item.start).Format("2006-01-02"),
+	}
+}
+
+// checkTravelTime 检查两个相邻元素之间是否留出了足够的交通时间，交通元素本身负责位移，不参与检查
+func checkTravelTime(prev, next *scheduleItem, prevEnd, nextStart time.Time) *models.ScheduleIssue {
+	if prev == nil || next == nil ||
+		prev.itemType == models.ItemTypeTransport || next.itemType == models.ItemTypeTransport ||
+		prev.latitude == nil || prev.longitude == nil || next.latitude == nil || next.longitude == nil {
+		return nil
+	}
+
+	distance := haversineKm(*prev.latitude, *prev.longitude, *next.latitude, *next.longitude)
+	if distance <= nearbyDistanceKm {
+		return nil
+	}
+
+	available := nextStart.Sub(prevEnd)
+	required := time.Duration(distance / groundSpeedKmh * float64(time.Hour))
+	if available >= required {
+		return nil
+	}
+
+	issue := &models.ScheduleIssue{
+		Code:    models.IssueInsufficientTravel,
+		ItemIDs: []string{prev.id, next.id},
+		Date:    nextStart.Format("2006-01-02"),
+	}
+	if available <= 0 {
+		issue.Severity = models.IssueSeverityError
+		issue.Message = fmt.Sprintf("「%s」与「%s」相距 %.1f 公里，但之间没有留出交通时间", prev.name, next.name, distance)
+	} else {
+		issue.Severity = models.IssueSeverityWarning
+		issue.Message = fmt.Sprintf("「%s」与「%s」相距 %.1f 公里，只留出 %d 分钟，预计需要约 %d 分钟",
+			prev.name, next.name, distance, int(available.Minutes()), int(math.Ceil(required.Minutes())))
+	}
+	return issue
+}
+
+// checkAccommodationSequence 按入住时间排序后检查每个住宿的退房是否晚于下一个住宿的入住
+func checkAccommodationSequence(items []scheduleItem) []models.ScheduleIssue {
+	type stay struct {
+		item     *scheduleItem
+		checkIn  time.Time
+		checkOut time.Time
+	}
+
+	var stays []stay
+	for i := range items {
+		item := &items[i]
+		if item.itemType != models.ItemTypeAccommodation {
+			continue
+		}
+		checkIn, checkOut := item.interval()
+		if checkIn == nil || checkOut == nil {
+			continue
+		}
+		stays = append(stays, stay{item: item, checkIn: *checkIn, checkOut: *checkOut})
+	}
+	sort.SliceStable(stays, func(i, j int) bool { return stays[i].checkIn.Before(stays[j].checkIn) })
+
+	var issues []models.ScheduleIssue
+	for i := 1; i < len(stays); i++ {
+		prev, next := stays[i-1], stays[i]
+		if !prev.checkOut.After(next.checkIn) {
+			continue
+		}
+		issues = append(issues, models.ScheduleIssue{
+			Code:     models.IssueCheckoutAfterCheckin,
+			Severity: models.IssueSeverityError,
+			Message: fmt.Sprintf("「%s」的退房时间 %s 晚于「%s」的入住时间 %s",
+				prev.item.name, prev.checkOut.Format("2006-01-02 15:04"), next.item.name, next.checkIn.Format("2006-01-02 15:04")),
+			ItemIDs: []string{prev.item.id, next.item.id},
+			Date:    next.checkIn.Format("2006-01-02"),
+		})
+	}
+	return issues
+}
+
+// checkOvernightStays 检查行程中的每一晚是否安排了住宿，夜间行驶的交通视为已安排。
+// 计划未设置起止日期时按元素的最早和最晚日期推算。
+func checkOvernightStays(window planWindow, items []scheduleItem) []models.ScheduleIssue {
+	first, last := window.startDate, window.endDate
+	if first == nil || last == nil {
+		for i := range items {
+			start, end := items[i].interval()
+			for _, t := range []*time.Time{start, end} {
+				if t == nil {
+					continue
+				}
+				if first == nil || t.Before(*first) {
+					first = t
+				}
+				if last == nil || t.After(*last) {
+					last = t
+				}
+			}
+		}
+	}
+	if first == nil || last == nil {
+		return nil
+	}
+
+	// 覆盖的夜晚以日期字符串记录，入住当天至退房前一天
+	covered := make(map[string]bool)
+	for i := range items {
+		item := &items[i]
+		if item.itemType != models.ItemTypeAccommodation && item.itemType != models.ItemTypeTransport {
+			continue
+		}
+		start, end := item.interval()
+		if start == nil || end == nil {
+			continue
+		}
+		for d := dateOnly(*start); d.Before(dateOnly(*end)); d = d.AddDate(0, 0, 1) {
+			covered[d.Format("2006-01-02")] = true
+		}
+	}
+
+	var issues []models.ScheduleIssue
+	for d := dateOnly(*first); d.Before(dateOnly(*last)); d = d.AddDate(0, 0, 1) {
+		night := d.Format("2006-01-02")
+		if covered[night] {
+			continue
+		}
+		issues = append(issues, models.ScheduleIssue{
+			Code:     models.IssueMissingAccommodation,
+			Severity: models.IssueSeverityWarning,
+			Message:  fmt.Sprintf("%s 晚上没有安排住宿", night),
+			Date:     night,
+		})
+	}
+	return issues
+}
+
+// dateOnly 返回时间在其所在时区的日期，统一到 UTC 零点便于按天迭代
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scheduleTime(day, hour, minute int) *time.Time {
+	v := time.Date(2025, 10, day, hour, minute, 0, 0, time.UTC)
+	return &v
+}
+
+func issueCodes(issues []models.ScheduleIssue) []string {
+	codes := []string{}
+	for _, issue := range issues {
+		codes = append(codes, issue.Code)
+	}
+	return codes
+}
+
+func TestValidateScheduleTransportEndsBeforeStart(t *testing.T) {
+	s := func(v string) *string { return &v }
+	items := []scheduleItem{
+		{id: "drive", itemType: models.ItemTypeTransport, name: "稻城到亚丁", transportType: s("car"),
+			departureTime: scheduleTime(1, 9, 0), arrivalTime: scheduleTime(1, 8, 0)},
+	}
+
+	issues := validateSchedule(planWindow{}, items)
+	assert.Equal(t, []string{models.IssueInvalidTimeRange}, issueCodes(issues))
+	assert.Equal(t, models.IssueSeverityError, issues[0].Severity)
+}
+
+func TestValidateScheduleTransportTooFast(t *testing.T) {
+	s := func(v string) *string { return &v }
+	f := func(v float64) *float64 { return &v }
+	items := []scheduleItem{
+		{id: "drive", itemType: models.ItemTypeTransport, name: "稻城到亚丁", transportType: s("car"), distanceKm: f(110),
+			departureTime: scheduleTime(1, 9, 0), arrivalTime: scheduleTime(1, 9, 30)},
+	}
+
+	issues := validateSchedule(planWindow{}, items)
+	assert.Equal(t, []string{models.IssueInsufficientTravel}, issueCodes(issues))
+	assert.Contains(t, issues[0].Message, "至少需要约 55 分钟")
+}
+
+func TestValidateScheduleOverlapAndTravelTime(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	items := []scheduleItem{
+		// 稻城县城
+		{id: "a", itemType: models.ItemTypeAttraction, name: "县城", latitude: f(29.0379), longitude: f(100.2968),
+			start: scheduleTime(1, 9, 0), end: scheduleTime(1, 10, 0)},
+		// 亚丁景区，约 75 公里
+		{id: "b", itemType: models.ItemTypeAttraction, name: "亚丁", latitude: f(28.4263), longitude: f(100.3538),
+			start: scheduleTime(1, 10, 0), end: scheduleTime(1, 12, 0)},
+		{id: "c", itemType: models.ItemTypeOther, name: "午餐", latitude: f(28.4263), longitude: f(100.3538),
+			start: scheduleTime(1, 11, 30), end: scheduleTime(1, 12, 30)},
+		{id: "d", itemType: models.ItemTypeAttraction, name: "县城晚饭", latitude: f(29.0379), longitude: f(100.2968),
+			start: scheduleTime(1, 13, 0), durationHours: f(1)},
+	}
+
+	issues := validateSchedule(planWindow{}, items)
+	assert.Equal(t, []string{models.IssueInsufficientTravel, models.IssueTimeOverlap, models.IssueInsufficientTravel}, issueCodes(issues))
+
+	assert.Equal(t, models.IssueSeverityError, issues[0].Severity)
+	assert.Equal(t, []string{"a", "b"}, issues[0].ItemIDs)
+	assert.Equal(t, []string{"b", "c"}, issues[1].ItemIDs)
+	assert.Equal(t, models.IssueSeverityWarning, issues[2].Severity)
+	assert.Equal(t, []string{"c", "d"}, issues[2].ItemIDs)
+}
+
+func TestValidateScheduleTransportBetweenDistantItems(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	items := []scheduleItem{
+		{id: "a", itemType: models.ItemTypeAttraction, name: "县城", latitude: f(29.0379), longitude: f(100.2968),
+			start: scheduleTime(1, 9, 0), end: scheduleTime(1, 10, 0)},
+		{id: "bus", itemType: models.ItemTypeTransport, name: "大巴", start: scheduleTime(1, 10, 0), end: scheduleTime(1, 12, 0)},
+		{id: "b", itemType: models.ItemTypeAttraction, name: "亚丁", latitude: f(28.4263), longitude: f(100.3538),
+			start: scheduleTime(1, 12, 0), end: scheduleTime(1, 15, 0)},
+	}
+
+	assert.Empty(t, validateSchedule(planWindow{}, items))
+}
+
+func TestValidateScheduleAccommodation(t *testing.T) {
+	s := func(v string) *string { return &v }
+	items := []scheduleItem{
+		{id: "h1", itemType: models.ItemTypeAccommodation, name: "酒店一", start: scheduleTime(1, 0, 0), end: scheduleTime(3, 0, 0),
+			checkInTime: s("14:00:00"), checkOutTime: s("12:00:00")},
+		{id: "h2", itemType: models.ItemTypeAccommodation, name: "酒店二", start: scheduleTime(2, 0, 0), end: scheduleTime(3, 0, 0),
+			checkInTime: s("14:00:00"), checkOutTime: s("12:00:00")},
+		{id: "train", itemType: models.ItemTypeTransport, name: "夜车", start: scheduleTime(4, 21, 0), end: scheduleTime(5, 7, 0)},
+	}
+	start, end := scheduleTime(1, 0, 0), scheduleTime(6, 0, 0)
+
+	issues := validateSchedule(planWindow{startDate: start, endDate: end}, items)
+	assert.Equal(t, []string{models.IssueCheckoutAfterCheckin, models.IssueMissingAccommodation, models.IssueMissingAccommodation}, issueCodes(issues))
+	assert.Equal(t, []string{"h1", "h2"}, issues[0].ItemIDs)
+	assert.Equal(t, "2025-10-03", issues[1].Date)
+	assert.Equal(t, "2025-10-05", issues[2].Date)
+}
+
+func TestValidateScheduleOpeningHours(t *testing.T) {
+	hours := &models.OpeningHours{Weekly: []models.DailyHours{
+		{Weekday: int(time.Wednesday), Ranges: []models.TimeRange{{Open: "08:00", Close: "17:00"}}},
+	}}
+	items := []scheduleItem{
+		{id: "a", itemType: models.ItemTypeAttraction, name: "寺庙", openingHours: hours,
+			start: scheduleTime(1, 16, 0), end: scheduleTime(1, 18, 0)},
+	}
+
+	issues := validateSchedule(planWindow{}, items)
+	assert.Equal(t, []string{models.IssueOutsideOpeningHours}, issueCodes(issues))
+	assert.Equal(t, models.IssueSeverityWarning, issues[0].Severity)
+}
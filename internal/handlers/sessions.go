@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"planner/internal/auth"
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListSessions 获取当前用户仍然有效的登录会话
+func ListSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	claims := c.MustGet("token_claims").(*auth.AccessClaims)
+
+	rows, err := database.GetDB().Query(`
+		SELECT id, device_label, user_agent, ip_address, created_at, last_seen_at
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer rows.Close()
+
+	sessions := []models.UserSession{}
+	for rows.Next() {
+		var s models.UserSession
+		if err := rows.Scan(&s.ID, &s.DeviceLabel, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			c.Error(err)
+			return
+		}
+		s.Current = s.ID == claims.SessionID
+		sessions = append(sessions, s)
+	}
+	if err := rows.Err(); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      sessions,
+		Timestamp: time.Now(),
+	})
+}
+
+// RevokeSession 撤销当前用户的某个会话，该会话的访问令牌和刷新令牌立即失效
+func RevokeSession(c *gin.Context) {
+	userID := c.GetString("user_id")
+	sessionID := c.Param("sessionId")
+
+	err := database.Transaction(func(tx *sql.Tx) error {
+		return revokeSession(tx, userID, sessionID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "会话不存在或已撤销",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Message:   "会话已撤销",
+		Timestamp: time.Now(),
+	})
+}
+
+// RevokeOtherSessions 撤销当前用户除当前会话外的全部会话
+func RevokeOtherSessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	claims := c.MustGet("token_claims").(*auth.AccessClaims)
+
+	var revoked int64
+	err := database.Transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			UPDATE user_sessions SET revoked_at = NOW()
+			WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL
+		`, userID, claims.SessionID)
+		if err != nil {
+			return err
+		}
+		revoked, _ = result.RowsAffected()
+
+		_, err = tx.Exec(`
+			UPDATE refresh_tokens SET revoked_at = NOW()
+			WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL
+		`, userID, claims.SessionID)
+		return err
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      map[string]interface{}{"revoked": revoked},
+		Message:   "已撤销其他全部会话",
+		Timestamp: time.Now(),
+	})
+}
+
+// createSession 记录一次登录，未指定设备名称时根据 User-Agent 生成。mfaVerified 表示登录时通过了两步验证
+func createSession(tx *sql.Tx, userID, label, userAgent, ip string, mfaVerified bool) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = deviceLabel(userAgent)
+	}
+
+	sessionID := uuid.New().String()
+	_, err := tx.Exec(`
+		INSERT INTO user_sessions (id, user_id, device_label, user_agent, ip_address, mfa_verified, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+	`, sessionID, userID, label, nullIfEmpty(userAgent), nullIfEmpty(ip), mfaVerified, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+// revokeSession 撤销会话及其全部刷新令牌；会话不存在、不属于该用户或已撤销时返回 sql.ErrNoRows
+func revokeSession(tx *sql.Tx, userID, sessionID string) error {
+	result, err := tx.Exec(`
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = tx.Exec("UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL", sessionID)
+	return err
+}
+
+// deviceLabel 从 User-Agent 推断“浏览器 · 系统”形式的设备名称
+func deviceLabel(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return "未知设备"
+	}
+
+	// 顺序有意义：Edge 和 Chrome 的 UA 都包含 "safari"，Chrome 的 UA 包含 "chrome"
+	browser := ""
+	switch {
+	case strings.Contains(ua, "micromessenger"):
+		browser = "微信"
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	}
+
+	system := ""
+	switch {
+	case strings.Contains(ua, "iphone"):
+		system = "iPhone"
+	case strings.Contains(ua, "ipad"):
+		system = "iPad"
+	case strings.Contains(ua, "android"):
+		system = "Android"
+	case strings.Contains(ua, "windows"):
+		system = "Windows"
+	case strings.Contains(ua, "mac os"):
+		system = "macOS"
+	case strings.Contains(ua, "linux"):
+		system = "Linux"
+	}
+
+	switch {
+	case browser != "" && system != "":
+		return browser + " · " + system
+	case browser != "":
+		return browser
+	case system != "":
+		return system
+	}
+
+	// 非浏览器客户端（如 curl/8.0），取第一个产品名
+	label := []rune(strings.SplitN(userAgent, "/", 2)[0])
+	if len(label) > 100 {
+		label = label[:100]
+	}
+	return string(label)
+}
+
+// nullIfEmpty 空字符串按 NULL 写入
+func nullIfEmpty(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,410 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 精确求解最少转账次数的参与者上限，超过后使用贪心算法
+const maxExactSettleParticipants = 16
+
+// GetSettleUp 计算参与者之间的结算转账
+func GetSettleUp(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划的预算",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	db := database.GetDB()
+
+	var baseCurrency string
+	if err := db.QueryRow("SELECT base_currency FROM plans WHERE id = $1", planID).Scan(&baseCurrency); err != nil {
+		c.Error(err)
+		return
+	}
+
+	participants, err := loadParticipants(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	items, err := loadBudgetItems(planID, "", "")
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	book, err := loadRateBook()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	converted, _, missing := convertBudgetItems(items, baseCurrency, book)
+
+	result := settleUp(participants, converted)
+	result.PlanID = planID
+	result.BaseCurrency = baseCurrency
+	result.MissingRates = missing
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      result,
+		Timestamp: time.Now(),
+	})
+}
+
+// validateSplit 校验付款人和分摊规则
+func validateSplit(item *models.BudgetItem, participants []models.PlanParticipant) error {
+	known := make(map[string]bool, len(participants))
+	for _, p := range participants {
+		known[p.ID] = true
+	}
+
+	if item.PaidBy != nil && *item.PaidBy != "" && !known[*item.PaidBy] {
+		return fmt.Errorf("付款人不是此计划的参与者")
+	}
+	for id, value := range item.SplitDetails {
+		if !known[id] {
+			return fmt.Errorf("分摊参与者不属于此计划: %s", id)
+		}
+		if value < 0 {
+			return fmt.Errorf("分摊数值不能为负数")
+		}
+	}
+
+	switch item.SplitType {
+	case models.SplitTypeEqual:
+	case models.SplitTypeShares:
+		total := 0.0
+		for _, share := range item.SplitDetails {
+			total += share
+		}
+		if total <= 0 {
+			return fmt.Errorf("按份数分摊需要至少一个正数份额")
+		}
+	case models.SplitTypeExact:
+		amount, ok := settlementAmount(item)
+		if !ok {
+			return fmt.Errorf("按金额分摊需要填写金额")
+		}
+		total := 0.0
+		for _, v := range item.SplitDetails {
+			total += v
+		}
+		if math.Abs(total-amount) > 0.01 {
+			return fmt.Errorf("分摊金额合计 %.2f 与项目金额 %.2f 不一致", total, amount)
+		}
+	default:
+		return fmt.Errorf("无效的分摊方式: %s", item.SplitType)
+	}
+	return nil
+}
+
+// settlementAmount 参与结算的金额：优先实际金额，否则预估金额
+func settlementAmount(item *models.BudgetItem) (float64, bool) {
+	if item.ActualAmount != nil {
+		return *item.ActualAmount, true
+	}
+	if item.EstimatedAmount != nil {
+		return *item.EstimatedAmount, true
+	}
+	return 0, false
+}
+
+// scaleExactSplit 按金额分摊的明细随项目金额一起换算为基准货币。
+// 明细合计与原金额一致（误差一分以内）时按比例缩放到换算后的金额；不一致时原样返回，结算时跳过该项目
+func scaleExactSplit(details models.SplitDetails, original, converted float64) models.SplitDetails {
+	sum := 0.0
+	for _, v := range details {
+		sum += v
+	}
+	if sum <= 0 || math.Abs(sum-original) > 0.01 {
+		return details
+	}
+
+	scaled := make(models.SplitDetails, len(details))
+	for id, v := range details {
+		scaled[id] = v * converted / sum
+	}
+	return scaled
+}
+
+// settleUp 根据已支付且有付款人的预算项目计算每人余额和最少转账
+func settleUp(participants []models.PlanParticipant, items []models.BudgetItem) models.SettleUpResult {
+	result := models.SettleUpResult{
+		Balances:  []models.ParticipantBalance{},
+		Transfers: []models.SettlementTransfer{},
+	}
+
+	names := make(map[string]string, len(participants))
+	paid := make(map[string]int64, len(participants))
+	owed := make(map[string]int64, len(participants))
+	for _, p := range participants {
+		names[p.ID] = p.Name
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.PaidBy == nil || *item.PaidBy == "" {
+			continue
+		}
+		if item.PaymentStatus != models.PaymentStatusPaid && item.PaymentStatus != models.PaymentStatusPartial {
+			continue
+		}
+		if _, ok := names[*item.PaidBy]; !ok {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: 付款人已不在参与者中", item.Description))
+			continue
+		}
+
+		amount, ok := settlementAmount(item)
+		if !ok {
+			continue
+		}
+		cents := int64(math.Round(amount * 100))
+
+		shares, err := splitCents(cents, item, participants)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", item.Description, err))
+			continue
+		}
+
+		paid[*item.PaidBy] += cents
+		for id, share := range shares {
+			owed[id] += share
+		}
+	}
+
+	net := make(map[string]int64, len(participants))
+	for _, p := range participants {
+		net[p.ID] = paid[p.ID] - owed[p.ID]
+		result.Balances = append(result.Balances, models.ParticipantBalance{
+			ParticipantID: p.ID,
+			Name:          p.Name,
+			Paid:          centsToAmount(paid[p.ID]),
+			Owed:          centsToAmount(owed[p.ID]),
+			Net:           centsToAmount(net[p.ID]),
+		})
+	}
+
+	for _, t := range minimizeTransfers(net) {
+		result.Transfers = append(result.Transfers, models.SettlementTransfer{
+			FromParticipantID: t.from,
+			FromName:          names[t.from],
+			ToParticipantID:   t.to,
+			ToName:            names[t.to],
+			Amount:            centsToAmount(t.cents),
+		})
+	}
+
+	return result
+}
+
+// splitCents 按分摊规则将金额（分）分配给参与者，余数按参与者顺序逐分分配，保证合计不变
+func splitCents(total int64, item *models.BudgetItem, participants []models.PlanParticipant) (map[string]int64, error) {
+	// 参与分摊的人员及权重，保持参与者列表顺序以便余数分配稳定
+	var ids []string
+	var weights []float64
+
+	for _, p := range participants {
+		value, listed := item.SplitDetails[p.ID]
+		switch item.SplitType {
+		case models.SplitTypeEqual:
+			if len(item.SplitDetails) == 0 || listed {
+				ids = append(ids, p.ID)
+				weights = append(weights, 1)
+			}
+		case models.SplitTypeShares, models.SplitTypeExact:
+			if listed && value > 0 {
+				ids = append(ids, p.ID)
+				weights = append(weights, value)
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("没有可分摊的参与者")
+	}
+
+	if item.SplitType == models.SplitTypeExact {
+		sum := 0.0
+		for _, w := range weights {
+			sum += w
+		}
+		if math.Abs(sum*100-float64(total)) > 1 {
+			return nil, fmt.Errorf("分摊金额合计与项目金额不一致")
+		}
+
+		// 换算货币后的金额不是整分，先向下取整，剩余的分按参与者顺序逐分分配
+		shares := make(map[string]int64, len(ids))
+		var allocated int64
+		for i, id := range ids {
+			shares[id] = int64(math.Floor(weights[i]*100 + 1e-6))
+			allocated += shares[id]
+		}
+		if allocated > total {
+			shares[ids[0]] -= allocated - total
+			allocated = total
+		}
+		for i := 0; allocated < total; i = (i + 1) % len(ids) {
+			shares[ids[i]]++
+			allocated++
+		}
+		return shares, nil
+	}
+
+	totalWeight := 0.0
+	for _, w := range weights {
+		totalWeight += w
+	}
+
+	shares := make(map[string]int64, len(ids))
+	var allocated int64
+	for i, id := range ids {
+		shares[id] = int64(math.Floor(float64(total) * weights[i] / totalWeight))
+		allocated += shares[id]
+	}
+	for i := 0; allocated < total; i = (i + 1) % len(ids) {
+		shares[ids[i]]++
+		allocated++
+	}
+	return shares, nil
+}
+
+type settleTransfer struct {
+	from  string
+	to    string
+	cents int64
+}
+
+// minimizeTransfers 计算使所有余额归零的最少转账。
+// 最少转账数 = 人数 - 可划分出的零和子集最大数量；参与者较少时用状态压缩DP精确求解，
+// 每个零和子集内部再用贪心配对（k人最多k-1笔）。
+func minimizeTransfers(net map[string]int64) []settleTransfer {
+	var ids []string
+	for id, amount := range net {
+		if amount != 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > maxExactSettleParticipants {
+		return greedyTransfers(ids, net)
+	}
+
+	n := len(ids)
+	full := 1<<n - 1
+	sums := make([]int64, full+1)
+	for mask := 1; mask <= full; mask++ {
+		low := mask & -mask
+		i := 0
+		for 1<<i != low {
+			i++
+		}
+		sums[mask] = sums[mask^low] + net[ids[i]]
+	}
+
+	// dp[mask] 为 mask 中可划分出的零和子集最大数量
+	dp := make([]int, full+1)
+	for mask := 1; mask <= full; mask++ {
+		best := 0
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && dp[mask^(1<<i)] > best {
+				best = dp[mask^(1<<i)]
+			}
+		}
+		if sums[mask] == 0 {
+			best++
+		}
+		dp[mask] = best
+	}
+
+	// 回溯得到一个元素顺序，其中零和前缀即为各个子集的边界
+	var order []int
+	for mask := full; mask != 0; {
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) == 0 {
+				continue
+			}
+			gain := 0
+			if sums[mask] == 0 {
+				gain = 1
+			}
+			if dp[mask^(1<<i)]+gain == dp[mask] {
+				order = append(order, i)
+				mask ^= 1 << i
+				break
+			}
+		}
+	}
+
+	var transfers []settleTransfer
+	var group []string
+	var running int64
+	for k := len(order) - 1; k >= 0; k-- {
+		id := ids[order[k]]
+		group = append(group, id)
+		running += net[id]
+		if running == 0 {
+			transfers = append(transfers, greedyTransfers(group, net)...)
+			group = nil
+		}
+	}
+	return transfers
+}
+
+// greedyTransfers 每次让欠款最多的人向应收最多的人转账
+func greedyTransfers(ids []string, net map[string]int64) []settleTransfer {
+	balance := make(map[string]int64, len(ids))
+	for _, id := range ids {
+		balance[id] = net[id]
+	}
+
+	var transfers []settleTransfer
+	for {
+		var debtor, creditor string
+		for _, id := range ids {
+			if balance[id] < 0 && (debtor == "" || balance[id] < balance[debtor]) {
+				debtor = id
+			}
+			if balance[id] > 0 && (creditor == "" || balance[id] > balance[creditor]) {
+				creditor = id
+			}
+		}
+		if debtor == "" || creditor == "" {
+			return transfers
+		}
+
+		amount := -balance[debtor]
+		if balance[creditor] < amount {
+			amount = balance[creditor]
+		}
+		transfers = append(transfers, settleTransfer{from: debtor, to: creditor, cents: amount})
+		balance[debtor] += amount
+		balance[creditor] -= amount
+	}
+}
+
+func centsToAmount(cents int64) float64 {
+	return float64(cents) / 100
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCents(t *testing.T) {
+	participants := []models.PlanParticipant{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	equal := &models.BudgetItem{SplitType: models.SplitTypeEqual}
+	shares, err := splitCents(10000, equal, participants)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 3334, "b": 3333, "c": 3333}, shares)
+
+	weighted := &models.BudgetItem{
+		SplitType:    models.SplitTypeShares,
+		SplitDetails: models.SplitDetails{"a": 2, "c": 1},
+	}
+	shares, err = splitCents(900, weighted, participants)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 600, "c": 300}, shares)
+
+	exact := &models.BudgetItem{
+		SplitType:    models.SplitTypeExact,
+		SplitDetails: models.SplitDetails{"b": 12.5, "c": 7.5},
+	}
+	shares, err = splitCents(2000, exact, participants)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"b": 1250, "c": 750}, shares)
+}
+
+func TestValidateSplit(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+	payer := "a"
+	stranger := "x"
+	participants := []models.PlanParticipant{{ID: "a"}, {ID: "b"}}
+
+	assert.NoError(t, validateSplit(&models.BudgetItem{PaidBy: &payer, SplitType: models.SplitTypeEqual}, participants))
+	assert.Error(t, validateSplit(&models.BudgetItem{PaidBy: &stranger, SplitType: models.SplitTypeEqual}, participants))
+	assert.Error(t, validateSplit(&models.BudgetItem{SplitType: "random"}, participants))
+	assert.Error(t, validateSplit(&models.BudgetItem{
+		SplitType:    models.SplitTypeShares,
+		SplitDetails: models.SplitDetails{"x": 1},
+	}, participants))
+	assert.Error(t, validateSplit(&models.BudgetItem{
+		SplitType:       models.SplitTypeExact,
+		EstimatedAmount: amount(100),
+		SplitDetails:    models.SplitDetails{"a": 30, "b": 60},
+	}, participants))
+	assert.NoError(t, validateSplit(&models.BudgetItem{
+		SplitType:       models.SplitTypeExact,
+		EstimatedAmount: amount(100),
+		ActualAmount:    amount(90),
+		SplitDetails:    models.SplitDetails{"a": 30, "b": 60},
+	}, participants))
+}
+
+func TestMinimizeTransfers(t *testing.T) {
+	// a、b 与 c、d 各自可以两两抵消，最少只需两笔转账
+	net := map[string]int64{"a": 500, "b": -500, "c": 300, "d": -300, "e": 0}
+	transfers := minimizeTransfers(net)
+	assert.Len(t, transfers, 2)
+
+	balance := map[string]int64{}
+	for _, tr := range transfers {
+		assert.Greater(t, tr.cents, int64(0))
+		balance[tr.from] += tr.cents
+		balance[tr.to] -= tr.cents
+	}
+	for id, amount := range net {
+		assert.Equal(t, amount, -balance[id], id)
+	}
+}
+
+func TestSettleUp(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+	alice, bob := "alice", "bob"
+	participants := []models.PlanParticipant{
+		{ID: alice, Name: "Alice"},
+		{ID: bob, Name: "Bob"},
+		{ID: "carol", Name: "Carol"},
+	}
+
+	items := []models.BudgetItem{
+		{Description: "酒店", PaidBy: &alice, PaymentStatus: models.PaymentStatusPaid,
+			SplitType: models.SplitTypeEqual, ActualAmount: amount(300)},
+		{Description: "包车", PaidBy: &bob, PaymentStatus: models.PaymentStatusPaid,
+			SplitType: models.SplitTypeExact, EstimatedAmount: amount(90),
+			SplitDetails: models.SplitDetails{alice: 45, bob: 45}},
+		{Description: "未支付门票", PaidBy: &alice, PaymentStatus: models.PaymentStatusPending,
+			SplitType: models.SplitTypeEqual, EstimatedAmount: amount(600)},
+	}
+
+	result := settleUp(participants, items)
+
+	assert.Equal(t, []models.ParticipantBalance{
+		{ParticipantID: alice, Name: "Alice", Paid: 300, Owed: 145, Net: 155},
+		{ParticipantID: bob, Name: "Bob", Paid: 90, Owed: 145, Net: -55},
+		{ParticipantID: "carol", Name: "Carol", Paid: 0, Owed: 100, Net: -100},
+	}, result.Balances)
+
+	assert.ElementsMatch(t, []models.SettlementTransfer{
+		{FromParticipantID: "carol", FromName: "Carol", ToParticipantID: alice, ToName: "Alice", Amount: 100},
+		{FromParticipantID: bob, FromName: "Bob", ToParticipantID: alice, ToName: "Alice", Amount: 55},
+	}, result.Transfers)
+}
+
+func TestSettleUpConvertedExactSplit(t *testing.T) {
+	amount := func(v float64) *float64 { return &v }
+	alice, bob, carol := "alice", "bob", "carol"
+	participants := []models.PlanParticipant{
+		{ID: alice, Name: "Alice"},
+		{ID: bob, Name: "Bob"},
+		{ID: carol, Name: "Carol"},
+	}
+	book := newRateBook([]models.ExchangeRate{
+		{FromCurrency: "CNY", ToCurrency: "NPR", Rate: 19.0, RateDate: "2025-09-01"},
+	})
+	paid := time.Date(2025, 9, 10, 12, 0, 0, 0, time.UTC)
+
+	// 尼泊尔卢比按金额分摊，结算时明细应与金额按同一汇率换算为人民币
+	items := []models.BudgetItem{
+		{Description: "向导", Currency: "NPR", PaidBy: &alice, PaymentStatus: models.PaymentStatusPaid,
+			PaymentDate: &paid, SplitType: models.SplitTypeExact, ActualAmount: amount(5000),
+			SplitDetails: models.SplitDetails{alice: 1000, bob: 1500, carol: 2500}},
+	}
+
+	converted, _, missing := convertBudgetItems(items, "CNY", book)
+	assert.Empty(t, missing)
+	assert.Equal(t, models.SplitDetails{alice: 1000, bob: 1500, carol: 2500}, items[0].SplitDetails)
+
+	result := settleUp(participants, converted)
+
+	assert.Empty(t, result.Skipped)
+	var owed float64
+	for _, b := range result.Balances {
+		owed += b.Owed
+	}
+	assert.InDelta(t, 263.16, owed, 0.001)
+	assert.Equal(t, []models.ParticipantBalance{
+		{ParticipantID: alice, Name: "Alice", Paid: 263.16, Owed: 52.64, Net: 210.52},
+		{ParticipantID: bob, Name: "Bob", Paid: 0, Owed: 78.95, Net: -78.95},
+		{ParticipantID: carol, Name: "Carol", Paid: 0, Owed: 131.57, Net: -131.57},
+	}, result.Balances)
+}
@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 太阳高度角（度）
+const (
+	// 日出日落：太阳上边缘与地平线相切，含大气折射
+	sunriseElevation = -0.833
+	civilElevation   = -6.0
+	// 黄金时刻为太阳高度 -4° 到 6°，蓝调时刻为 -6° 到 -4°
+	goldenLowElevation  = -4.0
+	goldenHighElevation = 6.0
+)
+
+// solarState 太阳在当天是否经过指定高度
+type solarState int
+
+const (
+	solarCrosses solarState = iota
+	solarAlwaysAbove
+	solarAlwaysBelow
+)
+
+// GetItemSunTimes 按元素坐标和日期计算日出日落、民用晨昏蒙影和黄金/蓝调时刻
+func GetItemSunTimes(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+	db := database.GetDB()
+
+	var planID string
+	var latitude, longitude *float64
+	var start *time.Time
+	var itemZone, planZone *string
+	err := db.QueryRow(`
+		SELECT t.plan_id, t.latitude, t.longitude, t.start_datetime, t.time_zone, p.time_zone
+		FROM travel_items t
+		JOIN plans p ON p.id = t.plan_id
+		WHERE t.id = $1
+	`, itemID).Scan(&planID, &latitude, &longitude, &start, &itemZone, &planZone)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "元素不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此元素",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if latitude == nil || longitude == nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "元素没有坐标，无法计算日出日落",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	zone := resolveTimeZoneName(itemZone, planZone)
+	loc := resolveLocation(&zone)
+
+	var day time.Time
+	if raw := c.Query("date"); raw != "" {
+		day, err = time.ParseInLocation("2006-01-02", raw, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "date 格式应为 YYYY-MM-DD",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	} else if start != nil {
+		day = start.In(loc)
+	} else {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "元素没有开始时间，请指定 date",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	sun := computeSunTimes(*latitude, *longitude, day)
+	sun.TimeZone = zone
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      sun,
+		Timestamp: time.Now(),
+	})
+}
+
+// computeSunTimes 计算 day 所在当地日期的太阳时刻，结果使用 day 的时区
+func computeSunTimes(latitude, longitude float64, day time.Time) models.SunTimes {
+	loc := day.Location()
+	y, m, d := day.Date()
+	utcDay := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	event := func(elevation float64, rising bool) (*time.Time, solarState) {
+		t, state := solarEvent(utcDay, latitude, longitude, elevation, rising)
+		if state != solarCrosses {
+			return nil, state
+		}
+		t = t.In(loc)
+		return &t, state
+	}
+
+	sun := models.SunTimes{
+		Date:      utcDay.Format("2006-01-02"),
+		TimeZone:  loc.String(),
+		Latitude:  latitude,
+		Longitude: longitude,
+		SolarNoon: solarNoon(utcDay, longitude).In(loc),
+	}
+
+	var state solarState
+	sun.Sunrise, state = event(sunriseElevation, true)
+	sun.Sunset, _ = event(sunriseElevation, false)
+	sun.PolarDay = state == solarAlwaysAbove
+	sun.PolarNight = state == solarAlwaysBelow
+	sun.CivilDawn, _ = event(civilElevation, true)
+	sun.CivilDusk, _ = event(civilElevation, false)
+
+	goldenStart, _ := event(goldenLowElevation, true)
+	goldenEnd, _ := event(goldenLowElevation, false)
+	highRise, highState := event(goldenHighElevation, true)
+	highSet, _ := event(goldenHighElevation, false)
+
+	sun.MorningBlueHour = lightWindow(sun.CivilDawn, goldenStart)
+	sun.EveningBlueHour = lightWindow(goldenEnd, sun.CivilDusk)
+	if highState == solarAlwaysBelow {
+		// 太阳全天低于 6°，从 -4° 升起到落下都是黄金时刻
+		sun.MorningGoldenHour = lightWindow(goldenStart, goldenEnd)
+	} else {
+		sun.MorningGoldenHour = lightWindow(goldenStart, highRise)
+		sun.EveningGoldenHour = lightWindow(highSet, goldenEnd)
+	}
+	return sun
+}
+
+func lightWindow(start, end *time.Time) *models.LightWindow {
+	if start == nil || end == nil || !end.After(*start) {
+		return nil
+	}
+	return &models.LightWindow{Start: *start, End: *end}
+}
+
+// solarEvent 计算 utcDay 对应日期太阳经过指定高度的时刻（NOAA 算法），
+// 从太阳正午出发，用事件时刻的太阳位置迭代修正，精度约一分钟
+func solarEvent(utcDay time.Time, latitude, longitude, elevation float64, rising bool) (time.Time, solarState) {
+	lat := latitude * math.Pi / 180
+	h := elevation * math.Pi / 180
+
+	t := solarNoon(utcDay, longitude)
+	for i := 0; i < 3; i++ {
+		decl, eot := solarPosition(t)
+		cosH := (math.Sin(h) - math.Sin(lat)*math.Sin(decl)) / (math.Cos(lat) * math.Cos(decl))
+		if cosH > 1 {
+			return time.Time{}, solarAlwaysBelow
+		}
+		if cosH < -1 {
+			return time.Time{}, solarAlwaysAbove
+		}
+		hourAngle := math.Acos(cosH) * 180 / math.Pi
+		minutes := 720 - 4*longitude - eot
+		if rising {
+			minutes -= 4 * hourAngle
+		} else {
+			minutes += 4 * hourAngle
+		}
+		t = utcDay.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+	return t.Truncate(time.Second), solarCrosses
+}
+
+// solarNoon 太阳正午的 UTC 时刻
+func solarNoon(utcDay time.Time, longitude float64) time.Time {
+	t := utcDay.Add(time.Duration((720 - 4*longitude) * float64(time.Minute)))
+	for i := 0; i < 2; i++ {
+		_, eot := solarPosition(t)
+		t = utcDay.Add(time.Duration((720 - 4*longitude - eot) * float64(time.Minute)))
+	}
+	return t.Truncate(time.Second)
+}
+
+// solarPosition 返回指定时刻的太阳赤纬（弧度）和时差（分钟）
+func solarPosition(t time.Time) (float64, float64) {
+	rad := math.Pi / 180
+	julianDay := float64(t.Unix())/86400 + 2440587.5
+	c := (julianDay - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+c*(36000.76983+c*0.0003032), 360)
+	meanAnomaly := 357.52911 + c*(35999.05029-0.0001537*c)
+	ecc := 0.016708634 - c*(0.000042037+0.0000001267*c)
+
+	m := meanAnomaly * rad
+	center := math.Sin(m)*(1.914602-c*(0.004817+0.000014*c)) +
+		math.Sin(2*m)*(0.019993-0.000101*c) +
+		math.Sin(3*m)*0.000289
+	omega := (125.04 - 1934.136*c) * rad
+	apparentLong := (meanLong + center - 0.00569 - 0.00478*math.Sin(omega)) * rad
+
+	meanObliquity := 23 + (26+(21.448-c*(46.815+c*(0.00059-c*0.001813)))/60)/60
+	obliquity := (meanObliquity + 0.00256*math.Cos(omega)) * rad
+
+	decl := math.Asin(math.Sin(obliquity) * math.Sin(apparentLong))
+
+	y := math.Tan(obliquity / 2)
+	y *= y
+	l0 := meanLong * rad
+	eot := y*math.Sin(2*l0) - 2*ecc*math.Sin(m) + 4*ecc*y*math.Sin(m)*math.Cos(2*l0) -
+		0.5*y*y*math.Sin(4*l0) - 1.25*ecc*ecc*math.Sin(2*m)
+	return decl, 4 * eot / rad
+}
+
+// fillSunTimes 创建景点时根据坐标和开始日期自动填写未提供的日出日落时间，
+// 两者都是自动计算的时返回 true，之后坐标或日期变化时会重新计算
+func fillSunTimes(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, planID string, req *models.CreateTravelItemRequest) (bool, error) {
+	details := req.AttractionDetails
+	if details == nil || req.Latitude == nil || req.Longitude == nil || req.StartDatetime == nil ||
+		(details.SunriseTime != nil && details.SunsetTime != nil) {
+		return false, nil
+	}
+
+	planZone, err := planTimeZoneName(q, planID)
+	if err != nil {
+		return false, err
+	}
+	loc := resolveLocation(req.TimeZone, &planZone)
+	sunrise, sunset, _ := sunClockTimes(req.Latitude, req.Longitude, req.StartDatetime, loc)
+
+	auto := details.SunriseTime == nil && details.SunsetTime == nil
+	if details.SunriseTime == nil {
+		details.SunriseTime = sunrise
+	}
+	if details.SunsetTime == nil {
+		details.SunsetTime = sunset
+	}
+	return auto, nil
+}
+
+// refreshSunTimes 元素坐标或日期变化后重新计算自动填写的日出日落时间，景点还没有详情时一并创建。
+// 用户手动填写的时间保持不变，手动清空两者后恢复自动计算
+func refreshSunTimes(tx *sql.Tx, itemID string) error {
+	var itemType models.ItemType
+	var latitude, longitude *float64
+	var start *time.Time
+	var itemZone, planZone *string
+	var auto sql.NullBool
+	var sunrise, sunset *string
+	err := tx.QueryRow(`
+		SELECT t.item_type, t.latitude, t.longitude, t.start_datetime, t.time_zone, p.time_zone,
+			d.sun_times_auto, d.sunrise_time::text, d.sunset_time::text
+		FROM travel_items t
+		JOIN plans p ON p.id = t.plan_id
+		LEFT JOIN attraction_details d ON d.item_id = t.id
+		WHERE t.id = $1
+	`, itemID).Scan(&itemType, &latitude, &longitude, &start, &itemZone, &planZone, &auto, &sunrise, &sunset)
+	if err != nil {
+		return err
+	}
+
+	if itemType != models.ItemTypeAttraction && itemType != models.ItemTypePhotoSpot {
+		return nil
+	}
+	// auto 为 NULL 表示详情不存在
+	if auto.Valid && !auto.Bool && (sunrise != nil || sunset != nil) {
+		return nil
+	}
+
+	loc := resolveLocation(itemZone, planZone)
+	sunrise, sunset, ok := sunClockTimes(latitude, longitude, start, loc)
+	if !ok {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO attraction_details (item_id, sunrise_time, sunset_time, sun_times_auto)
+		VALUES ($1, $2, $3, true)
+		ON CONFLICT (item_id) DO UPDATE
+		SET sunrise_time = EXCLUDED.sunrise_time, sunset_time = EXCLUDED.sunset_time, sun_times_auto = true
+	`, itemID, sunrise, sunset)
+	return err
+}
+
+// sunClockTimes 计算开始日期当天（loc 时区）的日出日落时刻，格式与 TIME 列一致；
+// 极昼极夜时对应时刻为 nil，缺少坐标或开始时间时 ok 为 false
+func sunClockTimes(latitude, longitude *float64, start *time.Time, loc *time.Location) (sunrise, sunset *string, ok bool) {
+	if latitude == nil || longitude == nil || start == nil {
+		return nil, nil, false
+	}
+	sun := computeSunTimes(*latitude, *longitude, start.In(loc))
+	if sun.Sunrise != nil {
+		v := sun.Sunrise.Format("15:04:05")
+		sunrise = &v
+	}
+	if sun.Sunset != nil {
+		v := sun.Sunset.Format("15:04:05")
+		sunset = &v
+	}
+	return sunrise, sunset, true
+}
+
+// photoLightWarning 拍摄点的安排时间与当天的黄金时刻和蓝调时刻都不重叠时返回提示，
+// start 和 end 应为拍摄点当地时间；没有坐标或当天没有这些时段时不提示
+func photoLightWarning(latitude, longitude *float64, start, end *time.Time) string {
+	if latitude == nil || longitude == nil || start == nil {
+		return ""
+	}
+	visitEnd := *start
+	if end != nil && end.After(*start) {
+		visitEnd = *end
+	}
+
+	sun := computeSunTimes(*latitude, *longitude, *start)
+	var windows []string
+	for _, w := range []*models.LightWindow{sun.MorningBlueHour, sun.MorningGoldenHour, sun.EveningGoldenHour, sun.EveningBlueHour} {
+		if w == nil {
+			continue
+		}
+		if !start.After(w.End) && !visitEnd.Before(w.Start) {
+			return ""
+		}
+		windows = append(windows, w.Start.Format("15:04")+"-"+w.End.Format("15:04"))
+	}
+	if len(windows) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("拍摄时间 %s 不在黄金时刻或蓝调时刻（%s）", start.Format("15:04"), strings.Join(windows, "、"))
+}
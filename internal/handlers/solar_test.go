@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertNearClock(t *testing.T, want string, got *time.Time) {
+	t.Helper()
+	require.NotNil(t, got)
+	wantTime, err := time.ParseInLocation("2006-01-02 15:04", got.Format("2006-01-02")+" "+want, got.Location())
+	require.NoError(t, err)
+	assert.InDelta(t, 0, got.Sub(wantTime).Minutes(), 2, "want %s, got %s", want, got.Format("15:04:05"))
+}
+
+func TestComputeSunTimesNewYorkSolstice(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	sun := computeSunTimes(40.7128, -74.0060, time.Date(2025, 6, 21, 0, 0, 0, 0, ny))
+
+	assert.Equal(t, "2025-06-21", sun.Date)
+	assertNearClock(t, "05:25", sun.Sunrise)
+	assertNearClock(t, "20:31", sun.Sunset)
+	assertNearClock(t, "04:52", sun.CivilDawn)
+	assertNearClock(t, "21:04", sun.CivilDusk)
+	assert.False(t, sun.PolarDay)
+
+	require.NotNil(t, sun.MorningBlueHour)
+	require.NotNil(t, sun.MorningGoldenHour)
+	require.NotNil(t, sun.EveningGoldenHour)
+	require.NotNil(t, sun.EveningBlueHour)
+	assert.Equal(t, *sun.CivilDawn, sun.MorningBlueHour.Start)
+	assert.Equal(t, sun.MorningBlueHour.End, sun.MorningGoldenHour.Start)
+	assert.True(t, sun.MorningGoldenHour.End.After(*sun.Sunrise))
+	assert.True(t, sun.EveningGoldenHour.Start.Before(*sun.Sunset))
+	assert.Equal(t, sun.EveningGoldenHour.End, sun.EveningBlueHour.Start)
+}
+
+func TestComputeSunTimesPolar(t *testing.T) {
+	oslo := mustLocation(t, "Europe/Oslo")
+
+	winter := computeSunTimes(69.6492, 18.9553, time.Date(2025, 12, 21, 0, 0, 0, 0, oslo))
+	assert.True(t, winter.PolarNight)
+	assert.Nil(t, winter.Sunrise)
+	assert.Nil(t, winter.Sunset)
+	assert.NotNil(t, winter.CivilDawn)
+	assert.Nil(t, winter.EveningGoldenHour)
+
+	summer := computeSunTimes(69.6492, 18.9553, time.Date(2025, 6, 21, 0, 0, 0, 0, oslo))
+	assert.True(t, summer.PolarDay)
+	assert.Nil(t, summer.Sunrise)
+	assert.Nil(t, summer.MorningBlueHour)
+}
+
+func TestPhotoLightWarning(t *testing.T) {
+	shanghai := mustLocation(t, "Asia/Shanghai")
+	lat, lon := 29.0367, 100.3018 // 稻城
+	sun := computeSunTimes(lat, lon, time.Date(2025, 10, 1, 0, 0, 0, 0, shanghai))
+	require.NotNil(t, sun.EveningGoldenHour)
+
+	golden := sun.EveningGoldenHour.Start.Add(10 * time.Minute)
+	assert.Empty(t, photoLightWarning(&lat, &lon, &golden, nil))
+
+	noon := time.Date(2025, 10, 1, 12, 0, 0, 0, shanghai)
+	noonEnd := noon.Add(time.Hour)
+	assert.Contains(t, photoLightWarning(&lat, &lon, &noon, &noonEnd), "不在黄金时刻或蓝调时刻")
+
+	// 安排时间跨入黄金时刻即可
+	longEnd := sun.EveningGoldenHour.Start.Add(time.Minute)
+	assert.Empty(t, photoLightWarning(&lat, &lon, &noon, &longEnd))
+
+	assert.Empty(t, photoLightWarning(nil, &lon, &noon, nil))
+}
+
+func TestSunClockTimes(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	lat, lng := 40.7128, -74.0060
+	start := time.Date(2025, 6, 21, 14, 0, 0, 0, time.UTC)
+
+	sunrise, sunset, ok := sunClockTimes(&lat, &lng, &start, ny)
+	assert.True(t, ok)
+	require.NotNil(t, sunrise)
+	require.NotNil(t, sunset)
+	assert.Equal(t, "05:2", (*sunrise)[:4])
+	assert.Equal(t, "20:3", (*sunset)[:4])
+
+	// 缺少坐标或开始时间时不计算
+	_, _, ok = sunClockTimes(nil, &lng, &start, ny)
+	assert.False(t, ok)
+	_, _, ok = sunClockTimes(&lat, &lng, nil, ny)
+	assert.False(t, ok)
+
+	// 极夜没有日出日落
+	tromso := mustLocation(t, "Europe/Oslo")
+	lat, lng = 69.6492, 18.9553
+	winter := time.Date(2025, 12, 21, 12, 0, 0, 0, time.UTC)
+	sunrise, sunset, ok = sunClockTimes(&lat, &lng, &winter, tromso)
+	assert.True(t, ok)
+	assert.Nil(t, sunrise)
+	assert.Nil(t, sunset)
+}
@@ -60,267 +60,6 @@ func GetSystemStatus(c *gin.Context) {
 
 var startTime = time.Now()
 
-// ==================== 批量操作 ====================
-
-// BatchCreateItems 批量创建元素
-func BatchCreateItems(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "批量创建功能开发中",
-		Timestamp: time.Now(),
-	})
-}
-
-// BatchUpdateItems 批量更新元素
-func BatchUpdateItems(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "批量更新功能开发中",
-		Timestamp: time.Now(),
-	})
-}
-
-// BatchDeleteItems 批量删除元素
-func BatchDeleteItems(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "批量删除功能开发中",
-		Timestamp: time.Now(),
-	})
-}
-
-// ReorderItems 重新排序元素
-func ReorderItems(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "重新排序功能开发中",
-		Timestamp: time.Now(),
-	})
-}
-
-// UpdateItemStatus 更新元素状态
-func UpdateItemStatus(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "状态更新成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 住宿管理 ====================
-
-// GetAccommodations 获取住宿列表
-func GetAccommodations(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// CreateAccommodation 创建住宿
-func CreateAccommodation(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "住宿创建成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// GetAccommodationDetails 获取住宿详情
-func GetAccommodationDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      map[string]interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// UpdateAccommodationDetails 更新住宿详情
-func UpdateAccommodationDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "住宿更新成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 交通管理 ====================
-
-// GetTransports 获取交通列表
-func GetTransports(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// CreateTransport 创建交通
-func CreateTransport(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "交通创建成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// GetTransportDetails 获取交通详情
-func GetTransportDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      map[string]interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// UpdateTransportDetails 更新交通详情
-func UpdateTransportDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "交通更新成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 景点管理 ====================
-
-// GetAttractions 获取景点列表
-func GetAttractions(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// CreateAttraction 创建景点
-func CreateAttraction(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "景点创建成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// GetAttractionDetails 获取景点详情
-func GetAttractionDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      map[string]interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// UpdateAttractionDetails 更新景点详情
-func UpdateAttractionDetails(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "景点更新成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 附件管理 ====================
-
-// UploadAttachment 上传附件
-func UploadAttachment(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "文件上传成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// GetAttachments 获取附件列表
-func GetAttachments(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// DeleteAttachment 删除附件
-func DeleteAttachment(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "附件删除成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 预算管理 ====================
-
-// GetBudgetSummary 获取预算摘要
-func GetBudgetSummary(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success: true,
-		Data: map[string]interface{}{
-			"total_budget": 0,
-			"total_spent":  0,
-			"remaining":    0,
-		},
-		Timestamp: time.Now(),
-	})
-}
-
-// AddBudgetItem 添加预算项目
-func AddBudgetItem(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "预算项目添加成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// GetBudgetItems 获取预算项目列表
-func GetBudgetItems(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// UpdateBudgetItem 更新预算项目
-func UpdateBudgetItem(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "预算项目更新成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// DeleteBudgetItem 删除预算项目
-func DeleteBudgetItem(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "预算项目删除成功",
-		Timestamp: time.Now(),
-	})
-}
-
-// ==================== 行程视图 ====================
-
-// GetTimeline 获取时间线
-func GetTimeline(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      []interface{}{},
-		Timestamp: time.Now(),
-	})
-}
-
-// OptimizeItinerary 优化行程
-func OptimizeItinerary(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "行程优化完成",
-		Timestamp: time.Now(),
-	})
-}
-
 // ==================== 统计分析 ====================
 
 // GetPlanStatistics 获取计划统计
@@ -336,26 +75,6 @@ func GetPlanStatistics(c *gin.Context) {
 	})
 }
 
-// ==================== 导入导出 ====================
-
-// ExportPlanPDF 导出计划为PDF
-func ExportPlanPDF(c *gin.Context) {
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Message:   "PDF导出功能开发中",
-		Timestamp: time.Now(),
-	})
-}
-
-// ImportPlanJSON 导入JSON计划
-func ImportPlanJSON(c *gin.Context) {
-	c.JSON(http.StatusCreated, models.ApiResponse{
-		Success:   true,
-		Message:   "计划导入成功",
-		Timestamp: time.Now(),
-	})
-}
-
 // ==================== 管理员功能 ====================
 
 // ListUsers 用户列表
@@ -0,0 +1,471 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认只报告不短于30分钟的空闲时间
+const defaultTimelineMinGap = 30 * time.Minute
+
+// timelineEventRank 同一时刻事件的先后顺序：先退房、到达，再开始新的活动，最后入住
+var timelineEventRank = map[string]int{
+	models.TimelineEventCheckOut:  0,
+	models.TimelineEventSunrise:   1,
+	models.TimelineEventArrival:   2,
+	models.TimelineEventGap:       3,
+	models.TimelineEventItem:      4,
+	models.TimelineEventDeparture: 5,
+	models.TimelineEventOverlap:   6,
+	models.TimelineEventSunset:    7,
+	models.TimelineEventCheckIn:   8,
+}
+
+// timelineSource 生成时间线所需的元素及其详情字段
+type timelineSource struct {
+	id            string
+	itemType      models.ItemType
+	name          string
+	address       *string
+	start         *time.Time
+	end           *time.Time
+	durationHours *float64
+	cost          *float64
+	status        string
+	timeZone      *string
+
+	departureLocation *string
+	arrivalLocation   *string
+	departureTime     *time.Time
+	arrivalTime       *time.Time
+	departureTimeZone *string
+	arrivalTimeZone   *string
+
+	checkInTime  *string
+	checkOutTime *string
+
+	sunriseTime *string
+	sunsetTime  *string
+}
+
+// timelineSpan 占用时间段的事件，用于检测空闲和重叠
+type timelineSpan struct {
+	id    string
+	name  string
+	start time.Time
+	end   time.Time
+}
+
+// GetTimeline 获取按时间排序的统一时间线
+func GetTimeline(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权查看此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	day := c.Query("day")
+	if day != "" {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "day 格式应为 YYYY-MM-DD",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	var types map[string]bool
+	if raw := c.Query("types"); raw != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(raw, ",") {
+			t = strings.TrimSpace(t)
+			if _, ok := timelineEventRank[t]; !ok {
+				c.JSON(http.StatusBadRequest, models.ApiResponse{
+					Success:   false,
+					Message:   "无效的事件类型: " + t,
+					Timestamp: time.Now(),
+				})
+				return
+			}
+			types[t] = true
+		}
+	}
+
+	minGap := defaultTimelineMinGap
+	if raw := c.Query("min_gap_minutes"); raw != "" {
+		minutes, err := strconv.Atoi(raw)
+		if err != nil || minutes < 0 {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "min_gap_minutes 必须是非负整数",
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		minGap = time.Duration(minutes) * time.Minute
+	}
+
+	planZone, err := planTimeZoneName(database.GetDB(), planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	sources, err := loadTimelineSources(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	events := buildTimeline(sources, planZone, minGap)
+	timeline := models.Timeline{PlanID: planID, TimeZone: planZone}
+	if len(events) > 0 {
+		timeline.TotalCost = events[len(events)-1].RunningCost
+	}
+	timeline.Events = filterTimeline(events, day, types)
+	for _, e := range timeline.Events {
+		switch e.Type {
+		case models.TimelineEventGap:
+			timeline.GapCount++
+		case models.TimelineEventOverlap:
+			timeline.OverlapCount++
+		}
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      timeline,
+		Timestamp: time.Now(),
+	})
+}
+
+// loadTimelineSources 加载计划元素及交通、住宿、景点详情中与时间相关的字段
+func loadTimelineSources(planID string) ([]timelineSource, error) {
+	db := database.GetDB()
+	rows, err := db.Query(`
+		SELECT t.id, t.item_type, t.name, t.address,
+			t.start_datetime, t.end_datetime, t.duration_hours, t.cost, COALESCE(t.status, ''), t.time_zone,
+			td.departure_location, td.arrival_location, td.departure_time, td.arrival_time,
+			td.departure_time_zone, td.arrival_time_zone,
+			a.check_in_time::text, a.check_out_time::text,
+			ad.sunrise_time::text, ad.sunset_time::text
+		FROM travel_items t
+		LEFT JOIN transport_details td ON td.item_id = t.id
+		LEFT JOIN accommodation_details a ON a.item_id = t.id
+		LEFT JOIN attraction_details ad ON ad.item_id = t.id
+		WHERE t.plan_id = $1
+		ORDER BY t.start_datetime NULLS LAST, t.order_index NULLS LAST, t.id
+	`, planID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sources []timelineSource
+	for rows.Next() {
+		var s timelineSource
+		if err := rows.Scan(
+			&s.id, &s.itemType, &s.name, &s.address,
+			&s.start, &s.end, &s.durationHours, &s.cost, &s.status, &s.timeZone,
+			&s.departureLocation, &s.arrivalLocation, &s.departureTime, &s.arrivalTime,
+			&s.departureTimeZone, &s.arrivalTimeZone,
+			&s.checkInTime, &s.checkOutTime,
+			&s.sunriseTime, &s.sunsetTime,
+		); err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	return sources, rows.Err()
+}
+
+// buildTimeline 将元素展开为事件，检测空闲和重叠，排序后计算累计费用。
+// 事件时间转换为发生地的本地时间：交通出发和到达分别使用两端的时区，
+// 其他元素使用元素时区，未设置时使用计划时区
+func buildTimeline(sources []timelineSource, planZone string, minGap time.Duration) []models.TimelineEvent {
+	var events []models.TimelineEvent
+	var spans []timelineSpan
+
+	for i := range sources {
+		s := &sources[i]
+		zone := resolveTimeZoneName(s.timeZone, &planZone)
+		loc := resolveLocation(&zone)
+		start := localTime(s.start, loc)
+		end := localTime(s.end, loc)
+		base := models.TimelineEvent{
+			ItemID:   &s.id,
+			ItemType: s.itemType,
+			Name:     s.name,
+			Status:   normalizeItemStatus(s.status),
+			TimeZone: zone,
+		}
+
+		switch s.itemType {
+		case models.ItemTypeTransport:
+			departureZone := resolveTimeZoneName(s.departureTimeZone, &zone)
+			arrivalZone := resolveTimeZoneName(s.arrivalTimeZone, &zone)
+			departure := localTime(firstTime(s.departureTime, s.start), resolveLocation(&departureZone))
+			arrival := localTime(firstTime(s.arrivalTime, s.end), resolveLocation(&arrivalZone))
+			if departure != nil {
+				e := base
+				e.Type = models.TimelineEventDeparture
+				e.Time = *departure
+				e.EndTime = arrival
+				e.TimeZone = departureZone
+				e.Location = firstString(s.departureLocation, s.address)
+				e.Cost = s.cost
+				events = append(events, e)
+			}
+			if arrival != nil {
+				e := base
+				e.Type = models.TimelineEventArrival
+				e.Time = *arrival
+				e.TimeZone = arrivalZone
+				e.Location = s.arrivalLocation
+				events = append(events, e)
+			}
+			if departure != nil && arrival != nil && arrival.After(*departure) {
+				spans = append(spans, timelineSpan{id: s.id, name: s.name, start: *departure, end: *arrival})
+			}
+
+		case models.ItemTypeAccommodation:
+			if checkIn := atClock(start, s.checkInTime); checkIn != nil {
+				e := base
+				e.Type = models.TimelineEventCheckIn
+				e.Time = *checkIn
+				e.Location = s.address
+				e.Cost = s.cost
+				events = append(events, e)
+			}
+			if checkOut := atClock(end, s.checkOutTime); checkOut != nil {
+				e := base
+				e.Type = models.TimelineEventCheckOut
+				e.Time = *checkOut
+				e.Location = s.address
+				events = append(events, e)
+			}
+
+		default:
+			if start == nil {
+				continue
+			}
+			end := itemEnd(start, end, s.durationHours)
+			e := base
+			e.Type = models.TimelineEventItem
+			e.Time = *start
+			e.EndTime = end
+			e.Location = s.address
+			e.Cost = s.cost
+			if end != nil && end.After(*start) {
+				minutes := int(end.Sub(*start).Minutes())
+				e.DurationMinutes = &minutes
+				spans = append(spans, timelineSpan{id: s.id, name: s.name, start: *start, end: *end})
+			}
+			events = append(events, e)
+
+			if s.itemType == models.ItemTypeAttraction || s.itemType == models.ItemTypePhotoSpot {
+				for _, sun := range []struct {
+					kind  string
+					clock *string
+				}{
+					{models.TimelineEventSunrise, s.sunriseTime},
+					{models.TimelineEventSunset, s.sunsetTime},
+				} {
+					if sun.clock == nil {
+						continue
+					}
+					at := atClock(start, sun.clock)
+					e := base
+					e.Type = sun.kind
+					e.Time = *at
+					e.Location = s.address
+					e.Status = ""
+					events = append(events, e)
+				}
+			}
+		}
+	}
+
+	events = append(events, spanEvents(spans, minGap)...)
+
+	for i := range events {
+		e := &events[i]
+		if e.ID == "" {
+			e.ID = *e.ItemID + ":" + e.Type
+		}
+		e.Date = e.Time.Format("2006-01-02")
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		if timelineEventRank[a.Type] != timelineEventRank[b.Type] {
+			return timelineEventRank[a.Type] < timelineEventRank[b.Type]
+		}
+		return a.ID < b.ID
+	})
+
+	// 累计费用按分计算，避免浮点误差累积
+	var running int64
+	for i := range events {
+		if events[i].Cost != nil {
+			running += int64(math.Round(*events[i].Cost * 100))
+		}
+		events[i].RunningCost = centsToAmount(running)
+	}
+	return events
+}
+
+// spanEvents 按开始时间扫描占用时间段，生成同一天内的空闲事件和重叠事件
+func spanEvents(spans []timelineSpan, minGap time.Duration) []models.TimelineEvent {
+	var events []models.TimelineEvent
+	walkSpans(spans, func(cur, next timelineSpan) {
+		if next.start.Before(cur.end) {
+			end := cur.end
+			if next.end.Before(end) {
+				end = next.end
+			}
+			minutes := int(end.Sub(next.start).Minutes())
+			events = append(events, models.TimelineEvent{
+				ID:              "overlap:" + cur.id + ":" + next.id,
+				Type:            models.TimelineEventOverlap,
+				RelatedItemIDs:  []string{cur.id, next.id},
+				Name:            fmt.Sprintf("%s 与 %s 时间重叠", cur.name, next.name),
+				Time:            next.start,
+				EndTime:         &end,
+				DurationMinutes: &minutes,
+			})
+			return
+		}
+
+		gap := next.start.Sub(cur.end)
+		if gap > 0 && gap >= minGap && sameDay(cur.end, next.start) {
+			end := next.start
+			minutes := int(gap.Minutes())
+			events = append(events, models.TimelineEvent{
+				ID:              "gap:" + cur.id + ":" + next.id,
+				Type:            models.TimelineEventGap,
+				RelatedItemIDs:  []string{cur.id, next.id},
+				Name:            fmt.Sprintf("空闲 %d 分钟", minutes),
+				Time:            cur.end,
+				EndTime:         &end,
+				DurationMinutes: &minutes,
+			})
+		}
+	})
+	return events
+}
+
+// walkSpans 按开始时间排序后依次访问每个时间段，cur 为此前结束最晚的时间段，
+// 这样被长时段完全覆盖的元素也能检测到重叠；next.start 早于 cur.end 即为重叠
+func walkSpans(spans []timelineSpan, visit func(cur, next timelineSpan)) {
+	sort.Slice(spans, func(i, j int) bool {
+		if !spans[i].start.Equal(spans[j].start) {
+			return spans[i].start.Before(spans[j].start)
+		}
+		if !spans[i].end.Equal(spans[j].end) {
+			return spans[i].end.Before(spans[j].end)
+		}
+		return spans[i].id < spans[j].id
+	})
+
+	if len(spans) == 0 {
+		return
+	}
+	cur := spans[0]
+	for _, next := range spans[1:] {
+		visit(cur, next)
+		if next.end.After(cur.end) {
+			cur = next
+		}
+	}
+}
+
+// filterTimeline 按日期和事件类型过滤，空条件表示不过滤
+func filterTimeline(events []models.TimelineEvent, day string, types map[string]bool) []models.TimelineEvent {
+	filtered := []models.TimelineEvent{}
+	for _, e := range events {
+		if day != "" && e.Date != day {
+			continue
+		}
+		if types != nil && !types[e.Type] {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// itemEnd 元素结束时间：优先使用 end_datetime，否则按 duration_hours 推算
+func itemEnd(start, end *time.Time, durationHours *float64) *time.Time {
+	if end != nil {
+		return end
+	}
+	if start != nil && durationHours != nil && *durationHours > 0 {
+		t := start.Add(time.Duration(*durationHours * float64(time.Hour)))
+		return &t
+	}
+	return nil
+}
+
+// atClock 将 base 在其时区的日期与当地时刻 HH:MM[:SS] 组合；clock 为空或无效时返回 base。
+// 直接按时分构造，夏令时切换当天也能得到正确的当地时刻
+func atClock(base *time.Time, clock *string) *time.Time {
+	if base == nil {
+		return nil
+	}
+	if clock == nil {
+		return base
+	}
+	minutes, err := parseClock(*clock)
+	if err != nil {
+		return base
+	}
+	y, m, d := base.Date()
+	t := time.Date(y, m, d, minutes/60, minutes%60, 0, 0, base.Location())
+	return &t
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
+
+func firstTime(values ...*time.Time) *time.Time {
+	for _, v := range values {
+		if v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func firstString(values ...*string) *string {
+	for _, v := range values {
+		if v != nil && *v != "" {
+			return v
+		}
+	}
+	return nil
+}
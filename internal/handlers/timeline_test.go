@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	at := func(day, hour, minute int) *time.Time {
+		v := time.Date(2025, 10, day, hour, minute, 0, 0, time.UTC)
+		return &v
+	}
+	s := func(v string) *string { return &v }
+	f := func(v float64) *float64 { return &v }
+
+	sources := []timelineSource{
+		{id: "flight", itemType: models.ItemTypeTransport, name: "航班", start: at(1, 8, 0), end: at(1, 10, 0),
+			arrivalTime: at(1, 10, 30), departureLocation: s("成都"), arrivalLocation: s("稻城"), cost: f(1200.10)},
+		{id: "hotel", itemType: models.ItemTypeAccommodation, name: "酒店", start: at(1, 0, 0), end: at(2, 0, 0),
+			checkInTime: s("14:00:00"), checkOutTime: s("12:00:00"), cost: f(300.20)},
+		{id: "lake", itemType: models.ItemTypeAttraction, name: "牛奶海", start: at(1, 13, 0), durationHours: f(2),
+			sunsetTime: s("18:45:00"), cost: f(0.7)},
+		{id: "dinner", itemType: models.ItemTypeOther, name: "晚餐", start: at(1, 14, 30), end: at(1, 16, 0)},
+		{id: "unscheduled", itemType: models.ItemTypeOther, name: "未安排"},
+	}
+
+	events := buildTimeline(sources, "UTC", 30*time.Minute)
+
+	var ids []string
+	for _, e := range events {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{
+		"flight:transport_departure",
+		"flight:transport_arrival",
+		"gap:flight:lake",
+		"lake:item",
+		"hotel:check_in",
+		"dinner:item",
+		"overlap:lake:dinner",
+		"lake:sunset",
+		"hotel:check_out",
+	}, ids)
+
+	gap := events[2]
+	assert.Equal(t, 150, *gap.DurationMinutes)
+	assert.Equal(t, []string{"flight", "lake"}, gap.RelatedItemIDs)
+
+	overlap := events[6]
+	assert.Equal(t, 30, *overlap.DurationMinutes)
+	assert.Equal(t, "2025-10-01", overlap.Date)
+
+	assert.Equal(t, 1200.10, events[0].RunningCost)
+	assert.Equal(t, 1200.80, events[3].RunningCost)
+	assert.Equal(t, 1501.00, events[len(events)-1].RunningCost)
+	assert.Equal(t, "2025-10-02", events[len(events)-1].Date)
+
+	// 同样的输入得到同样的ID
+	again := buildTimeline(sources, "UTC", 30*time.Minute)
+	assert.Equal(t, events, again)
+}
+
+func TestSpanEventsNestedOverlap(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2025, 10, 1, hour, 0, 0, 0, time.UTC) }
+	spans := []timelineSpan{
+		{id: "long", start: at(8), end: at(18)},
+		{id: "a", start: at(9), end: at(10)},
+		{id: "b", start: at(12), end: at(13)},
+		{id: "c", start: at(20), end: at(21)},
+	}
+
+	events := spanEvents(spans, time.Hour)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "overlap:long:a", events[0].ID)
+	assert.Equal(t, "overlap:long:b", events[1].ID)
+	assert.Equal(t, "gap:long:c", events[2].ID)
+}
+
+func TestSpanEventsSkipsOvernightGap(t *testing.T) {
+	spans := []timelineSpan{
+		{id: "a", start: time.Date(2025, 10, 1, 9, 0, 0, 0, time.UTC), end: time.Date(2025, 10, 1, 20, 0, 0, 0, time.UTC)},
+		{id: "b", start: time.Date(2025, 10, 2, 9, 0, 0, 0, time.UTC), end: time.Date(2025, 10, 2, 10, 0, 0, 0, time.UTC)},
+	}
+	assert.Empty(t, spanEvents(spans, 30*time.Minute))
+}
+
+func TestFilterTimeline(t *testing.T) {
+	events := []models.TimelineEvent{
+		{ID: "a", Type: models.TimelineEventItem, Date: "2025-10-01"},
+		{ID: "b", Type: models.TimelineEventGap, Date: "2025-10-01"},
+		{ID: "c", Type: models.TimelineEventItem, Date: "2025-10-02"},
+	}
+
+	assert.Len(t, filterTimeline(events, "", nil), 3)
+	assert.Len(t, filterTimeline(events, "2025-10-01", nil), 2)
+	filtered := filterTimeline(events, "", map[string]bool{models.TimelineEventItem: true})
+	assert.Equal(t, "c", filtered[1].ID)
+	assert.Empty(t, filterTimeline(events, "2025-10-03", nil))
+}
+
+func TestAtClock(t *testing.T) {
+	base := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 10, 1, 14, 30, 0, 0, time.UTC), *atClock(&base, strPtr("14:30:00")))
+	assert.Equal(t, base, *atClock(&base, nil))
+	assert.Equal(t, base, *atClock(&base, strPtr("bad")))
+	assert.Nil(t, atClock(nil, strPtr("14:30")))
+}
+
+func strPtr(v string) *string { return &v }
+
+func TestBuildTimelinePhotoSpotSunTimes(t *testing.T) {
+	start := time.Date(2025, 10, 2, 5, 30, 0, 0, time.UTC)
+	sources := []timelineSource{
+		{id: "spot", itemType: models.ItemTypePhotoSpot, name: "珍珠海日出", start: &start,
+			sunriseTime: strPtr("07:05:00"), sunsetTime: strPtr("18:40:00")},
+	}
+
+	var ids []string
+	for _, e := range buildTimeline(sources, "UTC", 30*time.Minute) {
+		ids = append(ids, e.ID)
+	}
+	assert.Equal(t, []string{"spot:item", "spot:sunrise", "spot:sunset"}, ids)
+}
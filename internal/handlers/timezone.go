@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"planner/internal/config"
+)
+
+// 配置缺失或无效时使用的时区
+const fallbackTimeZone = "Asia/Shanghai"
+
+var locationCache sync.Map
+
+// invalidInputError 请求中的字段值无效，返回400
+type invalidInputError struct {
+	msg string
+}
+
+func (e *invalidInputError) Error() string {
+	return e.msg
+}
+
+// loadTimeZone 按 IANA 名称加载时区并缓存。不接受空名称和依赖服务器环境的 Local
+func loadTimeZone(name string) (*time.Location, error) {
+	if cached, ok := locationCache.Load(name); ok {
+		return cached.(*time.Location), nil
+	}
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("无效的时区: %q", name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区: %s", name)
+	}
+	locationCache.Store(name, loc)
+	return loc, nil
+}
+
+// validateTimeZone 校验可选的时区字段，nil 和空字符串表示不指定
+func validateTimeZone(name *string) error {
+	if name == nil || *name == "" {
+		return nil
+	}
+	if _, err := loadTimeZone(*name); err != nil {
+		return &invalidInputError{msg: err.Error()}
+	}
+	return nil
+}
+
+// nullableTimeZone 空字符串写入数据库时存为 NULL，表示沿用上一级时区
+func nullableTimeZone(name *string) *string {
+	if name == nil || *name == "" {
+		return nil
+	}
+	return name
+}
+
+// defaultTimeZoneName 计划未设置时区时使用的时区
+func defaultTimeZoneName() string {
+	if name := config.Get().DefaultTimeZone; name != "" {
+		if _, err := loadTimeZone(name); err == nil {
+			return name
+		}
+	}
+	return fallbackTimeZone
+}
+
+// resolveTimeZoneName 按顺序返回第一个有效的时区名称，都没有时使用默认时区
+func resolveTimeZoneName(names ...*string) string {
+	for _, name := range names {
+		if name == nil || *name == "" {
+			continue
+		}
+		if _, err := loadTimeZone(*name); err == nil {
+			return *name
+		}
+	}
+	return defaultTimeZoneName()
+}
+
+// resolveLocation 按顺序返回第一个有效的时区，例如交通出发地时区、元素时区、计划时区
+func resolveLocation(names ...*string) *time.Location {
+	loc, err := loadTimeZone(resolveTimeZoneName(names...))
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// planTimeZoneName 计划的有效时区名称
+func planTimeZoneName(q interface {
+	QueryRow(string, ...interface{}) *sql.Row
+}, planID string) (string, error) {
+	var name *string
+	if err := q.QueryRow("SELECT time_zone FROM plans WHERE id = $1", planID).Scan(&name); err != nil {
+		return "", err
+	}
+	return resolveTimeZoneName(name), nil
+}
+
+// localTime 将时间转换为指定时区的本地时间，nil 保持为 nil
+func localTime(t *time.Time, loc *time.Location) *time.Time {
+	if t == nil {
+		return nil
+	}
+	v := t.In(loc)
+	return &v
+}
+
+// localDateSQL 返回按元素时区（未设置时用 planZoneParam 指定的计划时区）计算本地日期的 SQL 表达式
+func localDateSQL(column, zoneColumn, planZoneParam string) string {
+	return fmt.Sprintf("DATE(%s AT TIME ZONE COALESCE(%s, %s))", column, zoneColumn, planZoneParam)
+}
+
+// calendarDaysBetween 两个时间在各自时区中的日历日期相差的天数，不受夏令时影响
+func calendarDaysBetween(from, to time.Time) int {
+	return int(dateOnly(to).Sub(dateOnly(from)).Hours() / 24)
+}
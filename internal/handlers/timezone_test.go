@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := loadTimeZone(name)
+	require.NoError(t, err)
+	return loc
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	assert.NoError(t, validateTimeZone(nil))
+	assert.NoError(t, validateTimeZone(strPtr("")))
+	assert.NoError(t, validateTimeZone(strPtr("Asia/Kathmandu")))
+
+	for _, name := range []string{"Local", "Mars/Olympus", "+08:00"} {
+		err := validateTimeZone(strPtr(name))
+		var inputErr *invalidInputError
+		assert.ErrorAs(t, err, &inputErr, name)
+	}
+}
+
+func TestResolveTimeZoneName(t *testing.T) {
+	assert.Equal(t, "Asia/Kathmandu", resolveTimeZoneName(nil, strPtr("Asia/Kathmandu"), strPtr("UTC")))
+	assert.Equal(t, "UTC", resolveTimeZoneName(strPtr("bad/zone"), strPtr(""), strPtr("UTC")))
+	assert.Equal(t, defaultTimeZoneName(), resolveTimeZoneName())
+}
+
+func TestCalendarDaysBetweenAcrossDST(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	// 2025-03-09 夏令时开始，当天只有23小时
+	from := time.Date(2025, 3, 8, 23, 30, 0, 0, ny)
+	to := time.Date(2025, 3, 10, 0, 30, 0, 0, ny)
+	assert.Equal(t, 2, calendarDaysBetween(from, to))
+	assert.Less(t, to.Sub(from), 48*time.Hour)
+}
+
+func TestAtClockOnDSTDay(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	base := time.Date(2025, 3, 9, 9, 0, 0, 0, ny)
+	got := atClock(&base, strPtr("14:00"))
+	assert.Equal(t, 14, got.Hour())
+	assert.Equal(t, "EDT", got.Format("MST"))
+}
+
+func TestWallClockOnDSTDay(t *testing.T) {
+	ny := mustLocation(t, "America/New_York")
+	day := time.Date(2025, 3, 9, 0, 0, 0, 0, ny)
+	assert.Equal(t, "08:00", wallClock(day, 8*time.Hour).Format("15:04"))
+	assert.Equal(t, "22:00", wallClock(day, 22*time.Hour).Format("15:04"))
+}
+
+func TestScheduleItemLocalize(t *testing.T) {
+	departure := time.Date(2025, 10, 1, 16, 0, 0, 0, time.UTC)
+	arrival := time.Date(2025, 10, 1, 20, 0, 0, 0, time.UTC)
+	item := scheduleItem{
+		itemType:        models.ItemTypeTransport,
+		start:           &departure,
+		departureTime:   &departure,
+		arrivalTime:     &arrival,
+		arrivalTimeZone: strPtr("Asia/Kathmandu"),
+	}
+	item.localize("Asia/Shanghai")
+
+	assert.Equal(t, "2025-10-02 00:00", item.departureTime.Format("2006-01-02 15:04"))
+	assert.Equal(t, "2025-10-02 01:45", item.arrivalTime.Format("2006-01-02 15:04"))
+	assert.True(t, item.start.Equal(departure))
+}
+
+func TestGroupDailyItineraryByLocalDate(t *testing.T) {
+	// 尼泊尔使用 UTC+5:45，UTC 19:00 已是当地次日凌晨
+	late := time.Date(2025, 10, 1, 19, 0, 0, 0, time.UTC)
+	morning := time.Date(2025, 10, 1, 3, 0, 0, 0, time.UTC)
+	cost := 100.0
+	items := []models.TravelItem{
+		{ID: "late", StartDatetime: &late, Cost: &cost},
+		{ID: "morning", StartDatetime: &morning},
+		{ID: "shanghai", StartDatetime: &late, TimeZone: strPtr("Asia/Shanghai")},
+		{ID: "unscheduled"},
+	}
+
+	days := groupDailyItinerary(items, "Asia/Kathmandu")
+	require.Len(t, days, 2)
+
+	assert.Equal(t, "2025-10-01", days[0].Date)
+	assert.Equal(t, "Asia/Kathmandu", days[0].TimeZone)
+	assert.Equal(t, []string{"morning"}, itemIDs(days[0].Items))
+	assert.Equal(t, 8, days[0].StartTime.Hour())
+	assert.Equal(t, 45, days[0].StartTime.Minute())
+
+	assert.Equal(t, "2025-10-02", days[1].Date)
+	assert.Equal(t, []string{"late", "shanghai"}, itemIDs(days[1].Items))
+	assert.Equal(t, 100.0, days[1].TotalCost)
+	assert.Equal(t, 3, days[1].Items[1].StartDatetime.Hour())
+}
+
+func TestBuildTimelineUsesEndpointTimeZones(t *testing.T) {
+	departure := time.Date(2025, 10, 1, 2, 0, 0, 0, time.UTC)
+	arrival := time.Date(2025, 10, 1, 7, 0, 0, 0, time.UTC)
+	sources := []timelineSource{
+		{id: "flight", itemType: models.ItemTypeTransport, name: "成都-加德满都",
+			departureTime: &departure, arrivalTime: &arrival, arrivalTimeZone: strPtr("Asia/Kathmandu")},
+	}
+
+	events := buildTimeline(sources, "Asia/Shanghai", 30*time.Minute)
+	require.Len(t, events, 2)
+
+	assert.Equal(t, "Asia/Shanghai", events[0].TimeZone)
+	assert.Equal(t, 10, events[0].Time.Hour())
+	assert.Equal(t, "Asia/Kathmandu", events[1].TimeZone)
+	assert.Equal(t, "12:45", events[1].Time.Format("15:04"))
+}
+
+func itemIDs(items []models.TravelItem) []string {
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return ids
+}
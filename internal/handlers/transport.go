@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"planner/internal/database"
+	"planner/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var errArrivalBeforeDeparture = errors.New("到达时间不能早于出发时间")
+
+// transportColumns 允许部分更新的交通详情字段
+var transportColumns = map[string]bool{
+	"transport_type":      true,
+	"departure_location":  true,
+	"arrival_location":    true,
+	"departure_time":      true,
+	"arrival_time":        true,
+	"departure_time_zone": true,
+	"arrival_time_zone":   true,
+	"distance_km":         true,
+	"booking_reference":   true,
+	"carrier_name":        true,
+	"vehicle_number":      true,
+	"seat_number":         true,
+	"route_polyline":      true,
+	"elevation_profile":   true,
+	"road_conditions":     true,
+	"fuel_stations":       true,
+	"rest_stops":          true,
+	"estimated_fuel_cost": true,
+	"toll_cost":           true,
+	"departure_terminal":  true,
+	"arrival_terminal":    true,
+	"transfer_info":       true,
+}
+
+const transportSelect = `
+	SELECT t.id, t.plan_id, t.item_type, t.name, t.description,
+		t.latitude, t.longitude, t.address,
+		t.start_datetime, t.end_datetime, t.time_zone, t.duration_hours,
+		t.cost, t.priority, t.status, t.booking_status,
+		t.properties, t.images, t.notes, t.tags,
+		t.order_index, t.group_id,
+		t.created_by, t.created_at, t.updated_at,
+		d.item_id, d.transport_type, d.departure_location, d.arrival_location,
+		d.departure_time, d.arrival_time, d.departure_time_zone, d.arrival_time_zone, d.distance_km,
+		d.booking_reference, d.carrier_name, d.vehicle_number, d.seat_number,
+		d.route_polyline, d.elevation_profile, d.road_conditions, d.fuel_stations, d.rest_stops,
+		d.estimated_fuel_cost, d.toll_cost, d.departure_terminal, d.arrival_terminal, d.transfer_info
+	FROM travel_items t
+	LEFT JOIN transport_details d ON d.item_id = t.id
+`
+
+// GetTransports 获取交通列表
+func GetTransports(c *gin.Context) {
+	planID := c.Param("planId")
+
+	legs, err := loadTransportLegs(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      legs,
+		Timestamp: time.Now(),
+	})
+}
+
+// CreateTransport 创建交通
+func CreateTransport(c *gin.Context) {
+	planID := c.Param("planId")
+	userID := c.GetString("user_id")
+
+	// 验证计划所有权
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权操作此计划",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req models.CreateTravelItemRequest
+	req.ItemType = models.ItemTypeTransport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效: " + err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+	req.ItemType = models.ItemTypeTransport
+
+	if req.TransportDetails == nil {
+		req.TransportDetails = &models.TransportDetails{}
+	}
+
+	// 出发和到达时间默认取元素的起止时间
+	if req.TransportDetails.DepartureTime == nil {
+		req.TransportDetails.DepartureTime = req.StartDatetime
+	}
+	if req.TransportDetails.ArrivalTime == nil {
+		req.TransportDetails.ArrivalTime = req.EndDatetime
+	}
+
+	if err := validateTransportTimes(req.TransportDetails.DepartureTime, req.TransportDetails.ArrivalTime); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   err.Error(),
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var itemID string
+	err := database.Transaction(func(tx *sql.Tx) error {
+		var err error
+		itemID, err = insertTravelItem(tx, planID, userID, &req)
+		return err
+	})
+
+	if err != nil {
+		respondItemError(c, err)
+		return
+	}
+
+	warnings, err := transportLegWarnings(planID, itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":       itemID,
+			"warnings": warnings,
+		},
+		Message:   "交通创建成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// GetTransportDetails 获取交通详情
+func GetTransportDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+
+	planID, itemType, err := getItemPlan(itemID)
+	if err == nil && itemType != models.ItemTypeTransport {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "交通不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	// 加载整个计划的交通以计算与上一段的衔接
+	legs, err := loadTransportLegs(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	for _, leg := range legs {
+		if leg.ID == itemID {
+			c.JSON(http.StatusOK, models.ApiResponse{
+				Success:   true,
+				Data:      leg,
+				Timestamp: time.Now(),
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, models.ApiResponse{
+		Success:   false,
+		Message:   "交通不存在",
+		Timestamp: time.Now(),
+	})
+}
+
+// UpdateTransportDetails 更新交通详情
+func UpdateTransportDetails(c *gin.Context) {
+	itemID := c.Param("itemId")
+	userID := c.GetString("user_id")
+
+	// 验证权限
+	planID, itemType, err := getItemPlan(itemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, models.ApiResponse{
+				Success:   false,
+				Message:   "交通不存在",
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	if itemType != models.ItemTypeTransport {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "该元素不是交通类型",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	if !verifyPlanOwnership(planID, userID) {
+		c.JSON(http.StatusForbidden, models.ApiResponse{
+			Success:   false,
+			Message:   "无权修改此交通",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	var req map[string]interface{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ApiResponse{
+			Success:   false,
+			Message:   "请求参数无效",
+			Timestamp: time.Now(),
+		})
+		return
+	}
+
+	for _, key := range []string{"departure_time_zone", "arrival_time_zone"} {
+		value, ok := req[key]
+		if !ok || value == nil {
+			continue
+		}
+		name, isString := value.(string)
+		if !isString || validateTimeZone(&name) != nil {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   "无效的时区: " + key,
+				Timestamp: time.Now(),
+			})
+			return
+		}
+		if name == "" {
+			req[key] = nil
+		}
+	}
+
+	err = database.Transaction(func(tx *sql.Tx) error {
+		if err := updateItemDetails(tx, "transport_details", itemID, req, transportColumns); err != nil {
+			return err
+		}
+
+		// 按更新后的完整记录校验时间
+		var departure, arrival *time.Time
+		err := tx.QueryRow("SELECT departure_time, arrival_time FROM transport_details WHERE item_id = $1",
+			itemID).Scan(&departure, &arrival)
+		if err != nil {
+			return err
+		}
+		return validateTransportTimes(departure, arrival)
+	})
+
+	if err != nil {
+		if errors.Is(err, errArrivalBeforeDeparture) {
+			c.JSON(http.StatusBadRequest, models.ApiResponse{
+				Success:   false,
+				Message:   err.Error(),
+				Timestamp: time.Now(),
+			})
+		} else {
+			c.Error(err)
+		}
+		return
+	}
+
+	warnings, err := transportLegWarnings(planID, itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"id":       itemID,
+			"warnings": warnings,
+		},
+		Message:   "交通更新成功",
+		Timestamp: time.Now(),
+	})
+}
+
+// loadTransportLegs 加载计划中的所有交通并按出发时间串联
+func loadTransportLegs(planID string) ([]models.TransportLeg, error) {
+	db := database.GetDB()
+
+	rows, err := db.Query(transportSelect+`
+		WHERE t.plan_id = $1 AND t.item_type = $2
+	`, planID, models.ItemTypeTransport)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	legs := []models.TransportLeg{}
+	for rows.Next() {
+		leg, err := scanTransport(rows)
+		if err != nil {
+			return nil, err
+		}
+		legs = append(legs, *leg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	chainTransportLegs(legs)
+	return legs, nil
+}
+
+// transportLegWarnings 获取指定交通段的衔接警告
+func transportLegWarnings(planID, itemID string) ([]string, error) {
+	legs, err := loadTransportLegs(planID)
+	if err != nil {
+		return nil, err
+	}
+	for _, leg := range legs {
+		if leg.ID == itemID {
+			return leg.Warnings, nil
+		}
+	}
+	return nil, nil
+}
+
+// scanTransport 扫描交通元素及详情
+func scanTransport(rows *sql.Rows) (*models.TransportLeg, error) {
+	var leg models.TransportLeg
+	var detailsID sql.NullString
+	var details models.TransportDetails
+
+	err := rows.Scan(
+		&leg.ID, &leg.PlanID, &leg.ItemType, &leg.Name, &leg.Description,
+		&leg.Latitude, &leg.Longitude, &leg.Address,
+		&leg.StartDatetime, &leg.EndDatetime, &leg.TimeZone, &leg.DurationHours,
+		&leg.Cost, &leg.Priority, &leg.Status, &leg.BookingStatus,
+		&leg.Properties, pq.Array(&leg.Images), &leg.Notes, pq.Array(&leg.Tags),
+		&leg.OrderIndex, &leg.GroupID,
+		&leg.CreatedBy, &leg.CreatedAt, &leg.UpdatedAt,
+		&detailsID, &details.TransportType, &details.DepartureLocation, &details.ArrivalLocation,
+		&details.DepartureTime, &details.ArrivalTime, &details.DepartureTimeZone, &details.ArrivalTimeZone, &details.DistanceKm,
+		&details.BookingReference, &details.CarrierName, &details.VehicleNumber, &details.SeatNumber,
+		&details.RoutePolyline, &details.ElevationProfile, &details.RoadConditions, &details.FuelStations, &details.RestStops,
+		&details.EstimatedFuelCost, &details.TollCost, &details.DepartureTerminal, &details.ArrivalTerminal, &details.TransferInfo,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if detailsID.Valid {
+		details.ItemID = detailsID.String
+		leg.Details = &details
+	}
+	return &leg, nil
+}
+
+// validateTransportTimes 校验到达时间不早于出发时间
+func validateTransportTimes(departure, arrival *time.Time) error {
+	if departure != nil && arrival != nil && arrival.Before(*departure) {
+		return errArrivalBeforeDeparture
+	}
+	return nil
+}
+
+// transportDeparture 交通段的出发时间，缺省使用元素开始时间
+func transportDeparture(leg *models.TransportLeg) *time.Time {
+	if details, ok := leg.Details.(*models.TransportDetails); ok && details.DepartureTime != nil {
+		return details.DepartureTime
+	}
+	return leg.StartDatetime
+}
+
+// chainTransportLegs 按出发时间排序，并标记出发地与上一段到达地不一致的交通段
+func chainTransportLegs(legs []models.TransportLeg) {
+	sort.SliceStable(legs, func(i, j int) bool {
+		a, b := transportDeparture(&legs[i]), transportDeparture(&legs[j])
+		if a == nil || b == nil {
+			return a != nil
+		}
+		return a.Before(*b)
+	})
+
+	for i := 1; i < len(legs); i++ {
+		prev, cur := &legs[i-1], &legs[i]
+		if transportDeparture(cur) == nil {
+			continue
+		}
+		cur.PreviousLegID = &prev.ID
+
+		prevDetails, _ := prev.Details.(*models.TransportDetails)
+		curDetails, _ := cur.Details.(*models.TransportDetails)
+		if prevDetails == nil || curDetails == nil ||
+			prevDetails.ArrivalLocation == nil || curDetails.DepartureLocation == nil {
+			continue
+		}
+
+		if !sameLocation(*prevDetails.ArrivalLocation, *curDetails.DepartureLocation) {
+			cur.ChainBroken = true
+			cur.Warnings = append(cur.Warnings, fmt.Sprintf("出发地「%s」与上一段「%s」的到达地「%s」不一致",
+				*curDetails.DepartureLocation, prev.Name, *prevDetails.ArrivalLocation))
+		}
+
+		if prevArrival := prevDetails.ArrivalTime; prevArrival != nil && curDetails.DepartureTime != nil &&
+			curDetails.DepartureTime.Before(*prevArrival) {
+			cur.Warnings = append(cur.Warnings, fmt.Sprintf("出发时间早于上一段「%s」的到达时间", prev.Name))
+		}
+	}
+}
+
+// sameLocation 忽略大小写和首尾空白比较地点名称
+func sameLocation(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
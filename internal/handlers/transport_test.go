@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"planner/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTransportLeg(id, from, to string, departure, arrival time.Time) models.TransportLeg {
+	return models.TransportLeg{
+		TravelItem: models.TravelItem{
+			ID:       id,
+			Name:     from + "-" + to,
+			ItemType: models.ItemTypeTransport,
+			Details: &models.TransportDetails{
+				ItemID:            id,
+				DepartureLocation: &from,
+				ArrivalLocation:   &to,
+				DepartureTime:     &departure,
+				ArrivalTime:       &arrival,
+			},
+		},
+	}
+}
+
+func TestValidateTransportTimes(t *testing.T) {
+	departure := time.Date(2025, 9, 10, 8, 0, 0, 0, time.UTC)
+	arrival := departure.Add(-time.Hour)
+
+	assert.ErrorIs(t, validateTransportTimes(&departure, &arrival), errArrivalBeforeDeparture)
+	assert.NoError(t, validateTransportTimes(&arrival, &departure))
+	assert.NoError(t, validateTransportTimes(&departure, nil))
+}
+
+func TestChainTransportLegs(t *testing.T) {
+	day := time.Date(2025, 9, 10, 0, 0, 0, 0, time.UTC)
+
+	legs := []models.TransportLeg{
+		newTransportLeg("c", "稻城", "亚丁", day.Add(30*time.Hour), day.Add(33*time.Hour)),
+		newTransportLeg("a", "成都", "康定", day.Add(8*time.Hour), day.Add(14*time.Hour)),
+		newTransportLeg("b", " 康定 ", "理塘", day.Add(24*time.Hour), day.Add(29*time.Hour)),
+	}
+
+	chainTransportLegs(legs)
+
+	assert.Equal(t, "a", legs[0].ID)
+	assert.Nil(t, legs[0].PreviousLegID)
+
+	assert.Equal(t, "b", legs[1].ID)
+	assert.Equal(t, "a", *legs[1].PreviousLegID)
+	assert.False(t, legs[1].ChainBroken)
+
+	assert.Equal(t, "c", legs[2].ID)
+	assert.Equal(t, "b", *legs[2].PreviousLegID)
+	assert.True(t, legs[2].ChainBroken)
+	assert.Len(t, legs[2].Warnings, 1)
+}
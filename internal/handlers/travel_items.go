@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"time"
 
@@ -48,68 +50,30 @@ func CreateTravelItem(c *gin.Context) {
 	}
 	defer tx.Rollback()
 
-	// 创建主记录
-	itemID := uuid.New().String()
-	priority := 3
-	if req.Priority != nil {
-		priority = *req.Priority
-	}
-	status := "planned"
-	if req.Status != nil {
-		status = *req.Status
-	}
-
-	_, err = tx.Exec(`
-		INSERT INTO travel_items (
-			id, plan_id, item_type, name, description,
-			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
-			cost, priority, status, properties,
-			created_by, created_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
-	`, itemID, planID, req.ItemType, req.Name, req.Description,
-		req.Latitude, req.Longitude, req.Address,
-		req.StartDatetime, req.EndDatetime, req.DurationHours,
-		req.Cost, priority, status, req.Properties,
-		userID, time.Now(), time.Now())
-
+	itemID, err := insertTravelItem(tx, planID, userID, &req)
 	if err != nil {
-		c.Error(err)
+		respondItemError(c, err)
 		return
 	}
 
-	// 根据类型插入详细信息
-	switch req.ItemType {
-	case models.ItemTypeAccommodation:
-		if req.AccommodationDetails != nil {
-			req.AccommodationDetails.ItemID = itemID
-			err = insertAccommodationDetails(tx, req.AccommodationDetails)
-		}
-	case models.ItemTypeTransport:
-		if req.TransportDetails != nil {
-			req.TransportDetails.ItemID = itemID
-			err = insertTransportDetails(tx, req.TransportDetails)
-		}
-	case models.ItemTypeAttraction, models.ItemTypePhotoSpot:
-		if req.AttractionDetails != nil {
-			req.AttractionDetails.ItemID = itemID
-			err = insertAttractionDetails(tx, req.AttractionDetails)
-		}
-	}
-
-	if err != nil {
+	if err := tx.Commit(); err != nil {
 		c.Error(err)
 		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		c.Error(err)
-		return
+	data := map[string]interface{}{"id": itemID}
+	if c.Query("validate") == "true" {
+		warnings, err := itemScheduleIssues(planID, itemID)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		data["warnings"] = warnings
 	}
 
 	c.JSON(http.StatusCreated, models.ApiResponse{
 		Success:   true,
-		Data:      map[string]string{"id": itemID},
+		Data:      data,
 		Message:   "元素创建成功",
 		Timestamp: time.Now(),
 	})
@@ -134,11 +98,22 @@ func GetTravelItems(c *gin.Context) {
 
 	db := database.GetDB()
 
+	// 日期按元素所在时区的本地日期计算，元素未设置时区时使用计划时区
+	planZone, err := planTimeZoneName(db, planID)
+	if err != nil && err != sql.ErrNoRows {
+		c.Error(err)
+		return
+	}
+	if planZone == "" {
+		planZone = defaultTimeZoneName()
+	}
+	localDate := localDateSQL("start_datetime", "time_zone", "$2")
+
 	// 构建查询
 	query := `
 		SELECT id, plan_id, item_type, name, description,
-			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
 			cost, priority, status, booking_status,
 			properties, images, notes, tags,
 			order_index, group_id,
@@ -147,8 +122,8 @@ func GetTravelItems(c *gin.Context) {
 		WHERE plan_id = $1
 	`
 
-	args := []interface{}{planID}
-	argIndex := 2
+	args := []interface{}{planID, planZone}
+	argIndex := 3
 
 	// 添加过滤条件
 	if itemType != "" {
@@ -158,13 +133,14 @@ func GetTravelItems(c *gin.Context) {
 	}
 
 	if date != "" {
-		query += fmt.Sprintf(" AND DATE(start_datetime) = $%d", argIndex)
+		query += fmt.Sprintf(" AND %s = $%d", localDate, argIndex)
 		args = append(args, date)
 		argIndex++
 	}
 
 	// 排序
-	query += " ORDER BY COALESCE(start_datetime, '9999-12-31'::timestamp), order_index, created_at"
+	// 按日期分组，同一天内按手动排序，未排序的旧数据按开始时间
+	query += " ORDER BY " + localDate + " NULLS LAST, order_index NULLS LAST, start_datetime, created_at"
 
 	// 分页
 	offset := (page - 1) * pageSize
@@ -183,8 +159,8 @@ func GetTravelItems(c *gin.Context) {
 		var item models.TravelItem
 		err := rows.Scan(
 			&item.ID, &item.PlanID, &item.ItemType, &item.Name, &item.Description,
-			&item.Latitude, &item.Longitude, &item.Address,
-			&item.StartDatetime, &item.EndDatetime, &item.DurationHours,
+			&item.Latitude, &item.Longitude, &item.Altitude, &item.Address,
+			&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
 			&item.Cost, &item.Priority, &item.Status, &item.BookingStatus,
 			&item.Properties, pq.Array(&item.Images), &item.Notes, pq.Array(&item.Tags),
 			&item.OrderIndex, &item.GroupID,
@@ -233,8 +209,8 @@ func GetTravelItem(c *gin.Context) {
 	var item models.TravelItem
 	err := db.QueryRow(`
 		SELECT id, plan_id, item_type, name, description,
-			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
 			cost, priority, status, booking_status,
 			properties, images, notes, tags,
 			order_index, group_id,
@@ -242,8 +218,8 @@ func GetTravelItem(c *gin.Context) {
 		FROM travel_items WHERE id = $1
 	`, itemID).Scan(
 		&item.ID, &item.PlanID, &item.ItemType, &item.Name, &item.Description,
-		&item.Latitude, &item.Longitude, &item.Address,
-		&item.StartDatetime, &item.EndDatetime, &item.DurationHours,
+		&item.Latitude, &item.Longitude, &item.Altitude, &item.Address,
+		&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
 		&item.Cost, &item.Priority, &item.Status, &item.BookingStatus,
 		&item.Properties, pq.Array(&item.Images), &item.Notes, pq.Array(&item.Tags),
 		&item.OrderIndex, &item.GroupID,
@@ -312,54 +288,26 @@ func UpdateTravelItem(c *gin.Context) {
 		return
 	}
 
-	// 构建更新语句
-	updates := make(map[string]interface{})
-	if req.Name != nil {
-		updates["name"] = *req.Name
-	}
-	if req.Description != nil {
-		updates["description"] = *req.Description
-	}
-	if req.StartDatetime != nil {
-		updates["start_datetime"] = *req.StartDatetime
-	}
-	if req.EndDatetime != nil {
-		updates["end_datetime"] = *req.EndDatetime
-	}
-	if req.Cost != nil {
-		updates["cost"] = *req.Cost
-	}
-	if req.Priority != nil {
-		updates["priority"] = *req.Priority
-	}
-	if req.Status != nil {
-		updates["status"] = *req.Status
+	if err := database.Transaction(func(tx *sql.Tx) error {
+		return updateTravelItem(tx, itemID, userID, &req)
+	}); err != nil {
+		respondItemError(c, err)
+		return
 	}
 
-	// 执行更新
-	if len(updates) > 0 {
-		query := "UPDATE travel_items SET updated_at = $1"
-		args := []interface{}{time.Now()}
-		argIndex := 2
-
-		for key, value := range updates {
-			query += fmt.Sprintf(", %s = $%d", key, argIndex)
-			args = append(args, value)
-			argIndex++
-		}
-
-		query += fmt.Sprintf(" WHERE id = $%d", argIndex)
-		args = append(args, itemID)
-
-		_, err = db.Exec(query, args...)
+	var data interface{}
+	if c.Query("validate") == "true" {
+		warnings, err := itemScheduleIssues(planID, itemID)
 		if err != nil {
 			c.Error(err)
 			return
 		}
+		data = map[string]interface{}{"id": itemID, "warnings": warnings}
 	}
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success:   true,
+		Data:      data,
 		Message:   "元素更新成功",
 		Timestamp: time.Now(),
 	})
@@ -397,12 +345,19 @@ func DeleteTravelItem(c *gin.Context) {
 		return
 	}
 
+	keys, err := attachmentKeys("t.id = $1", itemID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
 	// 删除元素
 	_, err = db.Exec("DELETE FROM travel_items WHERE id = $1", itemID)
 	if err != nil {
 		c.Error(err)
 		return
 	}
+	removeStoredFiles(keys)
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success:   true,
@@ -411,6 +366,103 @@ func DeleteTravelItem(c *gin.Context) {
 	})
 }
 
+// 辅助函数：按请求中非空字段更新元素，状态变更经过状态机校验并记录历史
+func updateTravelItem(tx *sql.Tx, itemID, userID string, req *models.UpdateTravelItemRequest) error {
+	updates := make(map[string]interface{})
+	if req.Name != nil {
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Latitude != nil {
+		updates["latitude"] = *req.Latitude
+	}
+	if req.Longitude != nil {
+		updates["longitude"] = *req.Longitude
+	}
+	if req.Altitude != nil {
+		updates["altitude"] = *req.Altitude
+	}
+	if req.Address != nil {
+		updates["address"] = *req.Address
+	}
+	if req.StartDatetime != nil {
+		updates["start_datetime"] = *req.StartDatetime
+	}
+	if req.EndDatetime != nil {
+		updates["end_datetime"] = *req.EndDatetime
+	}
+	if req.TimeZone != nil {
+		if err := validateTimeZone(req.TimeZone); err != nil {
+			return err
+		}
+		// 空字符串清除元素时区，改为沿用计划时区
+		updates["time_zone"] = nullableTimeZone(req.TimeZone)
+	}
+	if req.DurationHours != nil {
+		updates["duration_hours"] = *req.DurationHours
+	}
+	if req.Cost != nil {
+		updates["cost"] = *req.Cost
+	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.Properties != nil {
+		updates["properties"] = req.Properties
+	}
+
+	if req.Status != nil {
+		if err := changeItemStatus(tx, itemID, userID, "status", *req.Status, nil); err != nil {
+			return err
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	query := "UPDATE travel_items SET updated_at = $1"
+	args := []interface{}{time.Now()}
+	argIndex := 2
+
+	for key, value := range updates {
+		query += fmt.Sprintf(", %s = $%d", key, argIndex)
+		args = append(args, value)
+		argIndex++
+	}
+
+	query += fmt.Sprintf(" WHERE id = $%d", argIndex)
+	args = append(args, itemID)
+
+	// 开始时间变化时按新时间重新排位，读取旧值需在更新之前
+	var planID string
+	var oldStart *time.Time
+	if req.StartDatetime != nil {
+		if err := tx.QueryRow("SELECT plan_id, start_datetime FROM travel_items WHERE id = $1",
+			itemID).Scan(&planID, &oldStart); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+
+	if req.StartDatetime != nil && (oldStart == nil || !oldStart.Equal(*req.StartDatetime)) {
+		if err := reslotItem(tx, planID, itemID, req.StartDatetime); err != nil {
+			return err
+		}
+	}
+
+	// 坐标、日期或时区变化后重新计算自动填写的日出日落时间
+	if req.Latitude != nil || req.Longitude != nil || req.StartDatetime != nil || req.TimeZone != nil {
+		return refreshSunTimes(tx, itemID)
+	}
+	return nil
+}
+
 // 辅助函数：验证计划所有权
 func verifyPlanOwnership(planID, userID string) bool {
 	db := database.GetDB()
@@ -419,6 +471,127 @@ func verifyPlanOwnership(planID, userID string) bool {
 	return err == nil && ownerID == userID
 }
 
+// 辅助函数：获取元素所属计划和类型
+func getItemPlan(itemID string) (string, models.ItemType, error) {
+	db := database.GetDB()
+	var planID string
+	var itemType models.ItemType
+	err := db.QueryRow("SELECT plan_id, item_type FROM travel_items WHERE id = $1", itemID).Scan(&planID, &itemType)
+	return planID, itemType, err
+}
+
+// 辅助函数：按白名单部分更新详情表，不存在时先创建空记录
+func updateItemDetails(tx *sql.Tx, table, itemID string, updates map[string]interface{}, allowed map[string]bool) error {
+	if _, err := tx.Exec(fmt.Sprintf("INSERT INTO %s (item_id) VALUES ($1) ON CONFLICT (item_id) DO NOTHING", table), itemID); err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET item_id = item_id", table)
+	args := []interface{}{}
+	argIndex := 1
+
+	for key, value := range updates {
+		if !allowed[key] {
+			continue
+		}
+		// JSON对象和数组需要序列化后写入JSONB列
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			value = string(data)
+		}
+		query += fmt.Sprintf(", %s = $%d", key, argIndex)
+		args = append(args, value)
+		argIndex++
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	query += fmt.Sprintf(" WHERE item_id = $%d", argIndex)
+	args = append(args, itemID)
+
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// 辅助函数：插入旅游元素及其类型详情
+func insertTravelItem(tx *sql.Tx, planID, userID string, req *models.CreateTravelItemRequest) (string, error) {
+	itemID := uuid.New().String()
+	priority := 3
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+	status := models.ItemStatusPlanned
+	if req.Status != nil {
+		status = *req.Status
+	}
+	status, err := validateInitialStatus(status)
+	if err != nil {
+		return "", err
+	}
+	if err := validateTimeZone(req.TimeZone); err != nil {
+		return "", err
+	}
+
+	orderIndex, err := nextOrderIndex(tx, planID, req.StartDatetime)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO travel_items (
+			id, plan_id, item_type, name, description,
+			latitude, longitude, altitude, address,
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, priority, status, properties, order_index,
+			created_by, created_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
+	`, itemID, planID, req.ItemType, req.Name, req.Description,
+		req.Latitude, req.Longitude, req.Altitude, req.Address,
+		req.StartDatetime, req.EndDatetime, nullableTimeZone(req.TimeZone), req.DurationHours,
+		req.Cost, priority, status, req.Properties, orderIndex,
+		userID, time.Now(), time.Now())
+
+	if err != nil {
+		return "", err
+	}
+
+	// 根据类型插入详细信息
+	switch req.ItemType {
+	case models.ItemTypeAccommodation:
+		if req.AccommodationDetails != nil {
+			req.AccommodationDetails.ItemID = itemID
+			err = insertAccommodationDetails(tx, req.AccommodationDetails)
+		}
+	case models.ItemTypeTransport:
+		if req.TransportDetails != nil {
+			req.TransportDetails.ItemID = itemID
+			err = insertTransportDetails(tx, req.TransportDetails)
+		}
+	case models.ItemTypeAttraction, models.ItemTypePhotoSpot:
+		// 没有提交详情时也创建详情，用于保存自动计算的日出日落时间
+		if req.AttractionDetails == nil {
+			req.AttractionDetails = &models.AttractionDetails{}
+		}
+		req.AttractionDetails.ItemID = itemID
+		var sunAuto bool
+		if sunAuto, err = fillSunTimes(tx, planID, req); err != nil {
+			return "", err
+		}
+		err = insertAttractionDetails(tx, req.AttractionDetails, sunAuto)
+	}
+
+	if err != nil {
+		return "", err
+	}
+	return itemID, nil
+}
+
 // 辅助函数：插入住宿详情
 func insertAccommodationDetails(tx *sql.Tx, details *models.AccommodationDetails) error {
 	_, err := tx.Exec(`
@@ -437,29 +610,42 @@ func insertAccommodationDetails(tx *sql.Tx, details *models.AccommodationDetails
 
 // 辅助函数：插入交通详情
 func insertTransportDetails(tx *sql.Tx, details *models.TransportDetails) error {
+	if err := validateTimeZone(details.DepartureTimeZone); err != nil {
+		return err
+	}
+	if err := validateTimeZone(details.ArrivalTimeZone); err != nil {
+		return err
+	}
 	_, err := tx.Exec(`
 		INSERT INTO transport_details (
 			item_id, transport_type, departure_location, arrival_location,
-			departure_time, arrival_time, distance_km,
-			booking_reference, carrier_name, vehicle_number, seat_number
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			departure_time, arrival_time, departure_time_zone, arrival_time_zone, distance_km,
+			booking_reference, carrier_name, vehicle_number, seat_number,
+			route_polyline, elevation_profile, road_conditions, fuel_stations, rest_stops,
+			estimated_fuel_cost, toll_cost, departure_terminal, arrival_terminal, transfer_info
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)
 	`, details.ItemID, details.TransportType, details.DepartureLocation, details.ArrivalLocation,
-		details.DepartureTime, details.ArrivalTime, details.DistanceKm,
-		details.BookingReference, details.CarrierName, details.VehicleNumber, details.SeatNumber)
+		details.DepartureTime, details.ArrivalTime,
+		nullableTimeZone(details.DepartureTimeZone), nullableTimeZone(details.ArrivalTimeZone), details.DistanceKm,
+		details.BookingReference, details.CarrierName, details.VehicleNumber, details.SeatNumber,
+		details.RoutePolyline, details.ElevationProfile, details.RoadConditions, details.FuelStations, details.RestStops,
+		details.EstimatedFuelCost, details.TollCost, details.DepartureTerminal, details.ArrivalTerminal, details.TransferInfo)
 	return err
 }
 
-// 辅助函数：插入景点详情
-func insertAttractionDetails(tx *sql.Tx, details *models.AttractionDetails) error {
+// 辅助函数：插入景点详情，sunAuto 表示日出日落时间是自动计算的
+func insertAttractionDetails(tx *sql.Tx, details *models.AttractionDetails, sunAuto bool) error {
 	_, err := tx.Exec(`
 		INSERT INTO attraction_details (
-			item_id, attraction_type, ticket_price, ticket_type,
+			item_id, attraction_type, opening_hours, ticket_price, ticket_type,
 			advance_booking_required, best_visit_time, recommended_duration,
-			difficulty_level, photography_tips
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-	`, details.ItemID, details.AttractionType, details.TicketPrice, details.TicketType,
+			difficulty_level, photography_tips, best_photo_spots,
+			sunrise_time, sunset_time, facilities, accessibility_info, sun_times_auto
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`, details.ItemID, details.AttractionType, details.OpeningHours, details.TicketPrice, details.TicketType,
 		details.AdvanceBookingRequired, details.BestVisitTime, details.RecommendedDuration,
-		details.DifficultyLevel, details.PhotographyTips)
+		details.DifficultyLevel, details.PhotographyTips, details.BestPhotoSpots,
+		details.SunriseTime, details.SunsetTime, details.Facilities, details.AccessibilityInfo, sunAuto)
 	return err
 }
 
@@ -774,86 +960,134 @@ func DeleteAnnotation(c *gin.Context) {
 // GetDailyItinerary 获取每日行程
 func GetDailyItinerary(c *gin.Context) {
 	planID := c.Param("planId")
+
+	days, err := loadDailyItinerary(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ApiResponse{
+		Success:   true,
+		Data:      days,
+		Timestamp: time.Now(),
+	})
+}
+
+// loadDailyItinerary 加载已安排时间的元素并按当地日期分组
+func loadDailyItinerary(planID string) ([]models.DailyItinerary, error) {
 	db := database.GetDB()
 
+	planZone, err := planTimeZoneName(db, planID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if planZone == "" {
+		planZone = defaultTimeZoneName()
+	}
+
 	rows, err := db.Query(`
 		SELECT 
-			DATE(start_datetime) as travel_date,
 			id, item_type, name, description,
 			latitude, longitude, address,
-			start_datetime, end_datetime, duration_hours,
-			cost, status
+			start_datetime, end_datetime, time_zone, duration_hours,
+			cost, status, order_index
 		FROM travel_items
 		WHERE plan_id = $1 AND start_datetime IS NOT NULL
-		ORDER BY start_datetime, order_index
+		ORDER BY order_index NULLS LAST, start_datetime
 	`, planID)
 
 	if err != nil {
-		c.Error(err)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
-	dailyMap := make(map[string]*models.DailyItinerary)
-
+	var items []models.TravelItem
 	for rows.Next() {
-		var date sql.NullTime
 		var item models.TravelItem
 
 		err := rows.Scan(
-			&date,
 			&item.ID, &item.ItemType, &item.Name, &item.Description,
 			&item.Latitude, &item.Longitude, &item.Address,
-			&item.StartDatetime, &item.EndDatetime, &item.DurationHours,
-			&item.Cost, &item.Status,
+			&item.StartDatetime, &item.EndDatetime, &item.TimeZone, &item.DurationHours,
+			&item.Cost, &item.Status, &item.OrderIndex,
 		)
 
 		if err != nil {
 			continue
 		}
+		items = append(items, item)
+	}
 
-		if date.Valid {
-			dateStr := date.Time.Format("2006-01-02")
-			if _, exists := dailyMap[dateStr]; !exists {
-				dailyMap[dateStr] = &models.DailyItinerary{
-					Date:  dateStr,
-					Items: []models.TravelItem{},
-				}
-			}
+	return groupDailyItinerary(items, planZone), nil
+}
 
-			daily := dailyMap[dateStr]
-			daily.Items = append(daily.Items, item)
+// groupDailyItinerary 按元素所在时区的本地日期分组，时间转换为本地时间输出，
+// 跨时区旅行时每个元素都归入当地日历上的那一天
+func groupDailyItinerary(items []models.TravelItem, planZone string) []models.DailyItinerary {
+	dailyMap := make(map[string]*models.DailyItinerary)
 
-			if item.Cost != nil {
-				daily.TotalCost += *item.Cost
+	for _, item := range items {
+		if item.StartDatetime == nil {
+			continue
+		}
+		loc := resolveLocation(item.TimeZone, &planZone)
+		item.StartDatetime = localTime(item.StartDatetime, loc)
+		item.EndDatetime = localTime(item.EndDatetime, loc)
+
+		dateStr := item.StartDatetime.Format("2006-01-02")
+		if _, exists := dailyMap[dateStr]; !exists {
+			dailyMap[dateStr] = &models.DailyItinerary{
+				Date:     dateStr,
+				TimeZone: planZone,
+				Items:    []models.TravelItem{},
 			}
+		}
 
-			if daily.StartTime == nil || (item.StartDatetime != nil && item.StartDatetime.Before(*daily.StartTime)) {
-				daily.StartTime = item.StartDatetime
-			}
+		daily := dailyMap[dateStr]
+		daily.Items = append(daily.Items, item)
 
-			if daily.EndTime == nil || (item.EndDatetime != nil && item.EndDatetime.After(*daily.EndTime)) {
-				daily.EndTime = item.EndDatetime
-			}
+		if item.Cost != nil {
+			daily.TotalCost += *item.Cost
+		}
+
+		if daily.StartTime == nil || item.StartDatetime.Before(*daily.StartTime) {
+			daily.StartTime = item.StartDatetime
+		}
+
+		if daily.EndTime == nil || (item.EndDatetime != nil && item.EndDatetime.After(*daily.EndTime)) {
+			daily.EndTime = item.EndDatetime
 		}
 	}
 
-	// 转换为数组
+	// 转换为数组，按日期排序
 	var result []models.DailyItinerary
 	for _, daily := range dailyMap {
 		result = append(result, *daily)
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Date < result[j].Date })
+	return result
+}
+
+// GetPlanSummary 获取计划摘要
+func GetPlanSummary(c *gin.Context) {
+	planID := c.Param("planId")
+
+	summary, err := loadPlanSummary(planID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
 
 	c.JSON(http.StatusOK, models.ApiResponse{
 		Success:   true,
-		Data:      result,
+		Data:      summary,
 		Timestamp: time.Now(),
 	})
 }
 
-// GetPlanSummary 获取计划摘要
-func GetPlanSummary(c *gin.Context) {
-	planID := c.Param("planId")
+// loadPlanSummary 统计元素数量、日期范围和按基准货币换算的预算
+func loadPlanSummary(planID string) (*models.PlanSummary, error) {
 	db := database.GetDB()
 
 	summary := models.PlanSummary{
@@ -872,8 +1106,7 @@ func GetPlanSummary(c *gin.Context) {
 	`, planID)
 
 	if err != nil {
-		c.Error(err)
-		return
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -900,7 +1133,13 @@ func GetPlanSummary(c *gin.Context) {
 		}
 	}
 
-	// 获取日期范围
+	// 计划的基准货币和时区
+	var planZone *string
+	if err := db.QueryRow("SELECT base_currency, time_zone FROM plans WHERE id = $1", planID).Scan(&summary.BaseCurrency, &planZone); err != nil {
+		return nil, err
+	}
+
+	// 获取日期范围，天数按计划时区的日历日计算，不受夏令时切换影响
 	var startDate, endDate sql.NullTime
 	db.QueryRow(`
 		SELECT MIN(start_datetime), MAX(end_datetime)
@@ -908,106 +1147,39 @@ func GetPlanSummary(c *gin.Context) {
 		WHERE plan_id = $1 AND start_datetime IS NOT NULL
 	`, planID).Scan(&startDate, &endDate)
 
+	loc := resolveLocation(planZone)
 	if startDate.Valid {
-		summary.StartDate = startDate.Time
+		summary.StartDate = startDate.Time.In(loc)
 	}
 	if endDate.Valid {
-		summary.EndDate = endDate.Time
+		summary.EndDate = endDate.Time.In(loc)
 		if startDate.Valid {
-			summary.Duration = int(endDate.Time.Sub(startDate.Time).Hours()/24) + 1
+			summary.Duration = calendarDaysBetween(summary.StartDate, summary.EndDate) + 1
 		}
 	}
 
-	// 获取预算信息
-	db.QueryRow(`
-		SELECT COALESCE(SUM(estimated_amount), 0)
-		FROM budget_items
-		WHERE plan_id = $1
-	`, planID).Scan(&summary.EstimatedCost)
-
-	c.JSON(http.StatusOK, models.ApiResponse{
-		Success:   true,
-		Data:      summary,
-		Timestamp: time.Now(),
-	})
-}
-
-// ExportPlanJSON 导出计划为JSON
-func ExportPlanJSON(c *gin.Context) {
-	planID := c.Param("planId")
-	db := database.GetDB()
-
-	// 获取计划信息
-	var plan models.Plan
-	err := db.QueryRow(`
-		SELECT id, user_id, name, description, destination, start_date, end_date,
-			budget, participants, status, visibility, created_at, updated_at
-		FROM plans WHERE id = $1
-	`, planID).Scan(&plan.ID, &plan.UserID, &plan.Name, &plan.Description,
-		&plan.Destination, &plan.StartDate, &plan.EndDate, &plan.Budget,
-		&plan.Participants, &plan.Status, &plan.Visibility,
-		&plan.CreatedAt, &plan.UpdatedAt)
-
+	// 获取预算信息，按支付日期汇率换算为计划基准货币
+	budgetItems, err := loadBudgetItems(planID, "", "")
 	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, models.ApiResponse{
-				Success:   false,
-				Message:   "计划不存在",
-				Timestamp: time.Now(),
-			})
-		} else {
-			c.Error(err)
-		}
-		return
+		return nil, err
 	}
 
-	// 获取旅游元素
-	rows, err := db.Query(`
-		SELECT id, item_type, name, description, latitude, longitude,
-			start_datetime, end_datetime, cost
-		FROM travel_items WHERE plan_id = $1
-		ORDER BY start_datetime
-	`, planID)
-
+	book, err := loadRateBook()
 	if err != nil {
-		c.Error(err)
-		return
+		return nil, err
 	}
-	defer rows.Close()
 
-	var items []map[string]interface{}
-	for rows.Next() {
-		var item models.TravelItem
-		err := rows.Scan(&item.ID, &item.ItemType, &item.Name, &item.Description,
-			&item.Latitude, &item.Longitude, &item.StartDatetime, &item.EndDatetime, &item.Cost)
-		if err != nil {
-			continue
+	converted, applied, missing := convertBudgetItems(budgetItems, summary.BaseCurrency, book)
+	for _, item := range converted {
+		if item.EstimatedAmount != nil {
+			summary.EstimatedCost += *item.EstimatedAmount
 		}
-
-		itemMap := map[string]interface{}{
-			"id":          item.ID,
-			"type":        item.ItemType,
-			"name":        item.Name,
-			"description": item.Description,
-			"latitude":    item.Latitude,
-			"longitude":   item.Longitude,
-			"start_time":  item.StartDatetime,
-			"end_time":    item.EndDatetime,
-			"cost":        item.Cost,
+		if item.ActualAmount != nil {
+			summary.ActualCost += *item.ActualAmount
 		}
-		items = append(items, itemMap)
-	}
-
-	// 构建导出数据
-	exportData := map[string]interface{}{
-		"plan":        plan,
-		"items":       items,
-		"exported_at": time.Now(),
 	}
+	summary.AppliedRates = applied
+	summary.MissingRates = missing
 
-	// 设置响应头
-	c.Header("Content-Type", "application/json")
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"plan-%s.json\"", planID))
-
-	c.JSON(http.StatusOK, exportData)
+	return &summary, nil
 }
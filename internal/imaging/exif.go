@@ -0,0 +1,310 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoExif 文件中没有EXIF信息
+var ErrNoExif = errors.New("没有EXIF信息")
+
+// Exif 从照片中提取的信息
+type Exif struct {
+	TakenAt     *time.Time
+	Latitude    *float64
+	Longitude   *float64
+	Orientation int
+}
+
+const (
+	tagOrientation        = 0x0112
+	tagExifIFD            = 0x8769
+	tagGPSIFD             = 0x8825
+	tagDateTimeOriginal   = 0x9003
+	tagOffsetTimeOriginal = 0x9011
+	tagGPSLatitudeRef     = 0x0001
+	tagGPSLatitude        = 0x0002
+	tagGPSLongitudeRef    = 0x0003
+	tagGPSLongitude       = 0x0004
+)
+
+// ReadExif 从JPEG或WebP文件中读取拍摄时间、GPS坐标和方向
+func ReadExif(r io.Reader) (*Exif, error) {
+	br := bufio.NewReader(r)
+	find := findExifSegment
+	if magic, err := br.Peek(4); err == nil && string(magic) == "RIFF" {
+		find = findWebPExifChunk
+	}
+
+	payload, err := find(br)
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(payload)
+}
+
+// findWebPExifChunk 遍历WebP的RIFF块，返回EXIF块中的TIFF数据
+func findWebPExifChunk(r *bufio.Reader) ([]byte, error) {
+	var header [12]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil || string(header[8:]) != "WEBP" {
+		return nil, ErrNoExif
+	}
+
+	for {
+		var chunk [8]byte
+		if _, err := io.ReadFull(r, chunk[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		// 块长度为奇数时后面有一个填充字节
+		size := int64(binary.LittleEndian.Uint32(chunk[4:]))
+		padded := size + size&1
+
+		if string(chunk[:4]) != "EXIF" {
+			if _, err := io.CopyN(io.Discard, r, padded); err != nil {
+				return nil, ErrNoExif
+			}
+			continue
+		}
+		if size > 1<<20 {
+			return nil, ErrNoExif
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, ErrNoExif
+		}
+		// 部分编码器会保留JPEG的 Exif 前缀
+		return bytes.TrimPrefix(payload, []byte("Exif\x00\x00")), nil
+	}
+}
+
+// findExifSegment 遍历JPEG段，返回APP1中的TIFF数据
+func findExifSegment(r *bufio.Reader) ([]byte, error) {
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return nil, ErrNoExif
+	}
+
+	for {
+		marker, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrNoExif
+		}
+		if marker != 0xFF {
+			return nil, ErrNoExif
+		}
+		kind, err := r.ReadByte()
+		if err != nil {
+			return nil, ErrNoExif
+		}
+		// 填充字节
+		if kind == 0xFF {
+			r.UnreadByte()
+			continue
+		}
+		// 图像数据开始或结束，之后不会再有EXIF
+		if kind == 0xDA || kind == 0xD9 {
+			return nil, ErrNoExif
+		}
+
+		var size [2]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return nil, ErrNoExif
+		}
+		length := int(binary.BigEndian.Uint16(size[:])) - 2
+		if length < 0 {
+			return nil, ErrNoExif
+		}
+		segment := make([]byte, length)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, ErrNoExif
+		}
+
+		if kind == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+// tiffReader 按TIFF字节序读取IFD
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	typ    uint16
+	count  uint32
+	offset uint32
+	raw    []byte
+}
+
+func parseTIFF(data []byte) (*Exif, error) {
+	if len(data) < 8 {
+		return nil, ErrNoExif
+	}
+
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("无效的TIFF字节序")
+	}
+	if t.order.Uint16(data[2:4]) != 42 {
+		return nil, fmt.Errorf("无效的TIFF标识")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(data[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	exif := &Exif{Orientation: 1}
+	if e, ok := ifd0[tagOrientation]; ok {
+		if v, ok := t.short(e); ok {
+			exif.Orientation = int(v)
+		}
+	}
+
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if sub, err := t.readIFD(t.long(e)); err == nil {
+			exif.TakenAt = t.takenAt(sub)
+		}
+	}
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		if gps, err := t.readIFD(t.long(e)); err == nil {
+			exif.Latitude = t.coordinate(gps, tagGPSLatitude, tagGPSLatitudeRef, "S")
+			exif.Longitude = t.coordinate(gps, tagGPSLongitude, tagGPSLongitudeRef, "W")
+		}
+	}
+
+	return exif, nil
+}
+
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]ifdEntry, error) {
+	if int(offset)+2 > len(t.data) {
+		return nil, fmt.Errorf("IFD偏移越界")
+	}
+	count := int(t.order.Uint16(t.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(t.data) {
+		return nil, fmt.Errorf("IFD长度越界")
+	}
+
+	entries := make(map[uint16]ifdEntry, count)
+	for i := 0; i < count; i++ {
+		b := t.data[start+i*12 : start+i*12+12]
+		e := ifdEntry{
+			typ:    t.order.Uint16(b[2:4]),
+			count:  t.order.Uint32(b[4:8]),
+			offset: t.order.Uint32(b[8:12]),
+		}
+
+		size := typeSize(e.typ) * int(e.count)
+		if size <= 4 {
+			e.raw = b[8 : 8+size]
+		} else if int(e.offset)+size <= len(t.data) {
+			e.raw = t.data[e.offset : int(e.offset)+size]
+		}
+		entries[t.order.Uint16(b[0:2])] = e
+	}
+	return entries, nil
+}
+
+func typeSize(typ uint16) int {
+	switch typ {
+	case 1, 2, 7: // BYTE, ASCII, UNDEFINED
+		return 1
+	case 3: // SHORT
+		return 2
+	case 4, 9: // LONG, SLONG
+		return 4
+	case 5, 10: // RATIONAL, SRATIONAL
+		return 8
+	}
+	return 0
+}
+
+func (t *tiffReader) short(e ifdEntry) (uint16, bool) {
+	if e.typ != 3 || len(e.raw) < 2 {
+		return 0, false
+	}
+	return t.order.Uint16(e.raw), true
+}
+
+func (t *tiffReader) long(e ifdEntry) uint32 {
+	if e.typ == 3 && len(e.raw) >= 2 {
+		return uint32(t.order.Uint16(e.raw))
+	}
+	return e.offset
+}
+
+func (t *tiffReader) ascii(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	return strings.TrimRight(string(e.raw), "\x00 ")
+}
+
+func (t *tiffReader) rationals(e ifdEntry) []float64 {
+	if e.typ != 5 || len(e.raw) < int(e.count)*8 {
+		return nil
+	}
+	values := make([]float64, e.count)
+	for i := range values {
+		num := t.order.Uint32(e.raw[i*8:])
+		den := t.order.Uint32(e.raw[i*8+4:])
+		if den == 0 {
+			return nil
+		}
+		values[i] = float64(num) / float64(den)
+	}
+	return values
+}
+
+// takenAt 解析拍摄时间，有 OffsetTimeOriginal 时使用该时区，否则按UTC处理
+func (t *tiffReader) takenAt(ifd map[uint16]ifdEntry) *time.Time {
+	e, ok := ifd[tagDateTimeOriginal]
+	if !ok {
+		return nil
+	}
+	value := t.ascii(e)
+
+	if o, ok := ifd[tagOffsetTimeOriginal]; ok {
+		if parsed, err := time.Parse("2006:01:02 15:04:05-07:00", value+t.ascii(o)); err == nil {
+			return &parsed
+		}
+	}
+	parsed, err := time.Parse("2006:01:02 15:04:05", value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// coordinate 将度分秒转换为十进制度数
+func (t *tiffReader) coordinate(ifd map[uint16]ifdEntry, valueTag, refTag uint16, negativeRef string) *float64 {
+	e, ok := ifd[valueTag]
+	if !ok {
+		return nil
+	}
+	dms := t.rationals(e)
+	if len(dms) != 3 {
+		return nil
+	}
+
+	value := dms[0] + dms[1]/60 + dms[2]/3600
+	if ref, ok := ifd[refTag]; ok && t.ascii(ref) == negativeRef {
+		value = -value
+	}
+	return &value
+}
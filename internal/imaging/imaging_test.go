@@ -0,0 +1,147 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildExifTIFF 构造EXIF的TIFF数据：方向6，拍摄时间，稻城亚丁附近的GPS坐标
+func buildExifTIFF() []byte {
+	le := binary.LittleEndian
+	tiff := make([]byte, 190)
+	copy(tiff, "II")
+	le.PutUint16(tiff[2:], 42)
+	le.PutUint32(tiff[4:], 8)
+
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		le.PutUint16(tiff[at:], tag)
+		le.PutUint16(tiff[at+2:], typ)
+		le.PutUint32(tiff[at+4:], count)
+		le.PutUint32(tiff[at+8:], value)
+	}
+	rational := func(at int, values ...uint32) {
+		for i, v := range values {
+			le.PutUint32(tiff[at+i*4:], v)
+		}
+	}
+
+	// IFD0
+	le.PutUint16(tiff[8:], 3)
+	entry(10, tagOrientation, 3, 1, 6)
+	entry(22, tagExifIFD, 4, 1, 50)
+	entry(34, tagGPSIFD, 4, 1, 88)
+
+	// Exif IFD
+	le.PutUint16(tiff[50:], 1)
+	entry(52, tagDateTimeOriginal, 2, 20, 68)
+	copy(tiff[68:], "2025:10:01 07:30:00\x00")
+
+	// GPS IFD：北纬 28°26'24"，东经 100°20'6"
+	le.PutUint16(tiff[88:], 4)
+	entry(90, tagGPSLatitudeRef, 2, 2, uint32('N'))
+	entry(102, tagGPSLatitude, 5, 3, 142)
+	entry(114, tagGPSLongitudeRef, 2, 2, uint32('E'))
+	entry(126, tagGPSLongitude, 5, 3, 166)
+	rational(142, 28, 1, 26, 1, 24, 1)
+	rational(166, 100, 1, 20, 1, 6, 1)
+	return tiff
+}
+
+// buildExifJPEG 构造带EXIF的最小JPEG
+func buildExifJPEG(t *testing.T) []byte {
+	tiff := buildExifTIFF()
+
+	var img bytes.Buffer
+	require.NoError(t, jpeg.Encode(&img, image.NewRGBA(image.Rect(0, 0, 4, 2)), nil))
+	data := img.Bytes()
+
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(app1)+2))
+	segment = append(segment, app1...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+func TestReadExif(t *testing.T) {
+	exif, err := ReadExif(bytes.NewReader(buildExifJPEG(t)))
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, exif.Orientation)
+	require.NotNil(t, exif.TakenAt)
+	assert.Equal(t, "2025-10-01 07:30:00", exif.TakenAt.Format("2006-01-02 15:04:05"))
+	require.NotNil(t, exif.Latitude)
+	require.NotNil(t, exif.Longitude)
+	assert.InDelta(t, 28.44, *exif.Latitude, 0.0001)
+	assert.InDelta(t, 100.335, *exif.Longitude, 0.0001)
+
+	var plain bytes.Buffer
+	require.NoError(t, jpeg.Encode(&plain, image.NewRGBA(image.Rect(0, 0, 2, 2)), nil))
+	_, err = ReadExif(&plain)
+	assert.Equal(t, ErrNoExif, err)
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	thumb := Thumbnail(src, 100, 1)
+	assert.Equal(t, image.Rect(0, 0, 100, 50), thumb.Bounds())
+	r, g, b, _ := thumb.At(10, 10).RGBA()
+	assert.Equal(t, []uint32{200, 100, 50}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	// 方向6需要顺时针旋转90°，宽高互换
+	rotated := Thumbnail(src, 100, 6)
+	assert.Equal(t, image.Rect(0, 0, 50, 100), rotated.Bounds())
+
+	small := Thumbnail(src, 1000, 1)
+	assert.Equal(t, src.Bounds(), small.Bounds())
+}
+
+func TestWebP(t *testing.T) {
+	data, err := os.ReadFile("testdata/gopher.webp")
+	require.NoError(t, err)
+
+	// 在图像块之后追加EXIF块并更新RIFF长度
+	tiff := buildExifTIFF()
+	chunk := append([]byte("EXIF\x00\x00\x00\x00"), tiff...)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(tiff)))
+	withExif := append(append([]byte{}, data...), chunk...)
+	binary.LittleEndian.PutUint32(withExif[4:], uint32(len(withExif)-8))
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(withExif))
+	require.NoError(t, err)
+	assert.Equal(t, "webp", format)
+
+	src, _, err := image.Decode(bytes.NewReader(withExif))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, cfg.Width, cfg.Height), src.Bounds())
+
+	thumb := Thumbnail(src, 32, 1)
+	assert.LessOrEqual(t, max(thumb.Bounds().Dx(), thumb.Bounds().Dy()), 32)
+	_, err = EncodeJPEG(thumb)
+	assert.NoError(t, err)
+
+	exif, err := ReadExif(bytes.NewReader(withExif))
+	require.NoError(t, err)
+	assert.Equal(t, 6, exif.Orientation)
+	require.NotNil(t, exif.Latitude)
+	assert.InDelta(t, 28.44, *exif.Latitude, 0.001)
+
+	_, err = ReadExif(bytes.NewReader(data))
+	assert.Equal(t, ErrNoExif, err)
+}
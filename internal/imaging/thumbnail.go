@@ -0,0 +1,102 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// 注册常见图片格式的解码器
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Thumbnail 按最长边 maxEdge 等比缩小图片（区域平均采样），并按EXIF方向摆正。
+// 原图不大于 maxEdge 时只做方向校正。
+func Thumbnail(src image.Image, maxEdge, orientation int) image.Image {
+	img := orient(src, orientation)
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return img
+	}
+
+	tw, th := maxEdge, maxEdge
+	if w >= h {
+		th = max(1, h*maxEdge/w)
+	} else {
+		tw = max(1, w*maxEdge/h)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, tw, th))
+	for ty := 0; ty < th; ty++ {
+		y0 := b.Min.Y + ty*h/th
+		y1 := max(y0+1, b.Min.Y+(ty+1)*h/th)
+		for tx := 0; tx < tw; tx++ {
+			x0 := b.Min.X + tx*w/tw
+			x1 := max(x0+1, b.Min.X+(tx+1)*w/tw)
+
+			var r, g, bl, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := img.At(x, y).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(tx, ty, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// EncodeJPEG 将图片编码为JPEG
+func EncodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// orient 处理常见的EXIF方向：3 旋转180°，6 顺时针90°，8 逆时针90°
+func orient(src image.Image, orientation int) image.Image {
+	if orientation != 3 && orientation != 6 && orientation != 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var dst *image.RGBA
+	if orientation == 3 {
+		dst = image.NewRGBA(image.Rect(0, 0, w, h))
+	} else {
+		dst = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := src.At(b.Min.X+x, b.Min.Y+y)
+			switch orientation {
+			case 3:
+				dst.Set(w-1-x, h-1-y, c)
+			case 6:
+				dst.Set(h-1-y, x, c)
+			case 8:
+				dst.Set(y, w-1-x, c)
+			}
+		}
+	}
+	return dst
+}
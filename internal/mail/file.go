@@ -0,0 +1,43 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// File 把邮件保存为 .eml 文件，用于本地开发和测试
+type File struct {
+	dir  string
+	from string
+}
+
+// NewFile 创建文件发送后端，dir 为邮件保存目录
+func NewFile(dir, from string) (*File, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("未配置邮件保存目录")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("创建邮件目录失败: %v", err)
+	}
+	if from == "" {
+		from = "planner@localhost"
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+func (f *File) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	name := now.Format("20060102-150405") + "-" + uuid.New().String()[:8] + ".eml"
+	path := filepath.Join(f.dir, name)
+	if err := os.WriteFile(path, encodeMessage(f.from, msg, now), 0o644); err != nil {
+		return err
+	}
+	log.Printf("📧 邮件已保存: %s (%s)", path, msg.Subject)
+	return nil
+}
@@ -0,0 +1,83 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer 邮件发送后端
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Options 邮件配置
+type Options struct {
+	// Driver 发送驱动：smtp、file（写入目录，便于本地测试）或 log（打印到日志）
+	Driver string
+	From   string
+
+	// SMTP
+	SMTPHost     string
+	SMTPPort     int64
+	SMTPUser     string
+	SMTPPassword string
+
+	// 本地文件
+	FilePath string
+}
+
+var current Mailer = &Log{}
+
+// Init 根据配置初始化邮件发送后端
+func Init(opts Options) error {
+	var err error
+
+	switch opts.Driver {
+	case "", "log":
+		current = &Log{}
+	case "file":
+		current, err = NewFile(opts.FilePath, opts.From)
+	case "smtp":
+		current, err = NewSMTP(SMTPConfig{
+			Host:     opts.SMTPHost,
+			Port:     opts.SMTPPort,
+			Username: opts.SMTPUser,
+			Password: opts.SMTPPassword,
+			From:     opts.From,
+		})
+	default:
+		return fmt.Errorf("未知的邮件驱动: %s", opts.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ 邮件发送初始化成功 (%s)", opts.Driver)
+	return nil
+}
+
+// Get 获取当前邮件发送后端
+func Get() Mailer {
+	return current
+}
+
+// Set 替换当前邮件发送后端（用于测试）
+func Set(m Mailer) {
+	current = m
+}
+
+// Log 把邮件内容打印到日志，不实际发送
+type Log struct{}
+
+func (Log) Send(_ context.Context, msg Message) error {
+	log.Printf("📧 邮件 -> %s\n主题: %s\n\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
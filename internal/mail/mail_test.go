@@ -0,0 +1,152 @@
+package mail
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	data := TemplateData{Username: "阿明", Link: "https://example.com/reset-password?token=abc", ValidFor: time.Hour}
+
+	zh, err := Render(TemplatePasswordReset, "zh", "aming@example.com", data)
+	require.NoError(t, err)
+	assert.Equal(t, "aming@example.com", zh.To)
+	assert.Equal(t, "重置你的密码", zh.Subject)
+	assert.Contains(t, zh.Body, "阿明，你好")
+	assert.Contains(t, zh.Body, "1 小时内")
+	assert.Contains(t, zh.Body, data.Link)
+
+	data.ValidFor = 48 * time.Hour
+	en, err := Render(TemplateVerifyEmail, "en", "aming@example.com", data)
+	require.NoError(t, err)
+	assert.Equal(t, "Verify your email address", en.Subject)
+	assert.Contains(t, en.Body, "within 48 hours")
+
+	// 不支持的语言回退到中文
+	fallback, err := Render(TemplateVerifyEmail, "fr", "aming@example.com", data)
+	require.NoError(t, err)
+	assert.Equal(t, "请验证你的邮箱", fallback.Subject)
+
+	_, err = Render("unknown", "zh", "aming@example.com", data)
+	assert.Error(t, err)
+}
+
+func TestLanguage(t *testing.T) {
+	assert.Equal(t, "en", Language("en-US,en;q=0.9,zh-CN;q=0.8"))
+	assert.Equal(t, "zh", Language("zh-CN,zh;q=0.9,en;q=0.8"))
+	assert.Equal(t, "zh", Language(""))
+}
+
+func TestValidity(t *testing.T) {
+	assert.Equal(t, "30 分钟", validity("zh", 30*time.Minute))
+	assert.Equal(t, "90 minutes", validity("en", 90*time.Minute))
+	assert.Equal(t, "1 hour", validity("en", time.Hour))
+	assert.Equal(t, "24 小时", validity("zh", 24*time.Hour))
+}
+
+func TestEncodeMessage(t *testing.T) {
+	raw := string(encodeMessage("Planner <noreply@example.com>", Message{
+		To:      "aming@example.com",
+		Subject: "重置你的密码",
+		Body:    strings.Repeat("正文", 40),
+	}, time.Date(2024, 7, 1, 8, 0, 0, 0, time.UTC)))
+
+	head, body, ok := strings.Cut(raw, "\r\n\r\n")
+	require.True(t, ok)
+	assert.Contains(t, head, "From: Planner <noreply@example.com>\r\n")
+	assert.Contains(t, head, "Subject: =?UTF-8?b?")
+	assert.Contains(t, head, "Date: Mon, 01 Jul 2024 08:00:00 +0000")
+	assert.Contains(t, head, "Content-Transfer-Encoding: base64")
+
+	for _, line := range strings.Split(strings.TrimSpace(body), "\r\n") {
+		assert.LessOrEqual(t, len(line), 76)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("正文", 40), string(decoded))
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFile(dir, "")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Send(context.Background(), Message{To: "aming@example.com", Subject: "hi", Body: "hello"}))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "From: planner@localhost")
+	assert.Contains(t, string(content), "To: aming@example.com")
+}
+
+func TestSMTPSend(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	received := make(chan []string, 1)
+	go serveFakeSMTP(ln, received)
+
+	port := int64(ln.Addr().(*net.TCPAddr).Port)
+	m, err := NewSMTP(SMTPConfig{Host: "127.0.0.1", Port: port, From: "Planner <noreply@example.com>"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, m.Send(ctx, Message{To: "aming@example.com", Subject: "hi", Body: "hello"}))
+
+	commands := <-received
+	assert.Contains(t, commands, "MAIL FROM:<noreply@example.com>")
+	assert.Contains(t, commands, "RCPT TO:<aming@example.com>")
+
+	_, err = NewSMTP(SMTPConfig{Port: 587})
+	assert.Error(t, err)
+}
+
+// serveFakeSMTP 处理一次不带 TLS 和认证的 SMTP 会话，返回收到的命令
+func serveFakeSMTP(ln net.Listener, received chan<- []string) {
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	var commands []string
+	_ = tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			break
+		}
+		commands = append(commands, line)
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			_ = tp.PrintfLine("250 fake")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			_, _ = tp.ReadDotBytes()
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			received <- commands
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+	received <- commands
+}
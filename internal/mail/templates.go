@@ -0,0 +1,137 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// 邮件模板名称
+const (
+	TemplateVerifyEmail   = "verify_email"
+	TemplatePasswordReset = "password_reset"
+)
+
+// TemplateData 模板数据，ValidFor 为链接有效期
+type TemplateData struct {
+	Username string
+	Link     string
+	ValidFor time.Duration
+}
+
+type messageTemplate struct {
+	subject string
+	body    string
+}
+
+// templates 按模板名称和语言（zh、en）组织
+var templates = map[string]map[string]messageTemplate{
+	TemplateVerifyEmail: {
+		"zh": {
+			subject: "请验证你的邮箱",
+			body: `{{.Username}}，你好：
+
+感谢注册旅行计划。请在 {{validity .ValidFor}}内打开下面的链接完成邮箱验证：
+
+{{.Link}}
+
+如果这不是你本人的操作，请忽略这封邮件。
+`,
+		},
+		"en": {
+			subject: "Verify your email address",
+			body: `Hi {{.Username}},
+
+Thanks for signing up. Please confirm your email address within {{validity .ValidFor}} by opening the link below:
+
+{{.Link}}
+
+If you didn't create an account, you can safely ignore this email.
+`,
+		},
+	},
+	TemplatePasswordReset: {
+		"zh": {
+			subject: "重置你的密码",
+			body: `{{.Username}}，你好：
+
+我们收到了重置密码的请求。请在 {{validity .ValidFor}}内打开下面的链接设置新密码，链接只能使用一次：
+
+{{.Link}}
+
+如果你没有申请重置密码，请忽略这封邮件，你的密码不会改变。
+`,
+		},
+		"en": {
+			subject: "Reset your password",
+			body: `Hi {{.Username}},
+
+We received a request to reset your password. Open the link below within {{validity .ValidFor}} to choose a new one. The link can only be used once:
+
+{{.Link}}
+
+If you didn't request a password reset, you can ignore this email and your password will stay the same.
+`,
+		},
+	},
+}
+
+// Render 渲染邮件模板，不支持的语言使用中文
+func Render(name, lang, to string, data TemplateData) (Message, error) {
+	byLang, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("未知的邮件模板: %s", name)
+	}
+	tpl, ok := byLang[lang]
+	if !ok {
+		lang = "zh"
+		tpl = byLang[lang]
+	}
+
+	t, err := template.New(name).Funcs(template.FuncMap{
+		"validity": func(d time.Duration) string { return validity(lang, d) },
+	}).Parse(tpl.body)
+	if err != nil {
+		return Message{}, err
+	}
+	var body bytes.Buffer
+	if err := t.Execute(&body, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{To: to, Subject: tpl.subject, Body: body.String()}, nil
+}
+
+// Language 根据 Accept-Language 选择邮件语言：英语优先时用英文，否则用中文
+func Language(acceptLanguage string) string {
+	first := strings.TrimSpace(strings.SplitN(acceptLanguage, ",", 2)[0])
+	if strings.HasPrefix(strings.ToLower(first), "en") {
+		return "en"
+	}
+	return "zh"
+}
+
+// validity 有效期的本地化描述，整小时按小时显示，否则按分钟
+func validity(lang string, d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		hours := int(d / time.Hour)
+		if lang == "en" {
+			if hours == 1 {
+				return "1 hour"
+			}
+			return fmt.Sprintf("%d hours", hours)
+		}
+		return fmt.Sprintf("%d 小时", hours)
+	}
+
+	minutes := int(d / time.Minute)
+	if lang == "en" {
+		if minutes == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", minutes)
+	}
+	return fmt.Sprintf("%d 分钟", minutes)
+}
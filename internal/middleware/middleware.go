@@ -2,18 +2,17 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"strings"
 	"time"
 
+	"planner/internal/auth"
 	"planner/internal/config"
 	"planner/internal/database"
 	"planner/internal/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -125,15 +124,7 @@ func Auth() gin.HandlerFunc {
 		tokenString = strings.TrimSpace(tokenString)
 
 		// 解析JWT
-		cfg := config.Get()
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// 验证签名方法
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("意外的签名方法: %v", token.Header["alg"])
-			}
-			return []byte(cfg.JWTSecret), nil
-		})
-
+		claims, err := auth.ParseAccessToken(tokenString)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Success:   false,
@@ -144,7 +135,19 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		if !token.Valid {
+		// 检查令牌是否已登出或被撤销
+		revoked, err := auth.GetDenylist().Contains(c.Request.Context(), claims.ID)
+		if err != nil {
+			log.Printf("⚠️ 查询令牌黑名单失败: %v", err)
+			c.JSON(http.StatusServiceUnavailable, models.ApiResponse{
+				Success:   false,
+				Message:   "暂时无法验证令牌",
+				Timestamp: time.Now(),
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Success:   false,
 				Message:   "令牌已失效",
@@ -154,57 +157,62 @@ func Auth() gin.HandlerFunc {
 			return
 		}
 
-		// 提取用户信息
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
+		// 检查token是否即将过期（提前5分钟）
+		if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) < 5*time.Minute {
+			// 设置标记，提示客户端需要刷新token
+			c.Header("X-Token-Refresh-Required", "true")
+		}
+
+		// 验证用户是否仍然存在且活跃，令牌版本不一致说明已被撤销全部会话
+		db := database.GetDB()
+		var isActive, sessionActive bool
+		var tokenVersion int
+		err = db.QueryRow(`
+			SELECT u.is_active, u.token_version, COALESCE(s.revoked_at IS NULL, FALSE)
+			FROM users u
+			LEFT JOIN user_sessions s ON s.id = $2 AND s.user_id = u.id
+			WHERE u.id = $1
+		`, claims.UserID, claims.SessionID).Scan(&isActive, &tokenVersion, &sessionActive)
+		if err != nil || !isActive {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Success:   false,
-				Message:   "无效的令牌格式",
+				Message:   "用户账户无效或已被禁用",
 				Timestamp: time.Now(),
 			})
 			c.Abort()
 			return
 		}
-
-		// 验证必要的claims
-		userID, ok := claims["user_id"].(string)
-		if !ok || userID == "" {
+		if tokenVersion != claims.Version {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Success:   false,
-				Message:   "令牌缺少用户信息",
+				Message:   "令牌已失效",
 				Timestamp: time.Now(),
 			})
 			c.Abort()
 			return
 		}
-
-		// 检查token是否即将过期（提前5分钟）
-		if exp, ok := claims["exp"].(float64); ok {
-			expirationTime := time.Unix(int64(exp), 0)
-			if time.Until(expirationTime) < 5*time.Minute {
-				// 设置标记，提示客户端需要刷新token
-				c.Header("X-Token-Refresh-Required", "true")
-			}
-		}
-
-		// 可选：验证用户是否仍然存在且活跃
-		db := database.GetDB()
-		var isActive bool
-		err = db.QueryRow("SELECT is_active FROM users WHERE id = $1", userID).Scan(&isActive)
-		if err != nil || !isActive {
+		if !sessionActive {
 			c.JSON(http.StatusUnauthorized, models.ApiResponse{
 				Success:   false,
-				Message:   "用户账户无效或已被禁用",
+				Message:   "会话已被撤销，请重新登录",
 				Timestamp: time.Now(),
 			})
 			c.Abort()
 			return
 		}
 
+		// 更新会话最后活跃时间，每分钟最多写一次
+		_, _ = db.Exec(`
+			UPDATE user_sessions SET last_seen_at = NOW()
+			WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+		`, claims.SessionID)
+
 		// 设置用户信息到上下文
-		c.Set("user_id", userID)
-		c.Set("username", claims["username"])
-		c.Set("email", claims["email"])
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_claims", claims)
 		c.Next()
 	}
 }
@@ -225,8 +233,13 @@ func Admin() gin.HandlerFunc {
 
 		// 检查管理员权限
 		db := database.GetDB()
-		var isAdmin bool
-		err := db.QueryRow("SELECT is_admin FROM users WHERE id = $1", userID).Scan(&isAdmin)
+		var isAdmin, totpEnabled, mfaVerified bool
+		err := db.QueryRow(`
+			SELECT u.is_admin, u.totp_enabled_at IS NOT NULL, COALESCE(s.mfa_verified, false)
+			FROM users u
+			LEFT JOIN user_sessions s ON s.id = $2 AND s.user_id = u.id
+			WHERE u.id = $1
+		`, userID, c.GetString("session_id")).Scan(&isAdmin, &totpEnabled, &mfaVerified)
 		if err != nil || !isAdmin {
 			c.JSON(http.StatusForbidden, models.ApiResponse{
 				Success:   false,
@@ -237,6 +250,57 @@ func Admin() gin.HandlerFunc {
 			return
 		}
 
+		// 开启“管理员必须启用两步验证”后，当前会话必须是通过两步验证登录的
+		required, _, err := database.GetSetting(models.SettingRequireAdminMFA)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if required == "true" && !mfaVerified {
+			message := "请使用两步验证重新登录"
+			if !totpEnabled {
+				message = "管理员账户需要先启用两步验证"
+			}
+			c.JSON(http.StatusForbidden, models.ApiResponse{
+				Success:   false,
+				Message:   message,
+				Timestamp: time.Now(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// VerifiedEmail 开启 REQUIRE_EMAIL_VERIFICATION 时要求用户已验证邮箱，需在 Auth 之后使用
+func VerifiedEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !config.Get().RequireEmailVerification {
+			c.Next()
+			return
+		}
+
+		var verified bool
+		err := database.GetDB().QueryRow("SELECT email_verified_at IS NOT NULL FROM users WHERE id = $1",
+			c.GetString("user_id")).Scan(&verified)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, models.ApiResponse{
+				Success:   false,
+				Message:   "请先验证邮箱",
+				Timestamp: time.Now(),
+			})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
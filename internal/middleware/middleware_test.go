@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"planner/internal/auth"
+	"planner/internal/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthRejectsRevokedToken(t *testing.T) {
+	config.Load()
+	gin.SetMode(gin.TestMode)
+
+	denylist := auth.NewMemoryDenylist()
+	auth.SetDenylist(denylist)
+	defer auth.SetDenylist(nil)
+
+	token, claims, err := auth.IssueAccessToken("user-1", "aming", "aming@example.com", 0, "session-1")
+	require.NoError(t, err)
+	require.NoError(t, auth.RevokeAccessToken(context.Background(), claims))
+
+	router := gin.New()
+	router.GET("/protected", Auth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "令牌已失效")
+}
+
+func TestAuthRejectsInvalidToken(t *testing.T) {
+	config.Load()
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/protected", Auth(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, header := range []string{"", "Bearer not-a-token"} {
+		req, _ := http.NewRequest("GET", "/protected", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, header)
+	}
+}
+
+func TestVerifiedEmailDisabled(t *testing.T) {
+	config.Load()
+	config.Get().RequireEmailVerification = false
+	gin.SetMode(gin.TestMode)
+
+	// 未开启时不查询数据库，直接放行
+	router := gin.New()
+	router.POST("/plans", VerifiedEmail(), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	req, _ := http.NewRequest("POST", "/plans", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
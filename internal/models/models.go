@@ -19,22 +19,110 @@ type ApiResponse struct {
 // ==================== 用户相关 ====================
 
 type User struct {
-	ID          string     `json:"id" db:"id"`
-	Username    string     `json:"username" db:"username"`
-	Email       string     `json:"email" db:"email"`
-	Password    string     `json:"-" db:"password_hash"`
-	AvatarURL   *string    `json:"avatar_url,omitempty" db:"avatar_url"`
-	Bio         *string    `json:"bio,omitempty" db:"bio"`
-	IsActive    bool       `json:"is_active" db:"is_active"`
-	IsAdmin     bool       `json:"is_admin" db:"is_admin"`
-	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	ID              string     `json:"id" db:"id"`
+	Username        string     `json:"username" db:"username"`
+	Email           string     `json:"email" db:"email"`
+	Password        string     `json:"-" db:"password_hash"`
+	AvatarURL       *string    `json:"avatar_url,omitempty" db:"avatar_url"`
+	Bio             *string    `json:"bio,omitempty" db:"bio"`
+	IsActive        bool       `json:"is_active" db:"is_active"`
+	IsAdmin         bool       `json:"is_admin" db:"is_admin"`
+	LastLoginAt     *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at" db:"totp_enabled_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+	// TokenVersion 撤销全部会话时递增，访问令牌中的版本不一致即失效
+	TokenVersion int `json:"-" db:"token_version"`
 }
 
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
+	Username    string `json:"username" binding:"required"`
+	Password    string `json:"password" binding:"required"`
+	DeviceLabel string `json:"device_label" binding:"max=100"`
+}
+
+// AuthTokens 登录和刷新返回的令牌，ExpiresIn 为访问令牌剩余秒数
+type AuthTokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// MFALoginRequest 两步登录第二步：用挑战令牌和验证码（或恢复码）换取访问令牌
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// MFACodeRequest 需要当前验证码（或恢复码）确认的操作
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableMFARequest 关闭两步验证需要同时提供密码和验证码
+type DisableMFARequest struct {
 	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TOTPSetup 启用两步验证时返回的密钥，ProvisioningURI 用于生成二维码
+type TOTPSetup struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// SettingRequireAdminMFA 系统设置：管理员必须启用两步验证
+const SettingRequireAdminMFA = "require_admin_2fa"
+
+// SecuritySettings 管理员可修改的安全设置
+type SecuritySettings struct {
+	RequireAdminMFA bool `json:"require_admin_2fa"`
+}
+
+// EmailTokenRequest 邮箱验证请求
+type EmailTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordRequest 申请重置密码
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 使用邮件中的令牌设置新密码
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// OIDCCompleteRequest 单点登录回调后用一次性登录码换取令牌
+type OIDCCompleteRequest struct {
+	Code        string `json:"code" binding:"required"`
+	DeviceLabel string `json:"device_label"`
+}
+
+// OIDCProvider 可用的单点登录身份提供方
+type OIDCProvider struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	LoginURL    string `json:"login_url"`
+}
+
+// UserSession 登录会话（设备），Current 表示发起请求的会话
+type UserSession struct {
+	ID          string    `json:"id" db:"id"`
+	DeviceLabel string    `json:"device_label" db:"device_label"`
+	UserAgent   *string   `json:"user_agent" db:"user_agent"`
+	IPAddress   *string   `json:"ip_address" db:"ip_address"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt  time.Time `json:"last_seen_at" db:"last_seen_at"`
+	Current     bool      `json:"current"`
 }
 
 type RegisterRequest struct {
@@ -54,6 +142,8 @@ type Plan struct {
 	StartDate    *string   `json:"start_date" db:"start_date"`
 	EndDate      *string   `json:"end_date" db:"end_date"`
 	Budget       float64   `json:"budget" db:"budget"`
+	BaseCurrency string    `json:"base_currency" db:"base_currency"`
+	TimeZone     *string   `json:"time_zone" db:"time_zone"`
 	Participants int       `json:"participants" db:"participants"`
 	Status       string    `json:"status" db:"status"`
 	Visibility   string    `json:"visibility" db:"visibility"`
@@ -76,6 +166,25 @@ const (
 	ItemTypeOther         ItemType = "other"
 )
 
+// 元素状态：planned → booked → in_progress → done，可随时 skipped / cancelled
+const (
+	ItemStatusPlanned    = "planned"
+	ItemStatusBooked     = "booked"
+	ItemStatusInProgress = "in_progress"
+	ItemStatusDone       = "done"
+	ItemStatusSkipped    = "skipped"
+	ItemStatusCancelled  = "cancelled"
+)
+
+// 预订状态
+const (
+	BookingStatusNotRequired = "not_required"
+	BookingStatusPending     = "pending"
+	BookingStatusConfirmed   = "confirmed"
+	BookingStatusFailed      = "failed"
+	BookingStatusCancelled   = "cancelled"
+)
+
 type TravelItem struct {
 	ID            string      `json:"id" db:"id"`
 	PlanID        string      `json:"plan_id" db:"plan_id"`
@@ -88,6 +197,7 @@ type TravelItem struct {
 	Address       *string     `json:"address,omitempty" db:"address"`
 	StartDatetime *time.Time  `json:"start_datetime,omitempty" db:"start_datetime"`
 	EndDatetime   *time.Time  `json:"end_datetime,omitempty" db:"end_datetime"`
+	TimeZone      *string     `json:"time_zone,omitempty" db:"time_zone"`
 	DurationHours *float64    `json:"duration_hours,omitempty" db:"duration_hours"`
 	Cost          *float64    `json:"cost,omitempty" db:"cost"`
 	Priority      int         `json:"priority" db:"priority"`
@@ -135,35 +245,81 @@ type TransportDetails struct {
 	ArrivalLocation   *string    `json:"arrival_location,omitempty" db:"arrival_location"`
 	DepartureTime     *time.Time `json:"departure_time,omitempty" db:"departure_time"`
 	ArrivalTime       *time.Time `json:"arrival_time,omitempty" db:"arrival_time"`
+	DepartureTimeZone *string    `json:"departure_time_zone,omitempty" db:"departure_time_zone"`
+	ArrivalTimeZone   *string    `json:"arrival_time_zone,omitempty" db:"arrival_time_zone"`
 	DistanceKm        *float64   `json:"distance_km,omitempty" db:"distance_km"`
 	BookingReference  *string    `json:"booking_reference,omitempty" db:"booking_reference"`
 	CarrierName       *string    `json:"carrier_name,omitempty" db:"carrier_name"`
 	VehicleNumber     *string    `json:"vehicle_number,omitempty" db:"vehicle_number"`
 	SeatNumber        *string    `json:"seat_number,omitempty" db:"seat_number"`
 	RoutePolyline     *string    `json:"route_polyline,omitempty" db:"route_polyline"`
+	ElevationProfile  JSONArray  `json:"elevation_profile,omitempty" db:"elevation_profile"`
+	RoadConditions    *string    `json:"road_conditions,omitempty" db:"road_conditions"`
+	FuelStations      JSONArray  `json:"fuel_stations,omitempty" db:"fuel_stations"`
+	RestStops         JSONArray  `json:"rest_stops,omitempty" db:"rest_stops"`
 	EstimatedFuelCost *float64   `json:"estimated_fuel_cost,omitempty" db:"estimated_fuel_cost"`
 	TollCost          *float64   `json:"toll_cost,omitempty" db:"toll_cost"`
 	DepartureTerminal *string    `json:"departure_terminal,omitempty" db:"departure_terminal"`
 	ArrivalTerminal   *string    `json:"arrival_terminal,omitempty" db:"arrival_terminal"`
+	TransferInfo      JSONB      `json:"transfer_info,omitempty" db:"transfer_info"`
 }
 
 type AttractionDetails struct {
-	ItemID                 string   `json:"item_id" db:"item_id"`
-	AttractionType         *string  `json:"attraction_type,omitempty" db:"attraction_type"`
-	TicketPrice            *float64 `json:"ticket_price,omitempty" db:"ticket_price"`
-	TicketType             *string  `json:"ticket_type,omitempty" db:"ticket_type"`
-	AdvanceBookingRequired bool     `json:"advance_booking_required" db:"advance_booking_required"`
-	BestVisitTime          *string  `json:"best_visit_time,omitempty" db:"best_visit_time"`
-	RecommendedDuration    *float64 `json:"recommended_duration,omitempty" db:"recommended_duration"`
-	DifficultyLevel        *int     `json:"difficulty_level,omitempty" db:"difficulty_level"`
-	PhotographyTips        *string  `json:"photography_tips,omitempty" db:"photography_tips"`
-	SunriseTime            *string  `json:"sunrise_time,omitempty" db:"sunrise_time"`
-	SunsetTime             *string  `json:"sunset_time,omitempty" db:"sunset_time"`
-	AccessibilityInfo      *string  `json:"accessibility_info,omitempty" db:"accessibility_info"`
+	ItemID                 string        `json:"item_id" db:"item_id"`
+	AttractionType         *string       `json:"attraction_type,omitempty" db:"attraction_type"`
+	OpeningHours           *OpeningHours `json:"opening_hours,omitempty" db:"opening_hours"`
+	TicketPrice            *float64      `json:"ticket_price,omitempty" db:"ticket_price"`
+	TicketType             *string       `json:"ticket_type,omitempty" db:"ticket_type"`
+	AdvanceBookingRequired bool          `json:"advance_booking_required" db:"advance_booking_required"`
+	BestVisitTime          *string       `json:"best_visit_time,omitempty" db:"best_visit_time"`
+	RecommendedDuration    *float64      `json:"recommended_duration,omitempty" db:"recommended_duration"`
+	DifficultyLevel        *int          `json:"difficulty_level,omitempty" db:"difficulty_level"`
+	PhotographyTips        *string       `json:"photography_tips,omitempty" db:"photography_tips"`
+	BestPhotoSpots         JSONArray     `json:"best_photo_spots,omitempty" db:"best_photo_spots"`
+	SunriseTime            *string       `json:"sunrise_time,omitempty" db:"sunrise_time"`
+	SunsetTime             *string       `json:"sunset_time,omitempty" db:"sunset_time"`
+	Facilities             JSONArray     `json:"facilities,omitempty" db:"facilities"`
+	AccessibilityInfo      *string       `json:"accessibility_info,omitempty" db:"accessibility_info"`
+}
+
+// OpeningHours 景点开放时间：每周时间表、季节性时间表和闭园日期
+type OpeningHours struct {
+	Weekly      []DailyHours    `json:"weekly,omitempty"`
+	Seasonal    []SeasonalHours `json:"seasonal,omitempty"`
+	ClosedDates []string        `json:"closed_dates,omitempty"` // YYYY-MM-DD
+}
+
+// DailyHours 某个星期几的开放时段，weekday 0 表示星期日
+type DailyHours struct {
+	Weekday int         `json:"weekday"`
+	Ranges  []TimeRange `json:"ranges"`
+}
+
+// SeasonalHours 季节性时间表，日期格式 MM-DD，允许跨年（如 11-01 至 03-31）
+type SeasonalHours struct {
+	Name      string       `json:"name,omitempty"`
+	StartDate string       `json:"start_date"`
+	EndDate   string       `json:"end_date"`
+	Weekly    []DailyHours `json:"weekly"`
+}
+
+// TimeRange 开放时段，时间格式 HH:MM
+type TimeRange struct {
+	Open  string `json:"open"`
+	Close string `json:"close"`
 }
 
 // ==================== 关联和标注 ====================
 
+// 元素关联类型。must_precede/blocks 表示源元素须排在目标元素之前，depends_on/follows 则相反
+const (
+	RelationMustPrecede = "must_precede"
+	RelationBlocks      = "blocks"
+	RelationDependsOn   = "depends_on"
+	RelationFollows     = "follows"
+	RelationRelatedTo   = "related_to"
+)
+
 type ItemRelation struct {
 	ID                 string    `json:"id" db:"id"`
 	SourceItemID       string    `json:"source_item_id" db:"source_item_id"`
@@ -174,19 +330,53 @@ type ItemRelation struct {
 }
 
 type ItemAttachment struct {
-	ID          string    `json:"id" db:"id"`
-	ItemID      string    `json:"item_id" db:"item_id"`
-	FileType    string    `json:"file_type" db:"file_type"`
-	FileURL     string    `json:"file_url" db:"file_url"`
-	FileName    *string   `json:"file_name,omitempty" db:"file_name"`
-	FileSize    *int      `json:"file_size,omitempty" db:"file_size"`
-	MimeType    *string   `json:"mime_type,omitempty" db:"mime_type"`
-	Title       *string   `json:"title,omitempty" db:"title"`
-	Description *string   `json:"description,omitempty" db:"description"`
-	IsPrimary   bool      `json:"is_primary" db:"is_primary"`
-	OrderIndex  *int      `json:"order_index,omitempty" db:"order_index"`
-	UploadedBy  *string   `json:"uploaded_by,omitempty" db:"uploaded_by"`
-	UploadedAt  time.Time `json:"uploaded_at" db:"uploaded_at"`
+	ID               string     `json:"id" db:"id"`
+	ItemID           string     `json:"item_id" db:"item_id"`
+	FileType         string     `json:"file_type" db:"file_type"`
+	FileURL          string     `json:"file_url" db:"file_url"`
+	FileName         *string    `json:"file_name,omitempty" db:"file_name"`
+	FileSize         *int       `json:"file_size,omitempty" db:"file_size"`
+	MimeType         *string    `json:"mime_type,omitempty" db:"mime_type"`
+	Checksum         *string    `json:"checksum,omitempty" db:"checksum"`
+	StorageKey       *string    `json:"-" db:"storage_key"`
+	Thumbnails       JSONB      `json:"thumbnails,omitempty" db:"thumbnails"`
+	ProcessingStatus *string    `json:"processing_status,omitempty" db:"processing_status"`
+	TakenAt          *time.Time `json:"taken_at,omitempty" db:"taken_at"`
+	GPSLatitude      *float64   `json:"gps_latitude,omitempty" db:"gps_latitude"`
+	GPSLongitude     *float64   `json:"gps_longitude,omitempty" db:"gps_longitude"`
+	Title            *string    `json:"title,omitempty" db:"title"`
+	Description      *string    `json:"description,omitempty" db:"description"`
+	IsPrimary        bool       `json:"is_primary" db:"is_primary"`
+	OrderIndex       *int       `json:"order_index,omitempty" db:"order_index"`
+	UploadedBy       *string    `json:"uploaded_by,omitempty" db:"uploaded_by"`
+	UploadedAt       time.Time  `json:"uploaded_at" db:"uploaded_at"`
+	// LocationSuggestion 照片位置与元素位置不符时的建议操作：set_item_location 或 add_marker
+	LocationSuggestion string `json:"location_suggestion,omitempty" db:"-"`
+}
+
+// 附件处理状态
+const (
+	AttachmentProcessingPending = "pending"
+	AttachmentProcessingDone    = "done"
+	AttachmentProcessingFailed  = "failed"
+)
+
+// 照片位置建议操作
+const (
+	PhotoLocationSetItem   = "set_item_location"
+	PhotoLocationAddMarker = "add_marker"
+)
+
+// ItemStatusHistory 元素状态变更记录，Field 为 status 或 booking_status
+type ItemStatusHistory struct {
+	ID        string    `json:"id" db:"id"`
+	ItemID    string    `json:"item_id" db:"item_id"`
+	Field     string    `json:"field" db:"field"`
+	From      *string   `json:"from,omitempty" db:"from_status"`
+	To        string    `json:"to" db:"to_status"`
+	Note      *string   `json:"note,omitempty" db:"note"`
+	ChangedBy *string   `json:"changed_by,omitempty" db:"changed_by"`
+	ChangedAt time.Time `json:"changed_at" db:"changed_at"`
 }
 
 type ItemAnnotation struct {
@@ -202,6 +392,148 @@ type ItemAnnotation struct {
 	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// ==================== 预算相关 ====================
+
+type BudgetItem struct {
+	ID              string       `json:"id" db:"id"`
+	PlanID          string       `json:"plan_id" db:"plan_id"`
+	ItemID          *string      `json:"item_id,omitempty" db:"item_id"`
+	Category        string       `json:"category" db:"category"`
+	Description     string       `json:"description" db:"description"`
+	EstimatedAmount *float64     `json:"estimated_amount,omitempty" db:"estimated_amount"`
+	ActualAmount    *float64     `json:"actual_amount,omitempty" db:"actual_amount"`
+	Currency        string       `json:"currency" db:"currency"`
+	PaymentMethod   *string      `json:"payment_method,omitempty" db:"payment_method"`
+	PaymentStatus   string       `json:"payment_status" db:"payment_status"`
+	PaymentDate     *time.Time   `json:"payment_date,omitempty" db:"payment_date"`
+	Notes           *string      `json:"notes,omitempty" db:"notes"`
+	ReceiptURL      *string      `json:"receipt_url,omitempty" db:"receipt_url"`
+	PaidBy          *string      `json:"paid_by,omitempty" db:"paid_by"`
+	SplitType       string       `json:"split_type" db:"split_type"`
+	SplitDetails    SplitDetails `json:"split_details,omitempty" db:"split_details"`
+	CreatedAt       time.Time    `json:"created_at" db:"created_at"`
+}
+
+const (
+	PaymentStatusPending  = "pending"
+	PaymentStatusPartial  = "partial"
+	PaymentStatusPaid     = "paid"
+	PaymentStatusRefunded = "refunded"
+)
+
+// 分摊规则：equal 平均分摊，shares 按份数分摊，exact 按指定金额分摊
+const (
+	SplitTypeEqual  = "equal"
+	SplitTypeShares = "shares"
+	SplitTypeExact  = "exact"
+)
+
+// SplitDetails 参与者ID到份数或金额的映射；平均分摊时仅键有效，为空表示全部参与者
+type SplitDetails map[string]float64
+
+type PlanParticipant struct {
+	ID        string    `json:"id" db:"id"`
+	PlanID    string    `json:"plan_id" db:"plan_id"`
+	Name      string    `json:"name" db:"name" binding:"required,max=100"`
+	UserID    *string   `json:"user_id,omitempty" db:"user_id"`
+	Email     *string   `json:"email,omitempty" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type ParticipantBalance struct {
+	ParticipantID string  `json:"participant_id"`
+	Name          string  `json:"name"`
+	Paid          float64 `json:"paid"`
+	Owed          float64 `json:"owed"`
+	Net           float64 `json:"net"`
+}
+
+type SettlementTransfer struct {
+	FromParticipantID string  `json:"from_participant_id"`
+	FromName          string  `json:"from_name"`
+	ToParticipantID   string  `json:"to_participant_id"`
+	ToName            string  `json:"to_name"`
+	Amount            float64 `json:"amount"`
+}
+
+type SettleUpResult struct {
+	PlanID       string               `json:"plan_id"`
+	BaseCurrency string               `json:"base_currency"`
+	Balances     []ParticipantBalance `json:"balances"`
+	Transfers    []SettlementTransfer `json:"transfers"`
+	Skipped      []string             `json:"skipped,omitempty"`
+	MissingRates []string             `json:"missing_rates,omitempty"`
+}
+
+type CreateBudgetItemRequest struct {
+	ItemID          *string      `json:"item_id"`
+	Category        string       `json:"category" binding:"required"`
+	Description     string       `json:"description" binding:"required"`
+	EstimatedAmount *float64     `json:"estimated_amount"`
+	ActualAmount    *float64     `json:"actual_amount"`
+	Currency        *string      `json:"currency"`
+	PaymentMethod   *string      `json:"payment_method"`
+	PaymentStatus   *string      `json:"payment_status"`
+	PaymentDate     *time.Time   `json:"payment_date"`
+	Notes           *string      `json:"notes"`
+	ReceiptURL      *string      `json:"receipt_url"`
+	PaidBy          *string      `json:"paid_by"`
+	SplitType       *string      `json:"split_type"`
+	SplitDetails    SplitDetails `json:"split_details"`
+}
+
+// ExchangeRate 汇率：1 单位 FromCurrency = Rate 单位 ToCurrency
+type ExchangeRate struct {
+	ID           string    `json:"id" db:"id"`
+	FromCurrency string    `json:"from_currency" db:"from_currency" binding:"required,len=3"`
+	ToCurrency   string    `json:"to_currency" db:"to_currency" binding:"required,len=3"`
+	Rate         float64   `json:"rate" db:"rate" binding:"required,gt=0"`
+	RateDate     string    `json:"rate_date" db:"rate_date" binding:"required"` // YYYY-MM-DD
+	Source       *string   `json:"source,omitempty" db:"source"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+}
+
+// AppliedRate 换算时实际使用的汇率
+type AppliedRate struct {
+	BudgetItemID string  `json:"budget_item_id,omitempty"`
+	FromCurrency string  `json:"from_currency"`
+	ToCurrency   string  `json:"to_currency"`
+	Rate         float64 `json:"rate"`
+	RateDate     string  `json:"rate_date"`
+	Inverse      bool    `json:"inverse,omitempty"`
+}
+
+type BudgetBreakdown struct {
+	Key       string  `json:"key"`
+	Count     int     `json:"count"`
+	Estimated float64 `json:"estimated"`
+	Actual    float64 `json:"actual"`
+}
+
+type UnbudgetedItem struct {
+	ItemID   string   `json:"item_id"`
+	Name     string   `json:"name"`
+	ItemType ItemType `json:"item_type"`
+	Cost     float64  `json:"cost"`
+}
+
+type BudgetSummary struct {
+	PlanID             string            `json:"plan_id"`
+	BaseCurrency       string            `json:"base_currency"`
+	TotalBudget        float64           `json:"total_budget"`
+	TotalEstimated     float64           `json:"total_estimated"`
+	TotalActual        float64           `json:"total_actual"`
+	Remaining          float64           `json:"remaining"`
+	EstimatedRemaining float64           `json:"estimated_remaining"`
+	OverBudget         bool              `json:"over_budget"`
+	ByCategory         []BudgetBreakdown `json:"by_category"`
+	ByPaymentStatus    []BudgetBreakdown `json:"by_payment_status"`
+	UnbudgetedItems    []UnbudgetedItem  `json:"unbudgeted_items"`
+	UnbudgetedTotal    float64           `json:"unbudgeted_total"`
+	AppliedRates       []AppliedRate     `json:"applied_rates"`
+	MissingRates       []string          `json:"missing_rates,omitempty"`
+}
+
 // ==================== 请求模型 ====================
 
 type CreateTravelItemRequest struct {
@@ -211,8 +543,10 @@ type CreateTravelItemRequest struct {
 	Latitude             *float64              `json:"latitude"`
 	Longitude            *float64              `json:"longitude"`
 	Address              *string               `json:"address"`
+	Altitude             *int                  `json:"altitude"`
 	StartDatetime        *time.Time            `json:"start_datetime"`
 	EndDatetime          *time.Time            `json:"end_datetime"`
+	TimeZone             *string               `json:"time_zone"`
 	DurationHours        *float64              `json:"duration_hours"`
 	Cost                 *float64              `json:"cost"`
 	Priority             *int                  `json:"priority"`
@@ -229,8 +563,10 @@ type UpdateTravelItemRequest struct {
 	Latitude             *float64              `json:"latitude"`
 	Longitude            *float64              `json:"longitude"`
 	Address              *string               `json:"address"`
+	Altitude             *int                  `json:"altitude"`
 	StartDatetime        *time.Time            `json:"start_datetime"`
 	EndDatetime          *time.Time            `json:"end_datetime"`
+	TimeZone             *string               `json:"time_zone"`
 	DurationHours        *float64              `json:"duration_hours"`
 	Cost                 *float64              `json:"cost"`
 	Priority             *int                  `json:"priority"`
@@ -241,8 +577,92 @@ type UpdateTravelItemRequest struct {
 	AttractionDetails    *AttractionDetails    `json:"attraction_details,omitempty"`
 }
 
+// 批量操作模式
+const (
+	// BatchModeAtomic 全部成功才提交，任一失败则全部回滚
+	BatchModeAtomic = "atomic"
+	// BatchModePartial 逐项提交，失败的项目不影响其他项目
+	BatchModePartial = "partial"
+)
+
+type BatchCreateItem struct {
+	PlanID string `json:"plan_id"`
+	CreateTravelItemRequest
+}
+
+type BatchCreateItemsRequest struct {
+	Mode string `json:"mode"`
+	// PlanID 项目未指定 plan_id 时使用的默认计划
+	PlanID string            `json:"plan_id"`
+	Items  []BatchCreateItem `json:"items" binding:"required,min=1"`
+}
+
+type BatchUpdateItem struct {
+	ID string `json:"id"`
+	UpdateTravelItemRequest
+}
+
+type BatchUpdateItemsRequest struct {
+	Mode  string            `json:"mode"`
+	Items []BatchUpdateItem `json:"items" binding:"required,min=1"`
+}
+
+type BatchDeleteItemsRequest struct {
+	Mode string   `json:"mode"`
+	IDs  []string `json:"ids" binding:"required,min=1"`
+}
+
+type UpdateItemStatusRequest struct {
+	Status        *string `json:"status"`
+	BookingStatus *string `json:"booking_status"`
+	Note          *string `json:"note"`
+}
+
+// ReorderItemsRequest 提供 item_ids 完整顺序，或 item_id 加 before_id/after_id 其中之一
+type ReorderItemsRequest struct {
+	ItemIDs  []string `json:"item_ids"`
+	ItemID   string   `json:"item_id"`
+	BeforeID string   `json:"before_id"`
+	AfterID  string   `json:"after_id"`
+}
+
 // ==================== 响应模型 ====================
 
+// ItemStatusState 元素当前状态及可转换到的状态
+type ItemStatusState struct {
+	ItemID                 string   `json:"item_id"`
+	Status                 string   `json:"status"`
+	BookingStatus          *string  `json:"booking_status"`
+	AllowedStatuses        []string `json:"allowed_statuses"`
+	AllowedBookingStatuses []string `json:"allowed_booking_statuses"`
+}
+
+type ItemOrder struct {
+	ID         string `json:"id"`
+	OrderIndex *int   `json:"order_index"`
+}
+
+type ReorderResult struct {
+	Items     []ItemOrder `json:"items"`
+	Compacted bool        `json:"compacted"`
+}
+
+type BatchItemResult struct {
+	Index   int    `json:"index"`
+	ID      string `json:"id,omitempty"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BatchResult struct {
+	Mode      string            `json:"mode"`
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Committed bool              `json:"committed"`
+	Results   []BatchItemResult `json:"results"`
+}
+
 type TravelItemListResponse struct {
 	Items    []TravelItem `json:"items"`
 	Total    int          `json:"total"`
@@ -253,23 +673,297 @@ type TravelItemListResponse struct {
 
 type DailyItinerary struct {
 	Date      string       `json:"date"`
+	TimeZone  string       `json:"time_zone"`
 	Items     []TravelItem `json:"items"`
 	TotalCost float64      `json:"total_cost"`
 	StartTime *time.Time   `json:"start_time"`
 	EndTime   *time.Time   `json:"end_time"`
 }
 
+// 时间线事件类型
+const (
+	TimelineEventItem      = "item"
+	TimelineEventDeparture = "transport_departure"
+	TimelineEventArrival   = "transport_arrival"
+	TimelineEventCheckIn   = "check_in"
+	TimelineEventCheckOut  = "check_out"
+	TimelineEventSunrise   = "sunrise"
+	TimelineEventSunset    = "sunset"
+	TimelineEventGap       = "gap"
+	TimelineEventOverlap   = "overlap"
+)
+
+// TimelineEvent 时间线中的一个事件。ID 由元素ID和事件类型派生，多次请求保持不变
+type TimelineEvent struct {
+	ID              string     `json:"id"`
+	Type            string     `json:"type"`
+	ItemID          *string    `json:"item_id,omitempty"`
+	ItemType        ItemType   `json:"item_type,omitempty"`
+	RelatedItemIDs  []string   `json:"related_item_ids,omitempty"`
+	Name            string     `json:"name"`
+	Location        *string    `json:"location,omitempty"`
+	Date            string     `json:"date"`
+	Time            time.Time  `json:"time"`
+	EndTime         *time.Time `json:"end_time,omitempty"`
+	TimeZone        string     `json:"time_zone,omitempty"`
+	DurationMinutes *int       `json:"duration_minutes,omitempty"`
+	Status          string     `json:"status,omitempty"`
+	Cost            *float64   `json:"cost,omitempty"`
+	RunningCost     float64    `json:"running_cost"`
+}
+
+type Timeline struct {
+	PlanID       string          `json:"plan_id"`
+	TimeZone     string          `json:"time_zone"`
+	Events       []TimelineEvent `json:"events"`
+	TotalCost    float64         `json:"total_cost"`
+	GapCount     int             `json:"gap_count"`
+	OverlapCount int             `json:"overlap_count"`
+}
+
+// 行程校验问题的严重程度
+const (
+	IssueSeverityError   = "error"
+	IssueSeverityWarning = "warning"
+)
+
+// 行程校验问题类型
+const (
+	IssueInvalidTimeRange     = "invalid_time_range"
+	IssueTimeOverlap          = "time_overlap"
+	IssueInsufficientTravel   = "insufficient_travel_time"
+	IssueCheckoutAfterCheckin = "checkout_after_checkin"
+	IssueOutsideOpeningHours  = "outside_opening_hours"
+	IssueMissingAccommodation = "missing_accommodation"
+	IssueOutsideGoodLight     = "outside_good_light"
+)
+
+type ScheduleIssue struct {
+	Code     string   `json:"code"`
+	Severity string   `json:"severity"`
+	Message  string   `json:"message"`
+	ItemIDs  []string `json:"item_ids,omitempty"`
+	Date     string   `json:"date,omitempty"`
+}
+
+type ScheduleReport struct {
+	PlanID       string          `json:"plan_id"`
+	Valid        bool            `json:"valid"`
+	ErrorCount   int             `json:"error_count"`
+	WarningCount int             `json:"warning_count"`
+	Issues       []ScheduleIssue `json:"issues"`
+}
+
+type OptimizeItineraryRequest struct {
+	Date  string `json:"date"` // YYYY-MM-DD，为空时优化整个计划
+	Apply bool   `json:"apply"`
+}
+
+// RouteMetrics 路线的总距离和按地面交通估算的行驶时间
+type RouteMetrics struct {
+	DistanceKm    float64 `json:"distance_km"`
+	TravelMinutes int     `json:"travel_minutes"`
+}
+
+type OptimizedItem struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	ItemType         ItemType   `json:"item_type"`
+	Priority         int        `json:"priority"`
+	Fixed            bool       `json:"fixed"`
+	FixedReason      string     `json:"fixed_reason,omitempty"`
+	PreviousPosition int        `json:"previous_position"`
+	Position         int        `json:"position"`
+	StartDatetime    *time.Time `json:"start_datetime,omitempty"`
+	EndDatetime      *time.Time `json:"end_datetime,omitempty"`
+	LegDistanceKm    float64    `json:"leg_distance_km"`
+}
+
+type OptimizeResult struct {
+	PlanID  string          `json:"plan_id"`
+	Date    string          `json:"date,omitempty"`
+	Applied bool            `json:"applied"`
+	Changed bool            `json:"changed"`
+	Retimed bool            `json:"retimed"`
+	Before  RouteMetrics    `json:"before"`
+	After   RouteMetrics    `json:"after"`
+	SavedKm float64         `json:"saved_km"`
+	Items   []OptimizedItem `json:"items"`
+	// Conflicts 调整时间后与其他元素重叠的问题，存在时不会写入
+	Conflicts []ScheduleIssue `json:"conflicts"`
+}
+
+type RebalanceRequest struct {
+	DailyHours *float64 `json:"daily_hours"` // 每天活动小时数上限，默认取配置
+	DayStart   string   `json:"day_start"`   // HH:MM，默认 08:00
+	DayEnd     string   `json:"day_end"`     // HH:MM，默认 22:00
+	DryRun     bool     `json:"dry_run"`
+}
+
+type RebalanceDay struct {
+	Date        string  `json:"date"`
+	BeforeHours float64 `json:"before_hours"`
+	AfterHours  float64 `json:"after_hours"`
+	ItemCount   int     `json:"item_count"`
+}
+
+// ItemMove 重新分配中被移动的元素，FromDate 为空表示原来未安排日期
+type ItemMove struct {
+	ItemID    string     `json:"item_id"`
+	Name      string     `json:"name"`
+	GroupID   *string    `json:"group_id,omitempty"`
+	FromDate  *string    `json:"from_date"`
+	FromStart *time.Time `json:"from_start"`
+	ToDate    string     `json:"to_date"`
+	ToStart   time.Time  `json:"to_start"`
+	ToEnd     time.Time  `json:"to_end"`
+}
+
+type UnplacedItem struct {
+	ItemIDs []string `json:"item_ids"`
+	Reason  string   `json:"reason"`
+}
+
+type RebalanceResult struct {
+	PlanID     string         `json:"plan_id"`
+	DryRun     bool           `json:"dry_run"`
+	DailyHours float64        `json:"daily_hours"`
+	Days       []RebalanceDay `json:"days"`
+	Moves      []ItemMove     `json:"moves"`
+	Unplaced   []UnplacedItem `json:"unplaced"`
+}
+
+// 高海拔适应问题代码
+const (
+	IssueExcessiveAscent      = "excessive_ascent"
+	IssueRestDayNeeded        = "rest_day_needed"
+	IssueUnknownSleepAltitude = "unknown_sleeping_altitude"
+)
+
+// AltitudeNight 某一晚的睡眠海拔，Ascent 为相对上一个已知睡眠海拔的上升高度
+type AltitudeNight struct {
+	Date     string  `json:"date"`
+	Altitude *int    `json:"altitude"`
+	ItemID   *string `json:"item_id,omitempty"`
+	ItemName string  `json:"item_name,omitempty"`
+	Ascent   *int    `json:"ascent,omitempty"`
+}
+
+// AltitudeDay 某一天到达的最高海拔
+type AltitudeDay struct {
+	Date             string  `json:"date"`
+	MaxAltitude      *int    `json:"max_altitude"`
+	ItemID           *string `json:"item_id,omitempty"`
+	ItemName         string  `json:"item_name,omitempty"`
+	SleepingAltitude *int    `json:"sleeping_altitude"`
+}
+
+// RestDaySuggestion 建议在某一晚之后增加的休整天数（在同一海拔多住）
+type RestDaySuggestion struct {
+	Date     string `json:"date"`
+	Altitude int    `json:"altitude"`
+	Nights   int    `json:"nights"`
+	Reason   string `json:"reason"`
+}
+
+type AltitudeReport struct {
+	PlanID          string              `json:"plan_id"`
+	ThresholdM      int                 `json:"threshold_m"`
+	MaxAscentM      int                 `json:"max_ascent_m"`
+	RestDayAscentM  int                 `json:"rest_day_ascent_m"`
+	HighestAltitude *int                `json:"highest_altitude"`
+	HighestSleeping *int                `json:"highest_sleeping"`
+	Nights          []AltitudeNight     `json:"nights"`
+	Days            []AltitudeDay       `json:"days"`
+	Issues          []ScheduleIssue     `json:"issues"`
+	RestDays        []RestDaySuggestion `json:"rest_days"`
+}
+
+type AccommodationItem struct {
+	TravelItem
+	Nights     int     `json:"nights"`
+	TotalPrice float64 `json:"total_price"`
+}
+
+type TransportLeg struct {
+	TravelItem
+	PreviousLegID *string  `json:"previous_leg_id,omitempty"`
+	ChainBroken   bool     `json:"chain_broken"`
+	Warnings      []string `json:"warnings,omitempty"`
+}
+
+type AttractionItem struct {
+	TravelItem
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// LightWindow 一段光线时段，例如黄金时刻或蓝调时刻
+type LightWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SunTimes 某地某天的日出日落和摄影光线时段，时间为当地时间；极昼或极夜时相应时刻为空
+type SunTimes struct {
+	Date              string       `json:"date"`
+	TimeZone          string       `json:"time_zone"`
+	Latitude          float64      `json:"latitude"`
+	Longitude         float64      `json:"longitude"`
+	CivilDawn         *time.Time   `json:"civil_dawn"`
+	Sunrise           *time.Time   `json:"sunrise"`
+	SolarNoon         time.Time    `json:"solar_noon"`
+	Sunset            *time.Time   `json:"sunset"`
+	CivilDusk         *time.Time   `json:"civil_dusk"`
+	MorningBlueHour   *LightWindow `json:"morning_blue_hour"`
+	MorningGoldenHour *LightWindow `json:"morning_golden_hour"`
+	EveningGoldenHour *LightWindow `json:"evening_golden_hour"`
+	EveningBlueHour   *LightWindow `json:"evening_blue_hour"`
+	PolarDay          bool         `json:"polar_day,omitempty"`
+	PolarNight        bool         `json:"polar_night,omitempty"`
+}
+
 type PlanSummary struct {
-	PlanID            string    `json:"plan_id"`
-	TotalItems        int       `json:"total_items"`
-	TotalCost         float64   `json:"total_cost"`
-	EstimatedCost     float64   `json:"estimated_cost"`
-	AccommodationDays int       `json:"accommodation_days"`
-	TransportCount    int       `json:"transport_count"`
-	AttractionCount   int       `json:"attraction_count"`
-	StartDate         time.Time `json:"start_date"`
-	EndDate           time.Time `json:"end_date"`
-	Duration          int       `json:"duration_days"`
+	PlanID            string        `json:"plan_id"`
+	BaseCurrency      string        `json:"base_currency"`
+	TotalItems        int           `json:"total_items"`
+	TotalCost         float64       `json:"total_cost"`
+	EstimatedCost     float64       `json:"estimated_cost"`
+	ActualCost        float64       `json:"actual_cost"`
+	AccommodationDays int           `json:"accommodation_days"`
+	TransportCount    int           `json:"transport_count"`
+	AttractionCount   int           `json:"attraction_count"`
+	StartDate         time.Time     `json:"start_date"`
+	EndDate           time.Time     `json:"end_date"`
+	Duration          int           `json:"duration_days"`
+	AppliedRates      []AppliedRate `json:"applied_rates,omitempty"`
+	MissingRates      []string      `json:"missing_rates,omitempty"`
+}
+
+// ==================== 导入导出 ====================
+
+// PlanDocumentVersion 当前计划文档格式版本，格式不兼容变更时递增
+const PlanDocumentVersion = 1
+
+// PlanDocument 计划导出文档。文档内的 ID 只用于表示引用关系，导入时全部重新生成
+type PlanDocument struct {
+	Version      int                `json:"version"`
+	ExportedAt   time.Time          `json:"exported_at"`
+	Plan         Plan               `json:"plan"`
+	Participants []PlanParticipant  `json:"participants"`
+	Items        []PlanDocumentItem `json:"items"`
+	Relations    []ItemRelation     `json:"relations"`
+	Annotations  []ItemAnnotation   `json:"annotations"`
+	BudgetItems  []BudgetItem       `json:"budget_items"`
+	// Attachments 只包含附件元数据，文件本身不随文档导出
+	Attachments []ItemAttachment `json:"attachments"`
+}
+
+// PlanDocumentItem 元素及其类型详情，按元素类型最多填写一种详情
+type PlanDocumentItem struct {
+	TravelItem
+	AccommodationDetails *AccommodationDetails `json:"accommodation_details,omitempty"`
+	TransportDetails     *TransportDetails     `json:"transport_details,omitempty"`
+	AttractionDetails    *AttractionDetails    `json:"attraction_details,omitempty"`
 }
 
 // ==================== 辅助类型 ====================
@@ -295,3 +989,59 @@ func (j *JSONB) Scan(value interface{}) error {
 	}
 	return json.Unmarshal(bytes, j)
 }
+
+// JSONArray 处理JSON数组数据库字段
+type JSONArray []interface{}
+
+func (j JSONArray) Value() (driver.Value, error) {
+	if j == nil {
+		return nil, nil
+	}
+	return json.Marshal(j)
+}
+
+func (j *JSONArray) Scan(value interface{}) error {
+	if value == nil {
+		*j = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into JSONArray", value)
+	}
+	return json.Unmarshal(bytes, j)
+}
+
+func (h OpeningHours) Value() (driver.Value, error) {
+	return json.Marshal(h)
+}
+
+func (h *OpeningHours) Scan(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into OpeningHours", value)
+	}
+	return json.Unmarshal(bytes, h)
+}
+
+func (s SplitDetails) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+func (s *SplitDetails) Scan(value interface{}) error {
+	if value == nil {
+		*s = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into SplitDetails", value)
+	}
+	return json.Unmarshal(bytes, s)
+}
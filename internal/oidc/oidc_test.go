@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"planner/internal/oidc/oidctest"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedirectURL = "http://localhost:3000/api/v1/auth/oidc/mock/callback"
+
+func newTestProvider(t *testing.T) (*oidctest.Provider, *Provider) {
+	mock := oidctest.NewProvider("planner", "s3cret")
+	t.Cleanup(mock.Close)
+	return mock, NewProvider(Config{
+		Name:         "mock",
+		Issuer:       mock.Issuer(),
+		ClientID:     "planner",
+		ClientSecret: "s3cret",
+		RedirectURL:  testRedirectURL,
+	})
+}
+
+// authorize 访问授权地址，返回模拟提供方跳转回来的授权码
+func authorize(t *testing.T, p *Provider, state, nonce, challenge string) string {
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, challenge)
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, state, location.Query().Get("state"))
+	return location.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	mock, p := newTestProvider(t)
+	mock.SetUser(oidctest.User{Subject: "u-42", Email: "aming@example.com", EmailVerified: true, PreferredUsername: "aming"})
+	ctx := context.Background()
+
+	verifier, challenge, err := NewPKCE()
+	require.NoError(t, err)
+	code := authorize(t, p, "state-1", "nonce-1", challenge)
+
+	token, err := p.Exchange(ctx, code, verifier)
+	require.NoError(t, err)
+
+	claims, err := p.VerifyIDToken(ctx, token.IDToken, "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "u-42", claims.Subject)
+	assert.Equal(t, "aming@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+	assert.Equal(t, "aming", claims.PreferredUsername)
+
+	// 授权码只能使用一次
+	_, err = p.Exchange(ctx, code, verifier)
+	assert.Error(t, err)
+
+	// nonce 不匹配
+	_, err = p.VerifyIDToken(ctx, token.IDToken, "other-nonce")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	_, p := newTestProvider(t)
+
+	_, challenge, err := NewPKCE()
+	require.NoError(t, err)
+	code := authorize(t, p, "state", "nonce", challenge)
+
+	otherVerifier, _, _ := NewPKCE()
+	_, err = p.Exchange(context.Background(), code, otherVerifier)
+	assert.ErrorContains(t, err, "PKCE")
+}
+
+func TestVerifyIDTokenRejectsBadClaims(t *testing.T) {
+	mock, p := newTestProvider(t)
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": mock.Issuer(), "aud": "planner", "sub": "u-1", "nonce": "n",
+			"iat": now.Unix(), "exp": now.Add(5 * time.Minute).Unix(),
+		}
+	}
+
+	_, err := p.VerifyIDToken(context.Background(), mock.SignIDToken(valid()), "n")
+	require.NoError(t, err)
+
+	cases := map[string]func(jwt.MapClaims){
+		"受众不符":  func(c jwt.MapClaims) { c["aud"] = "someone-else" },
+		"签发方不符": func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" },
+		"已过期":   func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"缺少sub": func(c jwt.MapClaims) { delete(c, "sub") },
+	}
+	for name, mutate := range cases {
+		claims := valid()
+		mutate(claims)
+		_, err := p.VerifyIDToken(context.Background(), mock.SignIDToken(claims), "n")
+		assert.ErrorIs(t, err, ErrInvalidIDToken, name)
+	}
+
+	// 对称算法签名的令牌不接受
+	hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, valid()).SignedString([]byte("s3cret"))
+	_, err = p.VerifyIDToken(context.Background(), hs, "n")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestVerifyIDTokenAfterKeyRotation(t *testing.T) {
+	mock, p := newTestProvider(t)
+	interval := jwksRefreshInterval
+	jwksRefreshInterval = 0
+	defer func() { jwksRefreshInterval = interval }()
+
+	claims := jwt.MapClaims{
+		"iss": mock.Issuer(), "aud": "planner", "sub": "u-1", "nonce": "n",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+	}
+	_, err := p.VerifyIDToken(context.Background(), mock.SignIDToken(claims), "n")
+	require.NoError(t, err)
+
+	mock.RotateKey()
+	_, err = p.VerifyIDToken(context.Background(), mock.SignIDToken(claims), "n")
+	assert.NoError(t, err)
+}
+
+func TestS256Challenge(t *testing.T) {
+	// RFC 7636 附录B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+		S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
+
+func TestJWKSECKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	enc := base64.RawURLEncoding.EncodeToString
+
+	set := jwkSet{Keys: []jwk{
+		{Kid: "ec", Kty: "EC", Crv: "P-256", X: enc(key.X.Bytes()), Y: enc(key.Y.Bytes())},
+		{Kid: "enc", Kty: "RSA", Use: "enc"},
+		{Kid: "oct", Kty: "oct"},
+	}}
+	keys, err := set.publicKeys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.True(t, key.PublicKey.Equal(keys["ec"]))
+
+	set.Keys[0].Y = enc([]byte{1, 2, 3})
+	_, err = set.publicKeys()
+	assert.Error(t, err)
+
+	_, err = jwkSet{}.publicKeys()
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package oidc
+
+import (
+	"fmt"
+	"log"
+	"sort"
+)
+
+var providers = map[string]*Provider{}
+
+// Init 根据配置注册身份提供方
+func Init(cfgs []Config) error {
+	registered := make(map[string]*Provider, len(cfgs))
+	for _, cfg := range cfgs {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return fmt.Errorf("身份提供方 %q 缺少 issuer、client_id 或 redirect_url", cfg.Name)
+		}
+		if _, ok := registered[cfg.Name]; ok {
+			return fmt.Errorf("身份提供方 %q 重复配置", cfg.Name)
+		}
+		registered[cfg.Name] = NewProvider(cfg)
+	}
+	providers = registered
+
+	if len(providers) > 0 {
+		log.Printf("✅ 单点登录初始化成功 (%d 个身份提供方)", len(providers))
+	}
+	return nil
+}
+
+// Get 按标识获取身份提供方
+func Get(name string) (*Provider, bool) {
+	p, ok := providers[name]
+	return p, ok
+}
+
+// List 按标识排序返回全部身份提供方
+func List() []*Provider {
+	list := make([]*Provider, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Set 替换已注册的身份提供方（用于测试）
+func Set(list ...*Provider) {
+	providers = make(map[string]*Provider, len(list))
+	for _, p := range list {
+		providers[p.Name()] = p
+	}
+}
@@ -0,0 +1,252 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// A4 纸张尺寸（单位：点）
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document 只包含文字、线条和矩形的简单PDF文档。
+// 坐标原点在页面左上角，y 向下增长；文字的 y 为基线位置
+type Document struct {
+	title     string
+	createdAt time.Time
+	pages     []*bytes.Buffer
+	page      *bytes.Buffer
+
+	fontSize float64
+	bold     bool
+}
+
+// New 创建空文档
+func New() *Document {
+	return &Document{createdAt: time.Now(), fontSize: 10}
+}
+
+// SetTitle 设置文档标题（显示在阅读器标题栏）
+func (d *Document) SetTitle(title string) {
+	d.title = title
+}
+
+// AddPage 新增一页并设为当前页
+func (d *Document) AddPage() {
+	d.page = &bytes.Buffer{}
+	d.pages = append(d.pages, d.page)
+}
+
+// PageCount 当前页数
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+// SetFont 设置后续文字的字号和是否加粗
+func (d *Document) SetFont(size float64, bold bool) {
+	d.fontSize = size
+	d.bold = bold
+}
+
+// FontSize 当前字号
+func (d *Document) FontSize() float64 {
+	return d.fontSize
+}
+
+// Text 在当前页写一行文字，颜色为 0-1 的灰度
+func (d *Document) Text(x, y float64, s string, gray float64) {
+	if d.page == nil {
+		d.AddPage()
+	}
+	mode := 0
+	if d.bold {
+		// 中文字体没有粗体字形，用描边模拟加粗
+		mode = 2
+	}
+	fmt.Fprintf(d.page, "BT %.3f g %.3f G %d Tr %.3f w /F1 %.2f Tf %.2f %.2f Td <%s> Tj ET\n",
+		gray, gray, mode, d.fontSize/30, d.fontSize, x, PageHeight-y, encodeText(s))
+}
+
+// Line 画一条线，width 为线宽
+func (d *Document) Line(x1, y1, x2, y2, width, gray float64) {
+	if d.page == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.page, "%.3f G %.2f w %.2f %.2f m %.2f %.2f l S\n",
+		gray, width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// FillRect 填充矩形，(x, y) 为左上角
+func (d *Document) FillRect(x, y, w, h, gray float64) {
+	if d.page == nil {
+		d.AddPage()
+	}
+	fmt.Fprintf(d.page, "%.3f g %.2f %.2f %.2f %.2f re f\n", gray, x, PageHeight-y-h, w, h)
+}
+
+// Width 按当前字号计算文字宽度
+func (d *Document) Width(s string) float64 {
+	return textWidth(s, d.fontSize)
+}
+
+// WrapText 按当前字号将文字折成不超过 width 的多行。
+// 中文逐字折行，英文和数字尽量在空格处折行
+func (d *Document) WrapText(s string, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		lines = append(lines, wrapLine(paragraph, width, d.fontSize)...)
+	}
+	return lines
+}
+
+func wrapLine(s string, width, size float64) []string {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return []string{""}
+	}
+
+	var lines []string
+	start, lastSpace := 0, -1
+	w := 0.0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == ' ' {
+			lastSpace = i
+		}
+		w += runeWidth(r) * size / 1000
+		if w <= width || i == start {
+			continue
+		}
+		end := i
+		if lastSpace > start && r < 0x80 && runes[i-1] < 0x80 {
+			end = lastSpace + 1
+		}
+		lines = append(lines, strings.TrimRight(string(runes[start:end]), " "))
+		start, lastSpace = end, -1
+		w = 0
+		i = end - 1
+	}
+	return append(lines, string(runes[start:]))
+}
+
+// Bytes 生成PDF文件内容
+func (d *Document) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := d.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteTo 将PDF写入 w
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// 对象编号：1 目录，2 页面树，3-5 字体，6 文档信息，之后每页占用页面和内容两个对象
+	const firstPage = 7
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // 页面树在知道页面对象编号后填写
+		"<< /Type /Font /Subtype /Type0 /BaseFont /" + cjkFont + "-" + cjkEncoding +
+			" /Encoding /" + cjkEncoding + " /DescendantFonts [4 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /" + cjkFont +
+			" /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >>" +
+			" /FontDescriptor 5 0 R /DW 1000 /W [1 [" + asciiWidthArray() + "]] >>",
+		"<< /Type /FontDescriptor /FontName /" + cjkFont +
+			" /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+		fmt.Sprintf("<< /Title <%s> /Producer (planner) /CreationDate (D:%s) >>",
+			encodeTextWithBOM(d.title), d.createdAt.UTC().Format("20060102150405Z")),
+	}
+
+	kids := make([]string, len(d.pages))
+	for i, page := range d.pages {
+		pageObj := firstPage + 2*i
+		kids[i] = fmt.Sprintf("%d 0 R", pageObj)
+
+		content, err := deflate(page.Bytes())
+		if err != nil {
+			return 0, err
+		}
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				PageWidth, PageHeight, pageObj+1),
+			fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+
+	cw := &countingWriter{w: w}
+	fmt.Fprint(cw, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int64, len(objects))
+	for i, obj := range objects {
+		offsets[i] = cw.n
+		fmt.Fprintf(cw, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := cw.n
+	fmt.Fprintf(cw, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(cw, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(cw, "trailer\n<< /Size %d /Root 1 0 R /Info 6 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return cw.n, cw.err
+}
+
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeText 将文字编码为 UCS-2 大端序的十六进制串，超出基本平面的字符替换为问号
+func encodeText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xFFFF || utf16.IsSurrogate(r) {
+			r = '?'
+		}
+		fmt.Fprintf(&b, "%04X", r)
+	}
+	return b.String()
+}
+
+// encodeTextWithBOM 文档信息中的文本字符串使用带字节序标记的 UTF-16
+func encodeTextWithBOM(s string) string {
+	var b strings.Builder
+	b.WriteString("FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	return b.String()
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
@@ -0,0 +1,72 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeText(t *testing.T) {
+	assert.Equal(t, "7A3B4E2D0020004100310032", encodeText("稻中 A12"))
+	assert.Equal(t, "003F", encodeText("😀"))
+	assert.Equal(t, "FEFFD83DDE00", encodeTextWithBOM("😀"))
+}
+
+func TestWrapText(t *testing.T) {
+	d := New()
+	d.SetFont(10, false)
+
+	// 全角字符宽度等于字号
+	assert.Equal(t, []string{"稻城亚丁", "环线"}, d.WrapText("稻城亚丁环线", 40))
+	assert.Equal(t, []string{"booking", "number"}, d.WrapText("booking number", 40))
+	assert.Equal(t, []string{"第一行", "", "第三行"}, d.WrapText("第一行\n\n第三行", 100))
+	// 单个字符超宽时也要输出，避免死循环
+	assert.Equal(t, []string{"稻", "城"}, d.WrapText("稻城", 5))
+}
+
+func TestWriteToProducesValidXref(t *testing.T) {
+	d := New()
+	d.SetTitle("稻城亚丁")
+	d.AddPage()
+	d.SetFont(18, true)
+	d.Text(50, 80, "行程手册", 0)
+	d.Line(50, 90, 300, 90, 0.5, 0.6)
+	d.AddPage()
+	d.FillRect(50, 50, 100, 20, 0.9)
+
+	data, err := d.Bytes()
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-1.4")))
+	assert.True(t, bytes.HasSuffix(data, []byte("%%EOF\n")))
+	assert.Contains(t, string(data), "/Count 2")
+	assert.Contains(t, string(data), "/BaseFont /STSong-Light")
+
+	// startxref 指向 xref 表，表中每个偏移都指向对应对象的开头
+	m := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(data)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(data[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n`).FindAllSubmatch(data[xref:], -1)
+	require.Len(t, entries, 10)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		assert.True(t, bytes.HasPrefix(data[offset:], []byte(strconv.Itoa(i+1)+" 0 obj")), "object %d", i+1)
+	}
+
+	// 第一页内容流解压后包含标题文字
+	stream := regexp.MustCompile(`(?s)stream\n(.*?)\nendstream`).FindSubmatch(data)
+	require.NotNil(t, stream)
+	zr, err := zlib.NewReader(bytes.NewReader(stream[1]))
+	require.NoError(t, err)
+	content, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "<"+encodeText("行程手册")+"> Tj")
+	assert.Contains(t, string(content), "2 Tr")
+}
@@ -0,0 +1,47 @@
+package pdf
+
+import (
+	"strconv"
+	"strings"
+)
+
+// 使用 Adobe 亚洲字体包中的宋体，不嵌入字体文件，由阅读器提供字形；
+// UniGB-UCS2-H 编码可直接按 Unicode 码位输出中文和 ASCII 字符
+const (
+	cjkFont     = "STSong-Light"
+	cjkEncoding = "UniGB-UCS2-H"
+)
+
+// asciiWidths STSong-Light 中 ASCII 可见字符（0x20-0x7E，对应 CID 1-95）的宽度，
+// 千分之一字号；其他字符为全角宽度 1000
+var asciiWidths = [95]int{
+	207, 270, 342, 467, 462, 797, 710, 239, 374, 374, 423, 605, 238, 375, 238, 334,
+	462, 462, 462, 462, 462, 462, 462, 462, 462, 462, 238, 238, 605, 605, 605, 344,
+	748, 684, 560, 695, 739, 563, 511, 729, 793, 318, 312, 666, 526, 896, 758, 772,
+	544, 772, 628, 465, 607, 753, 711, 972, 647, 620, 607, 374, 333, 374, 606, 500,
+	239, 417, 503, 427, 529, 415, 264, 444, 518, 241, 230, 495, 228, 793, 527, 524,
+	524, 504, 338, 336, 277, 517, 450, 652, 466, 452, 407, 370, 258, 370, 605,
+}
+
+func runeWidth(r rune) float64 {
+	if r >= 0x20 && r <= 0x7E {
+		return float64(asciiWidths[r-0x20])
+	}
+	return 1000
+}
+
+func textWidth(s string, size float64) float64 {
+	total := 0.0
+	for _, r := range s {
+		total += runeWidth(r)
+	}
+	return total * size / 1000
+}
+
+func asciiWidthArray() string {
+	parts := make([]string, len(asciiWidths))
+	for i, w := range asciiWidths {
+		parts[i] = strconv.Itoa(w)
+	}
+	return strings.Join(parts, " ")
+}
@@ -21,10 +21,26 @@ func Setup(router *gin.Engine) {
 		{
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
+			auth.POST("/login/2fa", handlers.VerifyMFALogin)
 			auth.POST("/logout", middleware.Auth(), handlers.Logout)
 			auth.POST("/refresh", handlers.RefreshToken)
 			auth.GET("/profile", middleware.Auth(), handlers.GetProfile)
 			auth.PUT("/profile", middleware.Auth(), handlers.UpdateProfile)
+			auth.POST("/verify-email", handlers.VerifyEmail)
+			auth.POST("/verify-email/send", middleware.Auth(), handlers.SendVerificationEmail)
+			auth.POST("/password/forgot", handlers.ForgotPassword)
+			auth.POST("/password/reset", handlers.ResetPassword)
+			auth.POST("/2fa/setup", middleware.Auth(), handlers.SetupTOTP)
+			auth.POST("/2fa/enable", middleware.Auth(), handlers.EnableTOTP)
+			auth.POST("/2fa/disable", middleware.Auth(), handlers.DisableTOTP)
+			auth.POST("/2fa/recovery-codes", middleware.Auth(), handlers.RegenerateRecoveryCodes)
+			auth.GET("/sessions", middleware.Auth(), handlers.ListSessions)
+			auth.DELETE("/sessions", middleware.Auth(), handlers.RevokeOtherSessions)
+			auth.DELETE("/sessions/:sessionId", middleware.Auth(), handlers.RevokeSession)
+			auth.GET("/oidc/providers", handlers.ListOIDCProviders)
+			auth.GET("/oidc/:provider/login", handlers.StartOIDCLogin)
+			auth.GET("/oidc/:provider/callback", handlers.OIDCCallback)
+			auth.POST("/oidc/complete", handlers.CompleteOIDCLogin)
 		}
 
 		// 需要认证的路由
@@ -34,14 +50,17 @@ func Setup(router *gin.Engine) {
 			// 计划管理
 			plans := protected.Group("/plans")
 			{
-				plans.POST("", handlers.CreatePlan)
+				plans.POST("", middleware.VerifiedEmail(), handlers.CreatePlan)
 				plans.GET("", handlers.GetMyPlans)
 				plans.GET("/user/:userId", handlers.GetUserPlans)
 				plans.GET("/:planId", handlers.GetPlan)
 				plans.PUT("/:planId", handlers.UpdatePlan)
 				plans.DELETE("/:planId", handlers.DeletePlan)
-				plans.POST("/:planId/duplicate", handlers.DuplicatePlan)
+				plans.POST("/:planId/duplicate", middleware.VerifiedEmail(), handlers.DuplicatePlan)
 				plans.POST("/:planId/share", handlers.SharePlan)
+				plans.GET("/:planId/participants", handlers.GetParticipants)
+				plans.POST("/:planId/participants", handlers.AddParticipant)
+				plans.DELETE("/:planId/participants/:participantId", handlers.RemoveParticipant)
 			}
 
 			// 旅游元素管理
@@ -57,6 +76,8 @@ func Setup(router *gin.Engine) {
 				items.DELETE("/batch", handlers.BatchDeleteItems)
 				items.PUT("/plan/:planId/reorder", handlers.ReorderItems)
 				items.PATCH("/:itemId/status", handlers.UpdateItemStatus)
+				items.GET("/:itemId/status-history", handlers.GetItemStatusHistory)
+				items.GET("/:itemId/sun", handlers.GetItemSunTimes)
 			}
 
 			// 住宿管理
@@ -84,6 +105,7 @@ func Setup(router *gin.Engine) {
 				attractions.POST("/plan/:planId", handlers.CreateAttraction)
 				attractions.GET("/:itemId", handlers.GetAttractionDetails)
 				attractions.PUT("/:itemId", handlers.UpdateAttractionDetails)
+				attractions.DELETE("/:itemId", handlers.DeleteAttraction)
 			}
 
 			// 关联管理
@@ -108,7 +130,9 @@ func Setup(router *gin.Engine) {
 			{
 				attachments.POST("/item/:itemId/upload", handlers.UploadAttachment)
 				attachments.GET("/item/:itemId", handlers.GetAttachments)
+				attachments.PUT("/:attachmentId", handlers.UpdateAttachment)
 				attachments.DELETE("/:attachmentId", handlers.DeleteAttachment)
+				attachments.POST("/:attachmentId/apply-location", handlers.ApplyPhotoLocation)
 			}
 
 			// 预算管理
@@ -119,6 +143,7 @@ func Setup(router *gin.Engine) {
 				budget.GET("/plan/:planId/items", handlers.GetBudgetItems)
 				budget.PUT("/items/:itemId", handlers.UpdateBudgetItem)
 				budget.DELETE("/items/:itemId", handlers.DeleteBudgetItem)
+				budget.GET("/plan/:planId/settle-up", handlers.GetSettleUp)
 			}
 
 			// 行程视图
@@ -126,14 +151,20 @@ func Setup(router *gin.Engine) {
 			{
 				itinerary.GET("/plan/:planId/daily", handlers.GetDailyItinerary)
 				itinerary.GET("/plan/:planId/timeline", handlers.GetTimeline)
+				itinerary.GET("/plan/:planId/validate", handlers.ValidateItinerary)
 				itinerary.POST("/plan/:planId/optimize", handlers.OptimizeItinerary)
+				itinerary.POST("/plan/:planId/rebalance", handlers.RebalanceItinerary)
 			}
 
+			// 汇率
+			protected.GET("/exchange-rates", handlers.GetExchangeRates)
+
 			// 统计分析
 			analytics := protected.Group("/analytics")
 			{
 				analytics.GET("/plan/:planId/summary", handlers.GetPlanSummary)
 				analytics.GET("/plan/:planId/statistics", handlers.GetPlanStatistics)
+				analytics.GET("/plan/:planId/altitude", handlers.GetAltitudeProfile)
 			}
 
 			// 导入导出
@@ -141,7 +172,7 @@ func Setup(router *gin.Engine) {
 			{
 				io.GET("/plan/:planId/export/json", handlers.ExportPlanJSON)
 				io.GET("/plan/:planId/export/pdf", handlers.ExportPlanPDF)
-				io.POST("/plan/import/json", handlers.ImportPlanJSON)
+				io.POST("/plan/import/json", middleware.VerifiedEmail(), handlers.ImportPlanJSON)
 			}
 		}
 
@@ -152,9 +183,13 @@ func Setup(router *gin.Engine) {
 			admin.GET("/users", handlers.ListUsers)
 			admin.GET("/users/:userId", handlers.GetUserDetails)
 			admin.PUT("/users/:userId/status", handlers.UpdateUserStatus)
+			admin.POST("/users/:userId/revoke-sessions", handlers.RevokeUserSessions)
 			admin.GET("/plans", handlers.ListAllPlans)
 			admin.DELETE("/plans/:planId", handlers.AdminDeletePlan)
 			admin.GET("/statistics", handlers.GetSystemStatistics)
+			admin.GET("/settings/security", handlers.GetSecuritySettings)
+			admin.PUT("/settings/security", handlers.UpdateSecuritySettings)
+			admin.POST("/exchange-rates", handlers.SetExchangeRates)
 		}
 	}
 
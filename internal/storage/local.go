@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local 本地文件系统存储
+type Local struct {
+	root       string
+	publicPath string
+}
+
+// NewLocal 创建本地存储，root 为存储目录，publicPath 为对外访问的URL前缀
+func NewLocal(root, publicPath string) (*Local, error) {
+	if root == "" {
+		return nil, fmt.Errorf("未配置上传目录")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %v", err)
+	}
+	if publicPath == "" {
+		publicPath = "/uploads"
+	}
+	return &Local{root: root, publicPath: strings.TrimRight(publicPath, "/")}, nil
+}
+
+// Root 返回存储目录
+func (l *Local) Root() string {
+	return l.root
+}
+
+// PublicPath 返回对外访问的URL前缀
+func (l *Local) PublicPath() string {
+	return l.publicPath
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	full, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return err
+	}
+
+	// 先写临时文件再重命名，避免留下写了一半的文件
+	tmp, err := os.CreateTemp(filepath.Dir(full), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), full)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	full, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(full)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	full, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(key string) string {
+	return l.publicPath + "/" + strings.TrimLeft(path.Clean("/"+key), "/")
+}
+
+// path 将 key 映射到存储目录内的路径，拒绝越出目录的 key
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("无效的存储路径: %s", key)
+	}
+	return filepath.Join(l.root, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("对象不存在")
+
+// Storage 文件存储后端
+type Storage interface {
+	// Put 写入对象，key 为存储内的相对路径
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 返回对象的访问地址
+	URL(key string) string
+}
+
+// Options 存储配置
+type Options struct {
+	// Driver 存储驱动：local 或 s3
+	Driver string
+
+	// 本地存储
+	UploadPath string
+	PublicPath string
+
+	// S3兼容存储（AWS S3、MinIO等）
+	S3Endpoint     string
+	S3Region       string
+	S3Bucket       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3UsePathStyle bool
+	S3PublicURL    string
+}
+
+var current Storage
+
+// Init 根据配置初始化存储后端
+func Init(opts Options) error {
+	var err error
+
+	switch opts.Driver {
+	case "", "local":
+		current, err = NewLocal(opts.UploadPath, opts.PublicPath)
+	case "s3":
+		current, err = NewS3(S3Config{
+			Endpoint:     opts.S3Endpoint,
+			Region:       opts.S3Region,
+			Bucket:       opts.S3Bucket,
+			AccessKey:    opts.S3AccessKey,
+			SecretKey:    opts.S3SecretKey,
+			UsePathStyle: opts.S3UsePathStyle,
+			PublicURL:    opts.S3PublicURL,
+		})
+	default:
+		return fmt.Errorf("未知的存储驱动: %s", opts.Driver)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("✅ 文件存储初始化成功 (%s)", opts.Driver)
+	return nil
+}
+
+// Get 获取当前存储后端
+func Get() Storage {
+	return current
+}
+
+// Set 替换当前存储后端（用于测试）
+func Set(s Storage) {
+	current = s
+}
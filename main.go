@@ -8,11 +8,16 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // 内置时区数据库，不依赖运行环境的 tzdata
 
 	"planner/internal/config"
 	"planner/internal/database"
+	"planner/internal/handlers"
+	"planner/internal/mail"
 	"planner/internal/middleware"
+	"planner/internal/oidc"
 	"planner/internal/routes"
+	"planner/internal/storage"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -28,6 +33,59 @@ func main() {
 	}
 	defer database.Close()
 
+	// 导入汇率文件（可选）
+	if cfg.ExchangeRatesFile != "" {
+		if err := handlers.LoadExchangeRatesFile(cfg.ExchangeRatesFile); err != nil {
+			log.Printf("⚠️ 汇率文件导入失败: %v", err)
+		}
+	}
+
+	// 初始化文件存储
+	if err := storage.Init(storage.Options{
+		Driver:         cfg.StorageDriver,
+		UploadPath:     cfg.UploadPath,
+		PublicPath:     "/uploads",
+		S3Endpoint:     cfg.S3Endpoint,
+		S3Region:       cfg.S3Region,
+		S3Bucket:       cfg.S3Bucket,
+		S3AccessKey:    cfg.S3AccessKey,
+		S3SecretKey:    cfg.S3SecretKey,
+		S3UsePathStyle: cfg.S3UsePathStyle,
+		S3PublicURL:    cfg.S3PublicURL,
+	}); err != nil {
+		log.Fatal("文件存储初始化失败:", err)
+	}
+
+	// 初始化邮件发送
+	if err := mail.Init(mail.Options{
+		Driver:       cfg.MailDriver,
+		From:         cfg.SMTPFrom,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUser:     cfg.SMTPUser,
+		SMTPPassword: cfg.SMTPPassword,
+		FilePath:     cfg.MailFilePath,
+	}); err != nil {
+		log.Fatal("邮件发送初始化失败:", err)
+	}
+
+	// 初始化单点登录身份提供方
+	var providers []oidc.Config
+	for _, p := range cfg.OIDCProviders {
+		providers = append(providers, oidc.Config{
+			Name:         p.Name,
+			DisplayName:  p.DisplayName,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		})
+	}
+	if err := oidc.Init(providers); err != nil {
+		log.Fatal("单点登录初始化失败:", err)
+	}
+
 	// 初始化Redis（可选）
 	if err := database.InitRedis(cfg.RedisURL); err != nil {
 		log.Printf("⚠️ Redis连接失败，某些功能可能受限: %v", err)
@@ -52,6 +110,9 @@ func main() {
 	// 设置静态文件服务
 	router.Static("/static", "./static")
 	router.StaticFile("/", "./static/index.html")
+	if local, ok := storage.Get().(*storage.Local); ok {
+		router.Static(local.PublicPath(), local.Root())
+	}
 
 	// 创建HTTP服务器
 	srv := &http.Server{